@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/dlactin/rdv/internal/cluster"
+	"github.com/dlactin/rdv/internal/helm"
+)
+
+// capabilities are the Kubernetes version and APIs of the cluster Helm
+// charts are rendered for with --capabilities-from-cluster, Helm's
+// defaults are used when nil
+var capabilities *helm.Capabilities
+
+// loadCapabilities queries the discovery API of the cluster for the
+// capabilities Helm charts are rendered with when
+// --capabilities-from-cluster is set
+func loadCapabilities(client *cluster.Client) error {
+	capabilities = nil
+	if !capabilitiesFromClusterFlag {
+		return nil
+	}
+
+	caps, err := client.Capabilities()
+	if err != nil {
+		return err
+	}
+	if debugFlag {
+		log.Printf("Rendering Helm charts for Kubernetes %s with %d API versions of cluster '%s'", caps.KubeVersion, len(caps.APIVersions), client.Context)
+	}
+	capabilities = caps
+	return nil
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/helm"
+)
+
+// ciValuesGlob matches the values files of chart-testing, each rendered
+// as its own variant of a chart with --ci-values
+const ciValuesGlob = "ci/*-values.yaml"
+
+// expandCIValues replaces every Helm chart target with a target per
+// ci/*-values.yaml file in the local chart when --ci-values is set.
+// Charts without any are diffed with the default values.
+func expandCIValues(targets []*target) ([]*target, error) {
+	if !ciValuesFlag {
+		return targets, nil
+	}
+
+	var expanded []*target
+	for _, t := range targets {
+		path := filepath.Join(localRoot, t.relativePath)
+		if !helm.IsHelmChart(path) {
+			expanded = append(expanded, t)
+			continue
+		}
+
+		files, err := filepath.Glob(filepath.Join(path, ciValuesGlob))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the ci values files of %s: %w", t.name, err)
+		}
+		if len(files) == 0 {
+			log.Printf("No %s files found in %s, diffing the default values", ciValuesGlob, t.name)
+			expanded = append(expanded, t)
+			continue
+		}
+
+		for _, file := range files {
+			variant := *t
+			variant.ciValues = filepath.Join("ci", filepath.Base(file))
+			variant.name = filepath.Join(t.name, variant.ciValues)
+			expanded = append(expanded, &variant)
+		}
+	}
+
+	return expanded, nil
+}
+
+// renderOptions returns the render options of the target's chart or
+// kustomization at path, with its --ci-values file after the --values
+func (t *target) renderOptions(path string) diff.RenderOptions {
+	opts := renderOptions(path)
+	if t.ciValues == "" {
+		return opts
+	}
+
+	// A values file added by this change doesn't exist on the target
+	// side, the variant is compared against the default values then
+	file := filepath.Join(path, t.ciValues)
+	if _, err := os.Stat(file); err == nil {
+		opts.Values = append(opts.Values, file)
+	}
+	return opts
+}
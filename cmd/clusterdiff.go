@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/cluster"
+	"github.com/dlactin/rdv/internal/config"
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/vcs"
+	"github.com/spf13/cobra"
+)
+
+// clusterDiffCmd compares the local render against the live objects in a
+// cluster, similar to 'kubectl diff' but only needing read access
+var clusterDiffCmd = &cobra.Command{
+	Use:   "cluster-diff",
+	Short: "Diff the local render against the objects in a live cluster",
+	Long: `cluster-diff renders the chart or kustomization at --path and compares it against the
+objects currently in the cluster. Each rendered object is fetched by kind, namespace and
+name, so only 'get' permissions are needed.
+
+Server managed fields (status, managedFields, resourceVersion, uid, ...) are removed from
+the live objects. Fields defaulted by the API server are not, --semantic makes those
+easier to read. Objects that don't exist in the cluster show up as additions, objects
+in the cluster that are no longer rendered are not detected.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		path, err := filepath.Abs(renderPathFlag)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+		}
+
+		if err := loadClusterConfig(); err != nil {
+			return err
+		}
+
+		client, err := cluster.NewClient(kubeconfigFlag, kubeContextFlag, kubeNamespaceFlag)
+		if err != nil {
+			return err
+		}
+		if err := loadCapabilities(client); err != nil {
+			return err
+		}
+
+		log.Printf("Starting diff against cluster '%s':", client.Context)
+
+		render, err := diff.RenderManifests(path, renderOptions(path))
+		if err != nil {
+			return fmt.Errorf("failed to render path: %w", err)
+		}
+		// Objects without a namespace are looked up in the kubeconfig's namespace otherwise
+		render, err = inject(render)
+		if err != nil {
+			return err
+		}
+
+		resources, err := manifest.Parse(render)
+		if err != nil {
+			return fmt.Errorf("failed to parse render: %w", err)
+		}
+
+		live, err := client.Fetch(cmd.Context(), resources, debugFlag)
+		if err != nil {
+			return err
+		}
+		// The live objects hold the real values of the secrets masked in the render
+		live = mask.String(live)
+
+		// Both sides are encoded the same way so field order doesn't show up in the diff
+		local, err := cluster.Normalize(resources)
+		if err != nil {
+			return err
+		}
+
+		fromName := fmt.Sprintf("cluster/%s", client.Context)
+		toName := fmt.Sprintf("local/%s", renderPathFlag)
+
+		if semanticDiffFlag {
+			renderedDiff, err := diff.CreateSemanticDiff(live, local, fromName, toName, semanticOptions(plainFlag))
+			if err != nil {
+				return fmt.Errorf("error creating dyff: %w", err)
+			}
+
+			if len(renderedDiff.Diffs) == 0 {
+				fmt.Println("\nNo differences found between the cluster and rendered manifests.")
+				return nil
+			}
+
+			fmt.Printf("\n--- Diff (%s vs. local) ---", fromName)
+			return renderedDiff.WriteReport(os.Stdout)
+		}
+
+		renderedDiff := diff.CreateDiff(live, local, fromName, toName)
+		if renderedDiff == "" {
+			fmt.Println("\nNo differences found between the cluster and rendered manifests.")
+			return nil
+		}
+
+		fmt.Printf("\n--- Diff (%s vs. local) ---\n", fromName)
+		fmt.Println(colorize(renderedDiff))
+		return nil
+	},
+}
+
+// loadClusterConfig loads the config file for the commands comparing
+// against a cluster. The common labels and annotations and the redact
+// patterns apply to the render. Outside of a repository the config file is
+// looked up in the working directory.
+func loadClusterConfig() error {
+	configRoot := ""
+	if repo, err := vcs.New("auto"); err == nil {
+		configRoot = repo.Root()
+	}
+
+	var err error
+	cfg, err = config.Load(configFlag, configRoot, debugFlag)
+	if err != nil {
+		return err
+	}
+	if err := mask.SetPatterns(cfg.Redact); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	if err := helm.SetMirrors(cfg.RepoMirrors); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	return setupTheme(cfg.Theme)
+}
+
+func init() {
+	clusterDiffCmd.Flags().SortFlags = false
+
+	clusterDiffCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	clusterDiffCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	clusterDiffCmd.Flags().AddFlagSet(newClusterFlagSet())
+	clusterDiffCmd.Flags().AddFlagSet(newHelmFlagSet())
+	clusterDiffCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	clusterDiffCmd.Flags().BoolVarP(&semanticDiffFlag, "semantic", "s", false, "Enable semantic diffing of k8s manifests (using dyff)")
+	clusterDiffCmd.Flags().AddFlagSet(newSemanticFlagSet())
+	clusterDiffCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	clusterDiffCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	clusterDiffCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	clusterDiffCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	clusterDiffCmd.Flags().StringVarP(&configFlag, "config", "c", "", "Path to the config file (defaults to .rdv.yaml in the repository root)")
+	clusterDiffCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(clusterDiffCmd)
+	rootCmd.AddCommand(clusterDiffCmd)
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dlactin/rdv/internal/daemon"
+	"golang.org/x/term"
+)
+
+// Values of --color
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// forwarded is the request the daemon is running, nil outside of it. The
+// daemon's stdout is captured, so the client's terminal is used instead.
+var forwarded *daemon.Request
+
+// stdoutTerminal is set when stdout is a terminal. It's detected by
+// setupColor before the pager replaces stdout.
+var stdoutTerminal bool
+
+// setupColor turns --plain on when the output shouldn't be colored: with
+// --color never, or with --color auto when stdout isn't a terminal or
+// NO_COLOR is set, see https://no-color.org
+func setupColor() error {
+	stdoutTerminal = term.IsTerminal(int(os.Stdout.Fd()))
+	if forwarded != nil {
+		stdoutTerminal = forwarded.Terminal
+	}
+
+	switch colorFlag {
+	case colorAlways:
+	case colorNever:
+		plainFlag = true
+	case colorAuto, "":
+		if os.Getenv("NO_COLOR") != "" || !stdoutTerminal {
+			plainFlag = true
+		}
+	default:
+		return fmt.Errorf("invalid --color %q, must be %s, %s or %s", colorFlag, colorAuto, colorAlways, colorNever)
+	}
+	return nil
+}
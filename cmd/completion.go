@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/logging"
+	"github.com/dlactin/rdv/internal/timoni"
+	"github.com/dlactin/rdv/internal/vcs"
+	"github.com/spf13/cobra"
+)
+
+// completionMaxDepth limits how deep completePath looks for charts and
+// kustomizations below the working directory, completion must be fast
+const completionMaxDepth = 6
+
+// completePath completes --path with the directories below the working
+// directory that contain a Chart.yaml, kustomization or timoni.cue file
+func completePath(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// WalkDir drops the leading ./ from the paths it returns
+	if strings.HasPrefix(toComplete, "./") {
+		paths := renderPaths(".", strings.TrimPrefix(toComplete, "./"))
+		for i, path := range paths {
+			paths[i] = "./" + path
+		}
+		return paths, cobra.ShellCompDirectiveNoFileComp
+	}
+	return renderPaths(".", toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// renderPaths returns the chart, kustomization and Timoni module
+// directories below root
+// starting with prefix. Hidden directories and the vendored dependencies
+// in a chart's charts directory are skipped.
+func renderPaths(root, prefix string) []string {
+	var paths []string
+
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if rel, _ := filepath.Rel(root, path); strings.Count(rel, string(filepath.Separator)) >= completionMaxDepth {
+			return filepath.SkipDir
+		}
+
+		if isChartDir(path) || timoni.IsModule(path) {
+			if strings.HasPrefix(path, prefix) {
+				paths = append(paths, path)
+			}
+			// Subcharts and module templates are rendered with their parent
+			return filepath.SkipDir
+		}
+		if kustomize.IsKustomize(path) && strings.HasPrefix(path, prefix) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+
+	return paths
+}
+
+// isChartDir checks for a Chart.yaml without loading the chart like
+// helm.IsHelmChart does, which is too slow for completion
+func isChartDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, "Chart.yaml"))
+	return err == nil && !info.IsDir()
+}
+
+// completeRef completes --ref, --from and --to with the branches and tags
+// of the repository
+func completeRef(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	repo, err := vcs.New(vcsFlag)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var matches []string
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, toComplete) {
+			matches = append(matches, ref)
+		}
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeValues returns a completion function for a fixed set of values
+func completeValues(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp)
+}
+
+// registerCompletions adds the dynamic completions to the flags of cmd
+// that it has
+func registerCompletions(cmd *cobra.Command) {
+	completions := map[string]func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective){
+		"path":            completePath,
+		"ref":             completeRef,
+		"from":            completeRef,
+		"to":              completeRef,
+		"renderer":        completeRenderer,
+		"vcs":             completeValues("auto", "git", "jj"),
+		"fail-on":         completeValues(failOnCategories...),
+		"load-restrictor": completeValues("rootOnly", "none"),
+		"log-level":       completeValues("debug", "info", "warn", "error"),
+		"log-format":      completeValues(logging.FormatText, logging.FormatJSON),
+		"theme":           completeValues(diff.ThemeDefault, diff.ThemeColorblind),
+		"color":           completeValues(colorAuto, colorAlways, colorNever),
+		// Other values are template files
+		"webhook-template": cobra.FixedCompletions([]string{webhookTemplateJSON, webhookTemplateTeams}, cobra.ShellCompDirectiveDefault),
+	}
+
+	for name, complete := range completions {
+		if cmd.Flags().Lookup(name) != nil {
+			_ = cmd.RegisterFlagCompletionFunc(name, complete)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRenderPaths(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"charts/app/Chart.yaml",
+		"charts/app/charts/dep/Chart.yaml",
+		"overlays/dev/kustomization.yaml",
+		"overlays/prod/kustomization.yml",
+		"docs/README.md",
+		".github/kustomization.yaml",
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "All render paths", prefix: "", want: []string{"charts/app", "overlays/dev", "overlays/prod"}},
+		{name: "Prefix", prefix: "overlays/p", want: []string{"overlays/prod"}},
+		{name: "No match", prefix: "base", want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, path := range renderPaths(root, filepath.Join(root, tc.prefix)) {
+				rel, _ := filepath.Rel(root, path)
+				got = append(got, rel)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("renderPaths() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// printConflicts lists the resources and fields changed both on the
+// --three-way target ref and the local side since the merge-base, they are
+// the most likely to behave unexpectedly after the merge
+func printConflicts(t *target) error {
+	baseResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse merge-base render for %s: %w", t.name, err)
+	}
+
+	upstreamResources, err := manifest.Parse(t.upstreamRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s render for %s: %w", upstreamRef, t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	conflicts, err := manifest.Conflicts(baseResources, upstreamResources, localResources)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n--- Conflicts (changed on both %s and %s) ---\n", upstreamRef, localRef())
+	if len(conflicts) == 0 {
+		fmt.Println("No resources changed on both sides.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RESOURCE\tFIELDS")
+	for _, c := range conflicts {
+		fields := strings.Join(c.Fields, ", ")
+		switch {
+		case c.Whole:
+			fields = "(added or removed)"
+		case fields == "":
+			fields = "(different fields)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", c.Resource, fields)
+	}
+
+	return tw.Flush()
+}
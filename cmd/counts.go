@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// printResourceCounts prints the number of resources per kind on each
+// side and how many were added and removed, summed across all targets.
+// Large refactors can create or delete many objects at once, and a values
+// change can multiply resources unexpectedly. This gives an idea of the
+// load the change will put on the API server and controllers.
+func printResourceCounts(targets []*target) error {
+	totals := map[string]*manifest.KindDelta{}
+
+	for _, t := range targets {
+		targetResources, err := manifest.Parse(t.targetRender)
+		if err != nil {
+			return fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+		}
+
+		localResources, err := manifest.Parse(t.localRender)
+		if err != nil {
+			return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+		}
+
+		for _, d := range manifest.CountChanges(targetResources, localResources) {
+			if totals[d.Kind] == nil {
+				totals[d.Kind] = &manifest.KindDelta{Kind: d.Kind}
+			}
+			totals[d.Kind].Target += d.Target
+			totals[d.Kind].Local += d.Local
+			totals[d.Kind].Added += d.Added
+			totals[d.Kind].Removed += d.Removed
+		}
+	}
+
+	fmt.Printf("\n--- Resource Counts (%s → %s) ---\n", fullRef, localRef())
+	if len(totals) == 0 {
+		fmt.Println("No resources rendered.")
+		return nil
+	}
+
+	kinds := make([]string, 0, len(totals))
+	for kind := range totals {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	sum := manifest.KindDelta{Kind: "Total"}
+	for _, kind := range kinds {
+		d := totals[kind]
+		printKindDelta(d)
+		sum.Target += d.Target
+		sum.Local += d.Local
+		sum.Added += d.Added
+		sum.Removed += d.Removed
+	}
+	printKindDelta(&sum)
+
+	if len(targets) > 1 {
+		fmt.Printf("Summed across %d environments.\n", len(targets))
+	}
+
+	return nil
+}
+
+// printKindDelta prints a row of the resource counts table
+func printKindDelta(d *manifest.KindDelta) {
+	fmt.Printf("  %-30s %4d → %-4d +%d -%d\n", d.Kind, d.Target, d.Local, d.Added, d.Removed)
+}
@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dlactin/rdv/internal/daemon"
+	"github.com/dlactin/rdv/internal/validate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var daemonFlag bool
+
+// warm holds the state the daemon keeps between requests, it is nil
+// outside of 'rdv daemon'. Requests are handled one at a time.
+var warm *warmCache
+
+type warmCache struct {
+	// renders holds target ref renders by renderKey
+	renders map[string]string
+	// validator is shared so downloaded schemas stay cached
+	validator *validate.Validator
+}
+
+// daemonCmd keeps a warm rdv process running and serves diffs to
+// 'rdv --daemon' invocations over a unix socket
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Serve fast diffs to 'rdv --daemon' from a process with warm caches",
+	Long: `daemon starts a long running rdv process listening on a local unix socket.
+Running rdv with --daemon forwards the invocation to the daemon instead of starting
+from scratch. It runs with the environment and working directory of the client, and
+colors and wraps the output for the client's terminal.
+
+The daemon keeps target ref renders, keyed by commit, and downloaded kubeconform
+schemas in memory. When every target render is cached the target ref is not checked
+out at all, so only the local render runs for each diff. Chart dependencies updated
+with --update stay on disk between runs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		socket, err := daemon.DefaultSocket()
+		if err != nil {
+			return err
+		}
+
+		warm = &warmCache{renders: map[string]string{}}
+		defer func() { warm = nil }()
+
+		server, err := daemon.Listen(socket, handleDaemonRequest)
+		if err != nil {
+			return err
+		}
+
+		// Stop serving on interrupt
+		go func() {
+			<-cmd.Context().Done()
+			_ = server.Close()
+		}()
+
+		log.Printf("rdv daemon listening on %s", socket)
+		return server.Serve()
+	},
+}
+
+// handleDaemonRequest runs a forwarded invocation in the daemon process.
+// Flags, the environment and the working directory are process wide, so
+// they are set for every request and the output is captured instead of
+// printed.
+func handleDaemonRequest(req daemon.Request) daemon.Response {
+	if len(req.Args) > 0 && req.Args[0] == "daemon" {
+		return daemon.Response{Error: "the daemon can't start another daemon"}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return daemon.Response{Error: err.Error()}
+	}
+	if err := os.Chdir(req.Dir); err != nil {
+		return daemon.Response{Error: err.Error()}
+	}
+	defer func() { _ = os.Chdir(cwd) }()
+
+	restoreEnv, err := setEnv(req.Env)
+	if err != nil {
+		return daemon.Response{Error: err.Error()}
+	}
+	defer restoreEnv()
+
+	forwarded = &req
+	defer func() { forwarded = nil }()
+
+	resetCommandFlags(rootCmd)
+	resetRunState()
+
+	var stdout, stderr bytes.Buffer
+	restore, err := captureOutput(&stdout, &stderr)
+	if err != nil {
+		return daemon.Response{Error: err.Error()}
+	}
+
+	// The client prints the error, and usage output is only noise here
+	rootCmd.SilenceErrors, rootCmd.SilenceUsage = true, true
+	rootCmd.SetArgs(req.Args)
+	err = rootCmd.Execute()
+	rootCmd.SilenceErrors, rootCmd.SilenceUsage = false, false
+	rootCmd.SetArgs(nil)
+
+	restore()
+
+	resp := daemon.Response{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err != nil {
+		resp.Error = err.Error()
+		resp.ExitCode = exitCode(err)
+	}
+	return resp
+}
+
+// resetCommandFlags sets every flag of cmd and its subcommands back to
+// its default value
+func resetCommandFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			reset, ok := slice.(*resettableSlice)
+			if !ok {
+				reset = &resettableSlice{Value: f.Value, slice: slice}
+				f.Value = reset
+			}
+			reset.reset(flagDefault(f))
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+
+	for _, sub := range cmd.Commands() {
+		resetCommandFlags(sub)
+	}
+}
+
+// setEnv replaces the environment of the process with env until the
+// returned function is called
+func setEnv(env []string) (func(), error) {
+	old := os.Environ()
+	restore := func() {
+		os.Clearenv()
+		for _, kv := range old {
+			name, value, _ := strings.Cut(kv, "=")
+			_ = os.Setenv(name, value)
+		}
+	}
+
+	os.Clearenv()
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if err := os.Setenv(name, value); err != nil {
+			restore()
+			return nil, fmt.Errorf("invalid environment variable %q: %w", name, err)
+		}
+	}
+	return restore, nil
+}
+
+// resettableSlice wraps the value of a list flag so it can be reset.
+// pflag appends to a list once it was set, and Replace doesn't undo that,
+// so without it a flag passed in one daemon request would keep the values
+// of the previous one.
+type resettableSlice struct {
+	pflag.Value
+	slice pflag.SliceValue
+	set   bool
+}
+
+// Set replaces the defaults on the first call, and appends after that
+func (s *resettableSlice) Set(value string) error {
+	if !s.set {
+		if err := s.slice.Replace(nil); err != nil {
+			return err
+		}
+		s.set = true
+	}
+	return s.Value.Set(value)
+}
+
+func (s *resettableSlice) Append(value string) error { return s.slice.Append(value) }
+
+func (s *resettableSlice) Replace(values []string) error { return s.slice.Replace(values) }
+
+func (s *resettableSlice) GetSlice() []string { return s.slice.GetSlice() }
+
+// reset sets the list back to its defaults
+func (s *resettableSlice) reset(defaults []string) {
+	_ = s.slice.Replace(defaults)
+	s.set = false
+}
+
+// captureOutput redirects stdout, stderr and the logger into the given
+// buffers until the returned function is called
+func captureOutput(stdout, stderr *bytes.Buffer) (func(), error) {
+	oldOut, oldErr := os.Stdout, os.Stderr
+
+	rOut, wOut, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture stdout: %w", err)
+	}
+	rErr, wErr, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture stderr: %w", err)
+	}
+
+	os.Stdout, os.Stderr = wOut, wErr
+	log.SetOutput(wErr)
+
+	// Drain the pipes while the command runs so large diffs don't block
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _, _ = io.Copy(stdout, rOut) }()
+	go func() { defer wg.Done(); _, _ = io.Copy(stderr, rErr) }()
+
+	return func() {
+		_ = wOut.Close()
+		_ = wErr.Close()
+		wg.Wait()
+
+		os.Stdout, os.Stderr = oldOut, oldErr
+		log.SetOutput(oldErr)
+	}, nil
+}
+
+// forwardToDaemon sends the current invocation to a running daemon and
+// prints its output
+func forwardToDaemon(cmd *cobra.Command) error {
+	socket, err := daemon.DefaultSocket()
+	if err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	// Everything but --daemon is passed on unchanged
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg == "--daemon" || strings.HasPrefix(arg, "--daemon=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	// The daemon's stdout isn't a terminal, it colors and wraps the output
+	// for this one, detected before the pager started
+	resp, err := daemon.Send(socket, daemon.Request{
+		Dir:      cwd,
+		Args:     args,
+		Env:      os.Environ(),
+		Terminal: stdoutTerminal,
+		Width:    terminalWidth,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(resp.Stdout)
+	fmt.Fprint(os.Stderr, resp.Stderr)
+
+	if resp.Error != "" {
+		// The daemon already validated the flags
+		cmd.SilenceUsage = true
+		code := resp.ExitCode
+		if code == exitClean {
+			code = exitError
+		}
+		return withExitCode(code, errors.New(resp.Error))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
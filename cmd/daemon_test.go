@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/daemon"
+)
+
+func TestDaemonRequestState(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer resetFlags()
+
+	// Left over from an earlier --three-way and 'rdv hook' request
+	upstreamRef = "main"
+	hookRun = true
+
+	resp := handleDaemonRequest(daemon.Request{Dir: dir, Args: []string{"--ref", "HEAD", "--plain", "--validate=false", "--render-cache=false"}, Env: os.Environ()})
+	if resp.Error != "" {
+		t.Fatalf("Request failed: %s\nStderr: %s", resp.Error, resp.Stderr)
+	}
+	if strings.Contains(resp.Stdout, "since the merge-base") {
+		t.Errorf("Expected a plain diff, got the --three-way sections of an earlier request:\n%s", resp.Stdout)
+	}
+	if !strings.Contains(resp.Stdout, "+  key: value") {
+		t.Errorf("Expected the diff of the ConfigMap, got:\n%s", resp.Stdout)
+	}
+	// A plain diff checks out the ref instead of exporting it like the hook
+	if hookRun {
+		t.Errorf("Expected the hook state of an earlier request to be cleared")
+	}
+}
+
+func TestDaemonRequestFlags(t *testing.T) {
+	dir := hookRepo(t)
+	defer resetFlags()
+
+	for range 2 {
+		resp := handleDaemonRequest(daemon.Request{Dir: dir, Args: []string{"--ref", "HEAD", "--fail-on", "diff", "--validate=false"}, Env: os.Environ()})
+		if resp.Error != "" {
+			t.Fatalf("Request failed: %s\nStderr: %s", resp.Error, resp.Stderr)
+		}
+		if !slices.Equal(failOnFlag, []string{failOnDiff}) {
+			t.Errorf("Expected --fail-on to be [diff], got %v", failOnFlag)
+		}
+	}
+}
+
+func TestDaemonRequestClient(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer resetFlags()
+
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool { return strings.HasPrefix(kv, "NO_COLOR=") })
+	tests := []struct {
+		name     string
+		env      []string
+		terminal bool
+		colored  bool
+	}{
+		{"client terminal", env, true, true},
+		{"client pipe", env, false, false},
+		{"client NO_COLOR", append(slices.Clone(env), "NO_COLOR=1"), true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := daemon.Request{Dir: dir, Args: []string{"--ref", "HEAD", "--validate=false"}, Env: tt.env, Terminal: tt.terminal, Width: 120}
+			resp := handleDaemonRequest(req)
+			if resp.Error != "" {
+				t.Fatalf("Request failed: %s\nStderr: %s", resp.Error, resp.Stderr)
+			}
+			if colored := strings.Contains(resp.Stdout, "\x1b["); colored != tt.colored {
+				t.Errorf("Expected colored output to be %v, got:\n%s", tt.colored, resp.Stdout)
+			}
+			if outputWidth != 120 {
+				t.Errorf("Expected the width of the client's terminal, got %d", outputWidth)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/dlactin/rdv/internal/helm"
+)
+
+// printDependencies prints a table of the Helm chart dependencies added,
+// removed or moved to another version or repository, they are easily
+// missed in the diff of a subchart bump. Nothing is printed when the
+// dependencies are the same or the target isn't a chart.
+func printDependencies(t *target, worktree string) error {
+	localPath := filepath.Join(localRoot, t.relativePath)
+	if !helm.IsHelmChart(localPath) {
+		return nil
+	}
+
+	local, err := readDependencies(func(name string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(localPath, name))
+	})
+	if err != nil {
+		return fmt.Errorf("local dependencies of %s: %w", t.name, err)
+	}
+	target, err := readDependencies(func(name string) ([]byte, error) {
+		return t.readTargetFile(worktree, name)
+	})
+	if err != nil {
+		return fmt.Errorf("target dependencies of %s: %w", t.name, err)
+	}
+
+	changes := helm.DependencyChanges(target, local)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fmt.Println("\n--- Dependencies ---")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tFROM\t\tTO\tREPOSITORY")
+	for _, c := range changes {
+		from, to := c.From, c.To
+		if from == "" {
+			from = "(added)"
+		}
+		if to == "" {
+			to = "(removed)"
+		}
+
+		repository := c.ToRepository
+		switch {
+		case c.To == "":
+			repository = c.FromRepository
+		case c.From != "" && c.FromRepository != c.ToRepository:
+			repository = c.FromRepository + " → " + c.ToRepository
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t→\t%s\t%s\n", c.Name, from, to, repository)
+	}
+
+	return tw.Flush()
+}
+
+// readDependencies returns the dependencies of a chart read with readFile,
+// none when it has no Chart.yaml on that side
+func readDependencies(readFile func(name string) ([]byte, error)) ([]helm.Dependency, error) {
+	chartYAML, err := readFile("Chart.yaml")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := readFile("Chart.lock")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return helm.ParseDependencies(chartYAML, lock)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dlactin/rdv/internal/deprecation"
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// printDeprecations prints the resources in the local render that use
+// APIs deprecated or removed in the --kube-version release
+func printDeprecations(t *target) error {
+	resources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	findings := deprecation.Check(resources, kubeVersion)
+
+	fmt.Printf("\n--- Deprecated APIs (Kubernetes %s) ---\n", kubeVersion)
+	if len(findings) == 0 {
+		fmt.Println("No deprecated or removed APIs found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STATUS\tRESOURCE\tAPI VERSION\tREPLACEMENT\tSOURCE")
+	for _, f := range findings {
+		status := fmt.Sprintf("deprecated in %s", f.API.DeprecatedIn)
+		if f.Removed {
+			status = fmt.Sprintf("REMOVED in %s", f.API.RemovedIn)
+		}
+
+		replacement := f.API.Replacement
+		if replacement == "" {
+			replacement = "none"
+		}
+
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", status, f.Resource, f.API.APIVersion, replacement, f.Resource.Source)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// diffFilesCmd diffs two sets of pre-rendered manifests with the same
+// filtering and output as a diff between refs
+var diffFilesCmd = &cobra.Command{
+	Use:   "diff-files OLD NEW",
+	Short: "Diff two pre-rendered manifest files or directories",
+	Long: `diff-files diffs manifests that were already rendered, e.g. by another tool or an
+earlier 'rdv render', without any git or render work. OLD and NEW are manifest files or
+directories, every .yaml and .yml file below a directory is read in lexical order.
+
+The diff is printed the same way as a diff between refs, so --semantic, --stat,
+--include/--exclude and the report flags all apply:
+
+  rdv diff-files --semantic rendered/main.yaml rendered/feature.yaml`,
+	Args: cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		if err := validateFailOn(failOnFlag); err != nil {
+			return err
+		}
+
+		if err := setupTheme(nil); err != nil {
+			return err
+		}
+
+		if unifiedFlag < 0 {
+			return fmt.Errorf("--unified must be 0 or more, got %d", unifiedFlag)
+		}
+		if markdownChunkBytesFlag < 0 {
+			return fmt.Errorf("--markdown-chunk-bytes must be 0 or more, got %d", markdownChunkBytesFlag)
+		}
+		if err := setupArtifacts(); err != nil {
+			return err
+		}
+		if err := setupReporters(); err != nil {
+			return err
+		}
+
+		var err error
+		includeSelectors, err = parseSelectors(includeFlag)
+		if err != nil {
+			return err
+		}
+		excludeSelectors, err = parseSelectors(excludeFlag)
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		stopPager, err := startPager()
+		if err != nil {
+			return err
+		}
+		defer stopPager()
+
+		// The file paths take the place of the refs in the diff headers
+		fullRef, toRef = args[0], args[1]
+		t := &target{name: args[1]}
+
+		t.targetRender, err = manifest.ReadFiles(args[0])
+		if err != nil {
+			return err
+		}
+		t.localRender, err = manifest.ReadFiles(args[1])
+		if err != nil {
+			return err
+		}
+		if err := checkDuplicates(t.localRender); err != nil {
+			log.Printf("Warning: %s: %v", args[1], err)
+		}
+
+		diffReport := newDiffReport()
+		if diffReport != nil {
+			defer func() {
+				if err := writeDiffReports(diffReport); err != nil {
+					log.Printf("Warning: %v", err)
+				}
+			}()
+		}
+
+		if err := t.filter(); err != nil {
+			return err
+		}
+
+		if statFlag {
+			err = t.printStat()
+		} else {
+			err = t.printDiff()
+		}
+		if err != nil {
+			return err
+		}
+
+		if diffReport != nil {
+			if err := diffReport.Add(t.name, t.targetRender, t.localRender, nil); err != nil {
+				return err
+			}
+		}
+
+		if t.changed && failsOn(failOnDiff) {
+			return withExitCode(exitDiff, errors.New("differences found between the manifests"))
+		}
+		return nil
+	},
+}
+
+func init() {
+	diffFilesCmd.Flags().SortFlags = false
+
+	diffFilesCmd.Flags().BoolVarP(&semanticDiffFlag, "semantic", "s", false, "Enable semantic diffing of k8s manifests (using dyff)")
+	diffFilesCmd.Flags().AddFlagSet(newSemanticFlagSet())
+	diffFilesCmd.Flags().StringArrayVarP(&includeFlag, "include", "", []string{}, "Only diff resources matching this selector, e.g. 'kind=Deployment,name=api*' (can be specified multiple times)")
+	diffFilesCmd.Flags().StringArrayVarP(&excludeFlag, "exclude", "", []string{}, "Don't diff resources matching this selector, e.g. 'kind=ConfigMap' (can be specified multiple times)")
+	diffFilesCmd.Flags().BoolVarP(&keepNoiseFlag, "keep-noise", "", false, "Keep the helm.sh/chart and app.kubernetes.io/version labels and checksum/* annotations in the diff, they change on every chart bump")
+	diffFilesCmd.Flags().BoolVarP(&ignoreWhitespaceFlag, "ignore-whitespace", "", false, "Ignore changes that only add or remove whitespace, like reindented lists or trailing newlines, in the unified diff")
+	diffFilesCmd.Flags().BoolVarP(&ignoreCommentsFlag, "ignore-comments", "", false, "Remove YAML comments from both renders before diffing, except Helm's '# Source:' comments")
+	diffFilesCmd.Flags().BoolVarP(&ignoreSourceCommentsFlag, "ignore-source-comments", "", false, "Remove Helm's '# Source:' comments too, implies --ignore-comments")
+	diffFilesCmd.Flags().IntVarP(&unifiedFlag, "unified", "U", diff.DefaultContext, "Number of unchanged lines shown around each change in the diff")
+	diffFilesCmd.Flags().BoolVarP(&fullContextFlag, "full-context", "", false, "Show the whole render around the changes in the diff, overrides --unified")
+	diffFilesCmd.Flags().BoolVarP(&statFlag, "stat", "", false, "Print a summary of the added, removed and modified resources with the lines changed in each instead of the diff")
+	diffFilesCmd.Flags().StringVarP(&htmlReportFlag, "html-report", "", "", "Write a self-contained HTML report with a collapsible diff per resource to this file")
+	diffFilesCmd.Flags().StringVarP(&junitReportFlag, "junit-report", "", "", "Write a JUnit XML report with a test case per resource to this file: changed passes, unchanged is skipped")
+	diffFilesCmd.Flags().StringVarP(&patchDirFlag, "patch-dir", "", "", "Write the diff of every changed resource to its own Kind_namespace_name.patch file in this directory")
+	diffFilesCmd.Flags().StringVarP(&markdownReportFlag, "markdown-report", "", "", "Write the report as GitHub flavored markdown to this file, split into numbered report.1.md, report.2.md, ... files on resource boundaries when it exceeds --markdown-chunk-bytes")
+	diffFilesCmd.Flags().IntVarP(&markdownChunkBytesFlag, "markdown-chunk-bytes", "", githubapp.MaxCommentLength, "Largest markdown report file in bytes before it is split, GitHub's comment limit by default, 0 never splits")
+	diffFilesCmd.Flags().StringVarP(&artifactURLFlag, "artifact-url", "", "", "Upload the HTML, markdown and JSON report to this bucket, s3://bucket/prefix/, gs://bucket/prefix/ or azblob://container/prefix/, and print their URLs")
+	diffFilesCmd.Flags().StringVarP(&slackWebhookFlag, "slack-webhook", "", "", "Post a summary of the diff with a table of the changed resources to this Slack incoming webhook, e.g. from RDV_SLACK_WEBHOOK")
+	diffFilesCmd.Flags().StringVarP(&slackChannelFlag, "slack-channel", "", "", "Post to this Slack channel instead of the webhook's default, e.g. '#infra'")
+	diffFilesCmd.Flags().StringVarP(&webhookFlag, "webhook", "", "", "Post a summary of the diff as JSON to this webhook after the run, in the format of --webhook-template, e.g. from RDV_WEBHOOK")
+	diffFilesCmd.Flags().StringVarP(&webhookTemplateFlag, "webhook-template", "", webhookTemplateJSON, "Body posted to --webhook: json for the summary as JSON, teams for a Microsoft Teams Adaptive Card, or the path of a Go template rendering JSON from the summary")
+	diffFilesCmd.Flags().StringSliceVarP(&failOnFlag, "fail-on", "", []string{}, "Failure categories that fail the run with their exit code, only diff applies here")
+	diffFilesCmd.Flags().BoolVarP(&accessibleFlag, "accessible", "", false, "Prefix changed lines with ADDED:/REMOVED: instead of relying on color, for screen readers and logs without ANSI support")
+	diffFilesCmd.Flags().BoolVarP(&noPagerFlag, "no-pager", "", false, "Don't pipe the output through $PAGER (less by default) when stdout is a terminal")
+	diffFilesCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	diffFilesCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	diffFilesCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	diffFilesCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+
+	rootCmd.AddCommand(diffFilesCmd)
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dlactin/rdv/internal/cluster"
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	driftIntervalFlag time.Duration
+	driftFormatFlag   string
+)
+
+// Drift statuses of a resource
+const (
+	// driftDrifted resources differ from the render in the cluster
+	driftDrifted = "drifted"
+	// driftMissing resources are rendered but don't exist in the cluster
+	driftMissing = "missing"
+)
+
+// driftReport is the result of one drift check, printed as a JSON line
+// with --format json
+type driftReport struct {
+	Time      time.Time       `json:"time"`
+	Context   string          `json:"context"`
+	Path      string          `json:"path"`
+	Drifted   int             `json:"drifted"`
+	Missing   int             `json:"missing"`
+	Resources []driftResource `json:"resources"`
+
+	// live and local are the compared streams, for the semantic diff
+	live, local string
+}
+
+// driftResource is a resource that drifted or is missing, Diff is the
+// unified diff from the live object to the render
+type driftResource struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Diff   string `json:"diff"`
+}
+
+// driftCmd compares the render against the cluster once or on an interval
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Detect drift between the render and the objects in a live cluster",
+	Long: `drift renders the chart or kustomization at --path and reports the rendered objects that
+were changed in the cluster or don't exist in it. Unlike cluster-diff, only the fields set in
+the render are compared, so fields defaulted by the API server or added by controllers are not
+reported as drift.
+
+Without --interval a single check is run and the exit code is 1 when drift was found, for cron
+jobs. With --interval the check is repeated until interrupted, rendering the working tree again
+every time, e.g. kept up to date by a git-sync sidecar. --format json prints every check as a
+JSON line for log pipelines and alerting.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+		if driftIntervalFlag > 0 {
+			log.SetFlags(log.LstdFlags) // Timestamps are useful when running continuously
+		}
+
+		if driftFormatFlag != "text" && driftFormatFlag != "json" {
+			return fmt.Errorf("invalid --format '%s', must be one of: text, json", driftFormatFlag)
+		}
+		cmd.SilenceUsage = true
+
+		path, err := filepath.Abs(renderPathFlag)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+		}
+
+		if err := loadClusterConfig(); err != nil {
+			return err
+		}
+
+		client, err := cluster.NewClient(kubeconfigFlag, kubeContextFlag, kubeNamespaceFlag)
+		if err != nil {
+			return err
+		}
+		if err := loadCapabilities(client); err != nil {
+			return err
+		}
+
+		if driftIntervalFlag == 0 {
+			rep, err := checkDrift(cmd.Context(), client, path)
+			if err != nil {
+				return err
+			}
+			if err := writeDrift(rep); err != nil {
+				return err
+			}
+			if len(rep.Resources) > 0 {
+				return withExitCode(exitDiff, errors.New("drift detected between the cluster and rendered manifests"))
+			}
+			return nil
+		}
+
+		log.Printf("Checking drift against cluster '%s' every %s", client.Context, driftIntervalFlag)
+		for {
+			// A failed check is retried on the next interval
+			rep, err := checkDrift(cmd.Context(), client, path)
+			if err == nil {
+				err = writeDrift(rep)
+			}
+			if err != nil {
+				log.Printf("Drift check failed: %v", err)
+			}
+
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-time.After(driftIntervalFlag):
+			}
+		}
+	},
+}
+
+// checkDrift renders path and compares it against the fields of the live
+// objects that are set in the render
+func checkDrift(ctx context.Context, client *cluster.Client, path string) (*driftReport, error) {
+	render, err := diff.RenderManifestsContext(ctx, path, renderOptions(path))
+	if err != nil {
+		return nil, withExitCode(exitRender, fmt.Errorf("failed to render path: %w", err))
+	}
+	render, err = inject(render)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := manifest.Parse(render)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse render: %w", err)
+	}
+
+	live, err := client.FetchManaged(ctx, resources, debugFlag)
+	if err != nil {
+		return nil, err
+	}
+	local, err := cluster.Normalize(resources)
+	if err != nil {
+		return nil, err
+	}
+
+	// The live objects hold the real values of the secrets masked in the render
+	live, local = mask.String(live), mask.String(local)
+
+	rep := &report.Report{From: fmt.Sprintf("cluster/%s", client.Context), To: "local"}
+	if err := rep.Add(renderPathFlag, live, local, nil); err != nil {
+		return nil, err
+	}
+
+	drift := &driftReport{
+		Time:      time.Now().UTC(),
+		Context:   client.Context,
+		Path:      renderPathFlag,
+		Resources: []driftResource{},
+		live:      live,
+		local:     local,
+	}
+	for _, res := range rep.Sections[0].Resources {
+		status := driftDrifted
+		switch res.Status {
+		case manifest.StatusAdded:
+			status = driftMissing
+			drift.Missing++
+		case manifest.StatusModified:
+			drift.Drifted++
+		default:
+			// Only rendered objects are fetched, nothing can be removed
+			continue
+		}
+		drift.Resources = append(drift.Resources, driftResource{
+			Name:   res.Name,
+			Kind:   res.Kind,
+			Status: status,
+			Diff:   res.Diff,
+		})
+	}
+	return drift, nil
+}
+
+// writeDrift prints a drift check in the --format
+func writeDrift(rep *driftReport) error {
+	if driftFormatFlag == "json" {
+		return json.NewEncoder(os.Stdout).Encode(rep)
+	}
+
+	if len(rep.Resources) == 0 {
+		log.Printf("No drift found between cluster '%s' and %s", rep.Context, rep.Path)
+		return nil
+	}
+	log.Printf("Drift found between cluster '%s' and %s: %d drifted, %d missing", rep.Context, rep.Path, rep.Drifted, rep.Missing)
+
+	if semanticDiffFlag {
+		semanticDiff, err := diff.CreateSemanticDiff(rep.live, rep.local, fmt.Sprintf("cluster/%s", rep.Context), fmt.Sprintf("local/%s", rep.Path), semanticOptions(plainFlag))
+		if err != nil {
+			return fmt.Errorf("error creating dyff: %w", err)
+		}
+		return semanticDiff.WriteReport(os.Stdout)
+	}
+	for _, res := range rep.Resources {
+		fmt.Printf("\n--- %s (%s) ---\n", res.Name, res.Status)
+		fmt.Println(colorize(res.Diff))
+	}
+	return nil
+}
+
+func init() {
+	driftCmd.Flags().SortFlags = false
+
+	driftCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	driftCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	driftCmd.Flags().DurationVarP(&driftIntervalFlag, "interval", "", 0, "Check for drift on this interval until interrupted, e.g. 1h. A single check is run when not set")
+	driftCmd.Flags().StringVarP(&driftFormatFlag, "format", "", "text", "Output format of each check: text or json (one JSON object per line)")
+	driftCmd.Flags().AddFlagSet(newClusterFlagSet())
+	driftCmd.Flags().AddFlagSet(newHelmFlagSet())
+	driftCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	driftCmd.Flags().BoolVarP(&semanticDiffFlag, "semantic", "s", false, "Enable semantic diffing of k8s manifests (using dyff) in the text output")
+	driftCmd.Flags().AddFlagSet(newSemanticFlagSet())
+	driftCmd.Flags().StringVarP(&configFlag, "config", "c", "", "Path to the config file (defaults to .rdv.yaml in the repository root)")
+	driftCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	driftCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	driftCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	driftCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	driftCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(driftCmd)
+	rootCmd.AddCommand(driftCmd)
+}
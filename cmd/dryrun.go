@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/cluster"
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// serverDryRun replaces the renders of the target with the objects returned
+// by a server-side dry-run, so defaulting and admission webhook mutations
+// show up in the diff the same way on both sides
+func serverDryRun(ctx context.Context, client *cluster.Client, t *target) error {
+	renders := []*string{&t.localRender, &t.targetRender}
+	if t.upstreamRender != "" {
+		renders = append(renders, &t.upstreamRender)
+	}
+	for _, render := range renders {
+		resources, err := manifest.Parse(*render)
+		if err != nil {
+			return fmt.Errorf("failed to parse render: %w", err)
+		}
+
+		*render, err = client.DryRun(ctx, resources, debugFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is prepended to the upper-cased flag name to get the
+// environment variable that sets it, e.g. RDV_REF for --ref
+const envPrefix = "RDV_"
+
+// flagEnvName returns the environment variable for a flag name
+func flagEnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets the flags of cmd that weren't passed on the command line
+// from their RDV_ environment variable. List flags take comma separated
+// values like on the command line.
+func applyEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+
+		value, ok := os.LookupEnv(flagEnvName(f.Name))
+		if !ok {
+			return
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, flagEnvName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// applyConfigFlags sets the flags of cmd that weren't passed on the
+// command line or through the environment from the flags section of the
+// config file
+func applyConfigFlags(cmd *cobra.Command, values map[string]any) error {
+	for name, value := range values {
+		f := cmd.Flags().Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown flag '%s' in config file", name)
+		}
+		if _, ok := os.LookupEnv(flagEnvName(name)); ok || f.Changed {
+			continue
+		}
+
+		var err error
+		if list, ok := value.([]any); ok {
+			slice, ok := f.Value.(pflag.SliceValue)
+			if !ok {
+				return fmt.Errorf("flag '%s' in config file takes a single value, got a list", name)
+			}
+
+			values := make([]string, len(list))
+			for i, v := range list {
+				values[i] = fmt.Sprint(v)
+			}
+			err = slice.Replace(values)
+		} else {
+			err = f.Value.Set(fmt.Sprint(value))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value for flag '%s' in config file: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// flagDefault returns the default values of a list flag, pflag formats
+// them as '[a,b]'
+func flagDefault(f *pflag.Flag) []string {
+	def := strings.Trim(f.DefValue, "[]")
+	if def == "" {
+		return nil
+	}
+	return strings.Split(def, ",")
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestFlagPrecedence(t *testing.T) {
+	var ref, renderer string
+	var validate bool
+	var values []string
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVar(&ref, "ref", "main", "")
+	cmd.Flags().StringVar(&renderer, "renderer", "auto", "")
+	cmd.Flags().BoolVar(&validate, "validate", false, "")
+	cmd.Flags().StringSliceVar(&values, "values", []string{}, "")
+
+	t.Setenv("RDV_REF", "env-ref")
+	t.Setenv("RDV_RENDERER", "helm")
+	t.Setenv("RDV_VALUES", "a.yaml,b.yaml")
+
+	if err := cmd.ParseFlags([]string{"--ref", "flag-ref"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(cmd); err != nil {
+		t.Fatalf("applyEnv() failed: %v", err)
+	}
+	err := applyConfigFlags(cmd, map[string]any{
+		"renderer": "kustomize",
+		"validate": true,
+		"values":   []any{"c.yaml"},
+	})
+	if err != nil {
+		t.Fatalf("applyConfigFlags() failed: %v", err)
+	}
+
+	if ref != "flag-ref" {
+		t.Errorf("ref = %q, want the command line value", ref)
+	}
+	if renderer != "helm" {
+		t.Errorf("renderer = %q, want the environment value", renderer)
+	}
+	if !validate {
+		t.Error("validate = false, want the config file value")
+	}
+	if want := []string{"a.yaml", "b.yaml"}; !slices.Equal(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+
+	if err := applyConfigFlags(cmd, map[string]any{"no-such-flag": true}); err == nil {
+		t.Error("applyConfigFlags() succeeded with an unknown flag, expected an error")
+	}
+}
+
+func TestApplyKubectlEnv(t *testing.T) {
+	var kubeconfig, kubeContext, namespace string
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", "", "")
+	cmd.Flags().StringVar(&kubeContext, "context", "", "")
+	cmd.Flags().StringVar(&namespace, "namespace", "", "")
+
+	t.Setenv("KUBECTL_PLUGINS_GLOBAL_FLAG_KUBECONFIG", "/kubectl/config")
+	t.Setenv("KUBECTL_PLUGINS_GLOBAL_FLAG_CONTEXT", "kubectl-context")
+	t.Setenv("KUBECTL_PLUGINS_CURRENT_NAMESPACE", "kubectl-namespace")
+	t.Setenv("RDV_CONTEXT", "rdv-context")
+
+	if err := cmd.ParseFlags([]string{"--namespace", "flag-namespace"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyEnv(cmd); err != nil {
+		t.Fatalf("applyEnv() failed: %v", err)
+	}
+	if err := applyKubectlEnv(cmd); err != nil {
+		t.Fatalf("applyKubectlEnv() failed: %v", err)
+	}
+
+	if kubeconfig != "/kubectl/config" {
+		t.Errorf("kubeconfig = %q, want the kubectl value", kubeconfig)
+	}
+	if kubeContext != "rdv-context" {
+		t.Errorf("context = %q, want the RDV_CONTEXT value", kubeContext)
+	}
+	if namespace != "flag-namespace" {
+		t.Errorf("namespace = %q, want the command line value", namespace)
+	}
+	// Values from kubectl take precedence over the config file
+	if err := applyConfigFlags(cmd, map[string]any{"kubeconfig": "/config/file"}); err != nil {
+		t.Fatalf("applyConfigFlags() failed: %v", err)
+	}
+	if kubeconfig != "/kubectl/config" {
+		t.Errorf("kubeconfig = %q after applyConfigFlags(), want the kubectl value", kubeconfig)
+	}
+}
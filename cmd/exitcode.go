@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Exit codes, documented in the README so wrappers can branch on them
+const (
+	exitClean = 0
+	// exitDiff is only used when --fail-on includes diff
+	exitDiff = 1
+	// exitError covers everything without its own code, like bad flags
+	exitError      = 2
+	exitValidation = 3
+	exitPolicy     = 4
+	exitRender     = 5
+	exitRules      = 6
+	exitVersion    = 7
+)
+
+// Failure categories accepted by --fail-on
+const (
+	failOnDiff       = "diff"
+	failOnValidation = "validation"
+	failOnPolicy     = "policy"
+	failOnRender     = "render"
+	// failOnRules covers the failRules of the config file
+	failOnRules = "rules"
+	// failOnVersion covers charts changed without a version bump
+	failOnVersion = "version"
+)
+
+var failOnCategories = []string{failOnDiff, failOnValidation, failOnPolicy, failOnRender, failOnRules, failOnVersion}
+
+var failOnFlag []string
+
+// codeError is an error with the exit code the process exits with
+type codeError struct {
+	code int
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+func (e *codeError) Unwrap() error { return e.err }
+
+// withExitCode attaches an exit code to err
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codeError{code: code, err: err}
+}
+
+// exitCode returns the exit code for an error returned by a command
+func exitCode(err error) int {
+	if err == nil {
+		return exitClean
+	}
+
+	var codeErr *codeError
+	if errors.As(err, &codeErr) {
+		return codeErr.code
+	}
+	return exitError
+}
+
+// validateFailOn checks the --fail-on categories
+func validateFailOn(categories []string) error {
+	for _, c := range categories {
+		if !slices.Contains(failOnCategories, c) {
+			return fmt.Errorf("invalid --fail-on category '%s', must be one of: %s", c, strings.Join(failOnCategories, ", "))
+		}
+	}
+	return nil
+}
+
+// failsOn reports whether failures of category fail the run
+func failsOn(category string) bool {
+	return slices.Contains(failOnFlag, category)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "No error", err: nil, want: exitClean},
+		{name: "Plain error", err: errors.New("bad flag"), want: exitError},
+		{name: "Policy error", err: withExitCode(exitPolicy, errors.New("denied")), want: exitPolicy},
+		{name: "Wrapped render error", err: fmt.Errorf("env dev: %w", withExitCode(exitRender, errors.New("failed"))), want: exitRender},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCode(tc.err); got != tc.want {
+				t.Errorf("exitCode() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
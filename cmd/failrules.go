@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/gate"
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// checkFailRules prints the changes of a target matching the failRules
+// of the config file and returns how many there are
+func checkFailRules(t *target) (int, error) {
+	targetResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	violations, err := gate.Evaluate(cfg.FailRules, targetResources, localResources)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(violations) > 0 {
+		fmt.Println("\n--- Fail Rules ---")
+		for _, v := range violations {
+			fmt.Printf("  %s\n", v)
+		}
+	}
+
+	return len(violations), nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/logging"
+	"github.com/spf13/pflag"
+)
+
+// Kustomize flag vars
+var (
+	enableHelmFlag   bool
+	helmCommandFlag  string
+	loadRestrictFlag string
+	alphaPluginsFlag bool
+	enableExecFlag   bool
+	fnNetworkFlag    bool
+	fnMountsFlag     []string
+	fnEnvFlag        []string
+	fnAllowFlag      []string
+)
+
+// Cluster flag vars
+var (
+	kubeconfigFlag    string
+	kubeContextFlag   string
+	kubeNamespaceFlag string
+
+	capabilitiesFromClusterFlag bool
+)
+
+// Logging flag vars
+var (
+	logLevelFlag  string
+	logFormatFlag string
+)
+
+// Semantic diff flag vars
+var (
+	semanticIgnoreOrderFlag      bool
+	semanticIgnoreWhitespaceFlag bool
+	semanticDetectK8sFlag        bool
+	semanticExcludeFlag          []string
+	semanticExcludeRegexpFlag    []string
+)
+
+// newHelmFlagSet returns the flags used to render Helm charts.
+// Each command that renders gets its own flag set bound to the same vars.
+func newHelmFlagSet() *pflag.FlagSet {
+	helmFlags := pflag.NewFlagSet("helm", pflag.ContinueOnError)
+	helmFlags.SortFlags = false
+
+	helmFlags.StringSliceVarP(&valuesFlag, "values", "f", []string{}, "Path to an additional values file (can be specified multiple times)")
+	helmFlags.StringSliceVarP(&showOnlyFlag, "show-only", "", []string{}, "Only render templates matching this path or glob, e.g. templates/deployment.yaml (can be specified multiple times)")
+	helmFlags.BoolVarP(&updateFlag, "update", "u", false, "Update Helm chart dependencies. Required if lockfile does not match dependencies")
+	helmFlags.StringVarP(&injectNamespaceFlag, "inject-namespace", "", "", "Set metadata.namespace on namespaced resources that don't have one and render Helm charts for this release namespace, like 'helm install -n'")
+	helmFlags.BoolVarP(&resolveRefsFlag, "resolve-refs", "", false, "Resolve vals references like ref+vault:// and ref+awsssm:// in values files with the vals CLI before rendering, resolved values are masked in the output")
+
+	return helmFlags
+}
+
+// newKustomizeFlagSet returns the flags used to build kustomizations
+func newKustomizeFlagSet() *pflag.FlagSet {
+	kustomizeFlags := pflag.NewFlagSet("kustomize", pflag.ContinueOnError)
+	kustomizeFlags.SortFlags = false
+
+	kustomizeFlags.BoolVarP(&enableHelmFlag, "enable-helm", "", false, "Enable the Helm chart inflator for kustomizations using helmCharts")
+	kustomizeFlags.StringVarP(&helmCommandFlag, "helm-command", "", "", "Helm binary used by the inflator (defaults to helm in PATH)")
+	kustomizeFlags.StringVarP(&loadRestrictFlag, "load-restrictor", "", "rootOnly", "Kustomize load restrictor: rootOnly or none (allows files outside the kustomization directory)")
+	kustomizeFlags.BoolVarP(&alphaPluginsFlag, "enable-alpha-plugins", "", false, "Enable kustomize generator and transformer plugins, including containerized KRM functions")
+	kustomizeFlags.BoolVarP(&enableExecFlag, "enable-exec", "", false, "Enable exec KRM functions, requires --enable-alpha-plugins")
+	kustomizeFlags.BoolVarP(&fnNetworkFlag, "fn-network", "", false, "Allow containerized KRM functions network access")
+	kustomizeFlags.StringSliceVarP(&fnMountsFlag, "fn-mount", "", []string{}, "Storage mount passed to containerized KRM functions, e.g. type=bind,src=/tmp,dst=/tmp (can be specified multiple times)")
+	kustomizeFlags.StringSliceVarP(&fnEnvFlag, "fn-env", "", []string{}, "Environment variable passed to KRM functions, e.g. FOO=bar or FOO (can be specified multiple times)")
+	kustomizeFlags.StringSliceVarP(&fnAllowFlag, "fn-allow", "", []string{}, "Only allow KRM functions with a matching image or exec path, globs are supported. Bases outside of the path are checked too, files referenced over http are rejected (can be specified multiple times)")
+
+	return kustomizeFlags
+}
+
+// newClusterFlagSet returns the flags used to connect to a cluster
+func newClusterFlagSet() *pflag.FlagSet {
+	clusterFlags := pflag.NewFlagSet("cluster", pflag.ContinueOnError)
+	clusterFlags.SortFlags = false
+
+	clusterFlags.StringVarP(&kubeconfigFlag, "kubeconfig", "", "", "Path to the kubeconfig file (defaults to KUBECONFIG or ~/.kube/config)")
+	clusterFlags.StringVarP(&kubeContextFlag, "context", "", "", "Kubeconfig context to use (defaults to the current context)")
+	clusterFlags.StringVarP(&kubeNamespaceFlag, "namespace", "n", "", "Namespace of rendered objects without one (defaults to the namespace of the context)")
+	clusterFlags.BoolVarP(&capabilitiesFromClusterFlag, "capabilities-from-cluster", "", false, "Render Helm charts with the Kubernetes version and API versions of the cluster as .Capabilities, like 'helm install'")
+
+	return clusterFlags
+}
+
+// newLoggingFlagSet returns the flags configuring the log output of
+// commands, --debug is short for --log-level debug
+func newLoggingFlagSet() *pflag.FlagSet {
+	loggingFlags := pflag.NewFlagSet("logging", pflag.ContinueOnError)
+	loggingFlags.SortFlags = false
+
+	loggingFlags.BoolVarP(&debugFlag, "debug", "", false, "Enable verbose logging for debugging")
+	loggingFlags.StringVarP(&logLevelFlag, "log-level", "", "info", "Minimum level of the log records of rendering, validation and git: debug, info, warn or error")
+	loggingFlags.StringVarP(&logFormatFlag, "log-format", "", logging.FormatText, "Format of the log records: text or json, one JSON object per line")
+
+	return loggingFlags
+}
+
+// newSemanticFlagSet returns the flags tuning the dyff comparison of --semantic
+func newSemanticFlagSet() *pflag.FlagSet {
+	semanticFlags := pflag.NewFlagSet("semantic", pflag.ContinueOnError)
+	semanticFlags.SortFlags = false
+
+	semanticFlags.BoolVarP(&semanticIgnoreOrderFlag, "semantic-ignore-order", "", true, "Ignore list entries that only changed position in the semantic diff")
+	semanticFlags.BoolVarP(&semanticIgnoreWhitespaceFlag, "semantic-ignore-whitespace", "", true, "Ignore leading and trailing whitespace changes in the semantic diff")
+	semanticFlags.BoolVarP(&semanticDetectK8sFlag, "semantic-detect-kubernetes", "", true, "Match documents and list entries by their Kubernetes identifiers in the semantic diff")
+	semanticFlags.StringArrayVarP(&semanticExcludeFlag, "semantic-exclude", "", []string{}, "Leave this go-patch style path out of the semantic diff, e.g. /spec/replicas (can be specified multiple times)")
+	semanticFlags.StringArrayVarP(&semanticExcludeRegexpFlag, "semantic-exclude-regexp", "", []string{}, "Leave paths matching this regular expression out of the semantic diff (can be specified multiple times)")
+
+	return semanticFlags
+}
+
+// semanticOptions builds the dyff options from the semantic diff flags
+func semanticOptions(plain bool) diff.SemanticOptions {
+	return diff.SemanticOptions{
+		IgnoreOrderChanges:        semanticIgnoreOrderFlag,
+		IgnoreWhitespaceChanges:   semanticIgnoreWhitespaceFlag,
+		KubernetesEntityDetection: semanticDetectK8sFlag,
+		ExcludePaths:              semanticExcludeFlag,
+		ExcludeRegexps:            semanticExcludeRegexpFlag,
+		Plain:                     plain,
+		Width:                     outputWidth,
+	}
+}
+
+// kustomizeOptions builds the kustomize options from the kustomize flags
+func kustomizeOptions() kustomize.Options {
+	return kustomize.Options{
+		EnableHelm:         enableHelmFlag,
+		HelmCommand:        helmCommandFlag,
+		LoadRestrictor:     loadRestrictFlag,
+		EnableAlphaPlugins: alphaPluginsFlag,
+		EnableExec:         enableExecFlag,
+		FnNetwork:          fnNetworkFlag,
+		FnMounts:           fnMountsFlag,
+		FnEnv:              fnEnvFlag,
+		FnAllow:            fnAllowFlag,
+		Offline:            offlineFlag,
+	}
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/spf13/cobra"
+)
+
+var flakeRunsFlag int
+
+// flakeCheckCmd renders the same path multiple times and reports any
+// differences between the renders. A nondeterministic chart produces
+// a diff on every run, which makes every diff untrustworthy.
+var flakeCheckCmd = &cobra.Command{
+	Use:   "flake-check",
+	Short: "Render a path multiple times and report nondeterministic output",
+	Long: `flake-check renders the chart or kustomization at --path multiple times and compares
+the output of every run against the first one. Any difference means the render is not
+deterministic, usually due to random or time based template functions.
+
+For Helm charts the templates are also scanned for functions known to produce
+nondeterministic output (randAlphaNum, now, uuidv4, genCA, unsorted keys, ...).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		if err := setupTheme(nil); err != nil {
+			return err
+		}
+
+		if flakeRunsFlag < 2 {
+			return fmt.Errorf("--runs must be at least 2, got %d", flakeRunsFlag)
+		}
+
+		path, err := filepath.Abs(renderPathFlag)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+		}
+
+		valuesPaths := make([]string, len(valuesFlag))
+		for i, v := range valuesFlag {
+			valuesPaths[i] = helm.ResolveValuesPath(path, v)
+		}
+
+		opts := diff.RenderOptions{
+			Renderer:    rendererFlag,
+			Values:      valuesPaths,
+			ShowOnly:    showOnlyFlag,
+			Kustomize:   kustomizeOptions(),
+			Debug:       debugFlag,
+			Update:      updateFlag,
+			ResolveRefs: resolveRefsFlag,
+		}
+
+		log.Printf("Rendering '%s' %d times:", renderPathFlag, flakeRunsFlag)
+
+		first, err := diff.RenderManifests(path, opts)
+		if err != nil {
+			return err
+		}
+
+		// Dependencies only need to be updated once
+		opts.Update = false
+
+		var flaky int
+		for run := 2; run <= flakeRunsFlag; run++ {
+			render, err := diff.RenderManifests(path, opts)
+			if err != nil {
+				return err
+			}
+
+			renderDiff := diff.CreateDiff(first, render, "run/1", fmt.Sprintf("run/%d", run))
+			if renderDiff == "" {
+				continue
+			}
+
+			flaky++
+			// Only print the first difference, the rest are usually the same
+			if flaky == 1 {
+				fmt.Printf("\n--- Run %d differs from run 1 ---\n", run)
+				fmt.Println(colorize(renderDiff))
+			}
+		}
+
+		if helm.IsHelmChart(path) {
+			findings, err := helm.FindNondeterministicCalls(path)
+			if err != nil {
+				return err
+			}
+
+			if len(findings) > 0 {
+				fmt.Println("\nTemplate functions that can produce nondeterministic output:")
+				for _, f := range findings {
+					fmt.Printf("  - %s\n", f)
+				}
+			}
+		}
+
+		if flaky > 0 {
+			return fmt.Errorf("render is nondeterministic: %d of %d runs differed from the first run", flaky, flakeRunsFlag-1)
+		}
+
+		fmt.Printf("\nAll %d renders are identical.\n", flakeRunsFlag)
+		return nil
+	},
+}
+
+func init() {
+	flakeCheckCmd.Flags().SortFlags = false
+
+	flakeCheckCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	flakeCheckCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	flakeCheckCmd.Flags().IntVarP(&flakeRunsFlag, "runs", "n", 5, "Number of times to render the path")
+	flakeCheckCmd.Flags().AddFlagSet(newHelmFlagSet())
+	flakeCheckCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	flakeCheckCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	flakeCheckCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	flakeCheckCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	flakeCheckCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	flakeCheckCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(flakeCheckCmd)
+	rootCmd.AddCommand(flakeCheckCmd)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/report"
+	"github.com/dlactin/rdv/internal/sarif"
+)
+
+var noGitHubActionsFlag bool
+
+// githubActions reports whether we run in a GitHub Actions step and
+// should write its summary, outputs and annotations
+func githubActions() bool {
+	return !noGitHubActionsFlag && os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// writeGitHubActions appends the markdown report to the step summary,
+// sets the step outputs and prints an annotation for every finding
+func writeGitHubActions(r *report.Report, findings *sarif.Log) error {
+	for _, f := range findings.Results() {
+		command := "error"
+		if f.Level == sarif.LevelWarning {
+			command = "warning"
+		}
+		properties := "title=" + escapeProperty(f.RuleID)
+		if f.Path != "" {
+			properties = "file=" + escapeProperty(f.Path) + "," + properties
+		}
+		fmt.Printf("::%s %s::%s\n", command, properties, escapeData(f.Message))
+	}
+
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := appendFile(path, r.WriteMarkdown); err != nil {
+			return fmt.Errorf("failed to write GitHub step summary: %w", err)
+		}
+	}
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		totals := r.Totals()
+		changed := totals.Added + totals.Removed + totals.Modified
+		outputs := fmt.Sprintf("has-diff=%t\nresources-changed=%d\nresources-added=%d\nresources-removed=%d\nresources-modified=%d\n",
+			changed > 0, changed, totals.Added, totals.Removed, totals.Modified)
+
+		err := appendFile(path, func(w io.Writer) error {
+			_, err := io.WriteString(w, outputs)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write GitHub step outputs: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// appendFile opens path for appending and writes to it with write
+func appendFile(path string, write func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// escapeData escapes a workflow command message
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a workflow command property value
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/report"
+	"github.com/dlactin/rdv/internal/sarif"
+)
+
+func TestWriteGitHubActions(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.md")
+	outputPath := filepath.Join(dir, "output")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+
+	r := &report.Report{From: "main", To: "local"}
+	err := r.Add("dev", "", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n", nil)
+	if err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	if err := writeGitHubActions(r, &sarif.Log{}); err != nil {
+		t.Fatalf("writeGitHubActions() failed: %v", err)
+	}
+
+	summary, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("failed to read step summary: %v", err)
+	}
+	if !strings.Contains(string(summary), "ConfigMap/the-map") {
+		t.Errorf("step summary doesn't list the added resource:\n%s", summary)
+	}
+
+	outputs, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read step outputs: %v", err)
+	}
+	for _, want := range []string{"has-diff=true\n", "resources-changed=1\n", "resources-added=1\n"} {
+		if !strings.Contains(string(outputs), want) {
+			t.Errorf("step outputs missing %q:\n%s", want, outputs)
+		}
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	got := escapeProperty("a,b:c%\nd")
+	if want := "a%2Cb%3Ac%25%0Ad"; got != want {
+		t.Errorf("escapeProperty() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/notify"
+	"github.com/dlactin/rdv/internal/report"
+)
+
+var (
+	githubWebhookSecretFlag string
+	githubTokenFlag         string
+	githubAppIDFlag         int64
+	githubAppKeyFlag        string
+	githubAPIURLFlag        string
+)
+
+// checkRunName is the name of the check run the bot posts on pull requests
+const checkRunName = "rdv"
+
+// githubBot diffs pull requests on pull_request webhooks for 'rdv serve'
+// and posts the result as a check run and a pull request comment
+type githubBot struct {
+	secret string
+	apiURL string
+	// app authenticates as a GitHub App installation, token is used when
+	// it's nil
+	app   *githubapp.App
+	token string
+	// slots limits the number of pull requests diffed at the same time
+	slots chan struct{}
+}
+
+// newGitHubBot returns the webhook handler configured by the --github-* flags
+func newGitHubBot() (*githubBot, error) {
+	bot := &githubBot{
+		secret: githubWebhookSecretFlag,
+		apiURL: githubAPIURLFlag,
+		token:  githubTokenFlag,
+		slots:  make(chan struct{}, max(serveConcurrencyFlag, 1)),
+	}
+
+	switch {
+	case githubAppIDFlag != 0 && githubAppKeyFlag != "":
+		app, err := githubapp.LoadApp(githubAppIDFlag, githubAppKeyFlag)
+		if err != nil {
+			return nil, err
+		}
+		bot.app = app
+	case githubAppIDFlag != 0 || githubAppKeyFlag != "":
+		return nil, errors.New("--github-app-id and --github-app-key must be set together")
+	case githubTokenFlag == "":
+		return nil, errors.New("--github-webhook-secret requires --github-app-id and --github-app-key, or --github-token")
+	default:
+		log.Printf("Warning: authenticating with --github-token, check runs are only posted by GitHub Apps")
+	}
+	return bot, nil
+}
+
+func (b *githubBot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 25<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	if err := githubapp.VerifySignature(b.secret, body, r.Header.Get("X-Hub-Signature-256")); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		_, _ = w.Write([]byte("pong\n"))
+		return
+	case "pull_request":
+	default:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	var event githubapp.PullRequestEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, fmt.Sprintf("invalid pull_request payload: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !event.Diffable() {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// GitHub gives up on deliveries after 10 seconds, the diff is posted
+	// back through the API once it's done
+	go b.diffPullRequest(event)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// diffPullRequest renders the paths changed by a pull request, posts the
+// diff as a comment and completes the check run
+func (b *githubBot) diffPullRequest(event githubapp.PullRequestEvent) {
+	ctx := context.Background()
+	if requestTimeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeoutFlag)
+		defer cancel()
+	}
+
+	b.slots <- struct{}{}
+	defer func() { <-b.slots }()
+
+	repo, number := event.Repository.FullName, event.Number
+	client, err := b.client(ctx, event.Installation.ID)
+	if err != nil {
+		log.Printf("%s#%d: %v", repo, number, err)
+		return
+	}
+
+	var checkRun int64
+	if client.App {
+		checkRun, err = client.CreateCheckRun(ctx, repo, checkRunName, event.PullRequest.Head.SHA)
+		if err != nil {
+			log.Printf("%s#%d: %v", repo, number, err)
+		}
+	}
+
+	conclusion, title := "success", "Rendered manifests diffed"
+	var summary strings.Builder
+	rep, err := b.diff(ctx, client, event)
+	switch {
+	case err != nil:
+		conclusion, title = "failure", "Failed to render the changes"
+		fmt.Fprintf(&summary, "### Rendered manifest diff failed\n\n```\n%s\n```\n", mask.String(err.Error()))
+		log.Printf("%s#%d: %v", repo, number, err)
+	case len(rep.Sections) == 0:
+		title = "No renderable paths changed"
+		summary.WriteString("No chart, kustomization or Timoni module is changed by this pull request.\n")
+	default:
+		totals := rep.Totals()
+		title = fmt.Sprintf("%d added, %d removed, %d modified resources", totals.Added, totals.Removed, totals.Modified)
+		if err := rep.WriteMarkdown(&summary); err != nil {
+			log.Printf("%s#%d: %v", repo, number, err)
+			return
+		}
+	}
+
+	// Pull requests that don't touch anything renderable don't get a
+	// comment, diffs too large for one are split over several
+	var postErr error
+	switch {
+	case err != nil:
+		postErr = client.UpsertComment(ctx, repo, number, summary.String())
+	case len(rep.Sections) > 0:
+		postErr = (&notify.GitHub{Client: client, Repo: repo, Number: number}).Notify(ctx, rep)
+	}
+	if postErr != nil {
+		log.Printf("%s#%d: %v", repo, number, postErr)
+	}
+	if checkRun != 0 {
+		if err := client.CompleteCheckRun(ctx, repo, checkRun, conclusion, title, summary.String()); err != nil {
+			log.Printf("%s#%d: %v", repo, number, err)
+		}
+	}
+	log.Printf("%s#%d: %s", repo, number, title)
+}
+
+// diff clones the merge-base and head of a pull request and diffs the
+// render paths its changed files belong to
+func (b *githubBot) diff(ctx context.Context, client *githubapp.Client, event githubapp.PullRequestEvent) (*report.Report, error) {
+	repo, head := event.Repository.FullName, event.PullRequest.Head
+	files, err := client.PullRequestFiles(ctx, repo, event.Number)
+	if err != nil {
+		return nil, err
+	}
+	base, err := client.MergeBase(ctx, repo, event.PullRequest.Base.SHA, head.SHA)
+	if err != nil {
+		return nil, err
+	}
+
+	cloneURL := client.CloneURL(event.Repository.CloneURL)
+	dirs, cleanup, err := cloneRefs(cloneURL, base, head.SHA)
+	if err != nil {
+		// The clone URL carries the token, keep it out of errors posted back
+		return nil, errors.New(strings.ReplaceAll(err.Error(), cloneURL, event.Repository.CloneURL))
+	}
+	defer cleanup()
+
+	// Paths removed by the pull request are only found in the base
+	seen := map[string]bool{}
+	var paths []string
+	for _, dir := range dirs {
+		for _, path := range diff.RenderRoots(dir, files) {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+
+	return diffClones(ctx, dirs, event.PullRequest.Base.Ref, head.Ref, paths, nil)
+}
+
+// client returns an API client for the installation that sent the
+// webhook, or for --github-token
+func (b *githubBot) client(ctx context.Context, installationID int64) (*githubapp.Client, error) {
+	if b.app != nil {
+		return b.app.InstallationClient(ctx, b.apiURL, installationID)
+	}
+	return githubapp.NewClient(b.apiURL, b.token), nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dlactin/rdv/internal/config"
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/vcs"
+	"github.com/spf13/cobra"
+)
+
+var (
+	stagedFlag bool
+	// hookRun is set by the hook command, the target side is exported from
+	// the object database instead of checked out in a fetched worktree
+	hookRun bool
+	// The hook flags below default differently from the root flags they set
+	hookRefFlag         string
+	hookValidateFlag    bool
+	hookRenderCacheFlag bool
+)
+
+// hookCmd diffs the staged changes against HEAD, for pre-commit hooks
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Diff the staged changes (or the working tree) against HEAD for pre-commit hooks",
+	Long: `hook renders --path from HEAD and from the index with --staged, or from the working tree
+without it, validates the local side and prints the diff. Broken templates and invalid manifests
+fail the hook before the commit lands.
+
+Nothing is fetched and no worktree is created, both sides are exported straight from git. The
+HEAD render is cached on disk by commit, so after the first commit on a branch only the staged
+side is rendered. With --staged the hook passes without rendering when nothing is staged.
+
+Run it from a pre-commit config:
+
+  - repo: local
+    hooks:
+      - id: rdv
+        name: rdv
+        entry: rdv hook --staged -p charts/app
+        language: system
+        pass_filenames: false`,
+	Args: cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		// The index is a git concept, jj has no staging area
+		var err error
+		repo, err = vcs.New("git")
+		if err != nil {
+			return err
+		}
+		repoRoot = repo.Root()
+		localRoot = repoRoot
+
+		cfg, err = config.Load(configFlag, repoRoot, debugFlag)
+		if err != nil {
+			return err
+		}
+		// Only the flags the hook takes are applied from the config file,
+		// the rest configure full diffs
+		flags := map[string]any{}
+		for name, value := range cfg.Flags {
+			if cmd.Flags().Lookup(name) != nil {
+				flags[name] = value
+			}
+		}
+		if err := applyConfigFlags(cmd, flags); err != nil {
+			return err
+		}
+
+		if err := mask.SetPatterns(cfg.Redact); err != nil {
+			return fmt.Errorf("config file: %w", err)
+		}
+		if err := helm.SetMirrors(cfg.RepoMirrors); err != nil {
+			return fmt.Errorf("config file: %w", err)
+		}
+		if err := setupTheme(cfg.Theme); err != nil {
+			return err
+		}
+
+		if err := validateFailOn(failOnFlag); err != nil {
+			return err
+		}
+
+		// HEAD is diffed as is, resolving it to an upstream branch would
+		// pull other people's changes into the diff
+		if _, err := git.RevParse(repoRoot, hookRefFlag); err != nil {
+			return err
+		}
+		fullRef = hookRefFlag
+		validateFlag = hookValidateFlag
+		renderCacheFlag = hookRenderCacheFlag
+		hookRun = true
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		if stagedFlag {
+			staged, err := git.HasStagedChanges(repoRoot)
+			if err != nil {
+				return err
+			}
+			if !staged {
+				log.Printf("No staged changes, skipping render.")
+				return nil
+			}
+		}
+		return runDiff(cmd)
+	},
+}
+
+func init() {
+	hookCmd.Flags().SortFlags = false
+
+	hookCmd.Flags().BoolVarP(&stagedFlag, "staged", "", false, "Render the local side from the staged index instead of the working tree")
+	hookCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	hookCmd.Flags().StringSliceVarP(&envFlag, "env", "e", []string{}, "Path to an environment overlay relative to --path, diffed in its own section (can be specified multiple times)")
+	hookCmd.Flags().StringVarP(&hookRefFlag, "ref", "r", "HEAD", "Git ref to diff against, used as is without looking up its remote-tracking branch")
+	hookCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	hookCmd.Flags().BoolVarP(&hookValidateFlag, "validate", "v", true, "Validate the local render with kubeconform")
+	hookCmd.Flags().StringSliceVarP(&failOnFlag, "fail-on", "", []string{failOnValidation, failOnPolicy, failOnRender, failOnRules}, "Failure categories that fail the hook with their exit code: diff, validation, policy, render and rules (the failRules of the config file)")
+	hookCmd.Flags().BoolVarP(&hookRenderCacheFlag, "render-cache", "", true, "Cache the target ref render on disk by commit and render flags")
+	hookCmd.Flags().AddFlagSet(newHelmFlagSet())
+	hookCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	hookCmd.Flags().BoolVarP(&statFlag, "stat", "", false, "Print a summary of the added, removed and modified resources instead of the diff")
+	hookCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	hookCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	hookCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	hookCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	hookCmd.Flags().StringVarP(&configFlag, "config", "c", "", "Path to the config file (defaults to .rdv.yaml in the repository root)")
+	hookCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(hookCmd)
+	rootCmd.AddCommand(hookCmd)
+}
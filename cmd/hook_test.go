@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hookRepo creates a git repository with a committed kustomization and
+// changes to the working directory
+func hookRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+	}
+
+	write("kustomization.yaml", "resources:\n  - configMap.yaml\n")
+	write("configMap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n")
+	git("init", "--quiet")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+
+	t.Chdir(dir)
+	return dir
+}
+
+func TestHookCmd(t *testing.T) {
+	testCases := []struct {
+		name string
+		// staged and unstaged are the ConfigMap names in the index and
+		// the working tree, empty leaves them unchanged
+		staged   string
+		unstaged string
+		args     []string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "Staged changes only",
+			staged:   "staged-map",
+			unstaged: "unstaged-map",
+			args:     []string{"--staged"},
+			want:     []string{"+  name: staged-map", "staged"},
+			notWant:  []string{"unstaged-map"},
+		},
+		{
+			name:     "Working tree without --staged",
+			staged:   "staged-map",
+			unstaged: "unstaged-map",
+			want:     []string{"+  name: unstaged-map"},
+		},
+		{
+			name:     "Nothing staged",
+			unstaged: "unstaged-map",
+			args:     []string{"--staged"},
+			notWant:  []string{"--- Diff"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := hookRepo(t)
+			configMap := filepath.Join(dir, "configMap.yaml")
+			if tc.staged != "" {
+				if err := os.WriteFile(configMap, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+tc.staged+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				cmd := exec.Command("git", "add", "configMap.yaml")
+				cmd.Dir = dir
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git add: %v\n%s", err, output)
+				}
+			}
+			if tc.unstaged != "" {
+				if err := os.WriteFile(configMap, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+tc.unstaged+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			args := append([]string{"hook", "--plain", "--validate=false"}, tc.args...)
+			stdout, stderr, err := executeCommand(context.Background(), args...)
+			if err != nil {
+				t.Fatalf("hook failed: %v\nStderr: %s", err, stderr)
+			}
+
+			for _, want := range tc.want {
+				if !strings.Contains(stdout, want) {
+					t.Errorf("Expected %q in stdout, got: %s", want, stdout)
+				}
+			}
+			for _, notWant := range tc.notWant {
+				if strings.Contains(stdout, notWant) {
+					t.Errorf("Didn't expect %q in stdout, got: %s", notWant, stdout)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// printImages prints the container images changed per workload between
+// the target and local renders. Image bumps are the most common change
+// and the one reviewers look for first.
+func printImages(t *target) error {
+	targetResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	changes, err := manifest.ImageChanges(targetResources, localResources)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n--- Images ---")
+	if len(changes) == 0 {
+		fmt.Println("No container images changed.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "WORKLOAD\tCONTAINER\tFROM\t\tTO")
+	for _, c := range changes {
+		from, to := c.From, c.To
+		if from == "" {
+			from = "(added)"
+		}
+		if to == "" {
+			to = "(removed)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t→\t%s\n", c.Workload, c.Container, from, to)
+	}
+
+	return tw.Flush()
+}
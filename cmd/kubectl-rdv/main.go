@@ -0,0 +1,10 @@
+// Command kubectl-rdv is rdv packaged as a kubectl plugin, run as
+// 'kubectl rdv'. It's the same binary as rdv under the name kubectl looks
+// up plugins by.
+package main
+
+import "github.com/dlactin/rdv/cmd"
+
+func main() {
+	cmd.Execute()
+}
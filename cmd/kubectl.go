@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// kubectlPluginName is the executable kubectl runs for 'kubectl rdv'
+const kubectlPluginName = "kubectl-rdv"
+
+// kubectlPluginEnv maps the cluster flags to the environment variables
+// kubectl passes its global flags to plugins in
+var kubectlPluginEnv = map[string]string{
+	"kubeconfig": "KUBECTL_PLUGINS_GLOBAL_FLAG_KUBECONFIG",
+	"context":    "KUBECTL_PLUGINS_GLOBAL_FLAG_CONTEXT",
+	"namespace":  "KUBECTL_PLUGINS_CURRENT_NAMESPACE",
+}
+
+// isKubectlPlugin reports whether rdv was run as the kubectl-rdv plugin
+func isKubectlPlugin() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == kubectlPluginName
+}
+
+// setupKubectlPlugin makes the usage and completions of the kubectl-rdv
+// executable read 'kubectl rdv'
+func setupKubectlPlugin() {
+	if rootCmd.Annotations == nil {
+		rootCmd.Annotations = map[string]string{}
+	}
+	rootCmd.Annotations[cobra.CommandDisplayNameAnnotation] = "kubectl rdv"
+}
+
+// applyKubectlEnv sets the cluster flags of cmd that weren't passed on the
+// command line or through their RDV_ environment variable from the
+// environment kubectl sets for plugins. They count as passed, so the config
+// file doesn't override the cluster kubectl was pointed at.
+func applyKubectlEnv(cmd *cobra.Command) error {
+	for name, env := range kubectlPluginEnv {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if _, ok := os.LookupEnv(flagEnvName(name)); ok {
+			continue
+		}
+
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, env, err)
+		}
+	}
+	return nil
+}
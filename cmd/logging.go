@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"log/slog"
+
+	"github.com/dlactin/rdv/internal/logging"
+)
+
+// setupLogging sets the level and format of the log records written by
+// the git, Helm, kustomize and validation packages. --debug lowers the
+// level to debug, and --log-level debug turns on the verbose output of
+// --debug.
+func setupLogging() error {
+	level := slog.LevelInfo
+	if logLevelFlag != "" {
+		var err error
+		level, err = logging.ParseLevel(logLevelFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	if debugFlag {
+		level = slog.LevelDebug
+	} else if level == slog.LevelDebug {
+		debugFlag = true
+	}
+
+	return logging.Setup(level, logFormatFlag)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/network"
+)
+
+// setupOffline turns off every network access when --offline is set.
+// Flags that only work with network access fail right away instead of
+// halfway through the run.
+func setupOffline() error {
+	helm.SetOffline(offlineFlag)
+	git.SetOffline(offlineFlag)
+	if !offlineFlag {
+		return nil
+	}
+
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"--update", updateFlag},
+		{"--resolve-refs", resolveRefsFlag},
+		{"--server-dry-run", serverDryRunFlag},
+		{"--capabilities-from-cluster", capabilitiesFromClusterFlag},
+	} {
+		if f.set {
+			return fmt.Errorf("%s needs network access and can't be used with --offline", f.name)
+		}
+	}
+
+	// kubeconform downloads the upstream schemas unless every schema
+	// location is on disk
+	if validateFlag || validateTargetFlag {
+		if len(schemaLocationFlag) == 0 {
+			return fmt.Errorf("--validate downloads the Kubernetes schemas, pass --schema-location with a local schema directory to validate with --offline")
+		}
+		for _, location := range schemaLocationFlag {
+			if location == "default" || strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+				return fmt.Errorf("schema location '%s' needs network access, only local schema locations can be used with --offline", location)
+			}
+		}
+	}
+
+	// The flags may have come from the config file, after the recorder
+	// would have been started
+	if recorder == nil {
+		var err error
+		recorder, err = network.StartOffline()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withBlockedCalls adds the targets the recorder refused so far to a render
+// error, Helm and kustomize rarely say which host they couldn't reach
+func withBlockedCalls(err error) error {
+	if recorder == nil {
+		return err
+	}
+	blocked := recorder.Blocked()
+	if len(blocked) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w (blocked network calls: %s)", err, strings.Join(blocked, ", "))
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/progress"
+	"golang.org/x/term"
+)
+
+var noPagerFlag bool
+
+// pagerCommand returns the pager to pipe output through, from $PAGER
+// like git, or an empty string when paging is disabled
+func pagerCommand() string {
+	pager, ok := os.LookupEnv("PAGER")
+	if !ok {
+		return "less"
+	}
+
+	pager = strings.TrimSpace(pager)
+	if pager == "cat" {
+		return ""
+	}
+	return pager
+}
+
+// startPager pipes stdout and the logger through the pager when stdout is
+// a terminal. Log messages are paged with the diff so they don't garble
+// the pager's screen. The returned function waits for the pager to exit
+// and must be called before the process exits.
+func startPager() (func(), error) {
+	pager := pagerCommand()
+	if noPagerFlag || pager == "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return func() {}, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start pager: %w", err)
+	}
+
+	// Run through the shell like git so $PAGER can have arguments
+	pagerCmd := exec.Command("sh", "-c", pager)
+	pagerCmd.Stdin = r
+	pagerCmd.Stdout = os.Stdout
+	pagerCmd.Stderr = os.Stderr
+
+	// Quit if the output fits on one screen, keep colors and the screen contents
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		pagerCmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+
+	if err := pagerCmd.Start(); err != nil {
+		_ = r.Close()
+		_ = w.Close()
+		return nil, fmt.Errorf("failed to start pager %q: %w", pager, err)
+	}
+	_ = r.Close()
+
+	oldOut := os.Stdout
+	os.Stdout = w
+	log.SetOutput(w)
+	// A spinner on stderr would garble the pager's screen
+	progress.Setup(nil, debugFlag)
+
+	return func() {
+		_ = w.Close()
+		_ = pagerCmd.Wait()
+
+		os.Stdout = oldOut
+		log.SetOutput(os.Stderr)
+	}, nil
+}
+
+// setupProgress shows spinners for long running steps when stderr is a
+// terminal, step durations are logged with --debug
+func setupProgress() {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		progress.Setup(nil, debugFlag)
+		return
+	}
+
+	progress.Setup(os.Stderr, debugFlag)
+	log.SetOutput(progress.Writer(os.Stderr))
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPagerCommand(t *testing.T) {
+	testCases := []struct {
+		name  string
+		pager string
+		unset bool
+		want  string
+	}{
+		{name: "Unset defaults to less", unset: true, want: "less"},
+		{name: "Custom pager with arguments", pager: "less -S", want: "less -S"},
+		{name: "Empty disables paging", pager: "", want: ""},
+		{name: "cat disables paging", pager: "cat", want: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PAGER", tc.pager)
+			if tc.unset {
+				t.Setenv("PAGER", "")
+				_ = os.Unsetenv("PAGER")
+			}
+
+			if got := pagerCommand(); got != tc.want {
+				t.Errorf("pagerCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
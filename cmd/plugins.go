@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/spf13/cobra"
+)
+
+// pluginsDirEnv overrides the directory renderer plugins are loaded from
+const pluginsDirEnv = "RDV_PLUGINS_DIR"
+
+// pluginsDir returns the directory renderer plugins are loaded from,
+// $RDV_PLUGINS_DIR or rdv/plugins in the user config directory
+func pluginsDir() string {
+	if dir := os.Getenv(pluginsDirEnv); dir != "" {
+		return dir
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "rdv", "plugins")
+}
+
+// loadPlugins registers the renderer plugins, so they can be picked with
+// --renderer or by auto detection
+func loadPlugins() error {
+	dir := pluginsDir()
+	if dir == "" {
+		return nil
+	}
+
+	plugins, err := diff.LoadPlugins(dir)
+	if err != nil {
+		return err
+	}
+	diff.SetPlugins(plugins)
+	return nil
+}
+
+// completeRenderer completes --renderer with the built-in renderers and
+// the installed plugins
+func completeRenderer(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	_ = loadPlugins()
+	return diff.RendererNames(), cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/dlactin/rdv/internal/policy"
+)
+
+// policyDirs returns the Rego policy directories from --policy-dir
+// and the rule packs in the config
+func policyDirs() []string {
+	dirs := append([]string{}, policyDirFlag...)
+	if cfg != nil {
+		for _, pack := range cfg.Packs {
+			if dir, ok := pack.PolicyDir(); ok {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// checkPolicies evaluates the local render of the target against all
+// configured policies, prints the results and returns the findings
+func checkPolicies(t *target) ([]policy.Finding, error) {
+	dirs := policyDirs()
+	if len(dirs) == 0 && len(kyvernoPolicyFlag) == 0 {
+		return nil, nil
+	}
+
+	var findings []policy.Finding
+	if len(dirs) > 0 {
+		conftestFindings, err := policy.Conftest(dirs, t.localRender, debugFlag)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, conftestFindings...)
+	}
+
+	if len(kyvernoPolicyFlag) > 0 {
+		kyvernoFindings, err := policy.Kyverno(kyvernoPolicyFlag, t.localRender, debugFlag)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, kyvernoFindings...)
+	}
+
+	if err := policy.WriteFindings(os.Stdout, findings); err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+var renderOutputDirFlag string
+
+// renderCmd prints the local render of a path, the same manifests the
+// local side of a diff is built from
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the rendered manifests of a path",
+	Long: `render renders the chart or kustomization at --path and prints the manifests to stdout,
+after dependencies are built and values files are merged. No git ref is checked out, so
+it also works outside of a git repository.
+
+Log messages are written to stderr, so the output can be piped into other tools:
+
+  rdv render -p ./charts/app -f values-prod.yaml | kubectl apply --dry-run=client -f -
+
+With --output-dir every resource is written to its own kind/namespace/name.yaml file instead,
+for repositories following the rendered manifests pattern. YAML files in the directory that
+are no longer rendered are removed, so it can be committed as is.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		path, err := filepath.Abs(renderPathFlag)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+		}
+
+		render, err := diff.RenderManifests(path, renderOptions(path))
+		if err != nil {
+			return withExitCode(exitRender, fmt.Errorf("failed to render path: %w", err))
+		}
+		render, err = inject(render)
+		if err != nil {
+			return withExitCode(exitRender, err)
+		}
+
+		if err := checkDuplicates(render); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		if renderOutputDirFlag != "" {
+			n, err := manifest.WriteFiles(render, renderOutputDirFlag)
+			if err != nil {
+				return err
+			}
+			log.Printf("Wrote %d manifests to: %s", n, renderOutputDirFlag)
+			return nil
+		}
+
+		fmt.Print(render)
+		return nil
+	},
+}
+
+func init() {
+	renderCmd.Flags().SortFlags = false
+
+	renderCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	renderCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	renderCmd.Flags().StringVarP(&renderOutputDirFlag, "output-dir", "", "", "Write every resource to its own kind/namespace/name.yaml file below this directory instead of stdout, removing YAML files that are no longer rendered")
+	renderCmd.Flags().AddFlagSet(newHelmFlagSet())
+	renderCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	renderCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(renderCmd)
+	rootCmd.AddCommand(renderCmd)
+}
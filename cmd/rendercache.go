@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/rendercache"
+)
+
+// renderKey identifies a target render. Target refs are keyed by commit so
+// a moved branch is rendered again, the files in the checkout, values files
+// included, are covered by the commit. Renders masked with another key
+// have other placeholders, so the key is part of it too.
+func renderKey(commit string, t *target) string {
+	var labels, annotations map[string]string
+	if cfg != nil {
+		labels, annotations = cfg.CommonLabels, cfg.CommonAnnotations
+	}
+	var caps helm.Capabilities
+	if capabilities != nil {
+		caps = *capabilities
+	}
+	return fmt.Sprintf("%s\x00%s\x00%q", commit, t.targetRelativePath, []any{
+		rendererFlag, valuesFlag, t.ciValues, showOnlyFlag, kustomizeOptions(),
+		updateFlag, argocdFlag, fluxFlag, remoteURLs, resolveRefsFlag,
+		injectNamespaceFlag, labels, annotations, caps, mask.KeyID(),
+	})
+}
+
+// lookupTargetRenders fills in the target renders found in the daemon's
+// warm cache or the --render-cache and reports if all of them were found
+func lookupTargetRenders(commit string, targets []*target, cache *rendercache.Cache) bool {
+	found := true
+
+	for _, t := range targets {
+		key := renderKey(commit, t)
+
+		t.targetRender, t.cached = "", false
+		if warm != nil {
+			t.targetRender, t.cached = warm.renders[key]
+		}
+		if !t.cached && cache != nil {
+			t.targetRender, t.cached = cache.Get(key)
+			if t.cached && debugFlag {
+				log.Printf("Using cached render of %s at %s", t.targetRelativePath, commit)
+			}
+		}
+
+		if !t.cached {
+			found = false
+		}
+	}
+
+	return found
+}
+
+// storeTargetRender adds a target render to the daemon's warm cache and
+// the --render-cache
+func storeTargetRender(commit string, t *target, cache *rendercache.Cache) {
+	key := renderKey(commit, t)
+
+	if warm != nil {
+		warm.renders[key] = t.targetRender
+	}
+	if cache != nil {
+		if err := cache.Put(key, t.targetRender); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/artifact"
+	"github.com/dlactin/rdv/internal/notify"
+	"github.com/dlactin/rdv/internal/report"
+)
+
+var (
+	htmlReportFlag         string
+	junitReportFlag        string
+	patchDirFlag           string
+	markdownReportFlag     string
+	markdownChunkBytesFlag int
+	slackWebhookFlag       string
+	slackChannelFlag       string
+	webhookFlag            string
+	webhookTemplateFlag    string
+	artifactURLFlag        string
+)
+
+// Built-in templates of --webhook-template, other values are the path of
+// a template file
+const (
+	webhookTemplateJSON  = "json"
+	webhookTemplateTeams = "teams"
+)
+
+// artifactBucket is the bucket of --artifact-url, nil without the flag
+var artifactBucket *artifact.Bucket
+
+// setupArtifacts opens the bucket of --artifact-url, so missing
+// credentials fail before rendering
+func setupArtifacts() error {
+	artifactBucket = nil
+	if artifactURLFlag == "" {
+		return nil
+	}
+
+	var err error
+	artifactBucket, err = artifact.Open(artifactURLFlag)
+	return err
+}
+
+// namedReporter is a Reporter with the name of its target for the log
+type namedReporter struct {
+	name string
+	notify.Reporter
+}
+
+// reporters publish the report of a run, set up from the notification
+// flags by setupReporters
+var reporters []namedReporter
+
+// setupReporters sets up the Reporters of the notification flags, webhook
+// templates are parsed up front so they fail before rendering
+func setupReporters() error {
+	reporters = nil
+	link := ciRunURL()
+
+	if slackWebhookFlag != "" {
+		reporters = append(reporters, namedReporter{"Slack", &notify.Slack{WebhookURL: slackWebhookFlag, Channel: slackChannelFlag, Link: link}})
+	}
+
+	if webhookFlag != "" {
+		switch webhookTemplateFlag {
+		case webhookTemplateJSON:
+			reporters = append(reporters, namedReporter{"the webhook", &notify.Webhook{URL: webhookFlag, Link: link}})
+		case webhookTemplateTeams:
+			reporters = append(reporters, namedReporter{"Teams", &notify.Teams{WebhookURL: webhookFlag, Link: link}})
+		default:
+			tmpl, err := notify.ParseTemplate(webhookTemplateFlag)
+			if err != nil {
+				return err
+			}
+			reporters = append(reporters, namedReporter{"the webhook", &notify.Webhook{URL: webhookFlag, Link: link, Template: tmpl}})
+		}
+	}
+
+	return nil
+}
+
+// newDiffReport returns a report collecting the diff of every target
+// when a report file or notification is requested, nil otherwise
+func newDiffReport() *report.Report {
+	if htmlReportFlag == "" && junitReportFlag == "" && patchDirFlag == "" && markdownReportFlag == "" && artifactBucket == nil && len(reporters) == 0 && !githubActions() {
+		return nil
+	}
+	return &report.Report{From: fullRef, To: localRef()}
+}
+
+// writeDiffReports writes the requested report files, uploads them to
+// --artifact-url and publishes the report with the reporters
+func writeDiffReports(r *report.Report) error {
+	if htmlReportFlag != "" {
+		if err := r.WriteHTMLFile(htmlReportFlag); err != nil {
+			return err
+		}
+		log.Printf("HTML report saved to: %s", htmlReportFlag)
+	}
+
+	if junitReportFlag != "" {
+		if err := r.WriteJUnitFile(junitReportFlag); err != nil {
+			return err
+		}
+		log.Printf("JUnit report saved to: %s", junitReportFlag)
+	}
+
+	if patchDirFlag != "" {
+		if err := r.WritePatchDir(patchDirFlag); err != nil {
+			return err
+		}
+		log.Printf("Patch files saved to: %s", patchDirFlag)
+	}
+
+	if markdownReportFlag != "" {
+		paths, err := r.WriteMarkdownFiles(markdownReportFlag, markdownChunkBytesFlag)
+		if err != nil {
+			return err
+		}
+		log.Printf("Markdown report saved to: %s", strings.Join(paths, ", "))
+	}
+
+	var errs []error
+	if artifactBucket != nil {
+		if err := uploadDiffReports(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// A failing reporter doesn't keep the others from posting
+	for _, reporter := range reporters {
+		if err := reporter.Notify(context.Background(), r); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("Diff summary posted to %s", reporter.name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// uploadDiffReports uploads the HTML, markdown and JSON report to
+// --artifact-url and logs their URLs
+func uploadDiffReports(r *report.Report) error {
+	uploads := []struct {
+		name        string
+		contentType string
+		write       func(io.Writer) error
+	}{
+		{"report.html", "text/html; charset=utf-8", r.WriteHTML},
+		{"report.md", "text/markdown; charset=utf-8", r.WriteMarkdown},
+		{"report.json", "application/json", r.WriteJSON},
+	}
+
+	for _, u := range uploads {
+		var buf bytes.Buffer
+		if err := u.write(&buf); err != nil {
+			return fmt.Errorf("failed to write %s: %w", u.name, err)
+		}
+		url, err := artifactBucket.Upload(context.Background(), u.name, buf.Bytes(), u.contentType)
+		if err != nil {
+			return err
+		}
+		log.Printf("Report uploaded to: %s", url)
+	}
+	return nil
+}
+
+// ciRunURL returns the URL of the CI run or job, empty outside of GitHub
+// Actions, GitLab CI and Jenkins
+func ciRunURL() string {
+	if run := gitHubRunURL(); run != "" {
+		return run
+	}
+	for _, name := range []string{"CI_JOB_URL", "BUILD_URL"} {
+		if url := os.Getenv(name); url != "" {
+			return url
+		}
+	}
+	return ""
+}
@@ -4,40 +4,137 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"strings"
 	"syscall"
+	"time"
 
+	"github.com/dlactin/rdv/internal/cluster"
+	"github.com/dlactin/rdv/internal/config"
+	"github.com/dlactin/rdv/internal/deprecation"
 	"github.com/dlactin/rdv/internal/diff"
 	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/network"
+	"github.com/dlactin/rdv/internal/policy"
+	"github.com/dlactin/rdv/internal/progress"
+	"github.com/dlactin/rdv/internal/rendercache"
 	"github.com/dlactin/rdv/internal/validate"
+	"github.com/dlactin/rdv/internal/vcs"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	"golang.org/x/sync/errgroup"
 )
 
 // Package vars
 // Includes flag vars and some set during PreRun
 var (
-	valuesFlag       []string
-	renderPathFlag   string
-	gitRefFlag       string
-	updateFlag       bool
-	debugFlag        bool
-	validateFlag     bool
-	semanticDiffFlag bool
-	plainFlag        bool
-	outputPathFlag   string
-
-	repoRoot string
-	fullRef  string
+	valuesFlag               []string
+	showOnlyFlag             []string
+	renderPathFlag           string
+	gitRefFlag               string
+	updateFlag               bool
+	resolveRefsFlag          bool
+	injectNamespaceFlag      string
+	debugFlag                bool
+	validateFlag             bool
+	validateTargetFlag       bool
+	schemaLocationFlag       []string
+	semanticDiffFlag         bool
+	plainFlag                bool
+	themeFlag                string
+	colorFlag                string
+	widthFlag                int
+	maxOutputBytesFlag       int
+	outputPathFlag           string
+	countsFlag               bool
+	imagesFlag               bool
+	accessibleFlag           bool
+	vcsFlag                  string
+	configFlag               string
+	profileFlag              string
+	rendererFlag             string
+	envFlag                  []string
+	ciValuesFlag             bool
+	checkVersionFlag         bool
+	netReportFlag            bool
+	netAllowFlag             []string
+	fetchRetriesFlag         int
+	fetchTimeoutFlag         time.Duration
+	offlineFlag              bool
+	validationReportFlag     string
+	timingReportFlag         string
+	policyDirFlag            []string
+	kyvernoPolicyFlag        []string
+	kubeVersionFlag          string
+	scoreFlag                bool
+	serverDryRunFlag         bool
+	argocdFlag               bool
+	fluxFlag                 bool
+	fromFlag                 string
+	toFlag                   string
+	mergeBaseFlag            bool
+	threeWayFlag             bool
+	targetRepoFlag           string
+	targetPathFlag           string
+	worktreeCacheFlag        int
+	renderCacheFlag          bool
+	sparseFlag               bool
+	watchFlag                bool
+	unifiedFlag              int
+	fullContextFlag          bool
+	perResourceLinesFlag     int
+	includeFlag              []string
+	excludeFlag              []string
+	keepNoiseFlag            bool
+	ignoreWhitespaceFlag     bool
+	ignoreCommentsFlag       bool
+	ignoreSourceCommentsFlag bool
+	parallelFlag             int
+
+	repo     vcs.VCS
+	cfg      *config.Config
+	recorder *network.Recorder
+	// kubeVersion is the parsed --kube-version
+	kubeVersion deprecation.Version
+	// includeSelectors and excludeSelectors are the parsed --include and --exclude
+	includeSelectors []manifest.Selector
+	excludeSelectors []manifest.Selector
+	// remoteURLs are the remotes Argo CD and Flux sources are matched against
+	remoteURLs []string
+	// validationReport collects validation results when --validation-report is set
+	validationReport *validate.Report
+	repoRoot         string
+	fullRef          string
+	// toRef is the resolved --to ref, the working tree is diffed if empty
+	toRef string
+	// upstreamRef is the resolved target ref with --three-way, fullRef is
+	// its merge-base then
+	upstreamRef string
+	// localRoot is the checkout the local side is rendered from,
+	// the repository root unless --to is set
+	localRoot string
 )
 
+// resetRunState clears the package vars set while a command runs, so
+// every daemon request and test starts from a clean slate
+func resetRunState() {
+	repo, cfg, recorder, validationReport = nil, nil, nil, nil
+	repoRoot, fullRef, toRef, upstreamRef, localRoot = "", "", "", "", ""
+	includeSelectors, excludeSelectors, remoteURLs = nil, nil, nil
+	capabilities = nil
+	outputLimit = nil
+	reporters, artifactBucket = nil, nil
+	hookRun = false
+	mask.Reset()
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "rdv",
@@ -47,196 +144,739 @@ var rootCmd = &cobra.Command{
 It renders your local Helm charts or Kustomize overlays, validates the output against Kubernetes schemas (via kubeconform),
 and generates a colored diff comparing your local changes against a target Git reference (e.g., 'main').`,
 	Version: getVersion(),
-	PreRunE: func(cmd *cobra.Command, args []string) error {
+	// Runs for every subcommand too, the flags are only parsed for the
+	// command being run
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyEnv(cmd); err != nil {
+			return err
+		}
+		if err := applyKubectlEnv(cmd); err != nil {
+			return err
+		}
+		if err := setupLogging(); err != nil {
+			return err
+		}
+		if err := setupColor(); err != nil {
+			return err
+		}
+		if err := setupWidth(); err != nil {
+			return err
+		}
+		setupProgress()
+		return loadPlugins()
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) (err error) {
 		log.SetFlags(0) // Disabling timestamps for log output
 
-		// A local git installation is required
-		_, err := exec.LookPath("git")
+		// The daemon does all of the setup for forwarded invocations
+		if daemonFlag {
+			return nil
+		}
+
+		// The recorder is stopped by the run, which won't start if any of
+		// the setup fails
+		defer func() {
+			if err != nil && recorder != nil {
+				recorder.Stop()
+				recorder = nil
+			}
+		}()
+
+		// The network recorder must be started before any network calls are made
+		if netReportFlag || len(netAllowFlag) > 0 || offlineFlag {
+			// Go caches the proxy environment on first use, the daemon has already made calls
+			if warm != nil {
+				return fmt.Errorf("--network-report, --network-allow and --offline are not supported by the daemon")
+			}
+
+			if offlineFlag {
+				recorder, err = network.StartOffline()
+			} else {
+				recorder, err = network.Start(netAllowFlag)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// Find our version control backend, git unless a jj repository is detected
+		repo, err = vcs.New(vcsFlag)
 		if err != nil {
-			return fmt.Errorf("git not found in PATH: %w", err)
+			return err
 		}
 
-		// Get Git repository root
-		repoRoot, err = git.GetRepoRoot()
+		// Get repository root
+		repoRoot = repo.Root()
+		localRoot = repoRoot
+
+		// Load the optional config file and any rule packs it references
+		cfg, err = config.Load(configFlag, repoRoot, debugFlag)
+		if err != nil {
+			return err
+		}
+		// Flags are taken from the command line first, then RDV_ environment
+		// variables and then the config file
+		flags, err := cfg.ProfileFlags(profileFlag)
 		if err != nil {
 			return err
 		}
+		if err := applyConfigFlags(cmd, flags); err != nil {
+			return err
+		}
+		if profileFlag != "" {
+			log.Printf("Using profile '%s'", profileFlag)
+		}
 
-		// Try to find the upstream for our target ref
-		upstreamRef := exec.Command("git", "rev-parse", "--abbrev-ref", gitRefFlag+"@{u}")
-		upstreamRef.Dir = repoRoot
+		if err := mask.SetPatterns(cfg.Redact); err != nil {
+			return fmt.Errorf("config file: %w", err)
+		}
+		if err := helm.SetMirrors(cfg.RepoMirrors); err != nil {
+			return fmt.Errorf("config file: %w", err)
+		}
+		if err := setupTheme(cfg.Theme); err != nil {
+			return err
+		}
 
-		output, err := upstreamRef.CombinedOutput()
-		if err == nil {
-			fullRef = strings.TrimSpace(string(output))
-			if debugFlag {
-				log.Printf("Found upstream for '%s', using '%s'", gitRefFlag, fullRef)
+		if debugFlag {
+			for _, pack := range cfg.Packs {
+				log.Printf("Loaded rule pack '%s' version '%s' from %s", pack.Name, pack.Version, pack.Source)
+			}
+		}
+
+		if validationReportFlag != "" && !validateFlag && !validateTargetFlag {
+			return fmt.Errorf("--validation-report requires --validate or --validate-target")
+		}
+
+		if markdownChunkBytesFlag < 0 {
+			return fmt.Errorf("--markdown-chunk-bytes must be 0 or more, got %d", markdownChunkBytesFlag)
+		}
+		if err := setupArtifacts(); err != nil {
+			return err
+		}
+		if err := setupReporters(); err != nil {
+			return err
+		}
+
+		if maxOutputBytesFlag < 0 {
+			return fmt.Errorf("--max-output-bytes must be 0 or more, got %d", maxOutputBytesFlag)
+		}
+		if maxOutputBytesFlag > 0 && semanticDiffFlag {
+			return fmt.Errorf("--max-output-bytes cuts unified diffs at resource boundaries and can't be combined with --semantic")
+		}
+
+		if err := setupOffline(); err != nil {
+			return err
+		}
+
+		if kubeVersionFlag != "" {
+			kubeVersion, err = deprecation.ParseVersion(kubeVersionFlag)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Argo CD and Flux sources from these repositories are rendered from the checkout
+		if argocdFlag || fluxFlag {
+			remoteURLs, err = repo.Remotes()
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := validateFailOn(failOnFlag); err != nil {
+			return err
+		}
+
+		if parallelFlag < 1 {
+			return fmt.Errorf("--parallel must be at least 1, got %d", parallelFlag)
+		}
+
+		if unifiedFlag < 0 {
+			return fmt.Errorf("--unified must be 0 or more, got %d", unifiedFlag)
+		}
+		if perResourceLinesFlag < 0 {
+			return fmt.Errorf("--per-resource-lines must be 0 or more, got %d", perResourceLinesFlag)
+		}
+
+		// Catch malformed --show-only globs before we start rendering
+		for _, pattern := range showOnlyFlag {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid --show-only pattern %q: %w", pattern, err)
+			}
+		}
+
+		includeSelectors, err = parseSelectors(includeFlag)
+		if err != nil {
+			return err
+		}
+		excludeSelectors, err = parseSelectors(excludeFlag)
+		if err != nil {
+			return err
+		}
+
+		// --from is the target ref when comparing two refs
+		if fromFlag != "" {
+			if cmd.Flags().Changed("ref") {
+				return fmt.Errorf("--from and --ref can't be combined, --from replaces --ref")
+			}
+			gitRefFlag = fromFlag
+		}
+
+		// Only the working tree changes between runs
+		if watchFlag {
+			switch {
+			case toFlag != "":
+				return fmt.Errorf("--watch can't be used with --to, the local side must be the working tree")
+			case warm != nil:
+				return fmt.Errorf("--watch is not supported by the daemon")
+			}
+		}
+
+		// Sparse worktrees only contain what the target paths reference
+		if sparseFlag {
+			switch {
+			case targetRepoFlag != "":
+				return fmt.Errorf("--sparse can't be used with --target-repo")
+			case worktreeCacheFlag > 0:
+				return fmt.Errorf("--sparse can't be used with --worktree-cache")
+			case argocdFlag || fluxFlag:
+				return fmt.Errorf("--sparse can't be used with --argocd or --flux, their sources may be anywhere in the repository")
+			}
+		}
+
+		// Resolve and validate our target ref. Refs of another repository
+		// are only verified when it is cloned.
+		if targetRepoFlag != "" {
+			if mergeBaseFlag {
+				return fmt.Errorf("--merge-base can't be used with --target-repo")
+			}
+			if threeWayFlag {
+				return fmt.Errorf("--three-way can't be used with --target-repo")
 			}
-		} else {
 			fullRef = gitRefFlag
-			if debugFlag {
-				log.Printf("No upstream found for '%s', using local ref", fullRef)
+		} else {
+			fullRef, err = repo.ResolveRef(gitRefFlag)
+			if err != nil {
+				return err
 			}
 		}
 
-		// Validate our git ref exists
-		validateRef := exec.Command("git", "rev-parse", "--verify", "--quiet", fullRef)
-		validateRef.Dir = repoRoot
+		// --to replaces the working tree as the local side
+		if toFlag != "" {
+			toRef, err = repo.ResolveRef(toFlag)
+			if err != nil {
+				return err
+			}
+		}
 
-		if out, err := validateRef.CombinedOutput(); err != nil {
-			return fmt.Errorf("invalid or non-existent ref %q: %s", fullRef, strings.TrimSpace(string(out)))
+		// Diff against the branch point, so changes that landed on the
+		// target ref after it don't show up in the diff. --three-way shows
+		// those in their own section.
+		if mergeBaseFlag || threeWayFlag {
+			base, err := repo.MergeBase(fullRef, toRef)
+			if err != nil {
+				return err
+			}
+			// A short commit id keeps the diff headers readable
+			if len(base) > 12 {
+				base = base[:12]
+			}
+			log.Printf("Using merge-base '%s' of git ref '%s'", base, fullRef)
+			if threeWayFlag {
+				upstreamRef = fullRef
+			}
+			fullRef = base
 		}
 
 		return nil
 	},
 
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// The flags were checked in PreRunE, usage output would only bury
+		// diff, validation and policy failures
+		cmd.SilenceUsage = true
+
+		// Long diffs are paged like git does, except in watch mode where the
+		// pager would block the next run
+		if !watchFlag {
+			stopPager, err := startPager()
+			if err != nil {
+				return err
+			}
+			defer stopPager()
+		}
+
+		if daemonFlag {
+			return forwardToDaemon(cmd)
+		}
+
+		if recorder != nil {
+			defer func() {
+				recorder.Stop()
+				if netReportFlag {
+					_ = recorder.WriteReport(os.Stderr)
+				}
+			}()
+		}
+
+		if watchFlag {
+			return watchDiff(cmd)
+		}
+		return runDiff(cmd)
+	},
+}
+
+// runDiff renders every target on both sides and prints the diffs
+func runDiff(cmd *cobra.Command) error {
+	if targetRepoFlag != "" {
+		log.Printf("Starting diff against git ref '%s' of '%s':", fullRef, targetRepoFlag)
+	} else if toRef != "" {
+		log.Printf("Starting diff of git ref '%s' against git ref '%s':", toRef, fullRef)
+	} else if stagedFlag {
+		log.Printf("Starting diff of the staged changes against git ref '%s':", fullRef)
+	} else {
 		log.Printf("Starting diff against git ref '%s':", fullRef)
+	}
+
+	// Phase timings are reported when the run ends, failed or not
+	progress.ResetTimings()
+	if timingReportFlag != "" {
+		defer func() {
+			if err := progress.WriteTimingsFile(timingReportFlag); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+	if debugFlag {
+		defer func() {
+			log.Println("Timings:")
+			_ = progress.WriteTimings(log.Writer())
+		}()
+	}
 
-		// Get the absolute path from the path flag
-		absPath, err := filepath.Abs(renderPathFlag)
+	// Resource diffs past the limit are left out, the footer is printed
+	// after all of them
+	outputLimit = nil
+	if maxOutputBytesFlag > 0 {
+		limit, err := newOutputLimit(maxOutputBytesFlag)
 		if err != nil {
-			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+			return err
 		}
+		outputLimit = limit
+		defer func() {
+			limit.close()
+			outputLimit = nil
+		}()
+	}
 
-		// Get the relative path compared to the repoRoot)
-		relativePath, err := filepath.Rel(repoRoot, absPath)
+	// The report is written even if validation fails, that's when it's needed
+	if validationReportFlag != "" {
+		validationReport = &validate.Report{}
+		defer func() {
+			if err := validationReport.WriteFile(validationReportFlag); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+
+	targets, err := resolveTargets()
+	if err != nil {
+		return err
+	}
+	err = setTargetPaths(targets)
+	if err != nil {
+		return err
+	}
+	targets, err = expandCIValues(targets)
+	if err != nil {
+		return err
+	}
+
+	// Both renders are sent through the API server when --server-dry-run is
+	// set, Helm charts are rendered with the cluster's capabilities when
+	// --capabilities-from-cluster is. They're part of the render key.
+	var client *cluster.Client
+	if serverDryRunFlag || capabilitiesFromClusterFlag {
+		client, err = cluster.NewClient(kubeconfigFlag, kubeContextFlag, kubeNamespaceFlag)
 		if err != nil {
-			return fmt.Errorf("failed to resolve relative path for -path %w", err)
+			return err
 		}
+	}
+	if err := loadCapabilities(client); err != nil {
+		return err
+	}
+	if serverDryRunFlag {
+		log.Printf("Normalizing renders with a server-side dry-run against cluster '%s'", client.Context)
+	}
 
-		if strings.HasPrefix(relativePath, "..") {
-			return fmt.Errorf("the provided path '%s' (resolves to '%s') is outside the git repository root '%s'", renderPathFlag, absPath, repoRoot)
+	// The daemon and --render-cache keep target renders by commit, the
+	// target ref is only checked out when one of them is missing
+	var cache *rendercache.Cache
+	if renderCacheFlag {
+		cache, err = rendercache.New()
+		if err != nil {
+			return err
 		}
+		// Secrets in cached renders must be masked like in this run's
+		key, err := cache.MaskKey()
+		if err != nil {
+			return err
+		}
+		mask.SetKey(key)
+	}
+	cacheTargets := (warm != nil || cache != nil) && targetRepoFlag == ""
 
-		localPath := filepath.Join(repoRoot, relativePath)
-
-		// Resolve relative values file paths to absolute paths for the local render
-		// This means we only support values files located in the path provided
-		localValuesPaths := make([]string, len(valuesFlag))
-		for i, v := range valuesFlag {
-			localValuesPaths[i] = filepath.Join(localPath, v)
+	var commit string
+	needCheckout := true
+	if cacheTargets {
+		commit, err = repo.Commit(fullRef)
+		if err != nil {
+			return err
 		}
+		needCheckout = !lookupTargetRenders(commit, targets, cache)
+	}
 
-		// Setup temporary work tree for diffs
-		tempDir, cleanup, err := git.SetupWorkTree(repoRoot, fullRef)
+	var tempDir string
+	if needCheckout {
+		// Setup temporary work tree for diffs, or a clone of --target-repo
+		var cleanup func()
+		done := progress.StartPhase(progress.PhaseWorktree, "Checking out %s", fullRef)
+		if targetRepoFlag != "" {
+			tempDir, cleanup, err = git.ShallowClone(targetRepoFlag, fullRef)
+		} else if hookRun {
+			tempDir, cleanup, err = git.ExportTree(repoRoot, fullRef)
+		} else if sparseFlag {
+			tempDir, cleanup, err = sparseCheckout(targets)
+		} else if worktreeCacheFlag > 0 {
+			tempDir, cleanup, err = repo.CachedCheckout(fullRef, worktreeCacheFlag)
+		} else {
+			tempDir, cleanup, err = repo.Checkout(fullRef)
+		}
+		done()
 		if err != nil {
 			return err
 		}
 		// We want this to run after we have generated our diffs
 		defer cleanup()
 
-		targetPath := filepath.Join(tempDir, relativePath)
+		// Checking out fetches from the remotes, so the ref may have moved
+		if cacheTargets {
+			checkedOut, err := repo.Commit(fullRef)
+			if err != nil {
+				return err
+			}
+			if checkedOut != commit {
+				commit = checkedOut
+				lookupTargetRenders(commit, targets, cache)
+			}
+		}
+	}
+
+	// The target ref itself is rendered for the changes that landed on it
+	// since the merge-base
+	var upstreamDir string
+	if upstreamRef != "" {
+		var cleanup func()
+		done := progress.StartPhase(progress.PhaseWorktree, "Checking out %s", upstreamRef)
+		upstreamDir, cleanup, err = repo.Checkout(upstreamRef)
+		done()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
 
-		// Resolve values file paths for the worktree
-		targetValuesPaths := make([]string, len(valuesFlag))
-		for i, v := range valuesFlag {
-			targetValuesPaths[i] = filepath.Join(targetPath, v)
+	// Render the local side from a checkout of --to instead of the working tree
+	if toRef != "" {
+		var cleanup func()
+		done := progress.StartPhase(progress.PhaseWorktree, "Checking out %s", toRef)
+		localRoot, cleanup, err = repo.Checkout(toRef)
+		done()
+		if err != nil {
+			return err
 		}
+		defer cleanup()
+	}
 
-		// Create localRender and targetRender outside of goroutines
-		// Create errgroup for chart/kustomization rendering
-		var localRender, targetRender string
-		g := new(errgroup.Group)
+	// Render the local side from the staged index instead of the working tree
+	if stagedFlag {
+		var cleanup func()
+		done := progress.StartPhase(progress.PhaseWorktree, "Exporting the index")
+		localRoot, cleanup, err = git.ExportTree(repoRoot, "")
+		done()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+	}
 
-		// We only lint our local version
-		// Render local Chart or Kustomization
-		g.Go(func() error {
-			localRender, err = diff.RenderManifests(localPath, localValuesPaths, debugFlag, updateFlag, true)
+	// Create a single validator for the run so downloaded schemas are cached
+	// and shared between targets
+	var validator *validate.Validator
+	if validateFlag || validateTargetFlag {
+		if warm != nil && warm.validator != nil {
+			validator = warm.validator
+		} else {
+			validator, err = validate.NewValidator(debugFlag, schemaLocationFlag...)
 			if err != nil {
-				return fmt.Errorf("failed to render path in local ref: %w", err)
+				return err
 			}
+		}
+
+		if warm != nil {
+			warm.validator = validator
+		}
+	}
 
-			// Run local rendered manifests through kubeconform if --validate flag is passed
-			if validateFlag {
-				err = validate.ValidateManifests(localRender, debugFlag)
-				if err != nil {
-					return err
+	// Collect validation and policy findings for --sarif-report and GitHub annotations
+	findingsLog := newFindingsLog()
+	if sarifReportFlag != "" {
+		defer func() {
+			if err := findingsLog.WriteFile(sarifReportFlag, getVersion()); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+
+	// Collect the diffs for report files, they are written even if
+	// validation fails since that's when they're needed
+	diffReport := newDiffReport()
+	if diffReport != nil {
+		defer func() {
+			if err := writeDiffReports(diffReport); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+
+	// Write a step summary, outputs and annotations when running in GitHub Actions
+	if githubActions() {
+		defer func() {
+			if err := writeGitHubActions(diffReport, findingsLog); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+	}
+
+	// Render every target up front with --parallel, they are still
+	// printed in order below
+	var renderErrs []error
+	if parallelFlag > 1 && len(targets) > 1 {
+		renderErrs = renderAll(targets, tempDir, validator, parallelFlag)
+	}
+
+	var denied, violations, unbumped int
+	var changed bool
+	for i, t := range targets {
+		// Print a section per environment when diffing multiple targets
+		if len(targets) > 1 {
+			fmt.Printf("\n=== Environment: %s ===\n", t.name)
+		}
+
+		if renderErrs != nil {
+			err = renderErrs[i]
+		} else {
+			err = t.render(tempDir, validator)
+		}
+		if err == nil && upstreamDir != "" {
+			err = t.renderUpstream(upstreamDir)
+		}
+		if findingsLog != nil {
+			findingsLog.Add(t.validationResults()...)
+		}
+		if err != nil {
+			err = withBlockedCalls(err)
+			if diffReport != nil && t.localResults != nil {
+				_ = diffReport.Add(t.name, t.targetRender, t.localRender, t.localResults)
+			}
+			if !failsOn(failOnRender) {
+				log.Printf("Error: %s: %v", t.name, err)
+				continue
+			}
+			return withExitCode(exitRender, err)
+		}
+
+		if t.localInvalid != nil {
+			err = fmt.Errorf("local render: %w", t.localInvalid)
+			if failsOn(failOnValidation) {
+				if diffReport != nil {
+					_ = diffReport.Add(t.name, t.targetRender, t.localRender, t.localResults)
 				}
+				return withExitCode(exitValidation, err)
 			}
-			return nil
-		})
+			log.Printf("Warning: %v", err)
+		}
 
-		// Render target Ref Chart or Kustomization
-		g.Go(func() error {
-			targetRender, err = diff.RenderManifests(targetPath, targetValuesPaths, debugFlag, updateFlag, false)
+		if cacheTargets && !t.cached {
+			storeTargetRender(commit, t, cache)
+		}
+
+		if t.targetInvalid != nil {
+			log.Printf("Warning: target render (%s): %v", fullRef, t.targetInvalid)
+		}
+
+		if serverDryRunFlag {
+			err = serverDryRun(cmd.Context(), client, t)
 			if err != nil {
-				// If the path does not exist in the target ref
-				// We can assume it's a new addition and diff against
-				// an empty string instead.
-				if os.IsNotExist(err) {
-					targetRender = ""
-				} else {
-					return fmt.Errorf("failed to render target ref manifests: %w", err)
-				}
+				return err
 			}
-			return nil
-		})
+		}
 
-		// Ensure both rendering goroutines have finished before creating our diff
-		err = g.Wait()
+		// Focus the diff on the resources selected with --include and --exclude,
+		// without chart version noise
+		err = t.filter()
+		if err != nil {
+			return err
+		}
+
+		// --three-way prints what landed on the target ref since the
+		// merge-base before what this change introduces
+		if upstreamDir != "" {
+			fmt.Printf("\n=== Changes on %s since the merge-base %s ===\n", upstreamRef, fullRef)
+			if err := t.printUpstream(); err != nil {
+				return err
+			}
+			fmt.Printf("\n=== Changes introduced by %s ===\n", localRef())
+		}
+
+		// --stat replaces the diff with a summary of the changed resources
+		if statFlag {
+			err = t.printStat()
+		} else {
+			err = t.printDiff()
+		}
 		if err != nil {
 			return err
 		}
+		changed = changed || t.changed
 
-		if semanticDiffFlag {
-			// We are using a more complex diff engine (dyff) which is better suited for k8s manifest comparison
-			renderedDiff, err := diff.CreateSemanticDiff(targetRender, localRender, fmt.Sprintf("%s/%s", fullRef, relativePath), fmt.Sprintf("local/%s", relativePath), plainFlag)
+		// Charts whose render changed need a new version with --check-version
+		if checkVersionFlag && t.changed {
+			msg, err := checkVersionBump(t, tempDir)
 			if err != nil {
-				return fmt.Errorf("error creating dyff: %w", err)
+				return err
+			}
+			if msg != "" {
+				fmt.Printf("\n--- Chart Version ---\n%s\n", msg)
+				unbumped++
 			}
+		}
 
-			if len(renderedDiff.Diffs) == 0 {
-				fmt.Println("\nNo differences found between rendered manifests.")
-				return nil
-			} else {
-				fmt.Printf("\n--- Diff (%s vs. local) ---", fullRef)
-				err := renderedDiff.WriteReport(os.Stdout)
-				if err != nil {
-					return err
-				}
+		// Flag what both sides changed since the merge-base
+		if upstreamDir != "" {
+			err = printConflicts(t)
+			if err != nil {
+				return err
 			}
-		} else {
-			// Generate and Print our simple diff
-			// This is better suited for github comments, or small changes
-			renderedDiff := diff.CreateDiff(targetRender, localRender, fmt.Sprintf("%s/%s", fullRef, relativePath), fmt.Sprintf("local/%s", relativePath))
+		}
 
-			if renderedDiff == "" {
-				fmt.Println("\nNo differences found between rendered manifests.")
-			} else {
-				fmt.Printf("\n--- Diff (%s vs. local) ---\n", fullRef)
-				fmt.Println(diff.ColorizeDiff(renderedDiff, plainFlag))
+		if diffReport != nil {
+			err = diffReport.Add(t.name, t.targetRender, t.localRender, t.localResults)
+			if err != nil {
+				return err
+			}
+		}
 
+		// Check the changes against the fail rules of the config file
+		if cfg != nil && len(cfg.FailRules) > 0 {
+			n, err := checkFailRules(t)
+			if err != nil {
+				return err
 			}
+			violations += n
 		}
 
-		// Output rendered manifests to local files for other comparisons
-		if outputPathFlag != "" {
-			dir := filepath.Dir(outputPathFlag)
-			if dir != "." {
-				if err := os.MkdirAll(dir, 0755); err != nil {
-					return fmt.Errorf("failed to create output directory: %w", err)
-				}
+		// Subchart bumps are listed on their own, they are buried in the diff
+		err = printDependencies(t, tempDir)
+		if err != nil {
+			return err
+		}
+
+		// Summarize the container image bumps
+		if imagesFlag {
+			err = printImages(t)
+			if err != nil {
+				return err
 			}
+		}
 
-			// We are having static local/target file names for the render
-			localRenderFile := filepath.Join(outputPathFlag, "local.yaml")
-			err = os.WriteFile(localRenderFile, []byte(localRender), 0644)
+		// List resources using APIs deprecated or removed in the target Kubernetes version
+		if kubeVersionFlag != "" {
+			err = printDeprecations(t)
 			if err != nil {
-				return fmt.Errorf("failed to write output file to %s: %w", outputPathFlag, err)
+				return err
 			}
+		}
 
-			targetRenderFile := filepath.Join(outputPathFlag, "target.yaml")
-			err = os.WriteFile(targetRenderFile, []byte(targetRender), 0644)
+		// Run best-practice checks on the workloads this change touches
+		if scoreFlag {
+			err = printScores(t)
 			if err != nil {
-				return fmt.Errorf("failed to write output file to %s: %w", outputPathFlag, err)
+				return err
 			}
+		}
 
-			fmt.Printf("Rendered manifest saved to: %s\n", outputPathFlag)
+		// Evaluate the local render against Rego and Kyverno policies, reported after the diff
+		findings, err := checkPolicies(t)
+		if err != nil {
+			return err
+		}
+		denied += policy.Denials(findings)
+		if findingsLog != nil {
+			findingsLog.Add(t.policyResults(findings)...)
 		}
 
-		return nil
-	},
+		// Output rendered manifests to local files for other comparisons
+		if outputPathFlag != "" {
+			dir := outputPathFlag
+			if len(targets) > 1 {
+				dir = filepath.Join(outputPathFlag, t.name)
+			}
+
+			err = t.writeRenders(dir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Print the number of objects added and removed per kind across all targets
+	if countsFlag {
+		err = printResourceCounts(targets)
+		if err != nil {
+			return err
+		}
+	}
+
+	if denied > 0 {
+		err = fmt.Errorf("policy check failed with %d denials", denied)
+		if failsOn(failOnPolicy) {
+			return withExitCode(exitPolicy, err)
+		}
+		log.Printf("Warning: %v", err)
+	}
+
+	if violations > 0 {
+		err = fmt.Errorf("%d changes matched fail rules", violations)
+		if failsOn(failOnRules) {
+			return withExitCode(exitRules, err)
+		}
+		log.Printf("Warning: %v", err)
+	}
+
+	if unbumped > 0 {
+		err = fmt.Errorf("%d charts changed without a version bump", unbumped)
+		if failsOn(failOnVersion) {
+			return withExitCode(exitVersion, err)
+		}
+		log.Printf("Warning: %v", err)
+	}
+
+	if changed && failsOn(failOnDiff) {
+		return withExitCode(exitDiff, errors.New("differences found between rendered manifests"))
+	}
+
+	return nil
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -247,9 +887,13 @@ func Execute() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	if isKubectlPlugin() {
+		setupKubectlPlugin()
+	}
+
 	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -260,32 +904,111 @@ func init() {
 	coreFlags.SortFlags = false
 
 	coreFlags.StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	coreFlags.StringSliceVarP(&envFlag, "env", "e", []string{}, "Path to an environment overlay relative to --path, diffed in its own section (can be specified multiple times)")
+	coreFlags.BoolVarP(&ciValuesFlag, "ci-values", "", false, "Diff Helm charts once per ci/*-values.yaml file, like chart-testing, each in its own section")
+	coreFlags.BoolVarP(&checkVersionFlag, "check-version", "", false, "Check that the Chart.yaml version of Helm charts whose render changed was bumped, a warning unless --fail-on includes version")
 	coreFlags.StringVarP(&gitRefFlag, "ref", "r", "main", "Target Git ref to compare against. Will try to find its remote-tracking branch (e.g., origin/main)")
+	coreFlags.StringVarP(&fromFlag, "from", "", "", "Git ref to diff from, replaces --ref. Use with --to to compare two refs")
+	coreFlags.StringVarP(&toFlag, "to", "", "", "Git ref to diff to instead of the working tree")
+	coreFlags.BoolVarP(&mergeBaseFlag, "merge-base", "", false, "Diff against the merge-base of the target ref and HEAD (or --to) instead of the ref's tip")
+	coreFlags.BoolVarP(&threeWayFlag, "three-way", "", false, "Diff against the merge-base like --merge-base, and print the changes that landed on the target ref since then in their own section")
+	coreFlags.StringVarP(&targetRepoFlag, "target-repo", "", "", "Git repository URL to diff against, --ref is shallow cloned from it for the target side")
+	coreFlags.StringVarP(&targetPathFlag, "target-path", "", "", "Path of the chart or kustomization in the target side, relative to its repository root (defaults to the same path as --path)")
+	coreFlags.StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	coreFlags.BoolVarP(&argocdFlag, "argocd", "", false, "Render the sources of Argo CD Applications and ApplicationSets found in the render, so app-of-apps changes are diffed by what they deploy")
+	coreFlags.BoolVarP(&fluxFlag, "flux", "", false, "Build the Flux Kustomizations and HelmReleases found in the render, so Flux managed changes are diffed by what they deploy")
 	coreFlags.BoolVarP(&validateFlag, "validate", "v", false, "Validate rendered manifests with kubeconform")
+	coreFlags.StringSliceVarP(&schemaLocationFlag, "schema-location", "", []string{}, "kubeconform schema location used by validation instead of the upstream Kubernetes schemas, 'default' includes them (can be specified multiple times)")
+	coreFlags.BoolVarP(&validateTargetFlag, "validate-target", "", false, "Also validate the target ref render, failures are reported as warnings")
+	coreFlags.StringVarP(&kubeVersionFlag, "kube-version", "", "", "Report resources using APIs deprecated or removed in this Kubernetes version, e.g. 1.29")
+	coreFlags.StringSliceVarP(&policyDirFlag, "policy-dir", "", []string{}, "Directory of Rego policies evaluated against the local render with conftest (can be specified multiple times)")
+	coreFlags.StringSliceVarP(&kyvernoPolicyFlag, "kyverno-policy", "", []string{}, "Kyverno policy file or directory applied to the local render with the kyverno CLI (can be specified multiple times)")
+	coreFlags.StringSliceVarP(&failOnFlag, "fail-on", "", []string{failOnValidation, failOnPolicy, failOnRender, failOnRules}, "Failure categories that fail the run with their exit code: diff, validation, policy, render, rules (the failRules of the config file) and version (--check-version)")
+	coreFlags.BoolVarP(&serverDryRunFlag, "server-dry-run", "", false, "Submit both renders to the cluster with dry-run=server and diff the returned objects, so defaulting and admission webhooks are accounted for")
+	coreFlags.StringSliceVarP(&netAllowFlag, "network-allow", "", []string{}, "Only allow outbound connections to these hosts, globs are supported (can be specified multiple times)")
+	coreFlags.BoolVarP(&offlineFlag, "offline", "", false, "Forbid any network access: chart dependencies must be vendored in charts/, kustomizations can't use remote bases and --validate needs local --schema-location files")
+	coreFlags.IntVarP(&fetchRetriesFlag, "fetch-retries", "", 3, "Retry chart dependency downloads and remote kustomize bases failing with a network error this many times, with exponential backoff")
+	coreFlags.DurationVarP(&fetchTimeoutFlag, "fetch-timeout", "", 0, "Maximum duration of each chart dependency download or kustomize build with remote bases, retries included, 0 disables the limit")
+	coreFlags.IntVarP(&parallelFlag, "parallel", "", 1, "Number of renders, of the local or target side of a path, run at the same time with multiple --env flags or --ci-values. With 1 the paths are rendered one after another, both sides concurrently")
+	coreFlags.BoolVarP(&sparseFlag, "sparse", "", false, "Only check out the diffed paths and their local chart dependencies and kustomize references in the target worktree")
+	coreFlags.IntVarP(&worktreeCacheFlag, "worktree-cache", "", 0, "Reuse target ref worktrees across runs from a cache keyed by commit, keeping up to this many (0 disables the cache)")
+	coreFlags.BoolVarP(&renderCacheFlag, "render-cache", "", false, "Cache target ref renders on disk by commit and render flags, so repeated runs against the same commit skip the target render")
+	coreFlags.StringVarP(&configFlag, "config", "c", "", "Path to the config file (defaults to .rdv.yaml in the repository root)")
+	coreFlags.StringVarP(&profileFlag, "profile", "", "", "Profile of the config file to apply, e.g. the overlay, values files and Kubernetes version of an environment")
+	coreFlags.StringVarP(&vcsFlag, "vcs", "", "auto", "Version control backend to use: auto, git or jj")
+	coreFlags.BoolVarP(&watchFlag, "watch", "w", false, "Watch the local paths and print the diff again on every change, target renders are kept in memory between runs")
+	coreFlags.BoolVarP(&daemonFlag, "daemon", "", false, "Forward this diff to a running 'rdv daemon'")
 
 	// Helm flags
-	helmFlags := pflag.NewFlagSet("helm", pflag.ContinueOnError)
-	helmFlags.SortFlags = false
+	helmFlags := newHelmFlagSet()
+
+	// Kustomize flags
+	kustomizeFlags := newKustomizeFlagSet()
 
-	helmFlags.StringSliceVarP(&valuesFlag, "values", "f", []string{}, "Path to an additional values file (can be specified multiple times)")
-	helmFlags.BoolVarP(&updateFlag, "update", "u", false, "Update Helm chart dependencies. Required if lockfile does not match dependencies")
+	// Cluster flags
+	clusterFlags := newClusterFlagSet()
 
 	// Output flags
 	outputFlags := pflag.NewFlagSet("output", pflag.ContinueOnError)
 	outputFlags.SortFlags = false
 
 	outputFlags.BoolVarP(&semanticDiffFlag, "semantic", "s", false, "Enable semantic diffing of k8s manifests (using dyff)")
+	outputFlags.StringArrayVarP(&includeFlag, "include", "", []string{}, "Only diff resources matching this selector, e.g. 'kind=Deployment,name=api*' (can be specified multiple times)")
+	outputFlags.StringArrayVarP(&excludeFlag, "exclude", "", []string{}, "Don't diff resources matching this selector, e.g. 'kind=ConfigMap' (can be specified multiple times)")
+	outputFlags.BoolVarP(&keepNoiseFlag, "keep-noise", "", false, "Keep the helm.sh/chart and app.kubernetes.io/version labels and checksum/* annotations in the diff, they change on every chart bump")
+	outputFlags.BoolVarP(&ignoreWhitespaceFlag, "ignore-whitespace", "", false, "Ignore changes that only add or remove whitespace, like reindented lists or trailing newlines, in the unified diff")
+	outputFlags.BoolVarP(&ignoreCommentsFlag, "ignore-comments", "", false, "Remove YAML comments from both renders before diffing, except Helm's '# Source:' comments")
+	outputFlags.BoolVarP(&ignoreSourceCommentsFlag, "ignore-source-comments", "", false, "Remove Helm's '# Source:' comments too, implies --ignore-comments")
+	outputFlags.IntVarP(&unifiedFlag, "unified", "U", diff.DefaultContext, "Number of unchanged lines shown around each change in the diff")
+	outputFlags.BoolVarP(&fullContextFlag, "full-context", "", false, "Show the whole render around the changes in the diff, overrides --unified")
+	outputFlags.IntVarP(&perResourceLinesFlag, "per-resource-lines", "", diff.DefaultPerResourceLines, "Diff renders over this many lines combined resource by resource, with a header per changed resource, 0 never does. Doesn't apply to --semantic")
+	outputFlags.AddFlagSet(newSemanticFlagSet())
 	outputFlags.StringVarP(&outputPathFlag, "output", "o", "", "Write the local and target rendered manifests to a specific file path")
+	outputFlags.StringVarP(&htmlReportFlag, "html-report", "", "", "Write a self-contained HTML report with a collapsible diff per resource to this file")
+	outputFlags.StringVarP(&junitReportFlag, "junit-report", "", "", "Write a JUnit XML report with a test case per resource to this file: failed validation fails, changed passes, unchanged is skipped")
+	outputFlags.StringVarP(&patchDirFlag, "patch-dir", "", "", "Write the diff of every changed resource to its own Kind_namespace_name.patch file in this directory, in a subdirectory per target when there are several")
+	outputFlags.StringVarP(&markdownReportFlag, "markdown-report", "", "", "Write the report as GitHub flavored markdown to this file, split into numbered report.1.md, report.2.md, ... files on resource boundaries when it exceeds --markdown-chunk-bytes")
+	outputFlags.IntVarP(&markdownChunkBytesFlag, "markdown-chunk-bytes", "", githubapp.MaxCommentLength, "Largest markdown report file in bytes before it is split, GitHub's comment limit by default, 0 never splits")
+	outputFlags.StringVarP(&artifactURLFlag, "artifact-url", "", "", "Upload the HTML, markdown and JSON report to this bucket, s3://bucket/prefix/, gs://bucket/prefix/ or azblob://container/prefix/, and print their URLs")
+	outputFlags.StringVarP(&slackWebhookFlag, "slack-webhook", "", "", "Post a summary of the diff with a table of the changed resources to this Slack incoming webhook, e.g. from RDV_SLACK_WEBHOOK")
+	outputFlags.StringVarP(&slackChannelFlag, "slack-channel", "", "", "Post to this Slack channel instead of the webhook's default, e.g. '#infra'")
+	outputFlags.StringVarP(&webhookFlag, "webhook", "", "", "Post a summary of the diff as JSON to this webhook after the run, in the format of --webhook-template, e.g. from RDV_WEBHOOK")
+	outputFlags.StringVarP(&webhookTemplateFlag, "webhook-template", "", webhookTemplateJSON, "Body posted to --webhook: json for the summary as JSON, teams for a Microsoft Teams Adaptive Card, or the path of a Go template rendering JSON from the summary")
+	outputFlags.StringVarP(&sarifReportFlag, "sarif-report", "", "", "Write validation and policy findings of the local render to this file as SARIF, reported against the templates that produced them")
+	outputFlags.StringVarP(&validationReportFlag, "validation-report", "", "", "Write validation results to this file, as JUnit XML for .xml files and JSON otherwise")
+	outputFlags.BoolVarP(&scoreFlag, "score", "", false, "Run best-practice checks (probes, resources, image tags, security context, PDBs) on added or modified workloads")
+	outputFlags.BoolVarP(&statFlag, "stat", "", false, "Print a summary of the added, removed and modified resources with the lines changed in each instead of the diff")
+	outputFlags.BoolVarP(&imagesFlag, "images", "", false, "Print the container images changed per workload, from repo:tag to repo:tag")
+	outputFlags.BoolVarP(&countsFlag, "resource-counts", "", false, "Print the number of resources per kind on each side and how many were added and removed")
+	outputFlags.IntVarP(&maxOutputBytesFlag, "max-output-bytes", "", 0, "Limit the diff output to this many bytes, e.g. 60000 for a GitHub comment. Diffs are cut at resource boundaries and a footer names the file with the full diff")
+	outputFlags.StringVarP(&timingReportFlag, "timing-report", "", "", "Write the time spent in each phase of the run (worktree setup, dependency build, renders, diff, validation) to this file as JSON, also logged with --debug")
+	outputFlags.BoolVarP(&netReportFlag, "network-report", "", false, "Print every outbound network call made during the run with its duration and size")
+	outputFlags.BoolVarP(&accessibleFlag, "accessible", "", false, "Prefix changed lines with ADDED:/REMOVED: instead of relying on color, for screen readers and logs without ANSI support")
+	outputFlags.BoolVarP(&noPagerFlag, "no-pager", "", false, "Don't pipe the output through $PAGER (less by default) when stdout is a terminal")
+	outputFlags.BoolVarP(&noGitHubActionsFlag, "no-github-actions", "", false, "Don't write a step summary, outputs and annotations when running in GitHub Actions")
 	outputFlags.BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
-	outputFlags.BoolVarP(&debugFlag, "debug", "", false, "Enable verbose logging for debugging")
+	outputFlags.StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	outputFlags.IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	outputFlags.StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	outputFlags.AddFlagSet(newLoggingFlagSet())
 
 	// Add our custom flagsets to our rootCMD
 	rootCmd.Flags().AddFlagSet(coreFlags)
 	rootCmd.Flags().AddFlagSet(helmFlags)
+	rootCmd.Flags().AddFlagSet(kustomizeFlags)
+	rootCmd.Flags().AddFlagSet(clusterFlags)
 	rootCmd.Flags().AddFlagSet(outputFlags)
+	registerCompletions(rootCmd)
+
+	// Subcommands keep the default cobra usage output
+	defaultUsage := rootCmd.UsageFunc()
 
 	// Clean up the help message to print our flag sets
 	rootCmd.SetUsageFunc(func(cmd *cobra.Command) error {
+		if cmd != rootCmd {
+			return defaultUsage(cmd)
+		}
+
 		out := cmd.OutOrStdout()
 
 		// Check for the auto-generated version flag
@@ -302,11 +1025,19 @@ func init() {
 		}
 
 		// Print the standard Usage header
-		_, err := fmt.Fprintf(out, "Usage:\n  %s [flags]\n", cmd.Use)
+		_, err := fmt.Fprintf(out, "Usage:\n  %s [flags]\n  %s [command]\n", cmd.Use, cmd.Use)
 		if err != nil {
 			return err
 		}
 
+		// Print available subcommands
+		_, _ = fmt.Fprintf(out, "\nAvailable Commands:\n")
+		for _, sub := range cmd.Commands() {
+			if sub.IsAvailableCommand() {
+				_, _ = fmt.Fprintf(out, "  %-15s %s\n", sub.Name(), sub.Short)
+			}
+		}
+
 		// Print global flags
 		_, _ = fmt.Fprintf(out, "\nCore Flags:\n")
 		_, err = fmt.Fprint(out, coreFlags.FlagUsages())
@@ -321,6 +1052,20 @@ func init() {
 			return err
 		}
 
+		// Print Kustomize flags
+		_, _ = fmt.Fprintf(out, "\nKustomize Flags:\n")
+		_, err = fmt.Fprint(out, kustomizeFlags.FlagUsages())
+		if err != nil {
+			return err
+		}
+
+		// Print cluster flags
+		_, _ = fmt.Fprintf(out, "\nCluster Flags:\n")
+		_, err = fmt.Fprint(out, clusterFlags.FlagUsages())
+		if err != nil {
+			return err
+		}
+
 		// Print output flags
 		_, _ = fmt.Fprintf(out, "\nOutput Flags:\n")
 		_, err = fmt.Fprint(out, outputFlags.FlagUsages())
@@ -1,11 +1,25 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/progress"
 )
 
 // resetFlags resets all package-level flag variables to their defaults.
@@ -14,13 +28,113 @@ import (
 func resetFlags() {
 	// Reset to default values from init()
 	renderPathFlag = "."
+	renderOutputDirFlag = ""
 	gitRefFlag = "HEAD"
 	valuesFlag = []string{}
+	showOnlyFlag = []string{}
+	resolveRefsFlag = false
+	updateFlag = false
+	injectNamespaceFlag = ""
+	imagesFlag = false
 	debugFlag = false
+	logLevelFlag = "info"
+	logFormatFlag = "text"
+	vcsFlag = "auto"
+	configFlag = ""
+	profileFlag = ""
+	schemaLocationFlag = []string{}
+	rendererFlag = "auto"
+	envFlag = []string{}
+	ciValuesFlag = false
+	checkVersionFlag = false
+	daemonFlag = false
+	validateTargetFlag = false
+	validationReportFlag = ""
+	timingReportFlag = ""
+	policyDirFlag = []string{}
+	kyvernoPolicyFlag = []string{}
+	kubeVersionFlag = ""
+	scoreFlag = false
+	serverDryRunFlag = false
+	argocdFlag = false
+	fluxFlag = false
+	fromFlag = ""
+	toFlag = ""
+	mergeBaseFlag = false
+	threeWayFlag = false
+	targetRepoFlag = ""
+	targetPathFlag = ""
+	worktreeCacheFlag = 0
+	renderCacheFlag = false
+	sparseFlag = false
+	watchFlag = false
+	noPagerFlag = false
+	statFlag = false
+	semanticDiffFlag = false
+	unifiedFlag = 3
+	perResourceLinesFlag = diff.DefaultPerResourceLines
+	fullContextFlag = false
+	includeFlag = []string{}
+	excludeFlag = []string{}
+	keepNoiseFlag = false
+	ignoreWhitespaceFlag = false
+	ignoreCommentsFlag = false
+	ignoreSourceCommentsFlag = false
+	htmlReportFlag = ""
+	junitReportFlag = ""
+	patchDirFlag = ""
+	markdownReportFlag = ""
+	markdownChunkBytesFlag = githubapp.MaxCommentLength
+	slackWebhookFlag = ""
+	slackChannelFlag = ""
+	webhookFlag = ""
+	webhookTemplateFlag = webhookTemplateJSON
+	artifactURLFlag = ""
+	snapshotUpdateFlag = false
+	snapshotCheckFlag = false
+	snapshotDirFlag = "snapshots"
+	snapshotNameFlag = ""
+	sarifReportFlag = ""
+	// CI runs these tests in GitHub Actions, keep them out of its step summary
+	noGitHubActionsFlag = true
+	parallelFlag = 1
+	failOnFlag = []string{failOnValidation, failOnPolicy, failOnRender, failOnRules}
+	semanticIgnoreOrderFlag = true
+	semanticIgnoreWhitespaceFlag = true
+	semanticDetectK8sFlag = true
+	semanticExcludeFlag = []string{}
+	semanticExcludeRegexpFlag = []string{}
+	kubeconfigFlag = ""
+	kubeContextFlag = ""
+	kubeNamespaceFlag = ""
+	capabilitiesFromClusterFlag = false
+	fetchRetriesFlag = 3
+	fetchTimeoutFlag = 0
+	offlineFlag = false
+	accessibleFlag = false
+	themeFlag = "default"
+	colorFlag = "auto"
+	widthFlag = 0
+	maxOutputBytesFlag = 0
+	plainFlag = false
+	enableHelmFlag = false
+	helmCommandFlag = ""
+	loadRestrictFlag = "rootOnly"
+	alphaPluginsFlag = false
+	enableExecFlag = false
+	fnNetworkFlag = false
+	fnMountsFlag = []string{}
+	fnEnvFlag = []string{}
+	fnAllowFlag = []string{}
+	netReportFlag = false
+	netAllowFlag = []string{}
+	stagedFlag = false
+	hookRefFlag = "HEAD"
+	hookValidateFlag = true
+	hookRenderCacheFlag = true
 
 	// Reset state variables set by PreRunE
-	repoRoot = ""
-	fullRef = ""
+	resetRunState()
 }
 
 // executeCommand is a helper to run the rootCmd with a given context and args.
@@ -85,6 +199,138 @@ func TestRootCmd(t *testing.T) {
 		}
 	})
 
+	t.Run("Render subcommand", func(t *testing.T) {
+		path := "../examples/kustomize/helloworld"
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skipf("Skipping test, example path not found: %s", path)
+		}
+
+		ctx := context.Background()
+		stdout, stderr, err := executeCommand(ctx, "render", "--path", path)
+
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+
+		if !strings.Contains(stdout, "name: the-map") || strings.Contains(stdout, "--- Diff") {
+			t.Errorf("Expected only the rendered manifests in stdout, got: %s", stdout)
+		}
+	})
+
+	t.Run("Render subcommand with an output directory", func(t *testing.T) {
+		path := "../examples/kustomize/helloworld"
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			t.Skipf("Skipping test, example path not found: %s", path)
+		}
+
+		dir := t.TempDir()
+		ctx := context.Background()
+		stdout, stderr, err := executeCommand(ctx, "render", "--path", path, "--output-dir", dir)
+
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("Expected no manifests in stdout, got: %s", stdout)
+		}
+
+		matches, _ := filepath.Glob(filepath.Join(dir, "configmap", "the-map*.yaml"))
+		if len(matches) != 1 {
+			t.Errorf("Expected the ConfigMap to be written to its own file, got %v", matches)
+		}
+	})
+
+	t.Run("diff-files subcommand", func(t *testing.T) {
+		dir := t.TempDir()
+		oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+		if err := os.WriteFile(oldPath, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(newPath, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		stdout, stderr, err := executeCommand(ctx, "diff-files", "--plain", "--fail-on", "diff", oldPath, newPath)
+
+		if exitCode(err) != exitDiff {
+			t.Fatalf("Expected exit code %d for a diff, got %d: %v\nStderr: %s", exitDiff, exitCode(err), err, stderr)
+		}
+
+		if !strings.Contains(stdout, "+  name: the-map2") {
+			t.Errorf("Expected diff output in stdout, got: %s", stdout)
+		}
+	})
+
+	t.Run("diff-files subcommand ignoring whitespace", func(t *testing.T) {
+		dir := t.TempDir()
+		oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+		if err := os.WriteFile(oldPath, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(newPath, []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n    name: the-map  \n\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		stdout, stderr, err := executeCommand(ctx, "diff-files", "--plain", "--fail-on", "diff", "--ignore-whitespace", oldPath, newPath)
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+
+		if !strings.Contains(stdout, "No differences found") {
+			t.Errorf("Expected no differences for a whitespace-only change, got: %s", stdout)
+		}
+	})
+
+	t.Run("diff-files subcommand ignoring comments", func(t *testing.T) {
+		dir := t.TempDir()
+		oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+		if err := os.WriteFile(oldPath, []byte("# Source: app/templates/cm.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map # old\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(newPath, []byte("# Source: app/templates/configmap.yaml\n# The map\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map # new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		stdout, stderr, err := executeCommand(ctx, "diff-files", "--plain", "--fail-on", "diff", "--ignore-source-comments", oldPath, newPath)
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+
+		if !strings.Contains(stdout, "No differences found") {
+			t.Errorf("Expected no differences for a comment-only change, got: %s", stdout)
+		}
+	})
+
+	t.Run("diff-files subcommand with duplicate resources", func(t *testing.T) {
+		dir := t.TempDir()
+		oldPath, newPath := filepath.Join(dir, "old.yaml"), filepath.Join(dir, "new.yaml")
+		doc := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n"
+		if err := os.WriteFile(oldPath, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(newPath, []byte(doc+"---\n"+doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		// Warnings are logged, which isn't redirected along with stderr
+		var logs bytes.Buffer
+		log.SetOutput(&logs)
+		defer log.SetOutput(os.Stderr)
+
+		ctx := context.Background()
+		_, stderr, err := executeCommand(ctx, "diff-files", "--plain", oldPath, newPath)
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+
+		if !strings.Contains(logs.String(), "duplicate resources: ConfigMap/the-map (v1) 2 times") {
+			t.Errorf("Expected a warning about the duplicate ConfigMap, got: %s", logs.String())
+		}
+	})
+
 	t.Run("PersistentPreRunE failure (invalid ref)", func(t *testing.T) {
 		ctx := context.Background()
 		_, _, err := executeCommand(ctx, "--ref", "this-ref-does-not-exist-12345")
@@ -98,6 +344,79 @@ func TestRootCmd(t *testing.T) {
 		}
 	})
 
+	t.Run("PreRunE failure (--from with --ref)", func(t *testing.T) {
+		ctx := context.Background()
+		_, _, err := executeCommand(ctx, "--from", "HEAD", "--ref", "HEAD")
+
+		if err == nil {
+			t.Fatal("Command succeeded, but expected an error for --from combined with --ref")
+		}
+
+		if !strings.Contains(err.Error(), "--from and --ref can't be combined") {
+			t.Errorf("Expected error message about --from and --ref, got: %v", err)
+		}
+	})
+
+	t.Run("PreRunE failure (invalid --fail-on)", func(t *testing.T) {
+		ctx := context.Background()
+		_, _, err := executeCommand(ctx, "--fail-on", "diff,everything")
+
+		if err == nil {
+			t.Fatal("Command succeeded, but expected an error for an invalid --fail-on category")
+		}
+
+		if !strings.Contains(err.Error(), "invalid --fail-on category 'everything'") {
+			t.Errorf("Expected error message about the --fail-on category, got: %v", err)
+		}
+	})
+
+	t.Run("PreRunE failure (invalid redact pattern)", func(t *testing.T) {
+		t.Cleanup(mask.Reset)
+		configPath := filepath.Join(t.TempDir(), "rdv.yaml")
+		if err := os.WriteFile(configPath, []byte("redact:\n  - 'token: ('\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err := executeCommand(context.Background(), "--config", configPath, "--ref", "HEAD")
+		if err == nil || !strings.Contains(err.Error(), "invalid redaction pattern") {
+			t.Errorf("Expected an invalid redaction pattern error, got: %v", err)
+		}
+	})
+
+	t.Run("Profile from the config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "rdv.yaml")
+		config := "flags:\n  path: ../examples/helm/helloworld\nprofiles:\n  kustomize:\n    path: ../examples/kustomize/helloworld\n"
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		stdout, stderr, err := executeCommand(context.Background(), "--config", configPath, "--profile", "kustomize", "--ref", "HEAD", "--stat")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if renderPathFlag != "../examples/kustomize/helloworld" {
+			t.Errorf("Expected the profile's path to override the flags section, got %q\nStdout: %s", renderPathFlag, stdout)
+		}
+
+		_, _, err = executeCommand(context.Background(), "--config", configPath, "--profile", "prod", "--ref", "HEAD")
+		if err == nil || !strings.Contains(err.Error(), "unknown profile 'prod', must be one of: kustomize") {
+			t.Errorf("Expected an unknown profile error, got: %v", err)
+		}
+	})
+
+	t.Run("PreRunE failure (--watch with --to)", func(t *testing.T) {
+		ctx := context.Background()
+		_, _, err := executeCommand(ctx, "--watch", "--to", "HEAD")
+
+		if err == nil {
+			t.Fatal("Command succeeded, but expected an error for --watch combined with --to")
+		}
+
+		if !strings.Contains(err.Error(), "--watch can't be used with --to") {
+			t.Errorf("Expected error message about --watch and --to, got: %v", err)
+		}
+	})
+
 	t.Run("RunE failure (path outside repo)", func(t *testing.T) {
 		// We use a path that is guaranteed to be outside the repo
 		path := os.TempDir()
@@ -113,4 +432,872 @@ func TestRootCmd(t *testing.T) {
 			t.Errorf("Expected error message about 'outside...root', got: %v", err)
 		}
 	})
+	t.Run("RunE failure (env outside repo)", func(t *testing.T) {
+		// Environments are resolved relative to --path
+		env, err := filepath.Rel(".", os.TempDir())
+		if err != nil {
+			t.Skipf("Skipping test, no relative path to temp dir: %v", err)
+		}
+
+		ctx := context.Background()
+		_, _, err = executeCommand(ctx, "--env", "overlays/dev", "--env", env)
+
+		if err == nil {
+			t.Fatalf("Command succeeded, but expected an error for env outside repo. Env: %s", env)
+		}
+
+		if !strings.Contains(err.Error(), "outside the git repository root") {
+			t.Errorf("Expected error message about 'outside...root', got: %v", err)
+		}
+	})
+}
+
+func TestThreeWay(t *testing.T) {
+	dir := hookRepo(t)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+	}
+	writeMap := func(name string) {
+		content := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// main and the feature branch both moved on from the merge-base
+	git("branch", "-M", "main")
+	git("checkout", "--quiet", "-b", "feature")
+	writeMap("feature-map")
+	git("commit", "--quiet", "-am", "feature")
+	git("checkout", "--quiet", "main")
+	if err := os.WriteFile(filepath.Join(dir, "secret.yaml"), []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: upstream-secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources:\n  - configMap.yaml\n  - secret.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "upstream")
+	git("checkout", "--quiet", "feature")
+
+	stdout, stderr, err := executeCommand(context.Background(), "--three-way", "--ref", "main", "--plain", "--validate=false", "--render-cache=false")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	upstream, local, found := strings.Cut(stdout, "=== Changes introduced by local ===")
+	if !found || !strings.Contains(upstream, "=== Changes on main since the merge-base") {
+		t.Fatalf("Expected a section per side, got: %s", stdout)
+	}
+	if !strings.Contains(upstream, "+  name: upstream-secret") || strings.Contains(upstream, "feature-map") {
+		t.Errorf("Expected only the changes on main in the first section, got: %s", upstream)
+	}
+	if !strings.Contains(local, "+  name: feature-map") || strings.Contains(local, "upstream-secret") {
+		t.Errorf("Expected only the changes of the branch in the second section, got: %s", local)
+	}
+	if !strings.Contains(local, "No resources changed on both sides.") {
+		t.Errorf("Expected no conflicts between the sides, got: %s", local)
+	}
+}
+
+func TestCIValues(t *testing.T) {
+	dir := hookRepo(t)
+	write := func(name, content string) {
+		path := filepath.Join(dir, "chart", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+	}
+
+	write("Chart.yaml", "apiVersion: v2\nname: chart\nversion: 0.1.0\n")
+	write("values.yaml", "size: default\n")
+	write("ci/large-values.yaml", "size: large\n")
+	write("ci/small-values.yaml", "size: small\n")
+	write("templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sized\ndata:\n  size: {{ .Values.size }}\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "chart")
+	write("templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sized\ndata:\n  size: {{ .Values.size }}-{{ .Values.size }}\n")
+
+	stdout, stderr, err := executeCommand(context.Background(), "--path", "chart", "--ci-values", "--plain", "--validate=false", "--render-cache=false")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	large, small, found := strings.Cut(stdout, "=== Environment: chart/ci/small-values.yaml ===")
+	if !found || !strings.Contains(large, "=== Environment: chart/ci/large-values.yaml ===") {
+		t.Fatalf("Expected a section per ci values file, got: %s", stdout)
+	}
+	if !strings.Contains(large, "+  size: large-large") {
+		t.Errorf("Expected the large variant to be rendered with its values, got: %s", large)
+	}
+	if !strings.Contains(small, "+  size: small-small") {
+		t.Errorf("Expected the small variant to be rendered with its values, got: %s", small)
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		version  string
+		wantCode int
+	}{
+		{name: "Chart version not bumped", version: "0.1.0", wantCode: exitVersion},
+		{name: "Chart version bumped", version: "0.2.0", wantCode: exitClean},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := hookRepo(t)
+			write := func(name, content string) {
+				path := filepath.Join(dir, "chart", name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			git := func(args ...string) {
+				cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+				cmd.Dir = dir
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("git %s: %v\n%s", args[0], err, output)
+				}
+			}
+
+			write("Chart.yaml", "apiVersion: v2\nname: chart\nversion: 0.1.0\n")
+			write("templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-chart-map\n")
+			git("add", ".")
+			git("commit", "--quiet", "-m", "chart")
+			write("Chart.yaml", "apiVersion: v2\nname: chart\nversion: "+tc.version+"\n")
+			write("templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-chart-map\ndata:\n  a: b\n")
+
+			stdout, stderr, err := executeCommand(context.Background(), "--path", "chart", "--check-version", "--fail-on", "version", "--plain", "--validate=false", "--render-cache=false")
+			if code := exitCode(err); code != tc.wantCode {
+				t.Fatalf("Expected exit code %d, got %d: %v\nStderr: %s", tc.wantCode, code, err, stderr)
+			}
+
+			if unbumped := strings.Contains(stdout, "wasn't bumped from 0.1.0"); unbumped != (tc.wantCode == exitVersion) {
+				t.Errorf("Expected the missing version bump to be reported %v, got: %s", tc.wantCode == exitVersion, stdout)
+			}
+		})
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	dir := hookRepo(t)
+	write := func(name, content string) {
+		path := filepath.Join(dir, "chart", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, output)
+		}
+	}
+	lock := func(version string) string {
+		return "dependencies:\n- name: redis\n  repository: https://charts.example.com\n  version: " + version + "\n"
+	}
+
+	write("Chart.yaml", "apiVersion: v2\nname: chart\nversion: 0.1.0\n")
+	write("Chart.lock", lock("17.1.0"))
+	write("templates/configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-chart-map\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "chart")
+	write("Chart.lock", lock("17.3.1"))
+
+	stdout, stderr, err := executeCommand(context.Background(), "--path", "chart", "--plain", "--validate=false", "--render-cache=false")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	_, table, found := strings.Cut(stdout, "--- Dependencies ---")
+	if !found {
+		t.Fatalf("Expected a dependencies table, got: %s", stdout)
+	}
+	if !strings.Contains(table, "redis  17.1.0  →  17.3.1  https://charts.example.com") {
+		t.Errorf("Expected the redis bump in the dependencies table, got: %s", table)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	dir := hookRepo(t)
+
+	testCases := []struct {
+		name    string
+		args    []string
+		remote  bool
+		wantErr string
+	}{
+		{
+			name: "Diffs without network access",
+			args: []string{"--validate=false"},
+		},
+		{
+			name:    "Rejects flags needing network access",
+			args:    []string{"--validate=false", "--update"},
+			wantErr: "--update needs network access and can't be used with --offline",
+		},
+		{
+			name:    "Rejects downloading schemas",
+			args:    []string{"--validate"},
+			wantErr: "pass --schema-location with a local schema directory",
+		},
+		{
+			name:    "Rejects remote bases",
+			args:    []string{"--validate=false"},
+			remote:  true,
+			wantErr: "offline, not fetching the remote bases",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resources := "resources:\n- configMap.yaml\n"
+			if tc.remote {
+				resources += "- https://github.com/kubernetes-sigs/kustomize//examples/helloWorld?ref=v5.0.0\n"
+			}
+			if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte(resources), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			args := append([]string{"--offline", "--plain", "--render-cache=false"}, tc.args...)
+			_, stderr, err := executeCommand(context.Background(), args...)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected an error containing %q, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Stops the recorder when the setup fails", func(t *testing.T) {
+		proxy := os.Getenv("HTTPS_PROXY")
+		_, _, err := executeCommand(context.Background(), "--offline", "--plain", "--parallel", "0")
+		if err == nil {
+			t.Fatal("Expected --parallel 0 to be rejected")
+		}
+		if recorder != nil {
+			t.Error("Expected the recorder to be stopped")
+		}
+		if got := os.Getenv("HTTPS_PROXY"); got != proxy {
+			t.Errorf("HTTPS_PROXY = %q, want it restored to %q", got, proxy)
+		}
+	})
+}
+
+func TestDuplicateResources(t *testing.T) {
+	dir := hookRepo(t)
+
+	// Two templates of a chart rendering the same ConfigMap
+	chart := filepath.Join(dir, "chart")
+	if err := os.MkdirAll(filepath.Join(chart, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"Chart.yaml":       "apiVersion: v2\nname: chart\nversion: 0.1.0\n",
+		"templates/a.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: twice\n",
+		"templates/b.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: twice\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chart, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Warnings are logged, which isn't redirected along with stderr
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	_, stderr, err := executeCommand(context.Background(), "--path", "chart", "--plain", "--render-cache=false")
+	if err != nil {
+		t.Fatalf("Duplicates failed the run without --validate: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(logs.String(), "Warning: chart: duplicate resources: ConfigMap/twice (v1) 2 times") {
+		t.Errorf("Expected a warning about the duplicate ConfigMap, got: %s", logs.String())
+	}
+}
+
+func TestSparse(t *testing.T) {
+	dir := hookRepo(t)
+
+	// A kustomization that doesn't exist on the target ref yet
+	app := filepath.Join(dir, "app")
+	if err := os.Mkdir(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app, "kustomization.yaml"), []byte("resources:\n- configMap.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(app, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new-map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := executeCommand(context.Background(), "--path", "app", "--sparse", "--plain", "--validate=false", "--render-cache=false")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+	if !strings.Contains(stdout, "+  name: new-map") {
+		t.Errorf("Expected the new ConfigMap to be added, got:\n%s", stdout)
+	}
+}
+
+func TestTimingReport(t *testing.T) {
+	dir := hookRepo(t)
+	reportPath := filepath.Join(dir, "timings.json")
+
+	_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--timing-report", reportPath)
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Expected a timing report: %v", err)
+	}
+	for _, phase := range []string{progress.PhaseWorktree, progress.PhaseLocalRender, progress.PhaseTargetRender, progress.PhaseDiff} {
+		if !strings.Contains(string(content), `"phase": "`+phase+`"`) {
+			t.Errorf("Expected the %s phase in the timing report, got:\n%s", phase, content)
+		}
+	}
+}
+
+func TestTheme(t *testing.T) {
+	hookRepo(t)
+	defer diff.SetTheme(diff.Themes[diff.ThemeDefault])
+
+	testCases := []struct {
+		name    string
+		theme   string
+		wantErr string
+	}{
+		{name: "Preset", theme: "colorblind"},
+		{name: "Preset and colors", theme: "colorblind,added=#0072b2,hunk=cyan"},
+		{name: "Unknown preset", theme: "solarized", wantErr: `unknown theme "solarized"`},
+		{name: "Two presets", theme: "default,colorblind", wantErr: "only one preset"},
+		{name: "Invalid color", theme: "removed=300", wantErr: "invalid removed color"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--theme", tc.theme)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestColor(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-new-map\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		args      []string
+		noColor   string
+		wantColor bool
+		wantErr   bool
+	}{
+		{name: "Auto without a terminal", args: []string{"--color", "auto"}},
+		{name: "Always", args: []string{"--color", "always"}, wantColor: true},
+		{name: "Always overrides NO_COLOR", args: []string{"--color", "always"}, noColor: "1", wantColor: true},
+		{name: "Never", args: []string{"--color", "never"}},
+		{name: "Plain overrides always", args: []string{"--color", "always", "--plain"}},
+		{name: "Invalid value", args: []string{"--color", "sometimes"}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tc.noColor)
+
+			args := append([]string{"--validate=false", "--render-cache=false"}, tc.args...)
+			stdout, stderr, err := executeCommand(context.Background(), args...)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+
+			if got := strings.Contains(stdout, "\033["); got != tc.wantColor {
+				t.Errorf("Expected escape sequences in the output to be %t, got:\n%q", tc.wantColor, stdout)
+			}
+		})
+	}
+}
+
+func TestWidth(t *testing.T) {
+	dir := hookRepo(t)
+	name := "the-map-" + strings.Repeat("x", 40)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: "+name+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		args    []string
+		columns string
+		wrapped bool
+	}{
+		{name: "Unknown width", wrapped: false},
+		{name: "Width flag", args: []string{"--width", "30"}, wrapped: true},
+		{name: "COLUMNS", columns: "30", wrapped: true},
+		{name: "Width flag overrides COLUMNS", args: []string{"--width", "200"}, columns: "30", wrapped: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("COLUMNS", tc.columns)
+
+			args := append([]string{"--plain", "--validate=false", "--render-cache=false"}, tc.args...)
+			stdout, stderr, err := executeCommand(context.Background(), args...)
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+
+			if got := !strings.Contains(stdout, "+  name: "+name); got != tc.wrapped {
+				t.Errorf("Expected the added name to be wrapped: %t, got:\n%s", tc.wrapped, stdout)
+			}
+		})
+	}
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	dir := hookRepo(t)
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("GITHUB_RUN_ID", "")
+
+	var maps strings.Builder
+	for _, name := range []string{"a", "b", "c"} {
+		fmt.Fprintf(&maps, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: map-%s\ndata:\n  key: value\n", name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte(maps.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		maxBytes  string
+		wantMaps  int
+		truncated bool
+	}{
+		{name: "No limit", maxBytes: "0", wantMaps: 3},
+		{name: "Large limit", maxBytes: "100000", wantMaps: 3},
+		{name: "Cut at a resource boundary", maxBytes: "400", wantMaps: 1, truncated: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--max-output-bytes", tc.maxBytes)
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+
+			if got := strings.Count(stdout, "+  name: map-"); got != tc.wantMaps {
+				t.Errorf("Expected %d ConfigMaps in the diff, got %d:\n%s", tc.wantMaps, got, stdout)
+			}
+
+			_, footer, found := strings.Cut(stdout, "… 2 more resources changed")
+			if found != tc.truncated {
+				t.Fatalf("Expected a truncation footer: %t, got:\n%s", tc.truncated, stdout)
+			}
+			if !found {
+				return
+			}
+			path := strings.TrimSpace(footer[strings.LastIndex(footer, " ")+1:])
+			full, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Expected the full diff in %s: %v", path, err)
+			}
+			if got := strings.Count(string(full), "+  name: map-"); got != 3 {
+				t.Errorf("Expected all 3 ConfigMaps in the full diff, got %d:\n%s", got, full)
+			}
+		})
+	}
+
+	t.Run("First resource over the limit", func(t *testing.T) {
+		stdout, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--max-output-bytes", "10")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+
+		for _, want := range []string{"--- Diff (HEAD vs. local) ---", "+ ConfigMap/map-a", "… 4 more resources changed"} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("Expected %q in the output, got:\n%s", want, stdout)
+			}
+		}
+	})
+
+	t.Run("Semantic diffs", func(t *testing.T) {
+		_, _, err := executeCommand(context.Background(), "--plain", "--validate=false", "--semantic", "--max-output-bytes", "400")
+		if err == nil || !strings.Contains(err.Error(), "can't be combined with --semantic") {
+			t.Errorf("Expected an error for --semantic, got %v", err)
+		}
+	})
+}
+
+func TestPerResourceLines(t *testing.T) {
+	dir := hookRepo(t)
+
+	var maps strings.Builder
+	for _, name := range []string{"a", "b"} {
+		fmt.Fprintf(&maps, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: map-%s\ndata:\n  key: value\n", name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte(maps.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		lines       string
+		wantHeaders int
+	}{
+		{name: "Whole render", lines: "0", wantHeaders: 1},
+		{name: "Resource by resource", lines: "10", wantHeaders: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--per-resource-lines", tc.lines)
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+			if got := strings.Count(stdout, "\n+++ "); got != tc.wantHeaders {
+				t.Errorf("Expected %d diff headers, got %d:\n%s", tc.wantHeaders, got, stdout)
+			}
+		})
+	}
+}
+
+func TestMarkdownReport(t *testing.T) {
+	dir := hookRepo(t)
+	out := t.TempDir()
+
+	var maps strings.Builder
+	for _, name := range []string{"a", "b", "c"} {
+		fmt.Fprintf(&maps, "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: map-%s\ndata:\n  key: value\n", name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte(maps.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		chunkBytes string
+		wantFiles  []string
+	}{
+		{name: "Fits in a comment", chunkBytes: "65000", wantFiles: []string{"report.md"}},
+		{name: "Split into chunks", chunkBytes: "600", wantFiles: []string{"report.1.md", "report.2.md", "report.3.md"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(out, "report.md")
+			_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--markdown-report", path, "--markdown-chunk-bytes", tc.chunkBytes)
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+
+			entries, err := os.ReadDir(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			maps := 0
+			for _, e := range entries {
+				names = append(names, e.Name())
+				data, err := os.ReadFile(filepath.Join(out, e.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				maps += strings.Count(string(data), "<code>ConfigMap/map-")
+			}
+			if !slices.Equal(names, tc.wantFiles) {
+				t.Errorf("Expected the files %v, got %v", tc.wantFiles, names)
+			}
+			if maps != 3 {
+				t.Errorf("Expected the 3 ConfigMaps in the report, got %d", maps)
+			}
+		})
+	}
+
+	t.Run("Negative chunk size", func(t *testing.T) {
+		_, _, err := executeCommand(context.Background(), "--plain", "--validate=false", "--markdown-report", filepath.Join(out, "report.md"), "--markdown-chunk-bytes", "-1")
+		if err == nil || !strings.Contains(err.Error(), "--markdown-chunk-bytes must be 0 or more") {
+			t.Errorf("Expected an error for a negative chunk size, got %v", err)
+		}
+	})
+}
+
+func TestSlackWebhook(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_REPOSITORY", "org/repo")
+	t.Setenv("GITHUB_RUN_ID", "42")
+
+	var payload struct {
+		Channel     string `json:"channel"`
+		Text        string `json:"text"`
+		Attachments []struct {
+			Text string `json:"text"`
+		} `json:"attachments"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--slack-webhook", srv.URL, "--slack-channel", "#infra")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	if payload.Channel != "#infra" {
+		t.Errorf("Expected the message in #infra, got %q", payload.Channel)
+	}
+	if !strings.Contains(payload.Text, "<https://github.com/org/repo/actions/runs/42|view the run>") {
+		t.Errorf("Expected a link to the run, got %q", payload.Text)
+	}
+	if len(payload.Attachments) != 1 || !strings.Contains(payload.Attachments[0].Text, "ConfigMap/the-map") {
+		t.Errorf("Expected the stat table of the ConfigMap, got %+v", payload.Attachments)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{name: "JSON summary", template: "json", want: `"resources":[{"name":"ConfigMap/the-map","kind":"ConfigMap","status":"modified"`},
+		{name: "Teams card", template: "teams", want: `"contentType":"application/vnd.microsoft.card.adaptive"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body = nil
+			_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--webhook", srv.URL, "--webhook-template", tc.template)
+			if err != nil {
+				t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("Expected %s in the webhook body, got %s", tc.want, body)
+			}
+		})
+	}
+
+	t.Run("Missing template", func(t *testing.T) {
+		body = nil
+		_, _, err := executeCommand(context.Background(), "--plain", "--validate=false", "--webhook", srv.URL, "--webhook-template", filepath.Join(dir, "missing.tmpl"))
+		if err == nil || !strings.Contains(err.Error(), "failed to read webhook template") {
+			t.Errorf("Expected an error for the missing template, got %v", err)
+		}
+		if body != nil {
+			t.Errorf("Expected nothing posted, got %s", body)
+		}
+	})
+}
+
+func TestArtifactURL(t *testing.T) {
+	dir := hookRepo(t)
+	if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	objects := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		objects[r.URL.Path] = string(body)
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	_, stderr, err := executeCommand(context.Background(), "--plain", "--validate=false", "--render-cache=false", "--artifact-url", "s3://reports/pr-42/")
+	if err != nil {
+		t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+	}
+
+	for _, name := range []string{"report.html", "report.md", "report.json"} {
+		path := "/reports/pr-42/" + name
+		if !strings.Contains(objects[path], "the-map") {
+			t.Errorf("Expected the diff in %s, got %q", path, objects[path])
+		}
+		if !strings.Contains(logs.String(), "Report uploaded to: "+srv.URL+path) {
+			t.Errorf("Expected the URL of %s in the output, got:\n%s", name, logs.String())
+		}
+	}
+
+	t.Run("Missing credentials", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "")
+		_, _, err := executeCommand(context.Background(), "--plain", "--validate=false", "--artifact-url", "s3://reports/pr-42/")
+		if err == nil || !strings.Contains(err.Error(), "need AWS_ACCESS_KEY_ID") {
+			t.Errorf("Expected an error for the missing credentials, got %v", err)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := hookRepo(t)
+	snapshot := filepath.Join("snapshots", "app.yaml")
+	writeMap := func(data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "configMap.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\n"+data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Missing snapshot", func(t *testing.T) {
+		_, _, err := executeCommand(context.Background(), "snapshot", "--plain", "--name", "app", "--check")
+		if err == nil || !strings.Contains(err.Error(), "write it with --update") {
+			t.Errorf("Expected an error for the missing snapshot, got %v", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		_, stderr, err := executeCommand(context.Background(), "snapshot", "--plain", "--name", "app", "--update")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		data, err := os.ReadFile(snapshot)
+		if err != nil {
+			t.Fatalf("Expected the snapshot at %s: %v", snapshot, err)
+		}
+		if !strings.Contains(string(data), "name: the-map") {
+			t.Errorf("Expected the render in the snapshot, got:\n%s", data)
+		}
+	})
+
+	t.Run("Check matching render", func(t *testing.T) {
+		stdout, stderr, err := executeCommand(context.Background(), "snapshot", "--plain", "--name", "app", "--check")
+		if err != nil {
+			t.Fatalf("Command failed unexpectedly: %v\nStderr: %s", err, stderr)
+		}
+		if stdout != "" {
+			t.Errorf("Expected no diff, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("Check changed render", func(t *testing.T) {
+		writeMap("data:\n  key: value\n")
+		defer writeMap("")
+
+		stdout, _, err := executeCommand(context.Background(), "snapshot", "--plain", "--name", "app", "--check")
+		if exitCode(err) != exitDiff {
+			t.Fatalf("Expected exit code %d, got %d: %v", exitDiff, exitCode(err), err)
+		}
+		if !strings.Contains(stdout, "--- snapshot/app") || !strings.Contains(stdout, "+  key: value") {
+			t.Errorf("Expected the diff against the snapshot, got:\n%s", stdout)
+		}
+	})
+
+	t.Run("Update or check is required", func(t *testing.T) {
+		_, _, err := executeCommand(context.Background(), "snapshot", "--update", "--check")
+		if err == nil || !strings.Contains(err.Error(), "exactly one of --update or --check") {
+			t.Errorf("Expected an error for --update and --check, got %v", err)
+		}
+	})
+}
+
+func TestSnapshotName(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		path    string
+		flag    string
+		want    string
+		wantErr bool
+	}{
+		{name: "Nested path", path: filepath.Join(cwd, "charts", "app"), want: "charts_app"},
+		{name: "Current directory", path: cwd, want: filepath.Base(cwd)},
+		{name: "Outside of the current directory", path: filepath.Join(filepath.Dir(cwd), "other"), want: "other"},
+		{name: "Name flag", path: cwd, flag: "app-prod", want: "app-prod"},
+		{name: "Name with a slash", path: cwd, flag: "app/prod", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshotNameFlag = tc.flag
+			defer func() { snapshotNameFlag = "" }()
+
+			got, err := snapshotName(tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("snapshotName() failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("snapshotName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/policy"
+	"github.com/dlactin/rdv/internal/sarif"
+	"github.com/dlactin/rdv/internal/validate"
+)
+
+var sarifReportFlag string
+
+// newFindingsLog returns a SARIF log when --sarif-report is set or the
+// findings are annotated in GitHub Actions, nil otherwise
+func newFindingsLog() *sarif.Log {
+	if sarifReportFlag == "" && !githubActions() {
+		return nil
+	}
+	return &sarif.Log{}
+}
+
+// validationResults converts the failed validations of the local render
+// to SARIF results
+func (t *target) validationResults() []sarif.Result {
+	var results []sarif.Result
+	for _, r := range t.localResults {
+		if r.Status != validate.StatusInvalid && r.Status != validate.StatusError {
+			continue
+		}
+		results = append(results, sarif.Result{
+			RuleID:  "kubeconform",
+			Level:   sarif.LevelError,
+			Message: fmt.Sprintf("%s: %s", r.ID(), r.Error),
+			Path:    t.sourceFile(r.Source),
+		})
+	}
+	return results
+}
+
+// policyResults converts policy findings on the local render to SARIF
+// results. Findings without a resource are reported against the chart or
+// kustomization.
+func (t *target) policyResults(findings []policy.Finding) []sarif.Result {
+	// The template of a resource comes from Helm's '# Source:' comments
+	sources := map[string]string{}
+	if resources, err := manifest.Parse(t.localRender); err == nil {
+		for _, r := range resources {
+			sources[r.String()] = r.Source
+		}
+	}
+
+	var results []sarif.Result
+	for _, f := range findings {
+		level := sarif.LevelError
+		if f.Severity == policy.SeverityWarn {
+			level = sarif.LevelWarning
+		}
+		results = append(results, sarif.Result{
+			RuleID:  f.Engine + "/" + f.Policy,
+			Level:   level,
+			Message: f.Message,
+			Path:    t.sourceFile(sources[f.Resource]),
+		})
+	}
+	return results
+}
+
+// sourceFile returns the repository relative path of the template in a
+// Helm '# Source:' comment, e.g. 'app/templates/deployment.yaml'. Resources
+// without a source, or a source that doesn't exist in the repository, are
+// reported against the Chart.yaml or kustomization file of the target.
+func (t *target) sourceFile(source string) string {
+	if _, path, ok := strings.Cut(source, "/"); ok {
+		file := filepath.Join(t.relativePath, path)
+		if _, err := os.Stat(filepath.Join(localRoot, file)); err == nil {
+			return filepath.ToSlash(file)
+		}
+	}
+
+	for _, name := range []string{"Chart.yaml", "kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		file := filepath.Join(t.relativePath, name)
+		if _, err := os.Stat(filepath.Join(localRoot, file)); err == nil {
+			return filepath.ToSlash(file)
+		}
+	}
+	return filepath.ToSlash(t.relativePath)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/score"
+)
+
+// printScores runs best-practice checks on the workloads that were added
+// or modified, so review feedback stays focused on what the change touches
+func printScores(t *target) error {
+	targetResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	results, err := score.Score(manifest.Changed(targetResources, localResources), localResources)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("\n--- Best Practices (changed resources) ---")
+	if len(results) == 0 {
+		fmt.Println("No changed workloads to score.")
+		return nil
+	}
+
+	for _, r := range results {
+		fmt.Printf("%s: %d/%d checks passed\n", r.Resource, r.Passed(), r.Checks)
+		for _, issue := range r.Issues {
+			fmt.Printf("  %s\n", issue)
+		}
+	}
+
+	return nil
+}
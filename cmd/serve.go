@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/progress"
+	"github.com/dlactin/rdv/internal/report"
+	"github.com/dlactin/rdv/internal/server"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	listenFlag           string
+	allowRepoFlag        []string
+	requestTimeoutFlag   time.Duration
+	serveConcurrencyFlag int
+)
+
+// serveCmd renders and diffs refs of any repository on request over HTTP
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve diffs between refs of a repository over an HTTP API",
+	Long: `serve starts an HTTP server that clones, renders and diffs two refs of a repository on
+request, so services and ChatOps bots can get a diff without waiting on CI.
+
+  POST /diff   {"repo": "https://github.com/org/repo", "base": "main", "ref": "feature",
+                "path": "charts/app", "values": ["values-prod.yaml"]}
+  GET /healthz
+
+base defaults to 'main' and path to the repository root. The diff is returned as JSON with
+the totals and a diff per changed resource, or as markdown with '?format=markdown'. Both refs
+are shallow cloned for every request, restrict the repositories with --allow-repo.
+
+With --github-webhook-secret, pull_request webhooks of a GitHub App (or repository webhooks
+with --github-token) are handled on POST /github/webhook. The paths changed by the pull request
+are rendered at its merge-base and head, and the diff is posted as a check run and as a pull
+request comment that is updated on every push.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(log.LstdFlags) // Timestamps are useful in server logs
+		cmd.SilenceUsage = true
+
+		// Concurrent requests would draw over each other's spinners
+		progress.Setup(nil, debugFlag)
+
+		mux := http.NewServeMux()
+		mux.Handle("/", server.New(serveDiff, server.Options{
+			AllowRepos:  allowRepoFlag,
+			Timeout:     requestTimeoutFlag,
+			Concurrency: serveConcurrencyFlag,
+		}))
+
+		// Pull requests are diffed on webhooks when the bot is configured
+		if githubWebhookSecretFlag != "" {
+			bot, err := newGitHubBot()
+			if err != nil {
+				return err
+			}
+			mux.Handle("POST /github/webhook", bot)
+			log.Printf("Handling GitHub pull_request webhooks on /github/webhook")
+		}
+
+		srv := &http.Server{
+			Addr:              listenFlag,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Finish the running requests on interrupt
+		go func() {
+			<-cmd.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			_ = srv.Shutdown(ctx)
+		}()
+
+		if len(allowRepoFlag) == 0 {
+			log.Printf("Warning: no --allow-repo set, any repository reachable from this host can be diffed")
+		}
+		log.Printf("rdv serve listening on %s", listenFlag)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	},
+}
+
+// serveDiff clones both refs of a request and diffs their renders
+func serveDiff(ctx context.Context, req server.Request) (*report.Report, error) {
+	dirs, cleanup, err := cloneRefs(req.Repo, req.Base, req.Ref)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	return diffClones(ctx, dirs, req.Base, req.Ref, []string{req.Path}, req.Values)
+}
+
+// cloneRefs shallow clones the base and head ref of repoURL at the same time
+func cloneRefs(repoURL, base, head string) ([2]string, func(), error) {
+	var dirs [2]string
+	var cleanups [2]func()
+	cleanup := func() {
+		for _, c := range cleanups {
+			if c != nil {
+				c()
+			}
+		}
+	}
+
+	g := new(errgroup.Group)
+	for i, ref := range []string{base, head} {
+		g.Go(func() error {
+			var err error
+			dirs[i], cleanups[i], err = git.ShallowClone(repoURL, ref)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		cleanup()
+		return dirs, nil, err
+	}
+	return dirs, cleanup, nil
+}
+
+// diffClones renders every path in the base and head clone and adds a
+// report section per path. values are Helm values files relative to
+// each path.
+func diffClones(ctx context.Context, dirs [2]string, base, head string, paths, values []string) (*report.Report, error) {
+	rep := &report.Report{From: base, To: head}
+	for _, relPath := range paths {
+		var renders [2]string
+		g, ctx := errgroup.WithContext(ctx)
+		for i, dir := range dirs {
+			g.Go(func() error {
+				path := filepath.Join(dir, relPath)
+				opts := renderOptions(path)
+				opts.Values = make([]string, len(values))
+				for j, v := range values {
+					opts.Values[j] = helm.ResolveValuesPath(path, v)
+				}
+
+				render, err := diff.RenderManifestsContext(ctx, path, opts)
+				if err != nil {
+					// The path may be added or removed by the change
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return fmt.Errorf("failed to render %s at '%s': %w", relPath, []string{base, head}[i], err)
+				}
+				renders[i] = manifest.RemoveNoise(mask.String(render), manifest.NoiseLabels, manifest.NoiseAnnotations)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+
+		if err := rep.Add(relPath, renders[0], renders[1], nil); err != nil {
+			return nil, err
+		}
+	}
+	return rep, nil
+}
+
+func init() {
+	serveCmd.Flags().SortFlags = false
+
+	serveCmd.Flags().StringVarP(&listenFlag, "listen", "", ":8080", "Address the HTTP server listens on")
+	serveCmd.Flags().StringSliceVarP(&allowRepoFlag, "allow-repo", "", []string{}, "Only diff repositories matching this glob, matched against host/path, e.g. 'github.com/org/*' (can be specified multiple times)")
+	serveCmd.Flags().DurationVarP(&requestTimeoutFlag, "request-timeout", "", 5*time.Minute, "Maximum duration of a diff request, 0 disables the limit")
+	serveCmd.Flags().IntVarP(&serveConcurrencyFlag, "concurrency", "", 2, "Number of diff requests handled at the same time, further requests wait")
+	serveCmd.Flags().StringVarP(&githubWebhookSecretFlag, "github-webhook-secret", "", "", "Secret of the GitHub webhook, enables diffing pull requests on POST /github/webhook")
+	serveCmd.Flags().Int64VarP(&githubAppIDFlag, "github-app-id", "", 0, "ID of the GitHub App the webhooks are delivered for")
+	serveCmd.Flags().StringVarP(&githubAppKeyFlag, "github-app-key", "", "", "Path to the PEM private key of the GitHub App")
+	serveCmd.Flags().StringVarP(&githubTokenFlag, "github-token", "", "", "GitHub token used instead of a GitHub App, only comments are posted since check runs require an App")
+	serveCmd.Flags().StringVarP(&githubAPIURLFlag, "github-api-url", "", githubapp.DefaultAPIURL, "GitHub API URL, e.g. https://github.example.com/api/v3 for GitHub Enterprise Server")
+	serveCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	serveCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	serveCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotUpdateFlag bool
+	snapshotCheckFlag  bool
+	snapshotDirFlag    string
+	snapshotNameFlag   string
+)
+
+// snapshotCmd keeps a golden file of the render of a path, so changes to
+// the output of a chart are caught independent of git refs
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Write or check a golden snapshot of the rendered manifests of a path",
+	Long: `snapshot renders the chart or kustomization at --path like render does and compares it
+against a snapshot committed to the repository, for golden file regression tests of charts.
+
+--update writes the render to --dir/<name>.yaml, --check diffs the render against it and
+fails with exit code 1 when they differ:
+
+  rdv snapshot -p ./charts/app -f values-prod.yaml --name app-prod --update
+  rdv snapshot -p ./charts/app -f values-prod.yaml --name app-prod --check
+
+The name defaults to the path relative to the current directory with its slashes replaced
+by underscores, charts_app for ./charts/app. Set --name to snapshot several values files
+of the same chart.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		if err := setupTheme(nil); err != nil {
+			return err
+		}
+
+		if snapshotUpdateFlag == snapshotCheckFlag {
+			return errors.New("exactly one of --update or --check is required")
+		}
+
+		path, err := filepath.Abs(renderPathFlag)
+		if err != nil {
+			return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+		}
+
+		name, err := snapshotName(path)
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(snapshotDirFlag, name+".yaml")
+
+		render, err := diff.RenderManifests(path, renderOptions(path))
+		if err != nil {
+			return withExitCode(exitRender, fmt.Errorf("failed to render path: %w", err))
+		}
+		render, err = inject(render)
+		if err != nil {
+			return withExitCode(exitRender, err)
+		}
+
+		snapshot, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		exists := err == nil
+
+		if snapshotUpdateFlag {
+			if exists && string(snapshot) == render {
+				log.Printf("Snapshot is up to date: %s", file)
+				return nil
+			}
+			if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+				return fmt.Errorf("failed to create snapshot directory: %w", err)
+			}
+			if err := os.WriteFile(file, []byte(render), 0o644); err != nil {
+				return fmt.Errorf("failed to write snapshot: %w", err)
+			}
+			log.Printf("Snapshot written to: %s", file)
+			return nil
+		}
+
+		if !exists {
+			return fmt.Errorf("no snapshot of '%s' at %s, write it with --update", renderPathFlag, file)
+		}
+
+		snapshotDiff := diff.CreateDiff(string(snapshot), render, "snapshot/"+name, "render/"+name)
+		if snapshotDiff == "" {
+			log.Printf("Render matches the snapshot: %s", file)
+			return nil
+		}
+
+		fmt.Println(colorize(snapshotDiff))
+		return withExitCode(exitDiff, fmt.Errorf("render of '%s' differs from the snapshot %s, run with --update if the change is expected", renderPathFlag, file))
+	},
+}
+
+// snapshotName returns --name, or the path relative to the current
+// directory with its slashes replaced by underscores. Paths outside of the
+// current directory are named by their last element.
+func snapshotName(path string) (string, error) {
+	if snapshotNameFlag != "" {
+		if strings.ContainsAny(snapshotNameFlag, `/\`) || snapshotNameFlag == "." || snapshotNameFlag == ".." {
+			return "", fmt.Errorf("--name '%s' must be a file name, without slashes", snapshotNameFlag)
+		}
+		return snapshotNameFlag, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return filepath.Base(path), nil
+	}
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "_"), nil
+}
+
+func init() {
+	snapshotCmd.Flags().SortFlags = false
+
+	snapshotCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory")
+	snapshotCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	snapshotCmd.Flags().BoolVarP(&snapshotUpdateFlag, "update", "", false, "Write the render to the snapshot")
+	snapshotCmd.Flags().BoolVarP(&snapshotCheckFlag, "check", "", false, "Diff the render against the snapshot and fail if they differ")
+	snapshotCmd.Flags().StringVarP(&snapshotDirFlag, "dir", "", "snapshots", "Directory the snapshots are kept in, commit it to the repository")
+	snapshotCmd.Flags().StringVarP(&snapshotNameFlag, "name", "", "", "Name of the snapshot file, without .yaml. Defaults to the path relative to the current directory with slashes replaced by underscores")
+	snapshotCmd.Flags().AddFlagSet(newHelmFlagSet())
+	snapshotCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	snapshotCmd.Flags().BoolVarP(&plainFlag, "plain", "", false, "Output in plain style without any highlighting")
+	snapshotCmd.Flags().StringVarP(&colorFlag, "color", "", colorAuto, "When to color the output: auto (only when stdout is a terminal and NO_COLOR isn't set), always or never")
+	snapshotCmd.Flags().IntVarP(&widthFlag, "width", "", 0, "Number of columns diffs are laid out for, longer unified diff lines are wrapped. Defaults to the width of the terminal or $COLUMNS, lines aren't wrapped if neither is known")
+	snapshotCmd.Flags().StringVarP(&themeFlag, "theme", "", diff.ThemeDefault, "Colors of the diff: a preset (default or colorblind), colors of added, removed, modified and hunk like 'added=#0072b2,removed=208', or both")
+	snapshotCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(snapshotCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
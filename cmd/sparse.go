@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/kustomize"
+)
+
+// sparseCheckout checks out the target ref with only the target paths,
+// and the local chart dependencies and kustomize references they need
+func sparseCheckout(targets []*target) (string, func(), error) {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		dir := filepath.ToSlash(t.targetRelativePath)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	worktree, add, cleanup, err := repo.SparseCheckout(fullRef, dirs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// References can point at more references, check out until none are missing
+	pending := dirs
+	for len(pending) > 0 {
+		var missing []string
+		for _, dir := range pending {
+			// Paths added since the target ref render empty, like they do
+			// in a full checkout
+			path := filepath.Join(worktree, dir)
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			refs, err := localReferences(path)
+			if err != nil {
+				cleanup()
+				return "", nil, err
+			}
+
+			for _, ref := range refs {
+				// Referenced files are checked out with their directory
+				if filepath.Ext(ref) != "" {
+					ref = filepath.Dir(ref)
+				}
+
+				rel, err := filepath.Rel(worktree, ref)
+				if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+					continue
+				}
+				rel = filepath.ToSlash(rel)
+				if !seen[rel] {
+					seen[rel] = true
+					missing = append(missing, rel)
+				}
+			}
+		}
+
+		if len(missing) > 0 {
+			if err := add(missing...); err != nil {
+				cleanup()
+				return "", nil, err
+			}
+		}
+		pending = missing
+	}
+
+	return worktree, cleanup, nil
+}
+
+// localReferences returns the files and directories outside of path
+// that a chart or kustomization at path needs to render
+func localReferences(path string) ([]string, error) {
+	deps, err := helm.LocalDependencies(path)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := kustomize.LocalReferences(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(deps, refs...), nil
+}
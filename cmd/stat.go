@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/progress"
+)
+
+var statFlag bool
+
+// printStat prints a summary of the resources changed between the target
+// ref and local render instead of the diff, with the lines changed in
+// each resource and totals
+func (t *target) printStat() error {
+	defer progress.Time(progress.PhaseDiff)()
+
+	targetResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	stats := manifest.Stat(targetResources, localResources)
+	if len(stats) == 0 {
+		fmt.Println("\nNo differences found between rendered manifests.")
+		return nil
+	}
+
+	t.changed = true
+
+	width := 0
+	for _, s := range stats {
+		width = max(width, len(s.Resource.String()))
+	}
+
+	fmt.Printf("\n--- Diff Stat (%s vs. %s) ---\n", fullRef, localRef())
+
+	counts := map[string]int{}
+	var added, removed int
+	for _, s := range stats {
+		fmt.Printf("  %-*s | %-8s +%d -%d\n", width, s.Resource, s.Status, s.Added, s.Removed)
+		counts[s.Status]++
+		added += s.Added
+		removed += s.Removed
+	}
+
+	fmt.Printf("  %d resources changed (%d added, %d removed, %d modified), %d lines added, %d lines removed\n",
+		len(stats), counts[manifest.StatusAdded], counts[manifest.StatusRemoved], counts[manifest.StatusModified], added, removed)
+
+	return nil
+}
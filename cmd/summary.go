@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// printResourceSummary lists the resources added and removed by the
+// change before the diff. New and deleted objects are the riskiest
+// changes and are easy to miss in a long diff.
+func (t *target) printResourceSummary() error {
+	targetResources, err := manifest.Parse(t.targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse target render for %s: %w", t.name, err)
+	}
+
+	localResources, err := manifest.Parse(t.localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", t.name, err)
+	}
+
+	var added, removed []string
+	for _, s := range manifest.Stat(targetResources, localResources) {
+		switch s.Status {
+		case manifest.StatusAdded:
+			added = append(added, "+ "+s.Resource.String())
+		case manifest.StatusRemoved:
+			removed = append(removed, "- "+s.Resource.String())
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	var summary strings.Builder
+	if len(added) > 0 {
+		fmt.Fprintf(&summary, "Added resources (%d):\n%s\n", len(added), strings.Join(added, "\n"))
+	}
+	if len(removed) > 0 {
+		fmt.Fprintf(&summary, "Removed resources (%d):\n%s\n", len(removed), strings.Join(removed, "\n"))
+	}
+
+	fmt.Print(diff.ColorizeDiff(summary.String(), plainFlag || accessibleFlag))
+	return nil
+}
@@ -0,0 +1,673 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/argocd"
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/flux"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/progress"
+	"github.com/dlactin/rdv/internal/retry"
+	"github.com/dlactin/rdv/internal/validate"
+	"golang.org/x/sync/errgroup"
+)
+
+// target is a single chart or kustomization rendered on both
+// the local and target ref side
+type target struct {
+	// name is the path or environment as provided by the user, used in output
+	name string
+	// relativePath is the path relative to the repository root
+	relativePath string
+	// targetRelativePath is the path relative to the target side's root,
+	// it only differs from relativePath when --target-path is set
+	targetRelativePath string
+	// ciValues is the ci/*-values.yaml file of the chart, relative to
+	// it, this variant of the chart is rendered with by --ci-values
+	ciValues string
+
+	localRender  string
+	targetRender string
+	// upstreamRender is the render of the target ref with --three-way,
+	// targetRender is the render of its merge-base then
+	upstreamRender string
+	// cached is set when targetRender came from the daemon cache
+	cached bool
+	// localResults holds the validation results of the local render
+	// when --validate is set
+	localResults []validate.Result
+	// localInvalid holds the validation errors of the local render,
+	// it fails the run unless --fail-on leaves out validation
+	localInvalid error
+	// targetInvalid holds the validation errors of the target render
+	// when --validate-target is set
+	targetInvalid error
+	// changed is set when the printed diff wasn't empty
+	changed bool
+}
+
+// resolveTarget checks the path is inside the repository and
+// returns a target for it
+func resolveTarget(path string) (*target, error) {
+	// Get the absolute path from the path flag
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve absolute path for -path %w", err)
+	}
+
+	// Get the relative path compared to the repoRoot)
+	relativePath, err := filepath.Rel(repoRoot, absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve relative path for -path %w", err)
+	}
+
+	if strings.HasPrefix(relativePath, "..") {
+		return nil, fmt.Errorf("the provided path '%s' (resolves to '%s') is outside the git repository root '%s'", path, absPath, repoRoot)
+	}
+
+	return &target{name: path, relativePath: relativePath, targetRelativePath: relativePath}, nil
+}
+
+// resolveTargets returns the targets for this run. Each --env path is
+// resolved relative to --path, otherwise --path is the only target.
+func resolveTargets() ([]*target, error) {
+	if len(envFlag) == 0 {
+		t, err := resolveTarget(renderPathFlag)
+		if err != nil {
+			return nil, err
+		}
+		return []*target{t}, nil
+	}
+
+	targets := make([]*target, 0, len(envFlag))
+	for _, env := range envFlag {
+		t, err := resolveTarget(filepath.Join(renderPathFlag, env))
+		if err != nil {
+			return nil, err
+		}
+		// Environments are named as passed to --env
+		t.name = env
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+// setTargetPaths points the target side of each target at --target-path,
+// keeping the environment subdirectory of each target
+func setTargetPaths(targets []*target) error {
+	if targetPathFlag == "" {
+		return nil
+	}
+
+	for _, t := range targets {
+		t.targetRelativePath = filepath.Clean(targetPathFlag)
+		if len(envFlag) > 0 {
+			t.targetRelativePath = filepath.Join(targetPathFlag, t.name)
+		}
+
+		if filepath.IsAbs(t.targetRelativePath) || strings.HasPrefix(t.targetRelativePath, "..") {
+			return fmt.Errorf("--target-path '%s' must be relative to the target repository root", targetPathFlag)
+		}
+	}
+
+	return nil
+}
+
+// renderOptions returns the render options for a chart or kustomization
+// at path, values files are resolved relative to the path
+func renderOptions(path string) diff.RenderOptions {
+	// Resolve relative values file paths to absolute paths for the render
+	// This means we only support values files located in the path provided
+	valuesPaths := make([]string, len(valuesFlag))
+	for i, v := range valuesFlag {
+		valuesPaths[i] = helm.ResolveValuesPath(path, v)
+	}
+
+	return diff.RenderOptions{
+		Renderer:     rendererFlag,
+		Namespace:    injectNamespaceFlag,
+		Values:       valuesPaths,
+		ShowOnly:     showOnlyFlag,
+		Kustomize:    kustomizeOptions(),
+		Debug:        debugFlag,
+		Update:       updateFlag,
+		ResolveRefs:  resolveRefsFlag,
+		Capabilities: capabilities,
+		Fetch:        retry.Policy{Retries: fetchRetriesFlag, Timeout: fetchTimeoutFlag},
+	}
+}
+
+// expandRender appends the output of the Argo CD Applications and Flux
+// objects in render, built from the checkout at root, when --argocd or
+// --flux is set
+func expandRender(render, root string) (string, error) {
+	var err error
+
+	if argocdFlag {
+		render, err = argocd.Expand(render, root, argocd.Options{
+			RepoURLs: remoteURLs,
+			Render:   renderOptions(root),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if fluxFlag {
+		render, err = flux.Expand(render, root, flux.Options{
+			RepoURLs: remoteURLs,
+			Render:   renderOptions(root),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return render, nil
+}
+
+// inject sets the --inject-namespace namespace on the namespaced resources
+// of render that don't have one, and adds the common labels and
+// annotations of the config file
+func inject(render string) (string, error) {
+	var err error
+	if injectNamespaceFlag != "" {
+		render, err = manifest.InjectNamespace(render, injectNamespaceFlag)
+		if err != nil {
+			return "", fmt.Errorf("failed to inject namespace: %w", err)
+		}
+	}
+
+	if cfg != nil {
+		render, err = manifest.AddMetadata(render, cfg.CommonLabels, cfg.CommonAnnotations)
+		if err != nil {
+			return "", fmt.Errorf("failed to add common labels and annotations: %w", err)
+		}
+	}
+	return render, nil
+}
+
+// render renders the local and target ref versions of the target
+// concurrently. worktree is the checkout of the target ref, validator is
+// only used when --validate or --validate-target is set.
+// The target ref is not rendered again if it was cached.
+func (t *target) render(worktree string, validator *validate.Validator) error {
+	var localErr, targetErr error
+
+	// Ensure both rendering goroutines have finished before creating our diff
+	g := new(errgroup.Group)
+	g.Go(func() error {
+		localErr = t.renderLocalSide(validator)
+		return nil
+	})
+	g.Go(func() error {
+		targetErr = t.renderTargetSide(worktree, validator)
+		return nil
+	})
+	_ = g.Wait()
+
+	return t.finishRender(localErr, targetErr)
+}
+
+// finishRender masks both renders once both sides are done, and returns
+// the error of the local side, or else the target side
+func (t *target) finishRender(localErr, targetErr error) error {
+	// Decrypted secrets and the redaction patterns of the config file are
+	// masked in everything printed or written from here on
+	t.localRender = mask.String(t.localRender)
+	t.targetRender = mask.String(t.targetRender)
+
+	if localErr != nil {
+		return localErr
+	}
+	return targetErr
+}
+
+// renderLocalSide renders and validates the local side of a target
+func (t *target) renderLocalSide(validator *validate.Validator) error {
+	localPath := filepath.Join(localRoot, t.relativePath)
+
+	// We only lint our local version
+	localOpts := t.renderOptions(localPath)
+	localOpts.Lint = true
+
+	localRender, err := renderLocal(localPath, localOpts)
+	if err != nil {
+		// The path may have been removed in the --to ref or the index
+		if (toRef != "" || stagedFlag) && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	t.localRender = localRender
+
+	// Run local rendered manifests through kubeconform if --validate flag is passed
+	if validateFlag {
+		results, err := validator.Check(localRender)
+		if err != nil {
+			return err
+		}
+		t.localResults = results
+		if validationReport != nil {
+			validationReport.Add(t.localName(), results)
+		}
+
+		t.localInvalid = validate.ResultsError(results)
+	}
+
+	// Duplicate resources fail to apply, and look like a single
+	// resource in a text diff. They only fail the run with --validate.
+	resources, err := manifest.Parse(localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render: %w", err)
+	}
+	if err := duplicateResources(resources); err != nil {
+		if !validateFlag {
+			log.Printf("Warning: %s: %v", t.name, err)
+			return nil
+		}
+		t.localInvalid = errors.Join(t.localInvalid, err)
+	}
+	return nil
+}
+
+// renderTargetSide renders the target ref side of a target from worktree,
+// unless it was cached, and validates it with --validate-target
+func (t *target) renderTargetSide(worktree string, validator *validate.Validator) error {
+	if !t.cached {
+		targetRender, err := t.renderRef(worktree)
+		if err != nil {
+			return err
+		}
+		t.targetRender = targetRender
+	}
+
+	// Target validation errors are reported but don't fail the run,
+	// they were introduced by the base branch and not this change
+	if validateTargetFlag {
+		results, err := validator.Check(t.targetRender)
+		if err != nil {
+			return err
+		}
+		if validationReport != nil {
+			validationReport.Add(t.targetName(), results)
+		}
+
+		t.targetInvalid = validate.ResultsError(results)
+	}
+	return nil
+}
+
+// renderLocal renders the local side of a target at localPath
+func renderLocal(localPath string, opts diff.RenderOptions) (string, error) {
+	defer progress.Time(progress.PhaseLocalRender)()
+
+	render, err := diff.RenderManifests(localPath, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to render path in local ref: %w", err)
+	}
+
+	render, err = expandRender(render, localRoot)
+	if err != nil {
+		return "", fmt.Errorf("local render: %w", err)
+	}
+	render, err = inject(render)
+	if err != nil {
+		return "", fmt.Errorf("local render: %w", err)
+	}
+	return render, nil
+}
+
+// checkDuplicates returns an error naming the resources that appear more
+// than once in render, or why render couldn't be parsed
+func checkDuplicates(render string) error {
+	resources, err := manifest.Parse(render)
+	if err != nil {
+		return fmt.Errorf("failed to parse render: %w", err)
+	}
+	return duplicateResources(resources)
+}
+
+// duplicateResources returns an error naming the resources that appear
+// more than once
+func duplicateResources(resources []manifest.Resource) error {
+	duplicates := manifest.Duplicates(resources)
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	names := make([]string, len(duplicates))
+	for i, d := range duplicates {
+		names[i] = fmt.Sprintf("%s (%s) %d times", d.Resource, d.Resource.APIVersion, d.Count)
+	}
+	return fmt.Errorf("duplicate resources: %s", strings.Join(names, ", "))
+}
+
+// renderRef renders the target side of the target from the checkout at
+// worktree
+func (t *target) renderRef(worktree string) (string, error) {
+	defer progress.Time(progress.PhaseTargetRender)()
+
+	targetPath := filepath.Join(worktree, t.targetRelativePath)
+
+	render, err := diff.RenderManifests(targetPath, t.renderOptions(targetPath))
+	if err != nil {
+		// If the path does not exist in the target ref
+		// We can assume it's a new addition and diff against
+		// an empty string instead.
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to render target ref manifests: %w", err)
+	}
+
+	render, err = expandRender(render, worktree)
+	if err != nil {
+		return "", fmt.Errorf("target render: %w", err)
+	}
+	render, err = inject(render)
+	if err != nil {
+		return "", fmt.Errorf("target render: %w", err)
+	}
+	return render, nil
+}
+
+// readTargetFile returns the content of a file in the target side's
+// directory from the checkout at worktree. The file is read from the
+// repository when the target render was cached and nothing was checked out.
+func (t *target) readTargetFile(worktree, name string) ([]byte, error) {
+	path := filepath.Join(t.targetRelativePath, name)
+	if worktree == "" {
+		return repo.ReadFile(fullRef, path)
+	}
+	return os.ReadFile(filepath.Join(worktree, path))
+}
+
+// renderUpstream renders the target side from the checkout of the
+// --three-way target ref at worktree
+func (t *target) renderUpstream(worktree string) error {
+	render, err := t.renderRef(worktree)
+	if err != nil {
+		return fmt.Errorf("%s: %w", upstreamRef, err)
+	}
+	t.upstreamRender = mask.String(render)
+	return nil
+}
+
+// renderAll renders the local and target side of every target through a
+// pool of parallel workers, and returns the render error of each target
+func renderAll(targets []*target, worktree string, validator *validate.Validator, parallel int) []error {
+	localErrs := make([]error, len(targets))
+	targetErrs := make([]error, len(targets))
+
+	g := new(errgroup.Group)
+	g.SetLimit(parallel)
+	for i, t := range targets {
+		g.Go(func() error {
+			localErrs[i] = t.renderLocalSide(validator)
+			return nil
+		})
+		g.Go(func() error {
+			targetErrs[i] = t.renderTargetSide(worktree, validator)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	errs := make([]error, len(targets))
+	for i, t := range targets {
+		errs[i] = t.finishRender(localErrs[i], targetErrs[i])
+	}
+	return errs
+}
+
+// parseSelectors parses the --include or --exclude selectors
+func parseSelectors(flags []string) ([]manifest.Selector, error) {
+	var selectors []manifest.Selector
+	for _, f := range flags {
+		selector, err := manifest.ParseSelector(f)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// filter removes the resources not selected by --include and --exclude
+// from both renders, and the labels and annotations that change on every
+// chart version bump unless --keep-noise is set, and the comments with
+// --ignore-comments. With --ignore-whitespace
+// the whitespace-only changes are reverted in the local render.
+func (t *target) filter() error {
+	t.localRender = removeNoise(t.localRender)
+	t.targetRender = removeNoise(t.targetRender)
+
+	var err error
+
+	t.localRender, err = manifest.Filter(t.localRender, includeSelectors, excludeSelectors)
+	if err != nil {
+		return fmt.Errorf("failed to filter local render for %s: %w", t.name, err)
+	}
+
+	t.targetRender, err = manifest.Filter(t.targetRender, includeSelectors, excludeSelectors)
+	if err != nil {
+		return fmt.Errorf("failed to filter target render for %s: %w", t.name, err)
+	}
+
+	if t.upstreamRender != "" {
+		t.upstreamRender = removeNoise(t.upstreamRender)
+		t.upstreamRender, err = manifest.Filter(t.upstreamRender, includeSelectors, excludeSelectors)
+		if err != nil {
+			return fmt.Errorf("failed to filter %s render for %s: %w", upstreamRef, t.name, err)
+		}
+		if ignoreWhitespaceFlag {
+			t.upstreamRender = diff.IgnoreWhitespace(t.targetRender, t.upstreamRender)
+		}
+	}
+
+	if ignoreWhitespaceFlag {
+		t.localRender = diff.IgnoreWhitespace(t.targetRender, t.localRender)
+	}
+
+	return nil
+}
+
+// removeNoise removes the noise labels and annotations from render unless
+// --keep-noise is set, and the comments with --ignore-comments
+func removeNoise(render string) string {
+	if !keepNoiseFlag {
+		render = manifest.RemoveNoise(render, manifest.NoiseLabels, manifest.NoiseAnnotations)
+	}
+	if ignoreCommentsFlag || ignoreSourceCommentsFlag {
+		render = manifest.StripComments(render, ignoreSourceCommentsFlag)
+	}
+	return render
+}
+
+// localRef names the local side, the --to ref, 'staged' for the index or
+// 'local' for the working tree
+func localRef() string {
+	if toRef != "" {
+		return toRef
+	}
+	if stagedFlag {
+		return "staged"
+	}
+	return "local"
+}
+
+// localName labels the local render in diffs and reports
+func (t *target) localName() string {
+	// Pre-rendered files from diff-files are labeled by their path alone
+	if t.relativePath == "" {
+		return localRef()
+	}
+	return fmt.Sprintf("%s/%s", localRef(), t.relativePath)
+}
+
+// targetName labels the target ref render in diffs and reports
+func (t *target) targetName() string {
+	if t.targetRelativePath == "" {
+		return fullRef
+	}
+	return fmt.Sprintf("%s/%s", fullRef, t.targetRelativePath)
+}
+
+// printDiff prints the diff between the target ref and local render
+func (t *target) printDiff() error {
+	defer progress.Time(progress.PhaseDiff)()
+
+	fromName := t.targetName()
+	toName := t.localName()
+
+	// outputLimit is always nil here, PreRunE rejects --max-output-bytes
+	// with --semantic
+	if semanticDiffFlag {
+		// We are using a more complex diff engine (dyff) which is better suited for k8s manifest comparison
+		renderedDiff, err := diff.CreateSemanticDiff(t.targetRender, t.localRender, fromName, toName, semanticOptions(plainFlag || accessibleFlag))
+		if err != nil {
+			return fmt.Errorf("error creating dyff: %w", err)
+		}
+
+		if len(renderedDiff.Diffs) == 0 {
+			fmt.Println("\nNo differences found between rendered manifests.")
+			return nil
+		}
+
+		t.changed = true
+		fmt.Printf("\n--- Diff (%s vs. %s) ---\n", fullRef, localRef())
+		if err := t.printResourceSummary(); err != nil {
+			return err
+		}
+		return renderedDiff.WriteReport(os.Stdout)
+	}
+
+	// Very large renders are diffed resource by resource, and the output
+	// is limited at resource boundaries
+	if perResourceLinesFlag > 0 && diff.Lines(t.targetRender)+diff.Lines(t.localRender) > perResourceLinesFlag {
+		log.Printf("Renders of %s are larger than --per-resource-lines %d, diffing resource by resource", t.name, perResourceLinesFlag)
+		return t.printResourceDiffs()
+	}
+	if outputLimit != nil {
+		return t.printResourceDiffs()
+	}
+
+	// Generate and Print our simple diff
+	// This is better suited for github comments, or small changes
+	renderedDiff := diff.CreateDiffWithContext(t.targetRender, t.localRender, fromName, toName, diffContext())
+
+	if renderedDiff == "" {
+		fmt.Println("\nNo differences found between rendered manifests.")
+		return nil
+	}
+
+	t.changed = true
+	fmt.Printf("\n--- Diff (%s vs. %s) ---\n", fullRef, localRef())
+	if err := t.printResourceSummary(); err != nil {
+		return err
+	}
+	if accessibleFlag {
+		fmt.Println(diff.AccessibleDiff(renderedDiff))
+	} else {
+		fmt.Println(colorize(renderedDiff))
+	}
+
+	return nil
+}
+
+// printUpstream prints the changes between the merge-base and the target
+// ref with --three-way, the same way as the changes of the local side.
+// They don't count as changes of this run.
+func (t *target) printUpstream() error {
+	upstream := &target{
+		name:               t.name,
+		relativePath:       t.targetRelativePath,
+		targetRelativePath: t.targetRelativePath,
+		targetRender:       t.targetRender,
+		localRender:        t.upstreamRender,
+	}
+
+	// The diff headers name the local side after localRef
+	localTo := toRef
+	toRef = upstreamRef
+	defer func() { toRef = localTo }()
+
+	if statFlag {
+		return upstream.printStat()
+	}
+	return upstream.printDiff()
+}
+
+// printResourceDiffs prints the diff of every changed resource as soon
+// as it's computed, instead of diffing the renders as a whole
+func (t *target) printResourceDiffs() error {
+	err := diff.ResourceDiffs(t.targetRender, t.localRender, t.targetName(), t.localName(), diffContext(), func(renderedDiff string) error {
+		output := colorize(renderedDiff)
+		if accessibleFlag {
+			output = diff.AccessibleDiff(renderedDiff)
+		}
+		// The header and summary are printed even if no resource diff fits
+		if !t.changed {
+			t.changed = true
+			fmt.Printf("\n--- Diff (%s vs. %s) ---\n", fullRef, localRef())
+			if err := t.printResourceSummary(); err != nil {
+				return err
+			}
+		}
+
+		if outputLimit != nil && !outputLimit.add(renderedDiff, output) {
+			return nil
+		}
+		fmt.Println(output)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to diff resources of %s: %w", t.name, err)
+	}
+
+	if !t.changed {
+		fmt.Println("\nNo differences found between rendered manifests.")
+	}
+	return nil
+}
+
+// writeRenders writes the local and target rendered manifests to dir
+func (t *target) writeRenders(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// We are having static local/target file names for the render
+	localRenderFile := filepath.Join(dir, "local.yaml")
+	if err := os.WriteFile(localRenderFile, []byte(t.localRender), 0644); err != nil {
+		return fmt.Errorf("failed to write output file to %s: %w", dir, err)
+	}
+
+	targetRenderFile := filepath.Join(dir, "target.yaml")
+	if err := os.WriteFile(targetRenderFile, []byte(t.targetRender), 0644); err != nil {
+		return fmt.Errorf("failed to write output file to %s: %w", dir, err)
+	}
+
+	fmt.Printf("Rendered manifest saved to: %s\n", dir)
+	return nil
+}
+
+// diffContext returns the number of unchanged lines to show around each
+// change, negative for the whole render
+func diffContext() int {
+	if fullContextFlag {
+		return -1
+	}
+	return unifiedFlag
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+)
+
+// setupTheme sets the diff colors from --theme and the theme section of
+// the config file. --theme is a preset, colors of diff elements or both,
+// e.g. 'colorblind', 'added=#0072b2,removed=208' or 'colorblind,hunk=cyan'.
+// Its colors take precedence over those of the config file.
+func setupTheme(configColors map[string]string) error {
+	colors := map[string]string{}
+	for element, color := range configColors {
+		colors[element] = color
+	}
+
+	var preset string
+	for _, entry := range strings.Split(themeFlag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		element, color, isColor := strings.Cut(entry, "=")
+		if !isColor {
+			if preset != "" {
+				return fmt.Errorf("--theme: only one preset can be used, got %s and %s", preset, entry)
+			}
+			preset = entry
+			continue
+		}
+		colors[strings.TrimSpace(element)] = color
+	}
+
+	theme, err := diff.NewTheme(preset, colors)
+	if err != nil {
+		return fmt.Errorf("--theme: %w", err)
+	}
+	diff.SetTheme(theme)
+	return nil
+}
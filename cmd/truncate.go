@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// outputLimit bounds the diff output to --max-output-bytes, nil without
+// the flag. Diffs are cut at resource boundaries and every resource diff
+// is also written to a file, referenced in the footer when any were left
+// out.
+var outputLimit *limitedOutput
+
+type limitedOutput struct {
+	// remaining is the number of bytes left for resource diffs
+	remaining int
+	// omitted is the number of changed resources that weren't printed
+	omitted int
+	// full is the complete diff, without colors
+	full *os.File
+}
+
+// newOutputLimit creates the output limit of a run and the file the full
+// diff is written to
+func newOutputLimit(maxBytes int) (*limitedOutput, error) {
+	full, err := os.CreateTemp("", "rdv-full-diff-*.patch")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the full diff file: %w", err)
+	}
+	return &limitedOutput{remaining: maxBytes, full: full}, nil
+}
+
+// add records the diff of a resource in the full diff and reports whether
+// its output still fits in the limit. Once one resource is left out all
+// following ones are too, so the output doesn't skip around.
+func (l *limitedOutput) add(resourceDiff, output string) bool {
+	_, _ = io.WriteString(l.full, resourceDiff+"\n")
+
+	size := len(output) + 1
+	if l.omitted > 0 || size > l.remaining {
+		l.omitted++
+		return false
+	}
+	l.remaining -= size
+	return true
+}
+
+// close prints the footer when resources were left out, the full diff is
+// removed otherwise
+func (l *limitedOutput) close() {
+	_ = l.full.Close()
+	if l.omitted == 0 {
+		_ = os.Remove(l.full.Name())
+		return
+	}
+
+	fmt.Printf("\n… %d more resources changed, output truncated to %d bytes. The full diff is in %s", l.omitted, maxOutputBytesFlag, l.full.Name())
+	if run := gitHubRunURL(); run != "" {
+		fmt.Printf(" on the runner of %s", run)
+	}
+	fmt.Println()
+}
+
+// gitHubRunURL returns the URL of the GitHub Actions run, empty outside of
+// GitHub Actions
+func gitHubRunURL() string {
+	server, repository, run := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server == "" || repository == "" || run == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repository, run)
+}
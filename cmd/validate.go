@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/validate"
+	"github.com/spf13/cobra"
+)
+
+// validateCmd renders and validates the local path without diffing it,
+// a fast check for pre-commit hooks
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Render and validate a path without diffing it",
+	Long: `validate renders the chart or kustomization at --path and validates the manifests with
+kubeconform, like --validate does for the local side of a diff. No git ref is checked out
+and no diff is computed, which makes it fast enough for a pre-commit check.
+
+Pass '--path -' to validate manifests read from stdin instead:
+
+  helm template ./charts/app | rdv validate -p -`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		log.SetFlags(0) // Disabling timestamps for log output
+
+		var render string
+		if renderPathFlag == "-" {
+			input, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed to read manifests from stdin: %w", err)
+			}
+			render = string(input)
+		} else {
+			path, err := filepath.Abs(renderPathFlag)
+			if err != nil {
+				return fmt.Errorf("failed to resolve absolute path for -path %w", err)
+			}
+
+			render, err = diff.RenderManifests(path, renderOptions(path))
+			if err != nil {
+				return withExitCode(exitRender, fmt.Errorf("failed to render path: %w", err))
+			}
+		}
+
+		validator, err := validate.NewValidator(debugFlag, schemaLocationFlag...)
+		if err != nil {
+			return err
+		}
+
+		results, err := validator.Check(render)
+		if err != nil {
+			return err
+		}
+
+		if validationReportFlag != "" {
+			report := &validate.Report{}
+			report.Add(renderPathFlag, results)
+			if err := report.WriteFile(validationReportFlag); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+
+		// The usage is only noise once the flags are parsed
+		cmd.SilenceUsage = true
+		if err := validate.ResultsError(results); err != nil {
+			return withExitCode(exitValidation, err)
+		}
+
+		counts := map[string]int{}
+		for _, r := range results {
+			counts[r.Status]++
+		}
+		log.Printf("All %d resources are valid (%d skipped without a schema).",
+			len(results), counts[validate.StatusSkipped])
+		return nil
+	},
+}
+
+func init() {
+	validateCmd.Flags().SortFlags = false
+
+	validateCmd.Flags().StringVarP(&renderPathFlag, "path", "p", ".", "Relative path to the chart or kustomization directory, or - to read manifests from stdin")
+	validateCmd.Flags().StringVarP(&rendererFlag, "renderer", "", "auto", "Renderer to use: auto, helm, kustomize, kustomize-helm (kustomize with the Helm chart inflator), timoni or the name of a renderer plugin")
+	validateCmd.Flags().AddFlagSet(newHelmFlagSet())
+	validateCmd.Flags().AddFlagSet(newKustomizeFlagSet())
+	validateCmd.Flags().StringSliceVarP(&schemaLocationFlag, "schema-location", "", []string{}, "kubeconform schema location used instead of the upstream Kubernetes schemas, 'default' includes them (can be specified multiple times)")
+	validateCmd.Flags().StringVarP(&validationReportFlag, "validation-report", "", "", "Write validation results to this file, as JUnit XML for .xml files and JSON otherwise")
+	validateCmd.Flags().AddFlagSet(newLoggingFlagSet())
+
+	registerCompletions(validateCmd)
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/dlactin/rdv/internal/helm"
+	"gopkg.in/yaml.v3"
+)
+
+// checkVersionBump returns a message when the render of a Helm chart
+// changed but the version in its Chart.yaml wasn't bumped, empty otherwise.
+// worktree is the checkout of the target ref, see readTargetFile.
+func checkVersionBump(t *target, worktree string) (string, error) {
+	localPath := filepath.Join(localRoot, t.relativePath)
+	if !helm.IsHelmChart(localPath) {
+		return "", nil
+	}
+
+	localChart, err := os.ReadFile(filepath.Join(localPath, "Chart.yaml"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the local Chart.yaml of %s: %w", t.name, err)
+	}
+
+	targetChart, err := t.readTargetFile(worktree, "Chart.yaml")
+	if errors.Is(err, fs.ErrNotExist) {
+		// A new chart has no version to bump
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the target Chart.yaml of %s: %w", t.name, err)
+	}
+
+	localVersion, err := chartVersion(localChart)
+	if err != nil {
+		return "", fmt.Errorf("local Chart.yaml of %s: %w", t.name, err)
+	}
+	targetVersion, err := chartVersion(targetChart)
+	if err != nil {
+		return "", fmt.Errorf("target Chart.yaml of %s: %w", t.name, err)
+	}
+
+	if versionBumped(targetVersion, localVersion) {
+		return "", nil
+	}
+	return fmt.Sprintf("The render of %s changed, but its chart version %s wasn't bumped from %s on %s", t.name, localVersion, targetVersion, fullRef), nil
+}
+
+// chartVersion returns the version field of a Chart.yaml
+func chartVersion(chart []byte) (string, error) {
+	var metadata struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(chart, &metadata); err != nil {
+		return "", fmt.Errorf("failed to parse: %w", err)
+	}
+	return metadata.Version, nil
+}
+
+// versionBumped reports whether to is a newer version than from. Versions
+// that aren't semver only need to differ.
+func versionBumped(from, to string) bool {
+	fromVersion, fromErr := semver.NewVersion(from)
+	toVersion, toErr := semver.NewVersion(to)
+	if fromErr != nil || toErr != nil {
+		return from != to
+	}
+	return toVersion.GreaterThan(fromVersion)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchDebounce is how long the watcher waits for more events before
+// rendering again, editors often write a file several times on save
+const watchDebounce = 200 * time.Millisecond
+
+// watchDiff prints the diff and prints it again every time a file under
+// the local targets, or a chart dependency or kustomize reference of
+// them, changes. Target renders are kept in memory like the daemon does,
+// so only the local side is rendered again.
+func watchDiff(cmd *cobra.Command) error {
+	warm = &warmCache{renders: map[string]string{}}
+	defer func() { warm = nil }()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start the file watcher: %w", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	targets, err := resolveTargets()
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		dir := filepath.Join(localRoot, t.relativePath)
+		refs, err := localReferences(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range append([]string{dir}, refs...) {
+			if err := watchTree(watcher, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for {
+		// Render errors are expected while editing, keep watching
+		if err := runDiff(cmd); err != nil {
+			log.Printf("Error: %v", err)
+		}
+		log.Printf("Watching for changes, press Ctrl+C to stop")
+
+		// Events caused by the render itself, e.g. 'helm dependency update', are dropped
+		drainEvents(watcher)
+
+		changed, err := waitForChange(cmd, watcher)
+		if err != nil || changed == "" {
+			return err
+		}
+		log.Printf("\n=== %s changed, rendering again ===", changed)
+	}
+}
+
+// watchTree adds path and every directory below it to the watcher.
+// Files are watched through their directory so editors replacing them
+// on save are still seen.
+func watchTree(watcher *fsnotify.Watcher, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		// References to missing paths fail the render, not the watcher
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if err := watcher.Add(p); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", p, err)
+		}
+		return nil
+	})
+}
+
+// drainEvents discards the events already queued on the watcher
+func drainEvents(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case <-watcher.Events:
+		case <-watcher.Errors:
+		default:
+			return
+		}
+	}
+}
+
+// waitForChange blocks until a watched file changes and no other change
+// follows within watchDebounce. It returns the first changed path, or an
+// empty path when the command is interrupted.
+func waitForChange(cmd *cobra.Command, watcher *fsnotify.Watcher) (string, error) {
+	var changed string
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-cmd.Context().Done():
+			return "", nil
+		case event := <-watcher.Events:
+			// New directories aren't watched by the directories above them
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchTree(watcher, event.Name); err != nil {
+						return "", err
+					}
+				}
+			}
+			// Permission and timestamp updates don't change the render
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			if changed == "" {
+				changed = event.Name
+				if rel, err := filepath.Rel(repoRoot, event.Name); err == nil {
+					changed = rel
+				}
+			}
+			debounce = time.After(watchDebounce)
+		case err := <-watcher.Errors:
+			return "", fmt.Errorf("file watcher failed: %w", err)
+		case <-debounce:
+			return changed, nil
+		}
+	}
+}
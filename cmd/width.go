@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"golang.org/x/term"
+)
+
+// outputWidth is the number of columns diffs are laid out for, 0 if
+// unknown. Unified diff lines longer than it are wrapped.
+var outputWidth int
+
+// terminalWidth is the number of columns of stdout if it's a terminal,
+// 0 otherwise
+var terminalWidth int
+
+// setupWidth sets outputWidth from --width, the width of stdout if it's a
+// terminal or $COLUMNS. It runs before the pager replaces stdout.
+func setupWidth() error {
+	outputWidth, terminalWidth = 0, 0
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		terminalWidth = width
+	}
+	if forwarded != nil {
+		terminalWidth = forwarded.Width
+	}
+
+	switch {
+	case widthFlag < 0:
+		return fmt.Errorf("--width must be 0 or more, got %d", widthFlag)
+	case widthFlag > 0:
+		outputWidth = widthFlag
+	default:
+		if terminalWidth > 0 {
+			outputWidth = terminalWidth
+		} else if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+			outputWidth = columns
+		}
+	}
+	return nil
+}
+
+// colorize wraps a unified diff to the output width and colors it unless
+// --plain is set
+func colorize(unified string) string {
+	return diff.ColorizeDiff(diff.WrapDiff(unified, outputWidth), plainFlag)
+}
@@ -0,0 +1,249 @@
+// Package argocd resolves Argo CD Application and ApplicationSet manifests
+// in a render to the charts and kustomizations they deploy, so app-of-apps
+// repositories can be diffed by what Argo CD would actually sync.
+package argocd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/dlactin/rdv/internal/manifest"
+	"sigs.k8s.io/yaml"
+)
+
+// Application is an Argo CD Application, or one generated by an ApplicationSet
+type Application struct {
+	Name    string
+	Sources []Source
+}
+
+// Source is a single source of an Application
+type Source struct {
+	RepoURL        string           `json:"repoURL"`
+	Path           string           `json:"path"`
+	Chart          string           `json:"chart"`
+	TargetRevision string           `json:"targetRevision"`
+	Ref            string           `json:"ref"`
+	Helm           *HelmSource      `json:"helm"`
+	Kustomize      *KustomizeSource `json:"kustomize"`
+}
+
+// HelmSource holds the Helm options of a source
+type HelmSource struct {
+	ReleaseName  string          `json:"releaseName"`
+	ValueFiles   []string        `json:"valueFiles"`
+	Values       string          `json:"values"`
+	ValuesObject map[string]any  `json:"valuesObject"`
+	Parameters   []HelmParameter `json:"parameters"`
+}
+
+// HelmParameter is a Helm value set like 'helm --set'
+type HelmParameter struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	ForceString bool   `json:"forceString"`
+}
+
+// KustomizeSource holds the kustomize options of a source
+type KustomizeSource struct {
+	NamePrefix        string            `json:"namePrefix"`
+	NameSuffix        string            `json:"nameSuffix"`
+	Namespace         string            `json:"namespace"`
+	Images            []string          `json:"images"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+}
+
+// application is the part of an Application manifest we need
+type application struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		Source  *Source  `json:"source"`
+		Sources []Source `json:"sources"`
+	} `json:"spec"`
+}
+
+// applicationSet is the part of an ApplicationSet manifest we need
+type applicationSet struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec struct {
+		GoTemplate bool             `json:"goTemplate"`
+		Generators []map[string]any `json:"generators"`
+		Template   map[string]any   `json:"template"`
+	} `json:"spec"`
+}
+
+// Find returns the Applications in a render. ApplicationSets are expanded
+// with their list generators, other generators need a cluster or an SCM
+// provider and are skipped.
+func Find(render string, debug bool) ([]Application, error) {
+	resources, err := manifest.Parse(render)
+	if err != nil {
+		return nil, err
+	}
+
+	var apps []Application
+	for _, r := range resources {
+		if !strings.HasPrefix(r.APIVersion, "argoproj.io/") {
+			continue
+		}
+
+		switch r.Kind {
+		case "Application":
+			var app application
+			if err := yaml.Unmarshal([]byte(r.Body), &app); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+			}
+			apps = append(apps, app.resolve())
+		case "ApplicationSet":
+			var set applicationSet
+			if err := yaml.Unmarshal([]byte(r.Body), &set); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+			}
+			generated, err := set.expand(debug)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand %s: %w", r, err)
+			}
+			apps = append(apps, generated...)
+		}
+	}
+
+	return apps, nil
+}
+
+// resolve converts the single and multi source forms to an Application
+func (a application) resolve() Application {
+	app := Application{Name: a.Metadata.Name, Sources: a.Spec.Sources}
+	if a.Spec.Source != nil {
+		app.Sources = append([]Source{*a.Spec.Source}, app.Sources...)
+	}
+	return app
+}
+
+// expand generates an Application for each element of the list generators
+func (s applicationSet) expand(debug bool) ([]Application, error) {
+	var apps []Application
+
+	for _, generator := range s.Spec.Generators {
+		list, ok := generator["list"].(map[string]any)
+		if !ok {
+			if debug {
+				for name := range generator {
+					log.Printf("Skipping the %s generator of ApplicationSet %s, only list generators are supported", name, s.Metadata.Name)
+				}
+			}
+			continue
+		}
+
+		elements, _ := list["elements"].([]any)
+		for _, element := range elements {
+			params, ok := element.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("list generator element is not an object: %v", element)
+			}
+
+			rendered, err := renderTemplate(s.Spec.Template, params, s.Spec.GoTemplate)
+			if err != nil {
+				return nil, err
+			}
+
+			// Round trip through YAML to decode the generated template
+			data, err := yaml.Marshal(rendered)
+			if err != nil {
+				return nil, err
+			}
+			var app application
+			if err := yaml.Unmarshal(data, &app); err != nil {
+				return nil, fmt.Errorf("failed to parse generated Application: %w", err)
+			}
+			apps = append(apps, app.resolve())
+		}
+	}
+
+	return apps, nil
+}
+
+// fasttemplateParam matches the '{{ param }}' placeholders of non Go templates
+var fasttemplateParam = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// renderTemplate substitutes the generator parameters into every string of the
+// template. Go templates get the parameters as is, the default templating uses
+// flattened keys, e.g. '{{ cluster.name }}'.
+func renderTemplate(value any, params map[string]any, goTemplate bool) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			renderedKey, err := renderTemplate(key, params, goTemplate)
+			if err != nil {
+				return nil, err
+			}
+			out[renderedKey.(string)], err = renderTemplate(item, params, goTemplate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			out[i], err = renderTemplate(item, params, goTemplate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+
+		if goTemplate {
+			tmpl, err := template.New("").Funcs(sprig.TxtFuncMap()).Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %q: %w", v, err)
+			}
+			var buf bytes.Buffer
+			if err := tmpl.Execute(&buf, params); err != nil {
+				return nil, fmt.Errorf("failed to execute template %q: %w", v, err)
+			}
+			return buf.String(), nil
+		}
+
+		flat := map[string]string{}
+		flatten("", params, flat)
+		return fasttemplateParam.ReplaceAllStringFunc(v, func(match string) string {
+			key := fasttemplateParam.FindStringSubmatch(match)[1]
+			if param, ok := flat[key]; ok {
+				return param
+			}
+			return match
+		}), nil
+	}
+
+	return value, nil
+}
+
+// flatten joins nested parameter keys with dots
+func flatten(prefix string, params map[string]any, out map[string]string) {
+	for key, value := range params {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = fmt.Sprint(value)
+	}
+}
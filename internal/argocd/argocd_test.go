@@ -0,0 +1,182 @@
+package argocd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testApps = `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: guestbook
+spec:
+  source:
+    repoURL: https://github.com/example/apps.git
+    path: apps/guestbook
+    helm:
+      valueFiles:
+        - values-prod.yaml
+---
+apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: clusters
+spec:
+  generators:
+    - list:
+        elements:
+          - cluster: dev
+            meta:
+              region: eu
+          - cluster: prod
+            meta:
+              region: us
+    - clusters: {}
+  template:
+    metadata:
+      name: '{{cluster}}-app'
+    spec:
+      source:
+        repoURL: https://github.com/example/apps.git
+        path: 'envs/{{ cluster }}/{{meta.region}}'
+---
+apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: go-template
+spec:
+  goTemplate: true
+  generators:
+    - list:
+        elements:
+          - name: api
+  template:
+    metadata:
+      name: '{{ .name | upper }}'
+    spec:
+      sources:
+        - repoURL: https://github.com/example/apps.git
+          path: 'services/{{ .name }}'
+`
+
+func TestFind(t *testing.T) {
+	apps, err := Find(testApps, false)
+	if err != nil {
+		t.Fatalf("Find() failed: %v", err)
+	}
+
+	want := map[string]string{
+		"guestbook": "apps/guestbook",
+		"dev-app":   "envs/dev/eu",
+		"prod-app":  "envs/prod/us",
+		"API":       "services/api",
+	}
+	if len(apps) != len(want) {
+		t.Fatalf("Find() returned %d applications, want %d: %+v", len(apps), len(want), apps)
+	}
+
+	for _, app := range apps {
+		if len(app.Sources) != 1 {
+			t.Errorf("Application %s has %d sources, want 1", app.Name, len(app.Sources))
+			continue
+		}
+		if got := app.Sources[0].Path; got != want[app.Name] {
+			t.Errorf("Application %s has path %q, want %q", app.Name, got, want[app.Name])
+		}
+	}
+
+	if got := apps[0].Sources[0].Helm.ValueFiles; len(got) != 1 || got[0] != "values-prod.yaml" {
+		t.Errorf("guestbook valueFiles = %v, want [values-prod.yaml]", got)
+	}
+}
+
+func TestParseImageOverride(t *testing.T) {
+	testCases := []struct {
+		image string
+		want  map[string]string
+	}{
+		{"nginx:1.27", map[string]string{"name": "nginx", "newTag": "1.27"}},
+		{"nginx=registry.local:5000/nginx:1.27", map[string]string{"name": "nginx", "newName": "registry.local:5000/nginx", "newTag": "1.27"}},
+		{"registry.local:5000/nginx@sha256:abc", map[string]string{"name": "registry.local:5000/nginx", "digest": "sha256:abc"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.image, func(t *testing.T) {
+			got := parseImageOverride(tc.image)
+			if len(got) != len(tc.want) {
+				t.Fatalf("parseImageOverride() = %v, want %v", got, tc.want)
+			}
+			for k, v := range tc.want {
+				if got[k] != v {
+					t.Errorf("parseImageOverride()[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	root := t.TempDir()
+
+	files := map[string]string{
+		// The child Application is deployed from a plain directory
+		"apps/child.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: child
+spec:
+  source:
+    repoURL: git@github.com:example/apps.git
+    path: workloads/child
+    kustomize:
+      namePrefix: prod-
+`,
+		"workloads/child/kustomization.yaml": "resources:\n  - configmap.yaml\n",
+		"workloads/child/configmap.yaml":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: child\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	render := `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: root
+spec:
+  source:
+    repoURL: https://github.com/example/apps
+    path: apps
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: external
+spec:
+  source:
+    repoURL: https://github.com/other/repo
+    path: apps
+`
+
+	got, err := Expand(render, root, Options{RepoURLs: []string{"https://github.com/example/apps.git"}})
+	if err != nil {
+		t.Fatalf("Expand() failed: %v", err)
+	}
+
+	if !strings.Contains(got, "name: child\n") {
+		t.Errorf("Expand() did not render the root Application. Got:\n%s", got)
+	}
+	if !strings.Contains(got, "name: prod-child") {
+		t.Errorf("Expand() did not render the child Application with its kustomize options. Got:\n%s", got)
+	}
+	if strings.Count(got, "name: child\n") != 1 {
+		t.Errorf("Expand() rendered the external Application. Got:\n%s", got)
+	}
+}
@@ -0,0 +1,324 @@
+package argocd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/helm"
+	"helm.sh/helm/v3/pkg/strvals"
+	"sigs.k8s.io/yaml"
+)
+
+// Options configures how Application sources are rendered
+type Options struct {
+	// RepoURLs are the remotes of the repository being diffed. Only sources
+	// from these repositories can be rendered from the checkout, if empty
+	// every source with a path is assumed to be in the repository.
+	RepoURLs []string
+	// Render holds the base render options, e.g. kustomize plugin settings
+	Render diff.RenderOptions
+}
+
+// isLocal reports if repoURL points at the repository being diffed
+func (o Options) isLocal(repoURL string) bool {
+	if len(o.RepoURLs) == 0 {
+		return true
+	}
+	for _, u := range o.RepoURLs {
+		if git.NormalizeURL(u) == git.NormalizeURL(repoURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand finds the Applications in render, renders their sources from the
+// checkout at root and returns render with the output of every Application
+// appended. Applications found in those renders are expanded as well, so
+// app-of-apps trees are rendered down to the workloads.
+// Sources are rendered from root, not their targetRevision, so the diff
+// shows what the Applications would deploy once the change is merged.
+func Expand(render, root string, opts Options) (string, error) {
+	seen := map[string]bool{}
+	var out strings.Builder
+	out.WriteString(render)
+
+	pending := []string{render}
+	for len(pending) > 0 {
+		apps, err := Find(pending[0], opts.Render.Debug)
+		if err != nil {
+			return "", err
+		}
+		pending = pending[1:]
+
+		for _, app := range apps {
+			if seen[app.Name] {
+				continue
+			}
+			seen[app.Name] = true
+
+			for _, src := range app.Sources {
+				appRender, err := renderSource(app, src, root, opts)
+				if err != nil {
+					return "", fmt.Errorf("failed to render Argo CD Application %s: %w", app.Name, err)
+				}
+				if appRender == "" {
+					continue
+				}
+
+				if !strings.HasSuffix(out.String(), "\n") && out.Len() > 0 {
+					out.WriteString("\n")
+				}
+				out.WriteString("---\n")
+				out.WriteString(appRender)
+				pending = append(pending, appRender)
+			}
+		}
+	}
+
+	return out.String(), nil
+}
+
+// renderSource renders a single source. Sources that can't be rendered from
+// the checkout, charts from Helm repositories or other git repositories,
+// are skipped.
+func renderSource(app Application, src Source, root string, opts Options) (string, error) {
+	debug := opts.Render.Debug
+
+	switch {
+	case src.Chart != "":
+		if debug {
+			log.Printf("Skipping chart %s of Application %s, only sources in this repository are rendered", src.Chart, app.Name)
+		}
+		return "", nil
+	case src.Path == "":
+		// A source only referenced for values files, e.g. '$values/...'
+		return "", nil
+	case !opts.isLocal(src.RepoURL):
+		if debug {
+			log.Printf("Skipping source %s of Application %s, it is not in this repository", src.RepoURL, app.Name)
+		}
+		return "", nil
+	}
+
+	path := filepath.Join(root, src.Path)
+	if rel, err := filepath.Rel(root, path); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("source path %s is outside the repository", src.Path)
+	}
+
+	renderer, err := diff.DetectRenderer(path)
+	if err != nil {
+		// Argo CD deploys plain directories of manifests as is
+		return readDirectory(path)
+	}
+
+	renderOpts := opts.Render
+	renderOpts.Renderer = renderer
+	renderOpts.ReleaseName = app.Name
+	renderOpts.Values = nil
+	renderOpts.ShowOnly = nil
+	renderOpts.Update = false
+	renderOpts.Lint = false
+
+	if renderer == diff.RendererHelm && src.Helm != nil {
+		cleanup, err := helmOptions(app, src, path, &renderOpts)
+		defer cleanup()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if renderer != diff.RendererHelm && src.Kustomize != nil {
+		overlay, err := writeOverlay(path, src.Kustomize)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = os.RemoveAll(overlay) }()
+		path = overlay
+	}
+
+	return diff.RenderManifests(path, renderOpts)
+}
+
+// helmOptions applies the Helm options of the source to the render options.
+// Inline values and parameters are written to a temporary values file, merged
+// after the value files like Argo CD does. The returned cleanup function
+// removes it.
+func helmOptions(app Application, src Source, path string, opts *diff.RenderOptions) (func(), error) {
+	cleanup := func() {}
+	h := src.Helm
+
+	if h.ReleaseName != "" {
+		opts.ReleaseName = h.ReleaseName
+	}
+
+	for _, f := range h.ValueFiles {
+		if strings.HasPrefix(f, "$") {
+			if opts.Debug {
+				log.Printf("Skipping values file %s of Application %s, multi-source value references are not supported", f, app.Name)
+			}
+			continue
+		}
+		opts.Values = append(opts.Values, helm.ResolveValuesPath(path, f))
+	}
+
+	values := map[string]any{}
+	if h.Values != "" {
+		if err := yaml.Unmarshal([]byte(h.Values), &values); err != nil {
+			return cleanup, fmt.Errorf("failed to parse helm values: %w", err)
+		}
+	}
+	for k, v := range h.ValuesObject {
+		values[k] = v
+	}
+	for _, p := range h.Parameters {
+		parse := strvals.ParseInto
+		if p.ForceString {
+			parse = strvals.ParseIntoString
+		}
+		if err := parse(fmt.Sprintf("%s=%s", p.Name, p.Value), values); err != nil {
+			return cleanup, fmt.Errorf("failed to parse helm parameter %s: %w", p.Name, err)
+		}
+	}
+	if len(values) == 0 {
+		return cleanup, nil
+	}
+
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return cleanup, err
+	}
+	f, err := os.CreateTemp("", "rdv-argocd-values-*.yaml")
+	if err != nil {
+		return cleanup, fmt.Errorf("failed to create values file: %w", err)
+	}
+	cleanup = func() { _ = os.Remove(f.Name()) }
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Write(data); err != nil {
+		return cleanup, fmt.Errorf("failed to write values file: %w", err)
+	}
+	opts.Values = append(opts.Values, f.Name())
+
+	return cleanup, nil
+}
+
+// writeOverlay writes a kustomization to a temporary directory that applies
+// the kustomize options of the source on top of path, and returns the directory
+func writeOverlay(path string, k *KustomizeSource) (string, error) {
+	dir, err := os.MkdirTemp("", "rdv-argocd-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kustomize overlay: %w", err)
+	}
+
+	// kustomize doesn't accept absolute paths as bases
+	base, err := filepath.Rel(dir, path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to create kustomize overlay: %w", err)
+	}
+
+	kustomization := map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  []string{base},
+	}
+	if k.NamePrefix != "" {
+		kustomization["namePrefix"] = k.NamePrefix
+	}
+	if k.NameSuffix != "" {
+		kustomization["nameSuffix"] = k.NameSuffix
+	}
+	if k.Namespace != "" {
+		kustomization["namespace"] = k.Namespace
+	}
+	if len(k.CommonLabels) > 0 {
+		kustomization["commonLabels"] = k.CommonLabels
+	}
+	if len(k.CommonAnnotations) > 0 {
+		kustomization["commonAnnotations"] = k.CommonAnnotations
+	}
+	if len(k.Images) > 0 {
+		images := make([]map[string]string, len(k.Images))
+		for i, image := range k.Images {
+			images[i] = parseImageOverride(image)
+		}
+		kustomization["images"] = images
+	}
+
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), data, 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write kustomize overlay: %w", err)
+	}
+
+	return dir, nil
+}
+
+// parseImageOverride converts an Argo CD image override,
+// '[old_image=]new_image[:tag|@digest]', to a kustomize image entry
+func parseImageOverride(image string) map[string]string {
+	entry := map[string]string{}
+
+	name, override, found := strings.Cut(image, "=")
+	if !found {
+		override = name
+	}
+
+	if ref, digest, ok := strings.Cut(override, "@"); ok {
+		override = ref
+		entry["digest"] = digest
+	} else if i := strings.LastIndex(override, ":"); i > strings.LastIndex(override, "/") {
+		entry["newTag"] = override[i+1:]
+		override = override[:i]
+	}
+
+	if found {
+		entry["name"] = name
+		entry["newName"] = override
+	} else {
+		entry["name"] = override
+	}
+
+	return entry
+}
+
+// readDirectory returns the YAML and JSON files of a plain directory source,
+// subdirectories are not included like Argo CD's default
+func readDirectory(path string) (string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	var docs []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+		if err != nil {
+			return "", err
+		}
+		if ext == ".json" {
+			if data, err = yaml.JSONToYAML(data); err != nil {
+				return "", fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+			}
+		}
+		docs = append(docs, strings.TrimPrefix(string(data), "---\n"))
+	}
+
+	return strings.Join(docs, "---\n"), nil
+}
@@ -0,0 +1,233 @@
+// Package artifact uploads report files to object storage, so diffs too
+// large for comments can be linked instead. Buckets are addressed by URL:
+// s3://bucket/prefix/, gs://bucket/prefix/ or azblob://container/prefix/.
+// Credentials are read from the environment variables of each
+// provider's CLI, without their SDKs.
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Schemes of the object stores
+const (
+	SchemeS3    = "s3"
+	SchemeGCS   = "gs"
+	SchemeAzure = "azblob"
+)
+
+// Bucket is a bucket or container objects are uploaded to under a prefix
+type Bucket struct {
+	scheme string
+	// bucket is the bucket, or the container on Azure
+	bucket string
+	// prefix is prepended to the names of uploaded objects
+	prefix string
+	// endpoint is the base URL objects are put under, followed by the
+	// bucket for path style requests
+	endpoint  string
+	pathStyle bool
+
+	// S3
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	// GCS
+	token string
+	// Azure
+	sas string
+
+	http *http.Client
+	now  func() time.Time
+}
+
+// Open parses a bucket URL and reads the credentials of its provider:
+//
+//   - s3: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+//     AWS_REGION or the region query parameter, AWS_ENDPOINT_URL for S3
+//     compatible stores
+//   - gs: an OAuth access token in GOOGLE_OAUTH_ACCESS_TOKEN or
+//     CLOUDSDK_AUTH_ACCESS_TOKEN, e.g. from 'gcloud auth print-access-token'
+//   - azblob: AZURE_STORAGE_ACCOUNT and a SAS token with write access in
+//     AZURE_STORAGE_SAS_TOKEN
+func Open(rawURL string) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifact URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid artifact URL %q, must name a bucket like s3://bucket/prefix/", rawURL)
+	}
+
+	b := &Bucket{
+		scheme: u.Scheme,
+		bucket: u.Host,
+		prefix: strings.TrimPrefix(u.Path, "/"),
+		http:   &http.Client{Timeout: 2 * time.Minute},
+		now:    time.Now,
+	}
+	if b.prefix != "" && !strings.HasSuffix(b.prefix, "/") {
+		b.prefix += "/"
+	}
+
+	switch u.Scheme {
+	case SchemeS3:
+		err = b.openS3(u.Query().Get("region"))
+	case SchemeGCS:
+		err = b.openGCS()
+	case SchemeAzure:
+		err = b.openAzure()
+	default:
+		return nil, fmt.Errorf("unsupported artifact URL scheme %q, must be %s, %s or %s", u.Scheme, SchemeS3, SchemeGCS, SchemeAzure)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Bucket) openS3(region string) error {
+	b.accessKey, b.secretKey, b.sessionToken = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+	if b.accessKey == "" || b.secretKey == "" {
+		return errors.New("s3 artifact uploads need AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	b.region = firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region != "" {
+		b.region = region
+	}
+	if b.region == "" {
+		b.region = "us-east-1"
+	}
+
+	// S3 compatible stores like MinIO take path style requests
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		b.endpoint, b.pathStyle = strings.TrimSuffix(endpoint, "/"), true
+		return nil
+	}
+	b.endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", b.bucket, b.region)
+	return nil
+}
+
+func (b *Bucket) openGCS() error {
+	b.token = firstEnv("GOOGLE_OAUTH_ACCESS_TOKEN", "CLOUDSDK_AUTH_ACCESS_TOKEN")
+	if b.token == "" {
+		return errors.New("gs artifact uploads need an access token in GOOGLE_OAUTH_ACCESS_TOKEN or CLOUDSDK_AUTH_ACCESS_TOKEN")
+	}
+
+	b.endpoint, b.pathStyle = "https://storage.googleapis.com", true
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		b.endpoint = strings.TrimSuffix(host, "/")
+	}
+	return nil
+}
+
+func (b *Bucket) openAzure() error {
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	b.sas = strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if account == "" || b.sas == "" {
+		return errors.New("azblob artifact uploads need AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_SAS_TOKEN")
+	}
+
+	b.endpoint, b.pathStyle = fmt.Sprintf("https://%s.blob.core.windows.net", account), true
+	return nil
+}
+
+// Upload puts data under the prefix of the bucket as name and returns the
+// URL of the object
+func (b *Bucket) Upload(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	key := b.prefix + name
+	objectURL := b.objectURL(key)
+
+	target := objectURL
+	if b.sas != "" {
+		target += "?" + b.sas
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	switch b.scheme {
+	case SchemeS3:
+		b.signS3(req, data)
+	case SchemeGCS:
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	case SchemeAzure:
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		req.Header.Set("x-ms-version", "2021-08-06")
+	}
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		// The request URL of Azure carries the SAS token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("failed to upload %s to %s: %w", name, objectURL, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("failed to upload %s to %s: %s: %s", name, objectURL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return objectURL, nil
+}
+
+// objectURL returns the HTTP URL of an object
+func (b *Bucket) objectURL(key string) string {
+	if b.pathStyle {
+		return b.endpoint + "/" + escapePath(b.bucket+"/"+key)
+	}
+	return b.endpoint + "/" + escapePath(key)
+}
+
+// escapePath escapes the segments of an object path the way S3's
+// signature requires, all but unreserved characters are percent-encoded
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// firstEnv returns the value of the first set environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// sha256Hex returns the hex encoded SHA-256 hash of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,352 @@
+// Package cluster provides functions to read the live state of rendered
+// resources from a Kubernetes cluster, so it can be diffed like a render.
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/manifest"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// Client reads objects from the cluster of a kubeconfig context
+type Client struct {
+	// Context is the kubeconfig context in use
+	Context string
+
+	dyn       dynamic.Interface
+	disco     discovery.DiscoveryInterface
+	mapper    meta.RESTMapper
+	namespace string
+}
+
+// NewClient creates a client from kubeconfig, the default loading rules
+// (KUBECONFIG, ~/.kube/config) are used if it is empty. kubeContext
+// overrides the current context and namespace the namespace of the
+// context if set.
+func NewClient(kubeconfig, kubeContext, namespace string) (*Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	overrides.Context.Namespace = namespace
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	if kubeContext == "" {
+		kubeContext = rawConfig.CurrentContext
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	namespace, _, err = clientConfig.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the default namespace: %w", err)
+	}
+
+	dyn, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	disco, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes discovery client: %w", err)
+	}
+
+	return &Client{
+		Context:   kubeContext,
+		dyn:       dyn,
+		disco:     disco,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(disco)),
+		namespace: namespace,
+	}, nil
+}
+
+// Capabilities returns the Kubernetes version of the cluster and the API
+// versions it serves, like 'helm install' passes them to templates. Groups
+// that fail discovery, e.g. an unavailable aggregated API, are left out.
+func (c *Client) Capabilities() (*helm.Capabilities, error) {
+	version, err := c.disco.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the kubernetes version of the cluster: %w", err)
+	}
+
+	_, resourceLists, err := c.disco.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover the APIs of the cluster: %w", err)
+	}
+
+	caps := &helm.Capabilities{KubeVersion: version.GitVersion}
+	seen := map[string]bool{}
+	add := func(apiVersion string) {
+		if !seen[apiVersion] {
+			seen[apiVersion] = true
+			caps.APIVersions = append(caps.APIVersions, apiVersion)
+		}
+	}
+	for _, list := range resourceLists {
+		add(list.GroupVersion)
+		for _, resource := range list.APIResources {
+			// Subresources like deployments/scale share the kind of their parent
+			if !strings.Contains(resource.Name, "/") {
+				add(list.GroupVersion + "/" + resource.Kind)
+			}
+		}
+	}
+	sort.Strings(caps.APIVersions)
+	return caps, nil
+}
+
+// resourceInterface returns the dynamic client for the resource, scoped to
+// its namespace, or the context's default namespace if it has none
+func (c *Client) resourceInterface(r manifest.Resource) (dynamic.ResourceInterface, error) {
+	gv, err := schema.ParseGroupVersion(r.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion for %s: %w", r, err)
+	}
+
+	mapping, err := c.mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: r.Kind}, gv.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return c.dyn.Resource(mapping.Resource), nil
+	}
+
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = c.namespace
+	}
+	return c.dyn.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// Fetch GETs every resource from the cluster and returns the live objects as
+// a YAML stream, without server managed fields. Resources that don't exist
+// in the cluster, including kinds whose CRD is not installed, are left out.
+func (c *Client) Fetch(ctx context.Context, resources []manifest.Resource, debug bool) (string, error) {
+	return c.fetch(ctx, resources, debug, func(_ manifest.Resource, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		return StripServerFields(obj), nil
+	})
+}
+
+// FetchManaged GETs every resource from the cluster like Fetch, but only
+// keeps the fields of the live objects that are set in the render. Fields
+// defaulted by the API server or added by controllers are left out, so
+// what remains differs from the render only where the object drifted.
+func (c *Client) FetchManaged(ctx context.Context, resources []manifest.Resource, debug bool) (string, error) {
+	return c.fetch(ctx, resources, debug, func(r manifest.Resource, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+		desired := map[string]any{}
+		if err := yaml.Unmarshal([]byte(r.Body), &desired); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+		pruned, _ := Prune(obj.Object, desired).(map[string]any)
+		return &unstructured.Unstructured{Object: pruned}, nil
+	})
+}
+
+// Prune returns the parts of live that are set in desired. Lists are
+// pruned entry by entry when both have the same length and kept whole
+// otherwise, so added or removed entries still show up.
+func Prune(live, desired any) any {
+	switch d := desired.(type) {
+	case map[string]any:
+		l, ok := live.(map[string]any)
+		if !ok {
+			return live
+		}
+		out := make(map[string]any, len(d))
+		for k, dv := range d {
+			if lv, ok := l[k]; ok {
+				out[k] = Prune(lv, dv)
+			}
+		}
+		return out
+	case []any:
+		l, ok := live.([]any)
+		if !ok || len(l) != len(d) {
+			return live
+		}
+		out := make([]any, len(l))
+		for i := range l {
+			out[i] = Prune(l[i], d[i])
+		}
+		return out
+	default:
+		return live
+	}
+}
+
+// fetch GETs every resource from the cluster and encodes the live objects
+// after passing them through transform
+func (c *Client) fetch(ctx context.Context, resources []manifest.Resource, debug bool, transform func(manifest.Resource, *unstructured.Unstructured) (*unstructured.Unstructured, error)) (string, error) {
+	var docs []string
+
+	for _, r := range resources {
+		ri, err := c.resourceInterface(r)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				if debug {
+					log.Printf("Kind %s is not served by the cluster, treating %s as new", r.Kind, r)
+				}
+				continue
+			}
+			return "", fmt.Errorf("failed to find API resource for %s: %w", r, err)
+		}
+
+		obj, err := ri.Get(ctx, r.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("failed to get %s from the cluster: %w", r, err)
+		}
+
+		obj, err = transform(r, obj)
+		if err != nil {
+			return "", err
+		}
+		doc, err := marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+
+	return joinDocuments(docs), nil
+}
+
+// fieldManager is the field manager used for server-side dry-run applies
+const fieldManager = "rdv"
+
+// DryRun submits every resource to the API server as a server-side apply with
+// dry-run=server and returns the objects as the server would persist them, after
+// defaulting and mutating admission webhooks, without server managed fields.
+// Resources the server can't take, e.g. a kind whose CRD is part of the same
+// render or an object in a namespace that doesn't exist yet, are kept as rendered.
+func (c *Client) DryRun(ctx context.Context, resources []manifest.Resource, debug bool) (string, error) {
+	var docs []string
+
+	for _, r := range resources {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(r.Body), &obj.Object); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+
+		result, err := c.dryRunApply(ctx, r, obj)
+		if err != nil {
+			if !meta.IsNoMatchError(err) && !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("server dry-run failed for %s: %w", r, err)
+			}
+			if debug {
+				log.Printf("Server dry-run not possible for %s, keeping it as rendered: %v", r, err)
+			}
+			result = obj
+		}
+
+		doc, err := marshal(StripServerFields(result))
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+
+	return joinDocuments(docs), nil
+}
+
+func (c *Client) dryRunApply(ctx context.Context, r manifest.Resource, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	ri, err := c.resourceInterface(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	force := true
+	return ri.Patch(ctx, r.Name, types.ApplyPatchType, data, metav1.PatchOptions{
+		DryRun:       []string{metav1.DryRunAll},
+		FieldManager: fieldManager,
+		Force:        &force,
+	})
+}
+
+// StripServerFields removes the fields set by the API server that never
+// appear in a render: status, managedFields, resourceVersion, uid, etc.
+func StripServerFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "annotations", "deployment.kubernetes.io/revision")
+
+	if annotations, _, _ := unstructured.NestedMap(obj.Object, "metadata", "annotations"); len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	}
+
+	return obj
+}
+
+// Normalize re-encodes the resources with sorted keys, the same way live
+// objects are encoded, so field order doesn't show up in the diff
+func Normalize(resources []manifest.Resource) (string, error) {
+	var docs []string
+
+	for _, r := range resources {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(r.Body), &obj.Object); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+
+		doc, err := marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc)
+	}
+
+	return joinDocuments(docs), nil
+}
+
+func marshal(obj *unstructured.Unstructured) (string, error) {
+	out, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode %s/%s: %w", obj.GetKind(), obj.GetName(), err)
+	}
+	return string(out), nil
+}
+
+func joinDocuments(docs []string) string {
+	if len(docs) == 0 {
+		return ""
+	}
+	return "---\n" + strings.Join(docs, "---\n")
+}
@@ -0,0 +1,287 @@
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func liveConfigMap() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]any{
+			"name":              "app",
+			"namespace":         "default",
+			"uid":               "1234",
+			"resourceVersion":   "42",
+			"creationTimestamp": "2024-01-01T00:00:00Z",
+			"managedFields":     []any{map[string]any{"manager": "kubectl"}},
+			"annotations": map[string]any{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+		"data": map[string]any{"key": "live"},
+	}}
+}
+
+func TestStripServerFields(t *testing.T) {
+	obj := StripServerFields(liveConfigMap())
+
+	out, err := marshal(obj)
+	if err != nil {
+		t.Fatalf("marshal() failed: %v", err)
+	}
+
+	want := `apiVersion: v1
+data:
+  key: live
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+`
+	if out != want {
+		t.Errorf("StripServerFields() =\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	c := &Client{
+		dyn:       fake.NewSimpleDynamicClient(runtime.NewScheme(), liveConfigMap()),
+		mapper:    mapper,
+		namespace: "default",
+	}
+
+	resources := []manifest.Resource{
+		// No namespace in the render, the default namespace is used
+		{APIVersion: "v1", Kind: "ConfigMap", Name: "app"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "missing"},
+		{APIVersion: "example.com/v1", Kind: "Widget", Name: "uninstalled-crd"},
+	}
+
+	live, err := c.Fetch(context.Background(), resources, false)
+	if err != nil {
+		t.Fatalf("Fetch() failed: %v", err)
+	}
+
+	if strings.Count(live, "---\n") != 1 {
+		t.Errorf("Fetch() should only return the existing ConfigMap. Got:\n%s", live)
+	}
+	if !strings.Contains(live, "key: live") || strings.Contains(live, "resourceVersion") {
+		t.Errorf("Fetch() returned unexpected content:\n%s", live)
+	}
+}
+
+func TestFetchManaged(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	c := &Client{
+		dyn:       fake.NewSimpleDynamicClient(runtime.NewScheme(), liveConfigMap()),
+		mapper:    mapper,
+		namespace: "default",
+	}
+
+	resources, err := manifest.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  key: local
+  other: local
+`)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	live, err := c.FetchManaged(context.Background(), resources, false)
+	if err != nil {
+		t.Fatalf("FetchManaged() failed: %v", err)
+	}
+
+	// The namespace and server fields aren't set in the render
+	want := "---\napiVersion: v1\ndata:\n  key: live\nkind: ConfigMap\nmetadata:\n  name: app\n"
+	if live != want {
+		t.Errorf("FetchManaged() = %q, want %q", live, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testCases := []struct {
+		name    string
+		live    any
+		desired any
+		want    any
+	}{
+		{
+			name:    "Defaulted fields are removed",
+			live:    map[string]any{"replicas": 2, "revisionHistoryLimit": 10},
+			desired: map[string]any{"replicas": 3},
+			want:    map[string]any{"replicas": 2},
+		},
+		{
+			name:    "Lists of the same length are pruned by entry",
+			live:    []any{map[string]any{"name": "web", "imagePullPolicy": "Always"}},
+			desired: []any{map[string]any{"name": "web"}},
+			want:    []any{map[string]any{"name": "web"}},
+		},
+		{
+			name:    "Lists of different lengths are kept",
+			live:    []any{"a", "b"},
+			desired: []any{"a"},
+			want:    []any{"a", "b"},
+		},
+		{
+			name:    "Changed types are kept",
+			live:    "text",
+			desired: map[string]any{"key": "value"},
+			want:    "text",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Prune(tc.live, tc.desired); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Prune() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	resources, err := manifest.Parse(`# Source: chart/templates/cm.yaml
+kind: ConfigMap
+metadata:
+  name: app
+apiVersion: v1
+`)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got, err := Normalize(resources)
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+
+	want := "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n"
+	if got != want {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	dyn := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	// The fake client doesn't implement server-side apply, respond like an
+	// API server that defaults a field and sets server managed fields
+	dyn.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		if patch.PatchType != types.ApplyPatchType {
+			t.Errorf("DryRun() sent a %s patch, want an apply patch", patch.PatchType)
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.Patch); err != nil {
+			return true, nil, err
+		}
+		obj.SetUID("1234")
+		obj.SetLabels(map[string]string{"defaulted": "true"})
+		return true, obj, nil
+	})
+
+	c := &Client{dyn: dyn, mapper: mapper, namespace: "default"}
+
+	resources, err := manifest.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+  namespace: default
+data:
+  key: local
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: uninstalled-crd
+`)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	got, err := c.DryRun(context.Background(), resources, false)
+	if err != nil {
+		t.Fatalf("DryRun() failed: %v", err)
+	}
+
+	want := `---
+apiVersion: v1
+data:
+  key: local
+kind: ConfigMap
+metadata:
+  labels:
+    defaulted: "true"
+  name: app
+  namespace: default
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: uninstalled-crd
+`
+	if got != want {
+		t.Errorf("DryRun() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	disco := &fakediscovery.FakeDiscovery{
+		Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{
+			{
+				GroupVersion: "v1",
+				APIResources: []metav1.APIResource{{Name: "configmaps", Kind: "ConfigMap"}},
+			},
+			{
+				GroupVersion: "apps/v1",
+				APIResources: []metav1.APIResource{
+					{Name: "deployments", Kind: "Deployment"},
+					{Name: "deployments/scale", Kind: "Scale"},
+				},
+			},
+		}},
+		FakedServerVersion: &version.Info{GitVersion: "v1.31.2"},
+	}
+
+	caps, err := (&Client{disco: disco}).Capabilities()
+	if err != nil {
+		t.Fatalf("Capabilities() failed: %v", err)
+	}
+
+	if caps.KubeVersion != "v1.31.2" {
+		t.Errorf("Capabilities() KubeVersion = %q, want v1.31.2", caps.KubeVersion)
+	}
+	want := []string{"apps/v1", "apps/v1/Deployment", "v1", "v1/ConfigMap"}
+	if !reflect.DeepEqual(caps.APIVersions, want) {
+		t.Errorf("Capabilities() APIVersions = %v, want %v", caps.APIVersions, want)
+	}
+}
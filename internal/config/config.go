@@ -0,0 +1,158 @@
+// Package config provides functions to load the optional .rdv.yaml
+// configuration file from the repository root, and the rule packs
+// it references.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/gate"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file looked up in the repository root
+const FileName = ".rdv.yaml"
+
+// Config is the contents of a .rdv.yaml file
+type Config struct {
+	// RulePacks are shared rule packs pulled from git or an OCI registry
+	RulePacks []RulePack `yaml:"rulePacks"`
+
+	// Flags sets flag defaults by flag name, e.g. 'validate: true'.
+	// Flags passed on the command line or through RDV_ environment
+	// variables take precedence.
+	Flags map[string]any `yaml:"flags"`
+
+	// Profiles are named sets of flags selected with --profile, e.g. the
+	// overlay, values files and Kubernetes version of an environment.
+	// They override the flags section.
+	Profiles map[string]map[string]any `yaml:"profiles"`
+
+	// Redact are regular expressions masked in the renders before they are
+	// diffed, like tokens or internal hostnames. Only the capture groups
+	// are masked in patterns that have any.
+	Redact []string `yaml:"redact"`
+
+	// CommonLabels and CommonAnnotations are added to the metadata of every
+	// rendered resource on both sides of the diff, like the tracking label
+	// of a GitOps controller, e.g. 'argocd.argoproj.io/instance: app'
+	CommonLabels      map[string]string `yaml:"commonLabels"`
+	CommonAnnotations map[string]string `yaml:"commonAnnotations"`
+
+	// FailRules fail the run when a changed resource matches one of them,
+	// e.g. a removed resource or a decreased replica count
+	FailRules []gate.Rule `yaml:"failRules"`
+
+	// RepoMirrors maps the URLs of chart repositories referenced in
+	// Chart.yaml to mirrors that Helm chart dependencies are downloaded
+	// from instead, for environments that can't reach the upstream
+	// repositories, e.g. 'https://charts.bitnami.com:
+	// https://artifactory.local/helm-bitnami'
+	RepoMirrors map[string]string `yaml:"repoMirrors"`
+
+	// Theme overrides the colors of the diff elements added, removed,
+	// modified and hunk, e.g. 'added: "#0072b2"'. The preset they
+	// override is selected with --theme.
+	Theme map[string]string `yaml:"theme"`
+
+	// Packs are the rule packs after they have been fetched, in the
+	// order they are declared in RulePacks
+	Packs []*Pack `yaml:"-"`
+}
+
+// Load reads the config file at path. If path is empty the .rdv.yaml
+// file in repoRoot is used, a missing default config is not an error.
+// Rule packs are fetched into the user cache directory.
+func Load(path, repoRoot string, debug bool) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		path = filepath.Join(repoRoot, FileName)
+	}
+
+	cfg := &Config{}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if err := gate.Compile(cfg.FailRules); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for _, rp := range cfg.RulePacks {
+		pack, err := rp.Fetch(debug)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Packs = append(cfg.Packs, pack)
+	}
+	cfg.merge()
+
+	return cfg, nil
+}
+
+// merge adds the sections of the rule packs to the config. The flags of
+// the config file and of later packs take precedence, the redact
+// patterns and fail rules of every pack apply.
+func (c *Config) merge() {
+	for i := len(c.Packs) - 1; i >= 0; i-- {
+		pack := c.Packs[i]
+
+		for name, value := range pack.Flags {
+			if _, ok := c.Flags[name]; ok {
+				continue
+			}
+			if c.Flags == nil {
+				c.Flags = map[string]any{}
+			}
+			c.Flags[name] = value
+		}
+	}
+
+	for _, pack := range c.Packs {
+		c.Redact = append(c.Redact, pack.Redact...)
+		c.FailRules = append(c.FailRules, pack.FailRules...)
+	}
+}
+
+// ProfileFlags returns the flags section with the flags of the named
+// profile applied on top, or just the flags section if name is empty
+func (c *Config) ProfileFlags(name string) (map[string]any, error) {
+	if name == "" {
+		return c.Flags, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown profile '%s', the config file has no profiles", name)
+		}
+		return nil, fmt.Errorf("unknown profile '%s', must be one of: %s", name, strings.Join(names, ", "))
+	}
+
+	flags := make(map[string]any, len(c.Flags)+len(profile))
+	for k, v := range c.Flags {
+		flags[k] = v
+	}
+	for k, v := range profile {
+		flags[k] = v
+	}
+	return flags, nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Missing default config", func(t *testing.T) {
+		cfg, err := Load("", t.TempDir(), false)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		if len(cfg.RulePacks) != 0 {
+			t.Errorf("Expected an empty config, got: %+v", cfg)
+		}
+	})
+
+	t.Run("Missing explicit config", func(t *testing.T) {
+		_, err := Load(filepath.Join(t.TempDir(), "rdv.yaml"), "", false)
+		if err == nil {
+			t.Error("Load() succeeded with a missing explicit config, expected an error")
+		}
+	})
+
+	t.Run("Invalid rule pack", func(t *testing.T) {
+		repoRoot := t.TempDir()
+		writeFile(t, filepath.Join(repoRoot, FileName), "rulePacks:\n  - path: rules\n")
+
+		_, err := Load("", repoRoot, false)
+		if err == nil {
+			t.Error("Load() succeeded with a rule pack without a source, expected an error")
+		}
+	})
+
+	t.Run("Fetches git rule pack", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		// Create a local git repository to act as our rule pack
+		packRepo := t.TempDir()
+		writeFile(t, filepath.Join(packRepo, "base", PackFileName), `name: platform-rules
+version: 1.2.0
+flags:
+  keep-noise: true
+  fail-on: [validation, policy]
+redact:
+  - 'token: (\S+)'
+failRules:
+  - name: no-removals
+    status: [removed]
+`)
+		git(t, packRepo, "init", "-q")
+		commit(t, packRepo)
+
+		repoRoot := t.TempDir()
+		writeFile(t, filepath.Join(repoRoot, FileName), "rulePacks:\n  - git: "+packRepo+"\n    path: base\nflags:\n  fail-on: [validation]\nredact:\n  - internal\\.example\\.com\n")
+
+		cfg, err := Load("", repoRoot, false)
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+
+		if len(cfg.Packs) != 1 {
+			t.Fatalf("Expected 1 rule pack, got %d", len(cfg.Packs))
+		}
+
+		if cfg.Packs[0].Name != "platform-rules" || cfg.Packs[0].Version != "1.2.0" {
+			t.Errorf("Unexpected rule pack metadata: %+v", cfg.Packs[0])
+		}
+
+		// The flags of the config file take precedence over the pack's
+		wantFlags := map[string]any{"keep-noise": true, "fail-on": []any{"validation"}}
+		if !reflect.DeepEqual(cfg.Flags, wantFlags) {
+			t.Errorf("Expected flags %v, got %v", wantFlags, cfg.Flags)
+		}
+		if wantRedact := []string{`internal\.example\.com`, `token: (\S+)`}; !reflect.DeepEqual(cfg.Redact, wantRedact) {
+			t.Errorf("Expected redact patterns %v, got %v", wantRedact, cfg.Redact)
+		}
+		if len(cfg.FailRules) != 1 || cfg.FailRules[0].Name != "no-removals" {
+			t.Errorf("Expected the fail rule of the pack, got %+v", cfg.FailRules)
+		}
+	})
+
+	t.Run("Refreshes rule packs on a branch", func(t *testing.T) {
+		t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+		packRepo := t.TempDir()
+		writeFile(t, filepath.Join(packRepo, PackFileName), "name: platform-rules\nversion: 1.0.0\n")
+		git(t, packRepo, "init", "-q", "-b", "main")
+		commit(t, packRepo)
+		git(t, packRepo, "tag", "v1")
+
+		testCases := []struct {
+			name        string
+			ref         string
+			wantVersion string
+		}{
+			{name: "Default branch", ref: "", wantVersion: "2.0.0"},
+			{name: "Branch", ref: "main", wantVersion: "2.0.0"},
+			{name: "Tag", ref: "v1", wantVersion: "1.0.0"},
+		}
+
+		// Cache every pack before the pack repository moves on
+		configs := map[string]string{}
+		for _, tc := range testCases {
+			repoRoot := t.TempDir()
+			writeFile(t, filepath.Join(repoRoot, FileName), "rulePacks:\n  - git: "+packRepo+"\n    ref: '"+tc.ref+"'\n")
+			if _, err := Load("", repoRoot, false); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			configs[tc.name] = repoRoot
+		}
+
+		writeFile(t, filepath.Join(packRepo, PackFileName), "name: platform-rules\nversion: 2.0.0\n")
+		commit(t, packRepo)
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg, err := Load("", configs[tc.name], false)
+				if err != nil {
+					t.Fatalf("Load() failed: %v", err)
+				}
+				if cfg.Packs[0].Version != tc.wantVersion {
+					t.Errorf("Expected version %s of the pack, got %s", tc.wantVersion, cfg.Packs[0].Version)
+				}
+			})
+		}
+	})
+}
+
+// git runs git in dir
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, output)
+	}
+}
+
+// commit commits every file in the repository at dir
+func commit(t *testing.T, dir string) {
+	t.Helper()
+	git(t, dir, "add", "-A")
+	git(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "rules")
+}
+
+func TestProfileFlags(t *testing.T) {
+	cfg := &Config{
+		Flags: map[string]any{"validate": true, "ref": "develop"},
+		Profiles: map[string]map[string]any{
+			"prod": {"ref": "main", "values": []any{"values-prod.yaml"}},
+			"dev":  {"env": []any{"overlays/dev"}},
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		profile string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:    "No profile",
+			profile: "",
+			want:    map[string]any{"validate": true, "ref": "develop"},
+		},
+		{
+			name:    "Profile overrides the flags section",
+			profile: "prod",
+			want:    map[string]any{"validate": true, "ref": "main", "values": []any{"values-prod.yaml"}},
+		},
+		{
+			name:    "Unknown profile",
+			profile: "staging",
+			wantErr: "unknown profile 'staging', must be one of: dev, prod",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := cfg.ProfileFlags(tc.profile)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("ProfileFlags() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProfileFlags() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ProfileFlags() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/gate"
+	"gopkg.in/yaml.v3"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// PackFileName is the file describing a rule pack, in the pack root
+const PackFileName = "rulepack.yaml"
+
+// RulePack is a versioned set of rules maintained outside the repository.
+// Exactly one of Git or OCI must be set.
+type RulePack struct {
+	// Git is a repository URL, e.g. https://github.com/org/rdv-rules.git
+	Git string `yaml:"git"`
+	// OCI is an artifact reference, e.g. ghcr.io/org/rdv-rules:v1.2.0
+	OCI string `yaml:"oci"`
+	// Ref is the git tag, branch or commit to check out. Packs on a branch
+	// or the default branch are refreshed on every run, tags and commits
+	// are fetched once.
+	Ref string `yaml:"ref"`
+	// Path is the pack directory inside the repository or artifact
+	Path string `yaml:"path"`
+}
+
+// Pack is a fetched rule pack
+type Pack struct {
+	// Source identifies where the pack was pulled from
+	Source string
+	// Dir is the local directory containing the pack files
+	Dir string
+	// Name and Version are read from the pack's rulepack.yaml
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+
+	// Flags, Redact and FailRules are merged into the config file like
+	// its own sections, see Config.merge
+	Flags     map[string]any `yaml:"flags"`
+	Redact    []string       `yaml:"redact"`
+	FailRules []gate.Rule    `yaml:"failRules"`
+}
+
+// source returns a string identifying the pack and its version
+func (rp RulePack) source() string {
+	if rp.OCI != "" {
+		return "oci://" + rp.OCI
+	}
+	source := rp.Git
+	if rp.Ref != "" {
+		source += "@" + rp.Ref
+	}
+	return source
+}
+
+// Fetch pulls the rule pack into the cache directory, unless it is already
+// cached. A cached pack that isn't pinned to a tag or commit is pulled
+// again, the cached one is used if that fails.
+func (rp RulePack) Fetch(debug bool) (*Pack, error) {
+	if (rp.Git == "") == (rp.OCI == "") {
+		return nil, fmt.Errorf("rule pack must set exactly one of 'git' or 'oci'")
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(rp.source()))
+	dir := filepath.Join(cacheDir, "rdv", "rulepacks", hex.EncodeToString(sum[:8]))
+
+	_, err = os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		if debug {
+			log.Printf("Fetching rule pack %s", rp.source())
+		}
+
+		if err := rp.pull(dir); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to fetch rule pack %s: %w", rp.source(), err)
+		}
+	case !rp.pinned(dir):
+		if debug {
+			log.Printf("Refreshing rule pack %s", rp.source())
+		}
+
+		if err := rp.refresh(dir); err != nil {
+			log.Printf("Warning: failed to refresh rule pack %s, using the cached version: %v", rp.source(), err)
+		}
+	case debug:
+		log.Printf("Using cached rule pack %s", rp.source())
+	}
+
+	packDir := filepath.Join(dir, rp.Path)
+	pack := &Pack{Source: rp.source(), Dir: packDir}
+
+	content, err := os.ReadFile(filepath.Join(packDir, PackFileName))
+	if err != nil {
+		return nil, fmt.Errorf("rule pack %s is missing %s: %w", rp.source(), PackFileName, err)
+	}
+	if err := yaml.Unmarshal(content, pack); err != nil {
+		return nil, fmt.Errorf("failed to parse %s in rule pack %s: %w", PackFileName, rp.source(), err)
+	}
+	if err := gate.Compile(pack.FailRules); err != nil {
+		return nil, fmt.Errorf("invalid %s in rule pack %s: %w", PackFileName, rp.source(), err)
+	}
+
+	return pack, nil
+}
+
+// commitPattern matches abbreviated and full git commit hashes
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// pinned reports whether the pack cached in dir can't change: OCI
+// artifacts and git packs checked out at a tag or commit. Git packs on a
+// branch or the default branch move.
+func (rp RulePack) pinned(dir string) bool {
+	if rp.OCI != "" || commitPattern.MatchString(rp.Ref) {
+		return true
+	}
+	if rp.Ref == "" {
+		return false
+	}
+	return exec.Command("git", "-C", dir, "rev-parse", "-q", "--verify", "refs/tags/"+rp.Ref).Run() == nil
+}
+
+// refresh pulls the pack again next to dir and replaces dir with it, so
+// a failed pull leaves the cached pack in place
+func (rp RulePack) refresh(dir string) error {
+	next := dir + ".next"
+	_ = os.RemoveAll(next)
+	if err := rp.pull(next); err != nil {
+		_ = os.RemoveAll(next)
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(next, dir)
+}
+
+// pull downloads the pack into dir
+func (rp RulePack) pull(dir string) error {
+	if rp.OCI != "" {
+		return pullOCI(rp.OCI, dir)
+	}
+	return pullGit(rp.Git, rp.Ref, dir)
+}
+
+// pullGit shallow fetches the repository at ref into dir. Tags are
+// fetched as tags, so pinned can tell them from branches.
+func pullGit(url, ref, dir string) error {
+	git := func(args ...string) error {
+		output, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git %s failed: %w\nOutput: %s", args[0], err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := git("init", "-q"); err != nil {
+		return err
+	}
+
+	switch {
+	case ref == "":
+		if err := git("fetch", "-q", "--depth", "1", url, "HEAD"); err != nil {
+			return err
+		}
+	case commitPattern.MatchString(ref):
+		if err := git("fetch", "-q", "--depth", "1", url, ref); err != nil {
+			return err
+		}
+	default:
+		tag := "refs/tags/" + ref
+		if git("fetch", "-q", "--depth", "1", url, "+"+tag+":"+tag) != nil {
+			if err := git("fetch", "-q", "--depth", "1", url, ref); err != nil {
+				return err
+			}
+		}
+	}
+
+	return git("checkout", "-q", "FETCH_HEAD")
+}
+
+// pullOCI copies the artifact layers into dir, like 'oras pull'.
+// Registry credentials are read from the docker config.
+func pullOCI(reference, dir string) error {
+	ctx := context.Background()
+
+	repo, err := remote.NewRepository(reference)
+	if err != nil {
+		return err
+	}
+
+	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to load registry credentials: %w", err)
+	}
+	repo.Client = &auth.Client{
+		Client:     retry.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: credentials.Credential(store),
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fs, err := file.New(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fs.Close() }()
+
+	tag := repo.Reference.Reference
+	if tag == "" {
+		return fmt.Errorf("OCI reference %q must include a tag or digest", reference)
+	}
+
+	_, err = oras.Copy(ctx, repo, tag, fs, tag, oras.DefaultCopyOptions)
+	return err
+}
+
+// PolicyDir returns the pack's 'policy' directory of Rego policies,
+// if the pack has one
+func (p *Pack) PolicyDir() (string, bool) {
+	dir := filepath.Join(p.Dir, "policy")
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return dir, true
+}
@@ -0,0 +1,144 @@
+// Package daemon implements the local socket protocol used by 'rdv daemon'.
+// A long running rdv process listens on a unix socket and runs the commands
+// sent by CLI invocations, keeping its caches warm between requests.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Request is a single rdv invocation forwarded to the daemon
+type Request struct {
+	// Dir is the working directory of the client
+	Dir string `json:"dir"`
+	// Args are the command line arguments, without the program name
+	Args []string `json:"args"`
+	// Env is the environment of the client, the daemon runs the command
+	// with it instead of its own
+	Env []string `json:"env"`
+	// Terminal is set when the client's stdout is a terminal
+	Terminal bool `json:"terminal,omitempty"`
+	// Width is the number of columns of the client's terminal, 0 if unknown
+	Width int `json:"width,omitempty"`
+}
+
+// Response holds the output of a forwarded invocation
+type Response struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// Error is set when the command failed
+	Error string `json:"error,omitempty"`
+	// ExitCode is the exit code the client exits with
+	ExitCode int `json:"exitCode,omitempty"`
+}
+
+// Handler runs a request and returns its output
+type Handler func(Request) Response
+
+// DefaultSocket returns the socket path shared by the daemon and clients
+func DefaultSocket() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "rdv", "daemon.sock"), nil
+}
+
+// Server accepts requests on a unix socket and runs them one at a time.
+// The handler is never called concurrently.
+type Server struct {
+	listener net.Listener
+	handler  Handler
+	mu       sync.Mutex
+}
+
+// Listen creates the socket and returns a Server ready to Serve.
+// A socket left behind by a daemon that exited uncleanly is replaced,
+// an error is returned if another daemon is still listening on it.
+func Listen(socket string, handler Handler) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", socket, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", socket, err)
+	}
+
+	return &Server{listener: listener, handler: handler}, nil
+}
+
+// Serve accepts connections until Close is called
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go s.handle(conn)
+	}
+}
+
+// Close stops the server and removes the socket
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Printf("Warning: failed to decode daemon request: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	resp := s.handler(req)
+	s.mu.Unlock()
+
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		log.Printf("Warning: failed to send daemon response: %v", err)
+	}
+}
+
+// Send forwards a request to the daemon listening on socket
+func Send(socket string, req Request) (*Response, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to rdv daemon on %s, is 'rdv daemon' running? %w", socket, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to send request to rdv daemon: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response from rdv daemon: %w", err)
+	}
+
+	return &resp, nil
+}
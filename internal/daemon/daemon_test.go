@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	// Unix socket paths are limited to ~100 characters, t.TempDir() can be too long
+	dir, err := os.MkdirTemp("", "rdv")
+	if err != nil {
+		t.Fatalf("MkdirTemp() failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	socket := filepath.Join(dir, "daemon.sock")
+
+	server, err := Listen(socket, func(req Request) Response {
+		if len(req.Args) == 0 {
+			return Response{Error: "no args"}
+		}
+		return Response{Stdout: req.Dir + ": " + strings.Join(req.Args, " ")}
+	})
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	go func() { _ = server.Serve() }()
+	defer func() { _ = server.Close() }()
+
+	t.Run("Request is handled", func(t *testing.T) {
+		resp, err := Send(socket, Request{Dir: "/repo", Args: []string{"--path", "chart"}})
+		if err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if resp.Stdout != "/repo: --path chart" {
+			t.Errorf("Stdout = %q, want %q", resp.Stdout, "/repo: --path chart")
+		}
+	})
+
+	t.Run("Error is returned", func(t *testing.T) {
+		resp, err := Send(socket, Request{Dir: "/repo"})
+		if err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+		if resp.Error != "no args" {
+			t.Errorf("Error = %q, want %q", resp.Error, "no args")
+		}
+	})
+
+	t.Run("Second daemon is refused", func(t *testing.T) {
+		_, err := Listen(socket, nil)
+		if err == nil {
+			t.Fatal("Listen() succeeded, but expected an error for a socket in use")
+		}
+	})
+
+	t.Run("No daemon listening", func(t *testing.T) {
+		_, err := Send(filepath.Join(dir, "missing.sock"), Request{})
+		if err == nil {
+			t.Fatal("Send() succeeded, but expected an error without a daemon")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+// Package deprecation detects resources using Kubernetes API versions
+// that are deprecated or removed in a given Kubernetes version.
+package deprecation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// Version is a Kubernetes minor release, e.g. 1.29
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// less reports whether v is an earlier release than o
+func (v Version) less(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major < o.Major
+	}
+	return v.Minor < o.Minor
+}
+
+// ParseVersion parses a Kubernetes version like '1.29', 'v1.29.3'
+// or '1.29.3-gke.100'. The patch version is ignored.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(s, "v"), ".", 3)
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected e.g. 1.29", s)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q: %w", s, err)
+	}
+	minor, err := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q: %w", s, err)
+	}
+
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// API is a deprecated apiVersion of a kind
+type API struct {
+	APIVersion   string
+	Kind         string
+	DeprecatedIn Version
+	RemovedIn    Version
+	// Replacement is the apiVersion to migrate to, empty if the kind was removed
+	Replacement string
+}
+
+// Finding is a resource using a deprecated or removed API
+type Finding struct {
+	Resource manifest.Resource
+	API      API
+	// Removed is set if the API is no longer served in the checked version
+	Removed bool
+}
+
+// Check returns a finding for every resource using an API that is
+// deprecated or removed in version. Removed APIs are listed first.
+func Check(resources []manifest.Resource, version Version) []Finding {
+	var findings []Finding
+
+	for _, r := range resources {
+		api, ok := lookup(r.APIVersion, r.Kind)
+		if !ok || version.less(api.DeprecatedIn) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Resource: r,
+			API:      api,
+			Removed:  !version.less(api.RemovedIn),
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Removed && !findings[j].Removed
+	})
+
+	return findings
+}
+
+func lookup(apiVersion, kind string) (API, bool) {
+	for _, api := range apis {
+		if api.APIVersion == apiVersion && api.Kind == kind {
+			return api, true
+		}
+	}
+	return API{}, false
+}
+
+func v(minor int) Version {
+	return Version{Major: 1, Minor: minor}
+}
+
+// apis lists the deprecated built-in APIs, from the Kubernetes
+// deprecated API migration guide
+var apis = []API{
+	// Removed in 1.16
+	{"extensions/v1beta1", "Deployment", v(9), v(16), "apps/v1"},
+	{"extensions/v1beta1", "DaemonSet", v(9), v(16), "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", v(9), v(16), "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", v(9), v(16), "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", v(10), v(16), "policy/v1beta1"},
+	{"apps/v1beta1", "Deployment", v(9), v(16), "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", v(9), v(16), "apps/v1"},
+	{"apps/v1beta2", "Deployment", v(9), v(16), "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", v(9), v(16), "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", v(9), v(16), "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", v(9), v(16), "apps/v1"},
+
+	// Removed in 1.22
+	{"extensions/v1beta1", "Ingress", v(14), v(22), "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", v(19), v(22), "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", v(19), v(22), "networking.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", v(16), v(22), "apiextensions.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", v(16), v(22), "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", v(16), v(22), "admissionregistration.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", v(19), v(22), "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io/v1beta1", "TokenReview", v(19), v(22), "authentication.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "LocalSubjectAccessReview", v(19), v(22), "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectAccessReview", v(19), v(22), "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SubjectAccessReview", v(19), v(22), "authorization.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", v(19), v(22), "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", v(19), v(22), "coordination.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", v(17), v(22), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", v(17), v(22), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", v(17), v(22), "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", v(17), v(22), "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", v(14), v(22), "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", v(19), v(22), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", v(17), v(22), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", v(19), v(22), "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", v(19), v(22), "storage.k8s.io/v1"},
+
+	// Removed in 1.25
+	{"batch/v1beta1", "CronJob", v(21), v(25), "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", v(21), v(25), "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", v(19), v(25), "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", v(22), v(25), "autoscaling/v2"},
+	{"policy/v1beta1", "PodDisruptionBudget", v(21), v(25), "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", v(21), v(25), ""},
+	{"node.k8s.io/v1beta1", "RuntimeClass", v(22), v(25), "node.k8s.io/v1"},
+
+	// Removed in 1.26
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", v(23), v(26), "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", v(23), v(26), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", v(23), v(26), "flowcontrol.apiserver.k8s.io/v1"},
+
+	// Removed in 1.27
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", v(24), v(27), "storage.k8s.io/v1"},
+
+	// Removed in 1.29
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", v(26), v(29), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", v(26), v(29), "flowcontrol.apiserver.k8s.io/v1"},
+
+	// Removed in 1.32
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", v(29), v(32), "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", v(29), v(32), "flowcontrol.apiserver.k8s.io/v1"},
+}
@@ -0,0 +1,96 @@
+package deprecation
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    Version
+		wantErr bool
+	}{
+		{input: "1.29", want: Version{1, 29}},
+		{input: "v1.25.3", want: Version{1, 25}},
+		{input: "1.27+", want: Version{1, 27}},
+		{input: "1.28.2-gke.1157000", want: Version{1, 28}},
+		{input: "latest", wantErr: true},
+		{input: "1", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseVersion(tc.input)
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseVersion(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseVersion(%q) = %v, want %v", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	resources := []manifest.Resource{
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+		{APIVersion: "batch/v1beta1", Kind: "CronJob", Name: "cleanup"},
+		{APIVersion: "autoscaling/v2beta2", Kind: "HorizontalPodAutoscaler", Name: "api"},
+		{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget", Name: "api"},
+	}
+
+	testCases := []struct {
+		name        string
+		version     Version
+		wantRemoved []string
+		wantDeprec  []string
+	}{
+		{
+			name:    "Before any deprecation",
+			version: Version{1, 20},
+		},
+		{
+			name:       "Deprecated but still served",
+			version:    Version{1, 23},
+			wantDeprec: []string{"CronJob", "HorizontalPodAutoscaler", "PodDisruptionBudget"},
+		},
+		{
+			name:        "Removed and deprecated",
+			version:     Version{1, 25},
+			wantRemoved: []string{"CronJob", "PodDisruptionBudget"},
+			wantDeprec:  []string{"HorizontalPodAutoscaler"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := Check(resources, tc.version)
+
+			var removed, deprecated []string
+			for _, f := range findings {
+				if f.Removed {
+					removed = append(removed, f.Resource.Kind)
+				} else {
+					deprecated = append(deprecated, f.Resource.Kind)
+				}
+			}
+
+			if !slices.Equal(removed, tc.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tc.wantRemoved)
+			}
+			if !slices.Equal(deprecated, tc.wantDeprec) {
+				t.Errorf("deprecated = %v, want %v", deprecated, tc.wantDeprec)
+			}
+			// Removed APIs are listed first
+			for i := 1; i < len(findings); i++ {
+				if findings[i].Removed && !findings[i-1].Removed {
+					t.Errorf("findings are not sorted, removed API after a deprecated one: %+v", findings)
+				}
+			}
+		})
+	}
+}
@@ -2,12 +2,18 @@
 package diff
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/dlactin/rdv/internal/helm"
 	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/retry"
 	"github.com/gonvenience/bunt"
 	"github.com/gonvenience/ytbx"
 	"github.com/hexops/gotextdiff"
@@ -17,62 +23,236 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
-// ANSI codes for diff colors
+// ANSI codes for diff highlighting, the colors come from the Theme
 const (
-	colorRed   = "\033[31m"
-	colorGreen = "\033[32m"
-	colorCyan  = "\033[36m"
 	colorReset = "\033[0m"
+	// Changed words of modified lines are shown in reverse video
+	colorReverse   = "\033[7m"
+	colorNoReverse = "\033[27m"
 )
 
+// Supported renderers
+const (
+	RendererAuto      = "auto"
+	RendererHelm      = "helm"
+	RendererKustomize = "kustomize"
+	// RendererKustomizeHelm builds the kustomization with the Helm
+	// chart inflator enabled, for kustomizations wrapping a chart
+	RendererKustomizeHelm = "kustomize-helm"
+	// RendererTimoni builds an instance of a Timoni module with the
+	// timoni CLI
+	RendererTimoni = "timoni"
+)
+
+// RenderOptions configures how a Helm Chart or Kustomization is rendered
+type RenderOptions struct {
+	// Renderer is one of the Renderer constants, defaults to auto
+	Renderer string
+	// ReleaseName is the Helm release name, defaults to 'release'
+	ReleaseName string
+	// Namespace is the Helm release and Timoni instance namespace,
+	// defaults to 'default'
+	Namespace string
+	// Values are additional Helm values files, merged in order
+	Values []string
+	// ShowOnly limits a Helm render to templates matching these globs
+	ShowOnly []string
+	// Kustomize configures kustomize builds
+	Kustomize kustomize.Options
+	Debug     bool
+	// Update runs 'helm dependency update' before building dependencies
+	Update bool
+	// Lint runs 'helm lint' on the chart before rendering
+	Lint bool
+	// ResolveRefs resolves vals references like 'ref+vault://' in the
+	// values files before rendering
+	ResolveRefs bool
+	// Capabilities override the Kubernetes version and API versions Helm
+	// charts are rendered for
+	Capabilities *helm.Capabilities
+	// Fetch retries chart dependency downloads and remote kustomize bases
+	Fetch retry.Policy
+}
+
+// ambiguousPaths are the paths the renderer choice was logged for
+var ambiguousPaths sync.Map
+
+// DetectRenderer picks the renderer for a path, the first built-in
+// renderer or plugin that detects it. If the path contains both a
+// Chart.yaml and a kustomization, the kustomization is assumed to wrap the
+// chart and is built with the Helm chart inflator.
+func DetectRenderer(path string) (string, error) {
+	for _, r := range Renderers() {
+		if r.Detect(path) {
+			return r.Name(), nil
+		}
+	}
+
+	return "", fmt.Errorf("path: %s is not a valid Helm Chart, Kustomization or Timoni module", path)
+}
+
 // RenderManifests will render a Helm Chart or build a Kustomization
-// and return the rendered manifests as a string
-func RenderManifests(path string, values []string, debug bool, update bool, lint bool) (string, error) {
-	var renderedManifests string
-	var err error
+// and return the rendered manifests as a string. If the path does not
+// exist, an error satisfying os.IsNotExist is returned.
+func RenderManifests(path string, opts RenderOptions) (string, error) {
+	return RenderManifestsContext(context.Background(), path, opts)
+}
+
+// RenderManifestsContext is RenderManifests with a context, which stops
+// renderer plugins when it's cancelled
+func RenderManifestsContext(ctx context.Context, path string, opts RenderOptions) (string, error) {
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
 
-	if helm.IsHelmChart(path) {
-		renderedManifests, err = helm.RenderChart(path, "release", values, debug, update, lint)
+	name := opts.Renderer
+	if name == "" || name == RendererAuto {
+		var err error
+		name, err = DetectRenderer(path)
 		if err != nil {
-			return "", fmt.Errorf("failed to render target Chart: '%w'", err)
+			return "", err
 		}
-		return renderedManifests, nil
-	} else if kustomize.IsKustomize(path) {
-		renderedManifests, err = kustomize.RenderKustomization(path)
-		if err != nil {
-			return "", fmt.Errorf("failed to build target Kustomization: '%w'", err)
+
+		// Picking one of two renderers changes the output, so it's always
+		// logged, once per path
+		if name == RendererKustomizeHelm {
+			if _, logged := ambiguousPaths.LoadOrStore(path, true); !logged {
+				log.Printf("Found both a Chart.yaml and a kustomization in %s, building the kustomization with the Helm chart inflator. Use --renderer helm or kustomize to override.", path)
+			}
 		}
-		return renderedManifests, nil
 	}
 
-	return "", fmt.Errorf("path: %s is not a valid Helm Chart or Kustomization", path)
+	renderer, err := lookupRenderer(name)
+	if err != nil {
+		return "", err
+	}
+	return renderer.Render(ctx, path, opts)
 }
 
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
 // createDiff generates a unified diff string between two text inputs.
 func CreateDiff(a, b string, fromName, toName string) string {
+	return CreateDiffWithContext(a, b, fromName, toName, DefaultContext)
+}
+
+// CreateDiffWithContext generates a unified diff string with context
+// unchanged lines around each change. A negative context includes every
+// line of the inputs in a single hunk.
+func CreateDiffWithContext(a, b string, fromName, toName string, context int) string {
 	edits := myers.ComputeEdits(span.URI(fromName), a, b)
 	diff := gotextdiff.ToUnified(fromName, toName, a, edits)
 
+	// gotextdiff always uses three lines of context, regroup the hunks otherwise
+	if context != DefaultContext && len(diff.Hunks) > 0 {
+		ops := lineOps(a, diff.Hunks)
+		if context < 0 {
+			context = len(ops)
+		}
+		diff.Hunks = regroupHunks(ops, context)
+	}
+
 	return fmt.Sprint(diff)
 }
 
+// lineOps returns an operation for every line of the diff, filling in
+// the unchanged lines of a between the hunks
+func lineOps(a string, hunks []*gotextdiff.Hunk) []gotextdiff.Line {
+	lines := strings.SplitAfter(a, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var ops []gotextdiff.Line
+	next := 0
+	for _, hunk := range hunks {
+		for ; next < hunk.FromLine-1 && next < len(lines); next++ {
+			ops = append(ops, gotextdiff.Line{Kind: gotextdiff.Equal, Content: lines[next]})
+		}
+		for _, line := range hunk.Lines {
+			ops = append(ops, line)
+			if line.Kind != gotextdiff.Insert {
+				next++
+			}
+		}
+	}
+	for ; next < len(lines); next++ {
+		ops = append(ops, gotextdiff.Line{Kind: gotextdiff.Equal, Content: lines[next]})
+	}
+
+	return ops
+}
+
+// regroupHunks groups line operations into hunks with context unchanged
+// lines around each change. Changes closer than twice the context share
+// a hunk.
+func regroupHunks(ops []gotextdiff.Line, context int) []*gotextdiff.Hunk {
+	// Line numbers in a and b of each operation
+	fromLines, toLines := make([]int, len(ops)), make([]int, len(ops))
+	fromLine, toLine := 1, 1
+	for i, op := range ops {
+		fromLines[i], toLines[i] = fromLine, toLine
+		if op.Kind != gotextdiff.Insert {
+			fromLine++
+		}
+		if op.Kind != gotextdiff.Delete {
+			toLine++
+		}
+	}
+
+	var hunks []*gotextdiff.Hunk
+	var hunk *gotextdiff.Hunk
+	// end is the index after the last change in hunk
+	end := 0
+	for i, op := range ops {
+		if op.Kind == gotextdiff.Equal {
+			continue
+		}
+
+		start := max(0, i-context)
+		if hunk != nil && start <= end+context {
+			hunk.Lines = append(hunk.Lines, ops[end:i+1]...)
+		} else {
+			if hunk != nil {
+				hunk.Lines = append(hunk.Lines, ops[end:min(len(ops), end+context)]...)
+				hunks = append(hunks, hunk)
+			}
+			hunk = &gotextdiff.Hunk{FromLine: fromLines[start], ToLine: toLines[start]}
+			hunk.Lines = append(hunk.Lines, ops[start:i+1]...)
+		}
+		end = i + 1
+	}
+	if hunk != nil {
+		hunk.Lines = append(hunk.Lines, ops[end:min(len(ops), end+context)]...)
+		hunks = append(hunks, hunk)
+	}
+
+	return hunks
+}
+
 // colorizeDiff adds simple ANSI colors to a diff string.
 func ColorizeDiff(diff string, plain bool) string {
 	if plain {
 		return diff
 	}
+	t := currentTheme()
 	var coloredDiff strings.Builder
 	lines := strings.Split(diff, "\n")
+	// Modified lines with only the changed words highlighted
+	words := highlightWords(lines)
 
-	for _, line := range lines {
+	for i, line := range lines {
 		switch {
+		case words[i] != "":
+			coloredDiff.WriteString(words[i] + "\n")
 		// Standard unified diff lines
 		case strings.HasPrefix(line, "+"):
-			coloredDiff.WriteString(colorGreen + line + colorReset + "\n")
+			coloredDiff.WriteString(sgr(t.Added) + line + colorReset + "\n")
 		case strings.HasPrefix(line, "-"):
-			coloredDiff.WriteString(colorRed + line + colorReset + "\n")
+			coloredDiff.WriteString(sgr(t.Removed) + line + colorReset + "\n")
 		case strings.HasPrefix(line, "@@"):
-			coloredDiff.WriteString(colorCyan + line + colorReset + "\n")
+			coloredDiff.WriteString(sgr(t.Hunk) + line + colorReset + "\n")
 		// --- and +++ are headers, no special color
 		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
 			coloredDiff.WriteString(line + "\n")
@@ -85,12 +265,87 @@ func ColorizeDiff(diff string, plain bool) string {
 	return coloredDiff.String()
 }
 
+// AccessibleDiff replaces the unified diff markers with explicit words,
+// so the diff can be followed without color or symbols. This is better
+// suited for screen readers and log systems that strip ANSI codes.
+func AccessibleDiff(diff string) string {
+	var accessibleDiff strings.Builder
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+
+	// Headers only come before the first hunk, after it a line like
+	// '--- a' is a removed '-- a'
+	inHunks := false
+	for _, line := range lines {
+		switch {
+		case !inHunks && strings.HasPrefix(line, "--- "):
+			accessibleDiff.WriteString("FROM: " + strings.TrimPrefix(line, "--- ") + "\n")
+		case !inHunks && strings.HasPrefix(line, "+++ "):
+			accessibleDiff.WriteString("TO: " + strings.TrimPrefix(line, "+++ ") + "\n")
+		case strings.HasPrefix(line, "@@"):
+			inHunks = true
+			accessibleDiff.WriteString("\nCHANGE AT " + strings.TrimSpace(strings.Trim(line, "@")) + "\n")
+		case strings.HasPrefix(line, "+"):
+			accessibleDiff.WriteString("ADDED: " + strings.TrimPrefix(line, "+") + "\n")
+		case strings.HasPrefix(line, "-"):
+			accessibleDiff.WriteString("REMOVED: " + strings.TrimPrefix(line, "-") + "\n")
+		case strings.HasPrefix(line, "\\"):
+			accessibleDiff.WriteString("NOTE: " + strings.TrimSpace(strings.TrimPrefix(line, "\\")) + "\n")
+		// Context lines start with a space
+		default:
+			accessibleDiff.WriteString("UNCHANGED: " + strings.TrimPrefix(line, " ") + "\n")
+		}
+	}
+
+	return accessibleDiff.String()
+}
+
+// SemanticOptions configures the dyff comparison of a semantic diff
+type SemanticOptions struct {
+	// IgnoreOrderChanges hides list entries that only moved
+	IgnoreOrderChanges bool
+	// IgnoreWhitespaceChanges hides changes in leading and trailing whitespace
+	IgnoreWhitespaceChanges bool
+	// KubernetesEntityDetection matches documents and list entries by
+	// their Kubernetes identifiers instead of their position
+	KubernetesEntityDetection bool
+	// ExcludePaths are go-patch style document paths left out of the
+	// report, e.g. /metadata/labels/team
+	ExcludePaths []string
+	// ExcludeRegexps are regular expressions matching document paths
+	// left out of the report
+	ExcludeRegexps []string
+	// Plain disables colors
+	Plain bool
+	// Width is the number of columns the report is laid out for, dyff
+	// detects the width of stdout if it's 0
+	Width int
+}
+
+// DefaultSemanticOptions returns the options tuned for Kubernetes manifests
+func DefaultSemanticOptions() SemanticOptions {
+	return SemanticOptions{
+		IgnoreOrderChanges:        true,
+		IgnoreWhitespaceChanges:   true,
+		KubernetesEntityDetection: true,
+	}
+}
+
 // This is more complex but k8s object aware diff engine
 // it is better suited for larger scale changes to a k8s resources
-func CreateSemanticDiff(targetRender, localRender, fromName, toName string, plain bool) (*dyff.HumanReport, error) {
-	// dyff is using bunt for text colouring
-	if plain {
+func CreateSemanticDiff(targetRender, localRender, fromName, toName string, opts SemanticOptions) (*SemanticReport, error) {
+	// dyff is using bunt for text colouring, whether to color is decided
+	// by the caller
+	if opts.Plain {
 		bunt.SetColorSettings(bunt.OFF, bunt.OFF)
+	} else {
+		bunt.SetColorSettings(bunt.ON, bunt.AUTO)
+	}
+
+	// dyff panics on invalid expressions
+	for _, pattern := range opts.ExcludeRegexps {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid semantic diff exclude expression %q: %w", pattern, err)
+		}
 	}
 
 	localRenderFile, err := createInputFileFromString(localRender, toName)
@@ -104,22 +359,27 @@ func CreateSemanticDiff(targetRender, localRender, fromName, toName string, plai
 	}
 
 	options := []dyff.CompareOption{
-		dyff.IgnoreOrderChanges(true),
-		dyff.KubernetesEntityDetection(true),
+		dyff.IgnoreOrderChanges(opts.IgnoreOrderChanges),
+		dyff.KubernetesEntityDetection(opts.KubernetesEntityDetection),
 		dyff.DetectRenames(true),
-		dyff.IgnoreWhitespaceChanges(true),
+		dyff.IgnoreWhitespaceChanges(opts.IgnoreWhitespaceChanges),
 	}
 
 	diff, err := dyff.CompareInputFiles(targetRenderFile, localRenderFile, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compare manifests: %w", err)
 	}
+	diff = diff.Exclude(opts.ExcludePaths...).ExcludeRegexp(opts.ExcludeRegexps...)
 
 	// Create our human readable report from our diffs
-	report := dyff.HumanReport{
-		Report:          diff,
-		OmitHeader:      true,
-		UseGoPatchPaths: true,
+	report := SemanticReport{
+		HumanReport: dyff.HumanReport{
+			Report:          diff,
+			OmitHeader:      true,
+			UseGoPatchPaths: true,
+		},
+		plain: opts.Plain,
+		width: opts.Width,
 	}
 
 	return &report, nil
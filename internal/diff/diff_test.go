@@ -1,7 +1,11 @@
 package diff
 
 import (
+	"bytes"
+	"log"
+	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -69,7 +73,7 @@ func TestRenderManifests(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output, err := RenderManifests(tc.path, tc.values, tc.debug, false, false)
+			output, err := RenderManifests(tc.path, RenderOptions{Values: tc.values, Debug: tc.debug})
 
 			if (err != nil) != tc.wantErr {
 				t.Fatalf("RenderManifests() error = %v, wantErr %v", err, tc.wantErr)
@@ -82,6 +86,97 @@ func TestRenderManifests(t *testing.T) {
 	}
 }
 
+func TestDetectRenderer(t *testing.T) {
+	// A kustomization wrapping a chart in the same directory
+	wrapped := t.TempDir()
+	chartYaml := "apiVersion: v2\nname: wrapped\nversion: 0.1.0\n"
+	if err := os.WriteFile(filepath.Join(wrapped, "Chart.yaml"), []byte(chartYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(wrapped, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Helm chart",
+			path: "../../examples/helm/helloworld",
+			want: RendererHelm,
+		},
+		{
+			name: "Kustomization",
+			path: "../../examples/kustomize/helloworld",
+			want: RendererKustomize,
+		},
+		{
+			name: "Kustomization wrapping a chart",
+			path: wrapped,
+			want: RendererKustomizeHelm,
+		},
+		{
+			name:    "Neither",
+			path:    t.TempDir(),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DetectRenderer(tc.path)
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DetectRenderer() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if got != tc.want {
+				t.Errorf("DetectRenderer() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Missing path is reported as not existing", func(t *testing.T) {
+		_, err := RenderManifests("../../examples/not-a-real-path", RenderOptions{})
+		if !os.IsNotExist(err) {
+			t.Errorf("RenderManifests() error = %v, want a not exist error", err)
+		}
+	})
+
+	t.Run("Kustomization wrapping a chart is logged once", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+
+		for range 2 {
+			_, _ = RenderManifests(wrapped, RenderOptions{})
+		}
+
+		if got := strings.Count(buf.String(), "Found both a Chart.yaml and a kustomization"); got != 1 {
+			t.Errorf("renderer choice logged %d times, want 1. Got:\n%s", got, buf.String())
+		}
+	})
+}
+
+func TestRenderRoots(t *testing.T) {
+	files := []string{
+		"examples/helm/helloworld/templates/deployment.yaml",
+		"examples/helm/helloworld/values.yaml",
+		"examples/kustomize/helloworld/configMap.yaml",
+		"README.md",
+		"cmd/root.go",
+	}
+
+	got := RenderRoots("../..", files)
+	want := []string{"examples/helm/helloworld", "examples/kustomize/helloworld"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenderRoots() = %v, want %v", got, want)
+	}
+}
+
 func TestCreateDiff(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -118,3 +213,331 @@ func TestCreateDiff(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateDiffWithContext(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	b := "1\ntwo\n3\n4\n5\n6\n7\neight\n9\n"
+
+	testCases := []struct {
+		name    string
+		context int
+		want    string
+	}{
+		{
+			name:    "Default context merges close changes",
+			context: DefaultContext,
+			want:    "--- a\n+++ b\n@@ -1,9 +1,9 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n",
+		},
+		{
+			name:    "One line of context splits hunks",
+			context: 1,
+			want:    "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 1\n-2\n+two\n 3\n@@ -7,3 +7,3 @@\n 7\n-8\n+eight\n 9\n",
+		},
+		{
+			name:    "No context",
+			context: 0,
+			want:    "--- a\n+++ b\n@@ -2 +2 @@\n-2\n+two\n@@ -8 +8 @@\n-8\n+eight\n",
+		},
+		{
+			name:    "Full context",
+			context: -1,
+			want:    "--- a\n+++ b\n@@ -1,9 +1,9 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := CreateDiffWithContext(a, b, "a", "b", tc.context)
+			if got != tc.want {
+				t.Errorf("CreateDiffWithContext() =\n%q\nWant:\n%q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Full context includes distant lines", func(t *testing.T) {
+		long := strings.Repeat("line\n", 20)
+		got := CreateDiffWithContext(long+"old\n", long+"new\n", "a", "b", -1)
+		if !strings.HasPrefix(got, "--- a\n+++ b\n@@ -1,21 +1,21 @@\n line\n") {
+			t.Errorf("CreateDiffWithContext() = %q, want a single hunk from line 1", got)
+		}
+	})
+}
+
+func TestIgnoreWhitespace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        string
+		b        string
+		want     string
+		wantDiff bool
+	}{
+		{
+			name: "Reindented list",
+			a:    "spec:\n  ports:\n  - port: 80\n",
+			b:    "spec:\n  ports:\n    - port:  80\n",
+			want: "spec:\n  ports:\n  - port: 80\n",
+		},
+		{
+			name: "Trailing whitespace and newlines",
+			a:    "data:\n  a: b\n",
+			b:    "data:  \n  a: b\n\n\n",
+			want: "data:\n  a: b\n",
+		},
+		{
+			name: "Blank line between documents",
+			a:    "a: 1\n---\nb: 2\n",
+			b:    "a: 1\n\n---\nb: 2\n",
+			want: "a: 1\n---\nb: 2\n",
+		},
+		{
+			name:     "Content changes are kept",
+			a:        "data:\n  a: b\n  c: d\n",
+			b:        "data:\n    a: b\n    c: e\n",
+			want:     "data:\n  a: b\n    c: e\n",
+			wantDiff: true,
+		},
+		{
+			name:     "Empty side",
+			a:        "a: 1\n",
+			b:        "",
+			want:     "",
+			wantDiff: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := IgnoreWhitespace(tc.a, tc.b)
+			if got != tc.want {
+				t.Errorf("IgnoreWhitespace() = %q, want %q", got, tc.want)
+			}
+			if diff := CreateDiff(tc.a, got, "a", "b"); (diff != "") != tc.wantDiff {
+				t.Errorf("Expected a diff %v after ignoring whitespace, got:\n%s", tc.wantDiff, diff)
+			}
+		})
+	}
+}
+
+func TestAccessibleDiff(t *testing.T) {
+	unified := "--- a.txt\n+++ b.txt\n@@ -1,3 +1,3 @@\n line 1\n-line 2\n+line two\n line 3\n"
+	want := "FROM: a.txt\nTO: b.txt\n\nCHANGE AT -1,3 +1,3\nUNCHANGED: line 1\nREMOVED: line 2\nADDED: line two\nUNCHANGED: line 3\n"
+
+	got := AccessibleDiff(unified)
+	if got != want {
+		t.Errorf("AccessibleDiff() =\n%q\nWant:\n%q", got, want)
+	}
+
+	t.Run("Changed lines that look like headers", func(t *testing.T) {
+		unified := "--- a.txt\n+++ b.txt\n@@ -1 +1 @@\n--- old\n+++ new\n"
+		want := "FROM: a.txt\nTO: b.txt\n\nCHANGE AT -1 +1\nREMOVED: -- old\nADDED: ++ new\n"
+
+		got := AccessibleDiff(unified)
+		if got != want {
+			t.Errorf("AccessibleDiff() =\n%q\nWant:\n%q", got, want)
+		}
+	})
+}
+
+func TestColorizeDiffWords(t *testing.T) {
+	unified := "--- a\n+++ b\n@@ -1,2 +1,2 @@\n-image: nginx:1.25\n+image: nginx:1.26\n-a\n+b\n"
+
+	got := strings.Split(ColorizeDiff(unified, false), "\n")
+
+	testCases := []struct {
+		name string
+		line int
+		want string
+	}{
+		{
+			name: "Changed tag is highlighted in removed line",
+			line: 3,
+			want: sgr("31") + "-image: nginx:1." + colorReverse + "25" + colorNoReverse + colorReset,
+		},
+		{
+			name: "Changed tag is highlighted in added line",
+			line: 4,
+			want: sgr("32") + "+image: nginx:1." + colorReverse + "26" + colorNoReverse + colorReset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got[tc.line] != tc.want {
+				t.Errorf("ColorizeDiff() line %d = %q, want %q", tc.line, got[tc.line], tc.want)
+			}
+		})
+	}
+
+	t.Run("Lines with nothing in common are colored whole", func(t *testing.T) {
+		if got[5] != sgr("31")+"-a"+colorReset || got[6] != sgr("32")+"+b"+colorReset {
+			t.Errorf("ColorizeDiff() = %q, want whole line colors for the second change", got[5:7])
+		}
+	})
+}
+
+func TestCreateSemanticDiff(t *testing.T) {
+	a := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 1\n  paused: false\n"
+	b := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  replicas: 2\n  paused: true\n"
+
+	testCases := []struct {
+		name      string
+		opts      func(*SemanticOptions)
+		wantDiffs int
+	}{
+		{name: "Default options", opts: func(*SemanticOptions) {}, wantDiffs: 2},
+		{name: "Excluded path", opts: func(o *SemanticOptions) { o.ExcludePaths = []string{"/spec/replicas"} }, wantDiffs: 1},
+		{name: "Excluded regexp", opts: func(o *SemanticOptions) { o.ExcludeRegexps = []string{"^/spec/"} }, wantDiffs: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultSemanticOptions()
+			opts.Plain = true
+			tc.opts(&opts)
+
+			report, err := CreateSemanticDiff(a, b, "a", "b", opts)
+			if err != nil {
+				t.Fatalf("CreateSemanticDiff() failed: %v", err)
+			}
+			if len(report.Diffs) != tc.wantDiffs {
+				t.Errorf("CreateSemanticDiff() returned %d diffs, want %d", len(report.Diffs), tc.wantDiffs)
+			}
+		})
+	}
+
+	t.Run("Invalid regexp", func(t *testing.T) {
+		opts := DefaultSemanticOptions()
+		opts.ExcludeRegexps = []string{"("}
+		if _, err := CreateSemanticDiff(a, b, "a", "b", opts); err == nil {
+			t.Error("CreateSemanticDiff() succeeded, expected an error for an invalid regexp")
+		}
+	})
+}
+
+func TestResourceDiffs(t *testing.T) {
+	a := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  key: old
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: removed
+`
+	b := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  key: new
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: added
+`
+
+	var diffs []string
+	err := ResourceDiffs(a, b, "main", "local", DefaultContext, func(d string) error {
+		diffs = append(diffs, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ResourceDiffs() failed: %v", err)
+	}
+
+	wantHeaders := []string{"+++ local/ConfigMap/kept", "+++ local/Secret/removed", "+++ local/Service/added"}
+	if len(diffs) != len(wantHeaders) {
+		t.Fatalf("ResourceDiffs() returned %d diffs, want %d:\n%s", len(diffs), len(wantHeaders), strings.Join(diffs, "\n"))
+	}
+	for i, header := range wantHeaders {
+		if !strings.Contains(diffs[i], header) {
+			t.Errorf("diff %d doesn't contain %q:\n%s", i, header, diffs[i])
+		}
+	}
+	if !strings.Contains(diffs[0], "-  key: old") || !strings.Contains(diffs[0], "+  key: new") {
+		t.Errorf("Expected the modified value in the first diff, got:\n%s", diffs[0])
+	}
+}
+
+func TestExecPlugin(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+detect) test -f "$2/app.jsonnet" ;;
+render) printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\ndata:\n  values: "%s"\n' "$RDV_RELEASE_NAME" "$RDV_VALUES" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "jsonnet.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Files that aren't executable aren't plugins
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("plugins\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatalf("LoadPlugins() failed: %v", err)
+	}
+	if len(plugins) != 1 || plugins[0].Name() != "jsonnet" {
+		t.Fatalf("LoadPlugins() = %v, want the jsonnet plugin", plugins)
+	}
+	SetPlugins(plugins)
+	t.Cleanup(func() { SetPlugins(nil) })
+
+	app := t.TempDir()
+	if err := os.WriteFile(filepath.Join(app, "app.jsonnet"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderer, err := DetectRenderer(app)
+	if err != nil {
+		t.Fatalf("DetectRenderer() failed: %v", err)
+	}
+	if renderer != "jsonnet" {
+		t.Errorf("DetectRenderer() = %q, want %q", renderer, "jsonnet")
+	}
+
+	// Built-in renderers are detected first
+	if renderer, _ := DetectRenderer("../../examples/helm/helloworld"); renderer != RendererHelm {
+		t.Errorf("DetectRenderer() = %q for a chart, want %q", renderer, RendererHelm)
+	}
+
+	output, err := RenderManifests(app, RenderOptions{ReleaseName: "demo", Values: []string{"a.yaml", "b.yaml"}})
+	if err != nil {
+		t.Fatalf("RenderManifests() failed: %v", err)
+	}
+	if !strings.Contains(output, "name: demo") || !strings.Contains(output, `values: "a.yaml,b.yaml"`) {
+		t.Errorf("RenderManifests() did not pass the options to the plugin. Got:\n%s", output)
+	}
+}
+
+func TestLoadPluginsBuiltinName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "helm"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPlugins(dir); err == nil {
+		t.Error("LoadPlugins() succeeded with a plugin named after a built-in renderer")
+	}
+
+	plugins, err := LoadPlugins(filepath.Join(dir, "missing"))
+	if err != nil || len(plugins) != 0 {
+		t.Errorf("LoadPlugins() = %v, %v for a missing directory, want no plugins", plugins, err)
+	}
+}
@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// execPlugin is a renderer implemented by an executable in the plugins
+// directory, named after the executable without its extension.
+//
+// 'PLUGIN detect PATH' exits with 0 when the plugin handles PATH and with
+// any other code when it doesn't. 'PLUGIN render PATH' prints the rendered
+// manifests to stdout. Both run in PATH, the render options are passed in
+// the RDV_RELEASE_NAME, RDV_NAMESPACE, RDV_VALUES, RDV_SHOW_ONLY and
+// RDV_DEBUG environment variables, lists are comma separated like the flags.
+type execPlugin struct {
+	name string
+	path string
+}
+
+// LoadPlugins returns a renderer for every executable in dir, in lexical
+// order. A missing directory has no plugins.
+func LoadPlugins(dir string) ([]Renderer, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugins directory %s: %w", dir, err)
+	}
+
+	builtin := map[string]bool{RendererAuto: true}
+	for _, r := range builtinRenderers {
+		builtin[r.Name()] = true
+	}
+
+	var renderers []Renderer
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plugin %s: %w", entry.Name(), err)
+		}
+		if info.IsDir() || info.Mode()&0o111 == 0 {
+			continue
+		}
+
+		name := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if builtin[name] {
+			return nil, fmt.Errorf("plugin %s in %s has the name of a built-in renderer", entry.Name(), dir)
+		}
+		renderers = append(renderers, &execPlugin{name: name, path: filepath.Join(dir, entry.Name())})
+	}
+
+	return renderers, nil
+}
+
+func (p *execPlugin) Name() string { return p.name }
+
+func (p *execPlugin) Detect(path string) bool {
+	cmd := exec.Command(p.path, "detect", path)
+	cmd.Dir = path
+	return cmd.Run() == nil
+}
+
+func (p *execPlugin) Render(ctx context.Context, path string, opts RenderOptions) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path, "render", path)
+	cmd.Dir = path
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(),
+		"RDV_RELEASE_NAME="+opts.ReleaseName,
+		"RDV_NAMESPACE="+opts.Namespace,
+		"RDV_VALUES="+strings.Join(opts.Values, ","),
+		"RDV_SHOW_ONLY="+strings.Join(opts.ShowOnly, ","),
+		fmt.Sprintf("RDV_DEBUG=%t", opts.Debug),
+	)
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("renderer plugin %s failed: %w: %s", p.name, err, msg)
+		}
+		return "", fmt.Errorf("renderer plugin %s failed: %w", p.name, err)
+	}
+
+	if opts.Debug && stderr.Len() > 0 {
+		log.Printf("Renderer plugin %s: %s", p.name, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,164 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/timoni"
+)
+
+// Renderer renders the manifests of a directory. The built-in renderers
+// are Helm, kustomize and Timoni, exec plugins add renderers for other
+// templating systems.
+type Renderer interface {
+	// Name is the value of --renderer that selects the renderer
+	Name() string
+	// Detect reports whether the renderer handles path, for --renderer auto
+	Detect(path string) bool
+	// Render returns the rendered manifests of path as multi-document YAML
+	Render(ctx context.Context, path string, opts RenderOptions) (string, error)
+}
+
+// builtinRenderers are tried in order by auto detection, a kustomization
+// wrapping a chart must be detected before the chart itself
+var builtinRenderers = []Renderer{kustomizeHelmRenderer{}, helmRenderer{}, kustomizeRenderer{}, timoniRenderer{}}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   []Renderer
+)
+
+// SetPlugins replaces the plugin renderers. They are detected after the
+// built-in renderers, in order.
+func SetPlugins(renderers []Renderer) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	plugins = renderers
+}
+
+// Renderers returns the built-in renderers followed by the plugins
+func Renderers() []Renderer {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	return append(append([]Renderer{}, builtinRenderers...), plugins...)
+}
+
+// RendererNames returns the values accepted by --renderer
+func RendererNames() []string {
+	names := []string{RendererAuto}
+	for _, r := range Renderers() {
+		names = append(names, r.Name())
+	}
+	return names
+}
+
+// lookupRenderer returns the renderer called name
+func lookupRenderer(name string) (Renderer, error) {
+	for _, r := range Renderers() {
+		if r.Name() == name {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported renderer %q, must be one of: %s", name, strings.Join(RendererNames(), ", "))
+}
+
+type helmRenderer struct{}
+
+func (helmRenderer) Name() string { return RendererHelm }
+
+func (helmRenderer) Detect(path string) bool { return helm.IsHelmChart(path) }
+
+func (helmRenderer) Render(_ context.Context, path string, opts RenderOptions) (string, error) {
+	releaseName := opts.ReleaseName
+	if releaseName == "" {
+		releaseName = "release"
+	}
+
+	renderedManifests, err := helm.RenderChart(path, releaseName, opts.Namespace, opts.Values, opts.ShowOnly, opts.Debug, opts.Update, opts.Lint, opts.ResolveRefs, opts.Capabilities, opts.Fetch)
+	if err != nil {
+		return "", fmt.Errorf("failed to render target Chart: '%w'", err)
+	}
+	return renderedManifests, nil
+}
+
+type kustomizeRenderer struct{}
+
+func (kustomizeRenderer) Name() string { return RendererKustomize }
+
+func (kustomizeRenderer) Detect(path string) bool { return kustomize.IsKustomize(path) }
+
+func (kustomizeRenderer) Render(_ context.Context, path string, opts RenderOptions) (string, error) {
+	opts.Kustomize.Fetch = opts.Fetch
+	renderedManifests, err := kustomize.RenderKustomization(path, opts.Kustomize)
+	if err != nil {
+		return "", fmt.Errorf("failed to build target Kustomization: '%w'", err)
+	}
+	return renderedManifests, nil
+}
+
+type kustomizeHelmRenderer struct{}
+
+func (kustomizeHelmRenderer) Name() string { return RendererKustomizeHelm }
+
+func (kustomizeHelmRenderer) Detect(path string) bool {
+	return helm.IsHelmChart(path) && kustomize.IsKustomize(path)
+}
+
+func (kustomizeHelmRenderer) Render(ctx context.Context, path string, opts RenderOptions) (string, error) {
+	opts.Kustomize.EnableHelm = true
+	return kustomizeRenderer{}.Render(ctx, path, opts)
+}
+
+type timoniRenderer struct{}
+
+func (timoniRenderer) Name() string { return RendererTimoni }
+
+func (timoniRenderer) Detect(path string) bool { return timoni.IsModule(path) }
+
+func (timoniRenderer) Render(ctx context.Context, path string, opts RenderOptions) (string, error) {
+	return timoni.Render(ctx, path, timoni.Options{
+		Instance:  opts.ReleaseName,
+		Namespace: opts.Namespace,
+		Values:    opts.Values,
+		Debug:     opts.Debug,
+	})
+}
+
+// RenderRoots returns the directories that have to be rendered to see the
+// changes to files, relative to root and sorted. Each file maps to the
+// nearest directory above it that a renderer detects, unless a Helm chart
+// or Timoni module further up contains it, since subcharts and module
+// templates are rendered with their parent. Files outside of any
+// renderable directory are ignored.
+func RenderRoots(root string, files []string) []string {
+	seen := map[string]bool{}
+	var roots []string
+
+	for _, file := range files {
+		var found string
+		for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+			if name, err := DetectRenderer(filepath.Join(root, dir)); err == nil {
+				if found == "" || name == RendererHelm || name == RendererTimoni {
+					found = dir
+				}
+			}
+			if dir == "." || dir == string(filepath.Separator) {
+				break
+			}
+		}
+
+		if found != "" && !seen[found] {
+			seen[found] = true
+			roots = append(roots, found)
+		}
+	}
+
+	sort.Strings(roots)
+	return roots
+}
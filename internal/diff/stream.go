@@ -0,0 +1,74 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+// DefaultPerResourceLines is the combined size in lines of both renders
+// above which rdv diffs resource by resource with ResourceDiffs by
+// default. Diffing a 50k line umbrella chart as a single text computes the
+// edits of the whole render at once, which is slow and memory hungry.
+const DefaultPerResourceLines = 20000
+
+// Lines returns the number of lines in a render
+func Lines(render string) int {
+	return strings.Count(render, "\n")
+}
+
+// ResourceDiffs diffs a and b resource by resource, matched by kind,
+// namespace and name, and passes the unified diff of every changed
+// resource to fn as soon as it's computed. The bodies of the resources
+// are slices of a and b, so besides the renders only the metadata of the
+// resources and the edits of one resource are held in memory at a time.
+// Resources are visited in the order of a, followed by the resources only
+// in b. Each diff is labeled with the resource after fromName and toName.
+func ResourceDiffs(a, b, fromName, toName string, context int, fn func(string) error) error {
+	from, err := manifest.Parse(a)
+	if err != nil {
+		return err
+	}
+	to, err := manifest.Parse(b)
+	if err != nil {
+		return err
+	}
+
+	// Duplicate keys are matched in order
+	remaining := map[string][]manifest.Resource{}
+	for _, r := range to {
+		remaining[r.Key()] = append(remaining[r.Key()], r)
+	}
+
+	diffResource := func(fromBody, toBody string, r manifest.Resource) error {
+		if fromBody == toBody {
+			return nil
+		}
+		name := "/" + r.String()
+		return fn(CreateDiffWithContext(fromBody, toBody, fromName+name, toName+name, context))
+	}
+
+	for _, r := range from {
+		var toBody string
+		if matches := remaining[r.Key()]; len(matches) > 0 {
+			toBody = matches[0].Body
+			remaining[r.Key()] = matches[1:]
+		}
+		if err := diffResource(r.Body, toBody, r); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range to {
+		matches := remaining[r.Key()]
+		if len(matches) == 0 || matches[0].Body != r.Body {
+			continue
+		}
+		remaining[r.Key()] = matches[1:]
+		if err := diffResource("", r.Body, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gonvenience/bunt"
+	"github.com/gonvenience/term"
+	"github.com/homeport/dyff/pkg/dyff"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Theme are the colors of a diff, as the parameters of ANSI SGR escape
+// sequences like '32', '38;5;208' or '38;2;0;114;178'. An empty color
+// keeps the color of the default theme.
+type Theme struct {
+	// Added lines and values
+	Added string
+	// Removed lines and values
+	Removed string
+	// Modified values in semantic diffs, dyff's yellow by default
+	Modified string
+	// Hunk headers of unified diffs, '@@ -1,4 +1,4 @@'
+	Hunk string
+}
+
+// Names of the preset themes
+const (
+	ThemeDefault    = "default"
+	ThemeColorblind = "colorblind"
+)
+
+// Themes are the preset themes by name. The colorblind theme uses the
+// blue and orange of the Okabe-Ito palette from the 256 color palette,
+// they are told apart with all common forms of color blindness.
+var Themes = map[string]Theme{
+	ThemeDefault: {
+		Added:   "32",
+		Removed: "31",
+		Hunk:    "36",
+	},
+	ThemeColorblind: {
+		Added:    "38;5;74",
+		Removed:  "38;5;214",
+		Modified: "38;5;227",
+		Hunk:     "38;5;175",
+	},
+}
+
+// basicColors are the names of the 4-bit colors and their foreground
+// parameter
+var basicColors = map[string]int{
+	"black":   30,
+	"red":     31,
+	"green":   32,
+	"yellow":  33,
+	"blue":    34,
+	"magenta": 35,
+	"cyan":    36,
+	"white":   37,
+}
+
+var (
+	themeMu sync.RWMutex
+	theme   = Themes[ThemeDefault]
+)
+
+// SetTheme sets the colors of the diffs
+func SetTheme(t Theme) {
+	themeMu.Lock()
+	defer themeMu.Unlock()
+	theme = t
+}
+
+// currentTheme returns the theme set with SetTheme, with the colors it
+// doesn't set taken from the default theme
+func currentTheme() Theme {
+	themeMu.RLock()
+	defer themeMu.RUnlock()
+
+	t := theme
+	defaults := Themes[ThemeDefault]
+	if t.Added == "" {
+		t.Added = defaults.Added
+	}
+	if t.Removed == "" {
+		t.Removed = defaults.Removed
+	}
+	if t.Hunk == "" {
+		t.Hunk = defaults.Hunk
+	}
+	return t
+}
+
+// NewTheme returns the preset theme with some of its colors replaced.
+// colors maps added, removed, modified and hunk to a color: a name like
+// green or bright-green, a number of the 256 color palette or a
+// truecolor hex code like #0072b2.
+func NewTheme(preset string, colors map[string]string) (Theme, error) {
+	if preset == "" {
+		preset = ThemeDefault
+	}
+	t, ok := Themes[preset]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown theme %q, must be one of %s", preset, strings.Join(themeNames(), ", "))
+	}
+
+	for element, color := range colors {
+		sgr, err := ParseColor(color)
+		if err != nil {
+			return Theme{}, fmt.Errorf("invalid %s color: %w", element, err)
+		}
+		switch element {
+		case "added":
+			t.Added = sgr
+		case "removed":
+			t.Removed = sgr
+		case "modified":
+			t.Modified = sgr
+		case "hunk":
+			t.Hunk = sgr
+		default:
+			return Theme{}, fmt.Errorf("unknown theme color %q, must be one of added, removed, modified or hunk", element)
+		}
+	}
+
+	return t, nil
+}
+
+// themeNames returns the names of the preset themes, sorted
+func themeNames() []string {
+	names := make([]string, 0, len(Themes))
+	for name := range Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColor returns the SGR parameters of a color name like green or
+// bright-green, a number of the 256 color palette or a truecolor hex code
+// like #0072b2
+func ParseColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+
+	if strings.HasPrefix(color, "#") {
+		c, err := colorful.Hex(color)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex color %q, must be like #0072b2", color)
+		}
+		r, g, b := c.RGB255()
+		return fmt.Sprintf("38;2;%d;%d;%d", r, g, b), nil
+	}
+
+	if n, err := strconv.Atoi(color); err == nil {
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("invalid color %d, must be between 0 and 255", n)
+		}
+		return fmt.Sprintf("38;5;%d", n), nil
+	}
+
+	name, bright := strings.CutPrefix(color, "bright-")
+	if code, ok := basicColors[name]; ok {
+		if bright {
+			code += 60
+		}
+		return strconv.Itoa(code), nil
+	}
+
+	return "", fmt.Errorf("unknown color %q, must be a name like green or bright-green, a number between 0 and 255 or a hex code like #0072b2", color)
+}
+
+// sgr returns the escape sequence of SGR parameters
+func sgr(params string) string {
+	return "\033[" + params + "m"
+}
+
+// dyffColors are the colors dyff uses for additions, removals and
+// modifications, see colors.go of github.com/homeport/dyff
+var dyffColors = []struct {
+	color   colorful.Color
+	element func(Theme) string
+}{
+	{mustHex("#58BF38"), func(t Theme) string { return t.Added }},
+	{bunt.LightGreen, func(t Theme) string { return t.Added }},
+	{mustHex("#B9311B"), func(t Theme) string { return t.Removed }},
+	{bunt.LightSalmon, func(t Theme) string { return t.Removed }},
+	{mustHex("#C7C43F"), func(t Theme) string { return t.Modified }},
+}
+
+func mustHex(hex string) colorful.Color {
+	c, err := colorful.Hex(hex)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// SemanticReport is a dyff report written in the colors of the theme
+type SemanticReport struct {
+	dyff.HumanReport
+	plain bool
+	width int
+}
+
+// widthMu guards the terminal width override of dyff, which is global
+var widthMu sync.Mutex
+
+// WriteReport writes the report to out. dyff's colors are replaced with
+// the colors of the theme that differ from the default theme, and it's
+// laid out for the width of the report.
+func (r *SemanticReport) WriteReport(out io.Writer) error {
+	if r.width > 0 {
+		widthMu.Lock()
+		defer widthMu.Unlock()
+		previous := term.FixedTerminalWidth
+		term.FixedTerminalWidth = r.width
+		defer func() { term.FixedTerminalWidth = previous }()
+	}
+
+	replacer := r.recolor()
+	if replacer == nil {
+		return r.HumanReport.WriteReport(out)
+	}
+
+	var buf bytes.Buffer
+	if err := r.HumanReport.WriteReport(&buf); err != nil {
+		return err
+	}
+	_, err := replacer.WriteString(out, buf.String())
+	return err
+}
+
+// recolor returns a replacer of the escape sequences of dyff's colors, as
+// bunt renders them for the terminal, with the colors of the theme. It's
+// nil if the colors aren't changed.
+func (r *SemanticReport) recolor() *strings.Replacer {
+	if r.plain {
+		return nil
+	}
+
+	t := currentTheme()
+	defaults := Themes[ThemeDefault]
+
+	var pairs []string
+	seen := map[string]bool{}
+	for _, c := range dyffColors {
+		color := c.element(t)
+		if color == "" || color == c.element(defaults) {
+			continue
+		}
+		// Only the escape sequence starting the colored text is replaced
+		rendered, _, found := strings.Cut(bunt.Style("x", bunt.Foreground(c.color)), "x")
+		if !found || rendered == "" || seen[rendered] {
+			continue
+		}
+		seen[rendered] = true
+		pairs = append(pairs, rendered, sgr(color))
+	}
+
+	if len(pairs) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(pairs...)
+}
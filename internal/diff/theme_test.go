@@ -0,0 +1,112 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gonvenience/bunt"
+)
+
+func TestNewTheme(t *testing.T) {
+	testCases := []struct {
+		name    string
+		preset  string
+		colors  map[string]string
+		want    Theme
+		wantErr bool
+	}{
+		{
+			name: "Default preset",
+			want: Theme{Added: "32", Removed: "31", Hunk: "36"},
+		},
+		{
+			name:   "Colorblind preset",
+			preset: ThemeColorblind,
+			want:   Themes[ThemeColorblind],
+		},
+		{
+			name:   "Color names, 256 colors and truecolor",
+			preset: ThemeDefault,
+			colors: map[string]string{"added": "bright-blue", "removed": "208", "modified": "#0072B2", "hunk": "magenta"},
+			want:   Theme{Added: "94", Removed: "38;5;208", Modified: "38;2;0;114;178", Hunk: "35"},
+		},
+		{
+			name:   "Colors override the preset",
+			preset: ThemeColorblind,
+			colors: map[string]string{"hunk": "cyan"},
+			want:   Theme{Added: "38;5;74", Removed: "38;5;214", Modified: "38;5;227", Hunk: "36"},
+		},
+		{name: "Unknown preset", preset: "solarized", wantErr: true},
+		{name: "Unknown element", colors: map[string]string{"context": "white"}, wantErr: true},
+		{name: "Unknown color name", colors: map[string]string{"added": "chartreuse"}, wantErr: true},
+		{name: "Color out of the 256 colors", colors: map[string]string{"added": "256"}, wantErr: true},
+		{name: "Invalid hex color", colors: map[string]string{"added": "#00ff"}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewTheme(tc.preset, tc.colors)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got theme %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewTheme() failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("NewTheme() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestThemeColors(t *testing.T) {
+	defer SetTheme(Themes[ThemeDefault])
+	defer bunt.SetColorSettings(bunt.AUTO, bunt.AUTO)
+
+	theme, err := NewTheme(ThemeColorblind, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTheme(theme)
+
+	t.Run("Unified diff", func(t *testing.T) {
+		got := ColorizeDiff("@@ -1 +1 @@\n-replicas: 1\n+replicas: 2\n", false)
+		for _, want := range []string{"\033[38;5;175m@@", "\033[38;5;214m-replicas: ", "\033[38;5;74m+replicas: "} {
+			if !strings.Contains(got, want) {
+				t.Errorf("Expected %q in the colored diff, got %q", want, got)
+			}
+		}
+	})
+
+	t.Run("Semantic diff", func(t *testing.T) {
+		a := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  mode: fast\n  removed: x\n"
+		b := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  mode: slow\n"
+
+		report, err := CreateSemanticDiff(a, b, "a", "b", DefaultSemanticOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, trueColor := range []bool{false, true} {
+			settings := bunt.OFF
+			if trueColor {
+				settings = bunt.ON
+			}
+			bunt.SetColorSettings(bunt.ON, settings)
+
+			var out bytes.Buffer
+			if err := report.WriteReport(&out); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{"\033[38;5;214m", "\033[38;5;227m"} {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected %q in the semantic diff (truecolor %t), got %q", want, trueColor, out.String())
+				}
+			}
+		}
+	})
+}
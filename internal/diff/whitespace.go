@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// IgnoreWhitespace returns b with every line that only differs from its
+// matching line in a in whitespace replaced by the line of a, and the
+// blank lines around those lines taken from a. Diffing a against the
+// result only shows changes to the content, like 'git diff -w'.
+func IgnoreWhitespace(a, b string) string {
+	aLines, bLines := strings.Split(a, "\n"), strings.Split(b, "\n")
+	aContent, aKeys := contentLines(aLines)
+	bContent, bKeys := contentLines(bLines)
+	if len(bContent) == 0 {
+		return b
+	}
+
+	// Match the content lines of both sides with all whitespace collapsed
+	aText := strings.Join(aKeys, "\n") + "\n"
+	edits := myers.ComputeEdits(span.URIFromPath(""), aText, strings.Join(bKeys, "\n")+"\n")
+	unified := gotextdiff.ToUnified("a", "b", aText, edits)
+
+	// matches maps content lines of b to the content line of a they equal
+	matches := map[int]int{}
+	ai, bi := 0, 0
+	for _, op := range lineOps(aText, unified.Hunks) {
+		switch op.Kind {
+		case gotextdiff.Equal:
+			matches[bi] = ai
+			ai++
+			bi++
+		case gotextdiff.Delete:
+			ai++
+		case gotextdiff.Insert:
+			bi++
+		}
+	}
+
+	var out []string
+	// lastA and lastB are the indexes of the last lines taken from each side
+	lastA, lastB := -1, -1
+	for k, i := range bContent {
+		if m, ok := matches[k]; ok {
+			j := aContent[m]
+			out = append(out, aLines[max(blankStart(aLines, j), lastA+1):j+1]...)
+			lastA = j
+		} else {
+			out = append(out, bLines[max(blankStart(bLines, i), lastB+1):i+1]...)
+		}
+		lastB = i
+	}
+
+	// Trailing blank lines follow the side of the last content line
+	if m, ok := matches[len(bContent)-1]; ok && m == len(aContent)-1 {
+		out = append(out, aLines[lastA+1:]...)
+	} else {
+		out = append(out, bLines[lastB+1:]...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// contentLines returns the indexes of the lines that aren't blank, and
+// each of them with its whitespace collapsed
+func contentLines(lines []string) ([]int, []string) {
+	var indexes []int
+	var keys []string
+	for i, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			indexes = append(indexes, i)
+			keys = append(keys, strings.Join(fields, " "))
+		}
+	}
+	return indexes, keys
+}
+
+// blankStart returns the index of the first of the blank lines directly
+// before line i, i itself when there are none
+func blankStart(lines []string, i int) int {
+	for i > 0 && strings.TrimSpace(lines[i-1]) == "" {
+		i--
+	}
+	return i
+}
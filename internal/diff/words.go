@@ -0,0 +1,160 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxWordTokens limits the lines compared word by word, the comparison
+// is quadratic in the number of tokens
+const maxWordTokens = 500
+
+// highlightWords pairs the removed and added lines of each change in a
+// unified diff and returns the colored lines with the changed words
+// highlighted, by line index. Changes with a different number of removed
+// and added lines, and lines with nothing in common, are left out.
+func highlightWords(lines []string) map[int]string {
+	highlighted := map[int]string{}
+	t := currentTheme()
+
+	inHunk := false
+	for i := 0; i < len(lines); i++ {
+		// File headers look like removed and added lines, hunks start after them
+		if strings.HasPrefix(lines[i], "@@") {
+			inHunk = true
+			continue
+		}
+		if !inHunk || !strings.HasPrefix(lines[i], "-") {
+			continue
+		}
+
+		removedStart := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "-") {
+			i++
+		}
+		addedStart := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "+") {
+			i++
+		}
+
+		count := addedStart - removedStart
+		if i-addedStart == count {
+			for j := 0; j < count; j++ {
+				removed, added, ok := diffWords(lines[removedStart+j][1:], lines[addedStart+j][1:])
+				if ok {
+					highlighted[removedStart+j] = sgr(t.Removed) + "-" + removed + colorReset
+					highlighted[addedStart+j] = sgr(t.Added) + "+" + added + colorReset
+				}
+			}
+		}
+		// The loop increment would skip the line after the change
+		i--
+	}
+
+	return highlighted
+}
+
+// diffWords compares two lines word by word and returns them with the
+// words that differ in reverse video. ok is false when the lines have no
+// words in common or are too long to compare.
+func diffWords(a, b string) (string, string, bool) {
+	aTokens, bTokens := tokenize(a), tokenize(b)
+	if len(aTokens) > maxWordTokens || len(bTokens) > maxWordTokens {
+		return "", "", false
+	}
+
+	// Longest common subsequence of tokens, lcs[i][j] covers aTokens[i:] and bTokens[j:]
+	lcs := make([][]int, len(aTokens)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bTokens)+1)
+	}
+	for i := len(aTokens) - 1; i >= 0; i-- {
+		for j := len(bTokens) - 1; j >= 0; j-- {
+			if aTokens[i] == bTokens[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	aChanged := make([]bool, len(aTokens))
+	bChanged := make([]bool, len(bTokens))
+	common := 0
+	i, j := 0, 0
+	for i < len(aTokens) || j < len(bTokens) {
+		switch {
+		case i < len(aTokens) && j < len(bTokens) && aTokens[i] == bTokens[j]:
+			if strings.TrimSpace(aTokens[i]) != "" {
+				common++
+			}
+			i++
+			j++
+		case j == len(bTokens) || (i < len(aTokens) && lcs[i+1][j] >= lcs[i][j+1]):
+			aChanged[i] = true
+			i++
+		default:
+			bChanged[j] = true
+			j++
+		}
+	}
+
+	// Highlighting every word is no better than coloring the line
+	if common == 0 {
+		return "", "", false
+	}
+
+	return joinTokens(aTokens, aChanged), joinTokens(bTokens, bChanged), true
+}
+
+// tokenize splits a line into runs of letters and digits, and single
+// other characters, so a changed image tag or number is a single token
+func tokenize(line string) []string {
+	var tokens []string
+
+	start := -1
+	for i, r := range line {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, line[start:i])
+			start = -1
+		}
+		tokens = append(tokens, string(r))
+	}
+	if start >= 0 {
+		tokens = append(tokens, line[start:])
+	}
+
+	return tokens
+}
+
+// joinTokens joins tokens back into a line, wrapping each run of changed
+// tokens in reverse video
+func joinTokens(tokens []string, changed []bool) string {
+	var line strings.Builder
+
+	reversed := false
+	for i, token := range tokens {
+		if changed[i] != reversed {
+			reversed = changed[i]
+			if reversed {
+				line.WriteString(colorReverse)
+			} else {
+				line.WriteString(colorNoReverse)
+			}
+		}
+		line.WriteString(token)
+	}
+	if reversed {
+		line.WriteString(colorNoReverse)
+	}
+
+	return line.String()
+}
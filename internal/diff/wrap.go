@@ -0,0 +1,55 @@
+package diff
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// minWrapWidth is the narrowest width diffs are wrapped to, narrower
+// widths leave too little of each line to read
+const minWrapWidth = 20
+
+// WrapDiff wraps the lines of a unified diff longer than width columns.
+// Continuation lines repeat the +, - or space marker of the line they
+// continue, so they are colored and read like it. File and hunk headers
+// aren't wrapped, and a width of 0 leaves the diff as it is.
+func WrapDiff(diff string, width int) string {
+	if width <= 0 {
+		return diff
+	}
+	width = max(width, minWrapWidth)
+
+	var wrapped strings.Builder
+	for i, line := range strings.Split(diff, "\n") {
+		if i > 0 {
+			wrapped.WriteByte('\n')
+		}
+		if utf8.RuneCountInString(line) <= width || !wrappable(line) {
+			wrapped.WriteString(line)
+			continue
+		}
+
+		marker, content := line[:1], []rune(line[1:])
+		for start := 0; start < len(content); start += width - 1 {
+			if start > 0 {
+				wrapped.WriteByte('\n')
+			}
+			end := min(start+width-1, len(content))
+			wrapped.WriteString(marker + string(content[start:end]))
+		}
+	}
+
+	return wrapped.String()
+}
+
+// wrappable reports whether line is an added, removed or context line of
+// a unified diff
+func wrappable(line string) bool {
+	switch {
+	case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+		return false
+	case strings.HasPrefix(line, "+"), strings.HasPrefix(line, "-"), strings.HasPrefix(line, " "):
+		return true
+	}
+	return false
+}
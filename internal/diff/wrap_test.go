@@ -0,0 +1,68 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapDiff(t *testing.T) {
+	long := "+  annotation: " + strings.Repeat("x", 30)
+
+	testCases := []struct {
+		name  string
+		diff  string
+		width int
+		want  string
+	}{
+		{
+			name:  "No width",
+			diff:  long,
+			width: 0,
+			want:  long,
+		},
+		{
+			name:  "Short lines",
+			diff:  "@@ -1 +1 @@\n-replicas: 1\n+replicas: 2",
+			width: 40,
+			want:  "@@ -1 +1 @@\n-replicas: 1\n+replicas: 2",
+		},
+		{
+			name:  "Continuation lines keep the marker",
+			diff:  " kind: ConfigMap\n" + long + "\n",
+			width: 20,
+			want:  " kind: ConfigMap\n+  annotation: xxxxx\n+xxxxxxxxxxxxxxxxxxx\n+xxxxxx\n",
+		},
+		{
+			name:  "Context lines",
+			diff:  " " + strings.Repeat("y", 25),
+			width: 20,
+			want:  " " + strings.Repeat("y", 19) + "\n " + strings.Repeat("y", 6),
+		},
+		{
+			name:  "Headers aren't wrapped",
+			diff:  "--- a/" + strings.Repeat("a", 30) + "\n+++ b/" + strings.Repeat("b", 30),
+			width: 20,
+			want:  "--- a/" + strings.Repeat("a", 30) + "\n+++ b/" + strings.Repeat("b", 30),
+		},
+		{
+			name:  "Multibyte characters",
+			diff:  "-" + strings.Repeat("ä", 25),
+			width: 20,
+			want:  "-" + strings.Repeat("ä", 19) + "\n-" + strings.Repeat("ä", 6),
+		},
+		{
+			name:  "Narrow widths are raised",
+			diff:  long,
+			width: 5,
+			want:  "+  annotation: xxxxx\n+xxxxxxxxxxxxxxxxxxx\n+xxxxxx",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := WrapDiff(tc.diff, tc.width); got != tc.want {
+				t.Errorf("WrapDiff() =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package flux resolves Flux HelmRelease and Kustomization manifests in a
+// render to the charts and kustomizations they deploy, so Flux managed
+// repositories can be diffed by what the controllers would apply.
+package flux
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"sigs.k8s.io/yaml"
+)
+
+// Object kinds and API groups handled by this package
+const (
+	kindKustomization = "Kustomization"
+	kindHelmRelease   = "HelmRelease"
+	kindGitRepository = "GitRepository"
+
+	groupKustomize = "kustomize.toolkit.fluxcd.io/"
+	groupHelm      = "helm.toolkit.fluxcd.io/"
+	groupSource    = "source.toolkit.fluxcd.io/"
+)
+
+// SourceRef references a Flux source object
+type SourceRef struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Kustomization is the part of a Flux Kustomization we need to build it
+type Kustomization struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Path            string           `json:"path"`
+		SourceRef       SourceRef        `json:"sourceRef"`
+		TargetNamespace string           `json:"targetNamespace"`
+		NamePrefix      string           `json:"namePrefix"`
+		NameSuffix      string           `json:"nameSuffix"`
+		Images          []map[string]any `json:"images"`
+		Patches         []map[string]any `json:"patches"`
+		CommonMetadata  *struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"commonMetadata"`
+		PostBuild *struct {
+			Substitute     map[string]string `json:"substitute"`
+			SubstituteFrom []ValuesReference `json:"substituteFrom"`
+		} `json:"postBuild"`
+	} `json:"spec"`
+}
+
+// HelmRelease is the part of a Flux HelmRelease we need to render it
+type HelmRelease struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		ReleaseName     string `json:"releaseName"`
+		TargetNamespace string `json:"targetNamespace"`
+		Chart           *struct {
+			Spec struct {
+				Chart       string    `json:"chart"`
+				SourceRef   SourceRef `json:"sourceRef"`
+				ValuesFiles []string  `json:"valuesFiles"`
+			} `json:"spec"`
+		} `json:"chart"`
+		Values     map[string]any    `json:"values"`
+		ValuesFrom []ValuesReference `json:"valuesFrom"`
+	} `json:"spec"`
+}
+
+// ValuesReference points at a key of a ConfigMap or Secret, used by
+// HelmRelease valuesFrom and Kustomization substituteFrom
+type ValuesReference struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	ValuesKey  string `json:"valuesKey"`
+	TargetPath string `json:"targetPath"`
+	Optional   bool   `json:"optional"`
+}
+
+// releaseName returns the Helm release name Flux would use
+func (h HelmRelease) releaseName() string {
+	if h.Spec.ReleaseName != "" {
+		return h.Spec.ReleaseName
+	}
+	if h.Spec.TargetNamespace != "" {
+		return h.Spec.TargetNamespace + "-" + h.Metadata.Name
+	}
+	return h.Metadata.Name
+}
+
+// objects holds the Flux objects found in a render
+type objects struct {
+	kustomizations []Kustomization
+	helmReleases   []HelmRelease
+	// gitRepositories maps namespace/name to the repository URL
+	gitRepositories map[string]string
+}
+
+// find parses the Flux objects in a render
+func find(render string) (objects, error) {
+	found := objects{gitRepositories: map[string]string{}}
+
+	resources, err := manifest.Parse(render)
+	if err != nil {
+		return found, err
+	}
+
+	for _, r := range resources {
+		var err error
+		switch {
+		case strings.HasPrefix(r.APIVersion, groupKustomize) && r.Kind == kindKustomization:
+			var k Kustomization
+			err = yaml.Unmarshal([]byte(r.Body), &k)
+			found.kustomizations = append(found.kustomizations, k)
+		case strings.HasPrefix(r.APIVersion, groupHelm) && r.Kind == kindHelmRelease:
+			var h HelmRelease
+			err = yaml.Unmarshal([]byte(r.Body), &h)
+			found.helmReleases = append(found.helmReleases, h)
+		case strings.HasPrefix(r.APIVersion, groupSource) && r.Kind == kindGitRepository:
+			var repo struct {
+				Spec struct {
+					URL string `json:"url"`
+				} `json:"spec"`
+			}
+			err = yaml.Unmarshal([]byte(r.Body), &repo)
+			found.gitRepositories[r.Namespace+"/"+r.Name] = repo.Spec.URL
+		}
+		if err != nil {
+			return found, fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+	}
+
+	return found, nil
+}
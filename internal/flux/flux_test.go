@@ -0,0 +1,182 @@
+package flux
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	root := t.TempDir()
+
+	writeFiles(t, root, map[string]string{
+		// A plain directory, kustomize-controller generates the kustomization
+		"apps/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: web
+data:
+  cluster: ${cluster_name}
+  region: ${region:=eu-west-1}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-values
+  namespace: web
+data:
+  values.yaml: |
+    greeting: from-valuesFrom
+    replicas: 2
+`,
+		"apps/release.yaml": `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: web
+  namespace: web
+spec:
+  chart:
+    spec:
+      chart: ./charts/web
+      sourceRef:
+        kind: GitRepository
+        name: flux-system
+        namespace: flux-system
+  valuesFrom:
+    - kind: ConfigMap
+      name: web-values
+  values:
+    replicas: 3
+`,
+		"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  greeting: {{ .Values.greeting }}
+  replicas: "{{ .Values.replicas }}"
+`,
+	})
+
+	render := `apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: flux-system
+  namespace: flux-system
+spec:
+  url: ssh://git@github.com/example/fleet
+---
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  path: ./apps
+  sourceRef:
+    kind: GitRepository
+    name: flux-system
+  commonMetadata:
+    labels:
+      team: web
+  postBuild:
+    substitute:
+      cluster_name: prod
+`
+
+	got, err := Expand(render, root, Options{RepoURLs: []string{"git@github.com:example/fleet.git"}})
+	if err != nil {
+		t.Fatalf("Expand() failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"cluster: prod",
+		"region: eu-west-1",
+		"team: web",
+		"name: web\n",
+		"greeting: from-valuesFrom",
+		`replicas: "3"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expand() output is missing %q. Got:\n%s", want, got)
+		}
+	}
+}
+
+func TestExpandOtherRepository(t *testing.T) {
+	render := `apiVersion: source.toolkit.fluxcd.io/v1
+kind: GitRepository
+metadata:
+  name: other
+  namespace: flux-system
+spec:
+  url: https://github.com/example/other
+---
+apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: apps
+  namespace: flux-system
+spec:
+  path: ./does-not-exist
+  sourceRef:
+    kind: GitRepository
+    name: other
+`
+
+	got, err := Expand(render, t.TempDir(), Options{RepoURLs: []string{"https://github.com/example/fleet"}})
+	if err != nil {
+		t.Fatalf("Expand() failed: %v", err)
+	}
+	if got != render {
+		t.Errorf("Expand() rendered a source from another repository. Got:\n%s", got)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	render := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  value: ${var}
+  unset: "${unset}"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  annotations:
+    kustomize.toolkit.fluxcd.io/substitute: disabled
+data:
+  value: ${var}
+`
+
+	got, err := substitute(render, map[string]string{"var": "set"})
+	if err != nil {
+		t.Fatalf("substitute() failed: %v", err)
+	}
+
+	if !strings.Contains(got, "value: set\n") || !strings.Contains(got, `unset: ""`) {
+		t.Errorf("substitute() did not replace variables. Got:\n%s", got)
+	}
+	if !strings.Contains(got, "value: ${var}") {
+		t.Errorf("substitute() replaced variables in a resource with substitution disabled. Got:\n%s", got)
+	}
+}
@@ -0,0 +1,474 @@
+package flux
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/manifest"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/strvals"
+	"sigs.k8s.io/yaml"
+)
+
+// substituteAnnotation disables post-build substitution for a resource
+const substituteAnnotation = "kustomize.toolkit.fluxcd.io/substitute: disabled"
+
+// Options configures how Flux objects are rendered
+type Options struct {
+	// RepoURLs are the remotes of the repository being diffed. Only sources
+	// from these repositories can be rendered from the checkout, if empty
+	// every GitRepository is assumed to be the repository.
+	RepoURLs []string
+	// Render holds the base render options, e.g. kustomize plugin settings
+	Render diff.RenderOptions
+}
+
+// expander renders Flux objects from a checkout
+type expander struct {
+	root string
+	opts Options
+	// out is the render so far, ConfigMaps and Secrets referenced by
+	// valuesFrom and substituteFrom are looked up in it
+	out strings.Builder
+	// repos maps namespace/name of every GitRepository seen to its URL
+	repos map[string]string
+}
+
+// Expand finds the Flux Kustomizations and HelmReleases in render, builds
+// them from the checkout at root and returns render with their output
+// appended. Flux objects found in those renders are expanded as well, so
+// the whole tree below a cluster entrypoint is rendered.
+func Expand(render, root string, opts Options) (string, error) {
+	e := &expander{root: root, opts: opts, repos: map[string]string{}}
+	e.out.WriteString(render)
+
+	seen := map[string]bool{}
+	pending := []string{render}
+	for len(pending) > 0 {
+		found, err := find(pending[0])
+		if err != nil {
+			return "", err
+		}
+		pending = pending[1:]
+
+		for k, v := range found.gitRepositories {
+			e.repos[k] = v
+		}
+
+		for _, k := range found.kustomizations {
+			key := kindKustomization + "/" + k.Metadata.Namespace + "/" + k.Metadata.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			built, err := e.buildKustomization(k)
+			if err != nil {
+				return "", fmt.Errorf("failed to build Flux Kustomization %s: %w", k.Metadata.Name, err)
+			}
+			if e.append(built) {
+				pending = append(pending, built)
+			}
+		}
+
+		for _, h := range found.helmReleases {
+			key := kindHelmRelease + "/" + h.Metadata.Namespace + "/" + h.Metadata.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			rendered, err := e.renderHelmRelease(h)
+			if err != nil {
+				return "", fmt.Errorf("failed to render Flux HelmRelease %s: %w", h.Metadata.Name, err)
+			}
+			if e.append(rendered) {
+				pending = append(pending, rendered)
+			}
+		}
+	}
+
+	return e.out.String(), nil
+}
+
+// append adds a render to the output and reports if it was not empty
+func (e *expander) append(render string) bool {
+	if strings.TrimSpace(render) == "" {
+		return false
+	}
+	if e.out.Len() > 0 && !strings.HasSuffix(e.out.String(), "\n") {
+		e.out.WriteString("\n")
+	}
+	e.out.WriteString("---\n")
+	e.out.WriteString(render)
+	return true
+}
+
+// sourcePath resolves a path in a GitRepository source to the checkout.
+// It returns false for sources that are not this repository.
+func (e *expander) sourcePath(ref SourceRef, namespace, path string) (string, bool, error) {
+	if ref.Kind != kindGitRepository {
+		if e.opts.Render.Debug {
+			log.Printf("Skipping %s source %s, only GitRepository sources are rendered", ref.Kind, ref.Name)
+		}
+		return "", false, nil
+	}
+
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+
+	// Sources that are not part of the render are usually the repository
+	// itself, e.g. the flux-system GitRepository created by bootstrap
+	if repoURL, ok := e.repos[namespace+"/"+ref.Name]; ok && !e.isLocal(repoURL) {
+		if e.opts.Render.Debug {
+			log.Printf("Skipping GitRepository %s (%s), it is not this repository", ref.Name, repoURL)
+		}
+		return "", false, nil
+	}
+
+	full := filepath.Join(e.root, path)
+	if rel, err := filepath.Rel(e.root, full); err != nil || strings.HasPrefix(rel, "..") {
+		return "", false, fmt.Errorf("source path %s is outside the repository", path)
+	}
+	return full, true, nil
+}
+
+// isLocal reports if repoURL points at the repository being diffed
+func (e *expander) isLocal(repoURL string) bool {
+	if len(e.opts.RepoURLs) == 0 {
+		return true
+	}
+	for _, u := range e.opts.RepoURLs {
+		if git.NormalizeURL(u) == git.NormalizeURL(repoURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildKustomization builds a Flux Kustomization the way kustomize-controller
+// does: the spec overrides are applied through an overlay, a kustomization is
+// generated for plain directories and post-build substitutions are applied.
+func (e *expander) buildKustomization(k Kustomization) (string, error) {
+	path, ok, err := e.sourcePath(k.Spec.SourceRef, k.Metadata.Namespace, k.Spec.Path)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	overlay, err := os.MkdirTemp("", "rdv-flux-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create kustomize overlay: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(overlay) }()
+
+	resources, err := overlayResources(overlay, path)
+	if err != nil {
+		return "", err
+	}
+
+	kustomization := map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	}
+	if k.Spec.TargetNamespace != "" {
+		kustomization["namespace"] = k.Spec.TargetNamespace
+	}
+	if k.Spec.NamePrefix != "" {
+		kustomization["namePrefix"] = k.Spec.NamePrefix
+	}
+	if k.Spec.NameSuffix != "" {
+		kustomization["nameSuffix"] = k.Spec.NameSuffix
+	}
+	if len(k.Spec.Images) > 0 {
+		kustomization["images"] = k.Spec.Images
+	}
+	if len(k.Spec.Patches) > 0 {
+		kustomization["patches"] = k.Spec.Patches
+	}
+	if m := k.Spec.CommonMetadata; m != nil {
+		// commonMetadata doesn't touch selectors, unlike commonLabels
+		if len(m.Labels) > 0 {
+			kustomization["labels"] = []map[string]any{{"pairs": m.Labels}}
+		}
+		if len(m.Annotations) > 0 {
+			kustomization["commonAnnotations"] = m.Annotations
+		}
+	}
+
+	data, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write kustomize overlay: %w", err)
+	}
+
+	opts := e.opts.Render
+	opts.Renderer = diff.RendererKustomize
+	// The overlay lists files from the checkout, outside of its own root
+	opts.Kustomize.LoadRestrictor = "none"
+
+	built, err := diff.RenderManifests(overlay, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if k.Spec.PostBuild == nil {
+		return built, nil
+	}
+	vars, err := e.substitutions(k)
+	if err != nil {
+		return "", err
+	}
+	return substitute(built, vars)
+}
+
+// overlayResources returns the resources entry of the overlay: the path
+// itself if it has a kustomization, otherwise every manifest below it,
+// like the kustomization kustomize-controller generates
+func overlayResources(overlay, path string) ([]string, error) {
+	if kustomize.IsKustomize(path) {
+		rel, err := filepath.Rel(overlay, path)
+		if err != nil {
+			return nil, err
+		}
+		return []string{rel}, nil
+	}
+
+	var resources []string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// Nested kustomizations are included as a whole
+			if p != path && kustomize.IsKustomize(p) {
+				resources = append(resources, p)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(p); ext == ".yaml" || ext == ".yml" {
+			resources = append(resources, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list manifests in %s: %w", path, err)
+	}
+
+	for i, r := range resources {
+		if resources[i], err = filepath.Rel(overlay, r); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// substitutions returns the post-build variables of a Kustomization,
+// substituteFrom is read first and substitute takes precedence
+func (e *expander) substitutions(k Kustomization) (map[string]string, error) {
+	vars := map[string]string{}
+
+	for _, ref := range k.Spec.PostBuild.SubstituteFrom {
+		data, ok, err := e.lookupData(ref, k.Metadata.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		for key, value := range data {
+			vars[key] = value
+		}
+	}
+	for key, value := range k.Spec.PostBuild.Substitute {
+		vars[key] = value
+	}
+
+	return vars, nil
+}
+
+// substituteVar matches ${var}, ${var:=default} and ${var:-default}
+var substituteVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::?[=-]([^}]*))?\}`)
+
+// substitute replaces the post-build variables in every resource that
+// hasn't disabled substitution. Unset variables without a default are
+// replaced with an empty string, like kustomize-controller does.
+func substitute(render string, vars map[string]string) (string, error) {
+	resources, err := manifest.Parse(render)
+	if err != nil {
+		return "", err
+	}
+
+	docs := make([]string, len(resources))
+	for i, r := range resources {
+		if strings.Contains(r.Body, substituteAnnotation) {
+			docs[i] = r.Body
+			continue
+		}
+		docs[i] = substituteVar.ReplaceAllStringFunc(r.Body, func(match string) string {
+			groups := substituteVar.FindStringSubmatch(match)
+			if value, ok := vars[groups[1]]; ok {
+				return value
+			}
+			return groups[2]
+		})
+	}
+
+	if len(docs) == 0 {
+		return "", nil
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// renderHelmRelease renders a HelmRelease whose chart is in a GitRepository.
+// Charts from Helm and OCI repositories are skipped.
+func (e *expander) renderHelmRelease(h HelmRelease) (string, error) {
+	if h.Spec.Chart == nil {
+		if e.opts.Render.Debug {
+			log.Printf("Skipping HelmRelease %s, only spec.chart is supported", h.Metadata.Name)
+		}
+		return "", nil
+	}
+	chart := h.Spec.Chart.Spec
+
+	path, ok, err := e.sourcePath(chart.SourceRef, h.Metadata.Namespace, chart.Chart)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	opts := e.opts.Render
+	opts.Renderer = diff.RendererHelm
+	opts.ReleaseName = h.releaseName()
+	opts.Values = nil
+	opts.ShowOnly = nil
+	opts.Update = false
+	opts.Lint = false
+
+	// valuesFiles are relative to the root of the source
+	for _, f := range chart.ValuesFiles {
+		opts.Values = append(opts.Values, filepath.Join(e.root, f))
+	}
+
+	values, err := e.helmValues(h)
+	if err != nil {
+		return "", err
+	}
+	if len(values) > 0 {
+		f, err := os.CreateTemp("", "rdv-flux-values-*.yaml")
+		if err != nil {
+			return "", fmt.Errorf("failed to create values file: %w", err)
+		}
+		defer func() { _ = os.Remove(f.Name()) }()
+
+		data, err := yaml.Marshal(values)
+		if err != nil {
+			return "", err
+		}
+		_, err = f.Write(data)
+		_ = f.Close()
+		if err != nil {
+			return "", fmt.Errorf("failed to write values file: %w", err)
+		}
+		opts.Values = append(opts.Values, f.Name())
+	}
+
+	return diff.RenderManifests(path, opts)
+}
+
+// helmValues merges the valuesFrom references in order, with spec.values
+// merged last like helm-controller does
+func (e *expander) helmValues(h HelmRelease) (map[string]any, error) {
+	values := map[string]any{}
+
+	for _, ref := range h.Spec.ValuesFrom {
+		if ref.ValuesKey == "" {
+			ref.ValuesKey = "values.yaml"
+		}
+
+		data, ok, err := e.lookupData(ref, h.Metadata.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		content, found := data[ref.ValuesKey]
+		if !ok || !found {
+			continue
+		}
+
+		if ref.TargetPath != "" {
+			if err := strvals.ParseInto(fmt.Sprintf("%s=%s", ref.TargetPath, content), values); err != nil {
+				return nil, fmt.Errorf("failed to set %s from %s %s: %w", ref.TargetPath, ref.Kind, ref.Name, err)
+			}
+			continue
+		}
+
+		current := map[string]any{}
+		if err := yaml.Unmarshal([]byte(content), &current); err != nil {
+			return nil, fmt.Errorf("failed to parse %s from %s %s: %w", ref.ValuesKey, ref.Kind, ref.Name, err)
+		}
+		values = chartutil.CoalesceTables(current, values)
+	}
+
+	if len(h.Spec.Values) > 0 {
+		values = chartutil.CoalesceTables(h.Spec.Values, values)
+	}
+
+	return values, nil
+}
+
+// lookupData finds the data of a ConfigMap or Secret in the render. References
+// that can't be found, e.g. Secrets created outside of git, are skipped with a
+// warning unless they are optional.
+func (e *expander) lookupData(ref ValuesReference, namespace string) (map[string]string, bool, error) {
+	resources, err := manifest.Parse(e.out.String())
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, r := range resources {
+		if r.Kind != ref.Kind || r.Name != ref.Name || (r.Namespace != "" && r.Namespace != namespace) {
+			continue
+		}
+
+		var obj struct {
+			Data       map[string]string `json:"data"`
+			StringData map[string]string `json:"stringData"`
+		}
+		if err := yaml.Unmarshal([]byte(r.Body), &obj); err != nil {
+			return nil, false, fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+
+		data := map[string]string{}
+		for k, v := range obj.Data {
+			if ref.Kind == "Secret" {
+				decoded, err := base64.StdEncoding.DecodeString(v)
+				if err != nil {
+					return nil, false, fmt.Errorf("failed to decode key %s of %s: %w", k, r, err)
+				}
+				v = string(decoded)
+			}
+			data[k] = v
+		}
+		for k, v := range obj.StringData {
+			data[k] = v
+		}
+		return data, true, nil
+	}
+
+	if !ref.Optional {
+		log.Printf("Warning: %s %s/%s is not in the render, skipping it", ref.Kind, namespace, ref.Name)
+	}
+	return nil, false, nil
+}
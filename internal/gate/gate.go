@@ -0,0 +1,212 @@
+// Package gate evaluates the fail rules of the config file against the
+// resources changed between two renders, like "fail if any resource is
+// removed" or "fail if replicas decrease", so rdv can block a change
+// and not only show it.
+package gate
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Changes a rule with a field can require
+const (
+	ChangeAny      = "any"
+	ChangeDecrease = "decrease"
+	ChangeIncrease = "increase"
+)
+
+// Rule is a fail rule from the failRules section of the config file
+type Rule struct {
+	Name string `yaml:"name"`
+	// Select limits the rule to resources matching a selector, e.g.
+	// 'kind=Deployment,name=api*'. Every resource matches if empty.
+	Select string `yaml:"select"`
+	// Status limits the rule to added, removed or modified resources,
+	// every change matches if empty
+	Status []string `yaml:"status"`
+	// Field is a dotted path, e.g. 'spec.replicas'. The rule only matches
+	// modified resources where the value at the path changed.
+	Field string `yaml:"field"`
+	// Change is any, decrease or increase. decrease and increase compare
+	// numeric field values, a missing value never matches.
+	Change string `yaml:"change"`
+	// Message explains the rule in the output
+	Message string `yaml:"message"`
+
+	selector manifest.Selector
+}
+
+// Violation is a changed resource matching a rule
+type Violation struct {
+	Rule     *Rule
+	Resource manifest.Resource
+	// Reason describes the change that matched
+	Reason string
+}
+
+// String returns the violation as printed by rdv
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s: %s %s", v.Rule.Name, v.Resource, v.Reason)
+	if v.Rule.Message != "" {
+		s += fmt.Sprintf(" (%s)", v.Rule.Message)
+	}
+	return s
+}
+
+// Compile checks the rules and parses their selectors, it must be called
+// before Evaluate
+func Compile(rules []Rule) error {
+	statuses := []string{manifest.StatusAdded, manifest.StatusRemoved, manifest.StatusModified}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("fail rule %d has no name", i+1)
+		}
+
+		if r.Select != "" {
+			selector, err := manifest.ParseSelector(r.Select)
+			if err != nil {
+				return fmt.Errorf("fail rule '%s': %w", r.Name, err)
+			}
+			r.selector = selector
+		}
+
+		for _, s := range r.Status {
+			if !slices.Contains(statuses, s) {
+				return fmt.Errorf("fail rule '%s': invalid status '%s', must be one of: %s", r.Name, s, strings.Join(statuses, ", "))
+			}
+		}
+
+		switch r.Change {
+		case "":
+			r.Change = ChangeAny
+		case ChangeAny, ChangeDecrease, ChangeIncrease:
+		default:
+			return fmt.Errorf("fail rule '%s': invalid change '%s', must be one of: any, decrease, increase", r.Name, r.Change)
+		}
+		if r.Field == "" && r.Change != ChangeAny {
+			return fmt.Errorf("fail rule '%s': change '%s' requires a field", r.Name, r.Change)
+		}
+	}
+	return nil
+}
+
+// Evaluate returns the violations of the rules by the resources changed
+// between the target and local renders
+func Evaluate(rules []Rule, target, local []manifest.Resource) ([]Violation, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	targetByKey := make(map[string]manifest.Resource, len(target))
+	for _, r := range target {
+		targetByKey[r.Key()] = r
+	}
+
+	var violations []Violation
+	for _, stat := range manifest.Stat(target, local) {
+		for i := range rules {
+			rule := &rules[i]
+			if rule.selector != nil && !rule.selector.Matches(stat.Resource) {
+				continue
+			}
+			if len(rule.Status) > 0 && !slices.Contains(rule.Status, stat.Status) {
+				continue
+			}
+
+			if rule.Field == "" {
+				violations = append(violations, Violation{Rule: rule, Resource: stat.Resource, Reason: "was " + stat.Status})
+				continue
+			}
+
+			if stat.Status != manifest.StatusModified {
+				continue
+			}
+			reason, err := rule.fieldChange(targetByKey[stat.Resource.Key()], stat.Resource)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				violations = append(violations, Violation{Rule: rule, Resource: stat.Resource, Reason: reason})
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// fieldChange describes how the rule's field changed between two
+// versions of a resource, or returns an empty string if the change
+// doesn't match the rule
+func (r *Rule) fieldChange(from, to manifest.Resource) (string, error) {
+	before, err := fieldValue(from, r.Field)
+	if err != nil {
+		return "", err
+	}
+	after, err := fieldValue(to, r.Field)
+	if err != nil {
+		return "", err
+	}
+	if reflect.DeepEqual(before, after) {
+		return "", nil
+	}
+
+	if r.Change == ChangeAny {
+		return fmt.Sprintf("changed %s", r.Field), nil
+	}
+
+	beforeNum, ok1 := number(before)
+	afterNum, ok2 := number(after)
+	if !ok1 || !ok2 {
+		return "", nil
+	}
+	if (r.Change == ChangeDecrease && afterNum < beforeNum) || (r.Change == ChangeIncrease && afterNum > beforeNum) {
+		return fmt.Sprintf("%sd %s from %v to %v", r.Change, r.Field, before, after), nil
+	}
+	return "", nil
+}
+
+// fieldValue returns the value at a dotted path in a resource, nil if
+// it's missing. Path segments index into lists when they are numbers.
+func fieldValue(r manifest.Resource, path string) (any, error) {
+	var value any
+	if err := yaml.Unmarshal([]byte(r.Body), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, nil
+			}
+			value = v[i]
+		default:
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+// number converts a YAML scalar to a float, quantities like '10Gi' aren't
+// numbers
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
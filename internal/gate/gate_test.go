@@ -0,0 +1,113 @@
+package gate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+func deployment(name string, replicas int) manifest.Resource {
+	return manifest.Resource{
+		Kind: "Deployment", Namespace: "default", Name: name,
+		Body: fmt.Sprintf("kind: Deployment\nmetadata:\n  name: %s\nspec:\n  replicas: %d\n", name, replicas),
+	}
+}
+
+func pvc(name, size string) manifest.Resource {
+	return manifest.Resource{
+		Kind: "PersistentVolumeClaim", Namespace: "default", Name: name,
+		Body: "kind: PersistentVolumeClaim\nspec:\n  resources:\n    requests:\n      storage: " + size + "\n",
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	target := []manifest.Resource{
+		deployment("api", 3),
+		deployment("worker", 2),
+		deployment("old", 1),
+		pvc("data", "10Gi"),
+	}
+	local := []manifest.Resource{
+		deployment("api", 2),
+		deployment("worker", 4),
+		deployment("new", 1),
+		pvc("data", "20Gi"),
+	}
+
+	testCases := []struct {
+		name string
+		rule Rule
+		want []string
+	}{
+		{
+			name: "Removed resources",
+			rule: Rule{Name: "no-removals", Status: []string{"removed"}},
+			want: []string{"no-removals: Deployment/default/old was removed"},
+		},
+		{
+			name: "PVC spec changes",
+			rule: Rule{Name: "pvc-spec", Select: "kind=PersistentVolumeClaim", Field: "spec", Message: "PVCs can't be resized"},
+			want: []string{"pvc-spec: PersistentVolumeClaim/default/data changed spec (PVCs can't be resized)"},
+		},
+		{
+			name: "Replicas decrease",
+			rule: Rule{Name: "replicas", Select: "kind=Deployment", Field: "spec.replicas", Change: ChangeDecrease},
+			want: []string{"replicas: Deployment/default/api decreased spec.replicas from 3 to 2"},
+		},
+		{
+			name: "Non-numeric values are never compared",
+			rule: Rule{Name: "storage", Field: "spec.resources.requests.storage", Change: ChangeIncrease},
+			want: nil,
+		},
+		{
+			name: "Any change",
+			rule: Rule{Name: "frozen", Select: "name=worker"},
+			want: []string{"frozen: Deployment/default/worker was modified"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := []Rule{tc.rule}
+			if err := Compile(rules); err != nil {
+				t.Fatalf("Compile() failed: %v", err)
+			}
+
+			violations, err := Evaluate(rules, target, local)
+			if err != nil {
+				t.Fatalf("Evaluate() failed: %v", err)
+			}
+
+			if len(violations) != len(tc.want) {
+				t.Fatalf("Evaluate() = %v, want %v", violations, tc.want)
+			}
+			for i, v := range violations {
+				if v.String() != tc.want[i] {
+					t.Errorf("Evaluate()[%d] = %q, want %q", i, v.String(), tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	testCases := []struct {
+		name string
+		rule Rule
+	}{
+		{name: "Missing name", rule: Rule{Status: []string{"removed"}}},
+		{name: "Invalid selector", rule: Rule{Name: "r", Select: "kind"}},
+		{name: "Invalid status", rule: Rule{Name: "r", Status: []string{"deleted"}}},
+		{name: "Invalid change", rule: Rule{Name: "r", Field: "spec", Change: "shrink"}},
+		{name: "Change without field", rule: Rule{Name: "r", Change: ChangeDecrease}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Compile([]Rule{tc.rule}); err == nil {
+				t.Error("Compile() succeeded, expected an error")
+			}
+		})
+	}
+}
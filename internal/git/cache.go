@@ -0,0 +1,136 @@
+package git
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CachedWorkTree returns a worktree of gitRef from a pool of worktrees kept in
+// the user cache directory, keyed by commit. A worktree is only created when the
+// commit isn't cached yet. The returned function removes all but the keep most
+// recently used worktrees of the repository, it doesn't remove the one in use.
+func CachedWorkTree(repoRoot, gitRef string, keep int) (string, func(), error) {
+	// Fetch from all remotes, the ref may have moved
+	if err := fetchAll(repoRoot); err != nil {
+		return "", nil, err
+	}
+
+	commit, err := RevParse(repoRoot, gitRef)
+	if err != nil {
+		return "", nil, err
+	}
+
+	poolDir, err := worktreePool(repoRoot)
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Join(poolDir, commit)
+
+	if head, err := RevParse(dir, "HEAD"); err == nil && head == commit {
+		slog.Debug("Using cached worktree", "ref", gitRef, "commit", commit)
+	} else {
+		// Remove a partially created or moved worktree before adding it again
+		_ = os.RemoveAll(dir)
+		pruneCmd := exec.Command("git", "worktree", "prune")
+		pruneCmd.Dir = repoRoot
+		_ = pruneCmd.Run()
+
+		if err := os.MkdirAll(poolDir, 0755); err != nil {
+			return "", nil, fmt.Errorf("failed to create worktree cache directory: %w", err)
+		}
+
+		addCmd := exec.Command("git", "worktree", "add", "-d", dir, commit)
+		addCmd.Dir = repoRoot
+		if output, err := addCmd.CombinedOutput(); err != nil {
+			return "", nil, fmt.Errorf("failed to create worktree for '%s': %v\nOutput: %s", gitRef, err, string(output))
+		}
+
+		// A worktree without its submodules must not be reused
+		if err := initSubmodules(dir); err != nil {
+			removeCmd := exec.Command("git", "worktree", "remove", "--force", dir)
+			removeCmd.Dir = repoRoot
+			_ = removeCmd.Run()
+			return "", nil, err
+		}
+	}
+
+	// The modification time tracks when a worktree was last used
+	now := time.Now()
+	_ = os.Chtimes(dir, now, now)
+
+	gc := func() {
+		if err := pruneWorkTrees(repoRoot, poolDir, keep); err != nil {
+			slog.Warn("Failed to remove old cached worktrees", "error", err)
+		}
+	}
+
+	return dir, gc, nil
+}
+
+// worktreePool returns the cache directory for the worktrees of a repository
+func worktreePool(repoRoot string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+
+	sum := sha256.Sum256([]byte(repoRoot))
+	return filepath.Join(cacheDir, "rdv", "worktrees", hex.EncodeToString(sum[:8])), nil
+}
+
+// pruneWorkTrees removes all but the keep most recently used worktrees in poolDir
+func pruneWorkTrees(repoRoot, poolDir string, keep int) error {
+	entries, err := os.ReadDir(poolDir)
+	if err != nil {
+		return err
+	}
+
+	type worktree struct {
+		path    string
+		modTime time.Time
+	}
+	var worktrees []worktree
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		worktrees = append(worktrees, worktree{filepath.Join(poolDir, entry.Name()), info.ModTime()})
+	}
+	if len(worktrees) <= keep {
+		return nil
+	}
+
+	sort.Slice(worktrees, func(i, j int) bool {
+		return worktrees[i].modTime.After(worktrees[j].modTime)
+	})
+
+	var failed []string
+	for _, wt := range worktrees[keep:] {
+		removeCmd := exec.Command("git", "worktree", "remove", "--force", wt.path)
+		removeCmd.Dir = repoRoot
+		if output, err := removeCmd.CombinedOutput(); err != nil {
+			// Not a registered worktree anymore, the directory can just be removed
+			if err := os.RemoveAll(wt.path); err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %s", wt.path, strings.TrimSpace(string(output))))
+			}
+		}
+	}
+
+	pruneCmd := exec.Command("git", "worktree", "prune")
+	pruneCmd.Dir = repoRoot
+	_ = pruneCmd.Run()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to remove worktrees:\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
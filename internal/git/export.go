@@ -0,0 +1,74 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExportTree writes the files of treeish to a temporary directory, or the
+// files of the index when treeish is empty. Unlike SetupWorkTree nothing
+// is fetched and no worktree is registered, which keeps it fast enough
+// for pre-commit hooks. Submodules are not exported.
+func ExportTree(repoRoot, treeish string) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "diff-tree-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	cleanup := func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
+		}
+	}
+
+	// A tree is read into a temporary index, the repository's index holds
+	// the staged changes and must not be touched
+	env := os.Environ()
+	if treeish != "" {
+		env = append(env, "GIT_INDEX_FILE="+filepath.Join(tempDir, ".index"))
+		readTree := exec.Command("git", "read-tree", treeish)
+		readTree.Dir = repoRoot
+		readTree.Env = env
+		if output, err := readTree.CombinedOutput(); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("failed to read tree of '%s': %w\nOutput: %s", treeish, err, string(output))
+		}
+	}
+
+	files := filepath.Join(tempDir, "tree")
+	checkoutIndex := exec.Command("git", "checkout-index", "--all", "--prefix="+files+string(filepath.Separator))
+	checkoutIndex.Dir = repoRoot
+	checkoutIndex.Env = env
+	if output, err := checkoutIndex.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to run 'git checkout-index': %w\nOutput: %s", err, string(output))
+	}
+
+	// An empty tree exports no files at all
+	if err := os.MkdirAll(files, 0o755); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	return files, cleanup, nil
+}
+
+// HasStagedChanges reports whether the index differs from HEAD
+func HasStagedChanges(repoRoot string) (bool, error) {
+	cmd := exec.Command("git", "diff", "--cached", "--quiet")
+	cmd.Dir = repoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return false, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to run 'git diff --cached': %w\nOutput: %s", err, strings.TrimSpace(string(output)))
+}
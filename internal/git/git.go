@@ -2,19 +2,45 @@
 package git
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
-func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
-	// Fetch from all remotes
+// offline is set by SetOffline
+var offline atomic.Bool
+
+// SetOffline turns off fetching from the remotes, refs are checked out as
+// they are in the local repository and clones fail
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// fetchAll fetches from all remotes of the repository unless offline
+func fetchAll(repoRoot string) error {
+	if offline.Load() {
+		return nil
+	}
+
 	fetchCmd := exec.Command("git", "fetch", "--all")
 	fetchCmd.Dir = repoRoot
 	if output, err := fetchCmd.CombinedOutput(); err != nil {
-		return "", nil, fmt.Errorf("failed to run 'git fetch --all': %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to run 'git fetch --all': %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
+	// Fetch from all remotes
+	if err := fetchAll(repoRoot); err != nil {
+		return "", nil, err
 	}
 
 	// Set up a Git Worktree for gitref
@@ -30,7 +56,7 @@ func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
 		cleanupCmd := exec.Command("git", "worktree", "remove", "--force", tempDir)
 		cleanupCmd.Dir = repoRoot
 		if output, err := cleanupCmd.CombinedOutput(); err != nil {
-			log.Printf("Warning: failed to run 'git worktree remove'. Manual cleanup may be required. Error: %v, Output: %s", err, string(output))
+			slog.Warn("Failed to run 'git worktree remove', manual cleanup may be required", "error", err, "output", string(output))
 		}
 		if err := os.RemoveAll(tempDir); err != nil {
 			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
@@ -45,9 +71,247 @@ func SetupWorkTree(repoRoot, gitRef string) (string, func(), error) {
 		return "", nil, fmt.Errorf("failed to create worktree for '%s': %v\nOutput: %s", gitRef, err, string(output))
 	}
 
+	if err := initSubmodules(tempDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return tempDir, cleanup, nil
+}
+
+// initSubmodules checks out the submodules of a new worktree or clone,
+// git worktree add and fetch leave them empty. If paths are given, only the
+// submodules in them are checked out.
+func initSubmodules(dir string, paths ...string) error {
+	if _, err := os.Stat(filepath.Join(dir, ".gitmodules")); err != nil {
+		return nil
+	}
+
+	args := []string{"submodule", "update", "--init", "--recursive", "--depth", "1"}
+	if len(paths) > 0 {
+		// git fails on paths without any submodules, pass only the
+		// submodules themselves
+		submodules, err := submodulesIn(dir, paths)
+		if err != nil || len(submodules) == 0 {
+			return err
+		}
+		args = append(append(args, "--"), submodules...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run 'git submodule update': %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// submodulesIn returns the paths of the submodules listed in .gitmodules
+// that are in one of the directories
+func submodulesIn(dir string, dirs []string) ([]string, error) {
+	cmd := exec.Command("git", "config", "--file", ".gitmodules", "--get-regexp", `\.path$`)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		// git config exits with 1 when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read .gitmodules: %w", err)
+	}
+
+	var submodules []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		_, path, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		for _, d := range dirs {
+			d = strings.TrimSuffix(d, "/")
+			// Directories inside a submodule need all of it
+			if path == d || strings.HasPrefix(path, d+"/") || strings.HasPrefix(d, path+"/") {
+				submodules = append(submodules, path)
+				break
+			}
+		}
+	}
+	return submodules, nil
+}
+
+// ShallowClone fetches gitRef from repoURL with a depth of one and checks it
+// out in a temporary directory. gitRef can be a branch, tag or commit, if the
+// server allows fetching commits directly. The cleanup function removes the clone.
+func ShallowClone(repoURL, gitRef string) (string, func(), error) {
+	// Only repositories on disk can be cloned offline
+	if _, err := os.Stat(repoURL); offline.Load() && err != nil && !strings.HasPrefix(repoURL, "file://") {
+		return "", nil, fmt.Errorf("offline, not cloning '%s': only repositories on disk can be cloned", repoURL)
+	}
+
+	tempDir, err := os.MkdirTemp("", "diff-repo-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	cleanup := func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
+		}
+	}
+
+	// 'git clone --branch' doesn't accept commits, fetching the ref into an
+	// empty repository works for all of them
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", repoURL, gitRef},
+		{"checkout", "--quiet", "--detach", "FETCH_HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tempDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("failed to clone '%s' at '%s', 'git %s' failed: %w\nOutput: %s", repoURL, gitRef, args[0], err, string(output))
+		}
+	}
+
+	if err := initSubmodules(tempDir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
 	return tempDir, cleanup, nil
 }
 
+// ResolveRef returns the remote-tracking branch for gitRef if one exists,
+// otherwise gitRef itself. The resolved ref is verified to exist.
+func ResolveRef(repoRoot, gitRef string) (string, error) {
+	var fullRef string
+
+	// Try to find the upstream for our target ref
+	upstreamRef := exec.Command("git", "rev-parse", "--abbrev-ref", gitRef+"@{u}")
+	upstreamRef.Dir = repoRoot
+
+	output, err := upstreamRef.CombinedOutput()
+	if err == nil {
+		fullRef = strings.TrimSpace(string(output))
+		slog.Debug("Found upstream, using it", "ref", gitRef, "upstream", fullRef)
+	} else {
+		fullRef = gitRef
+		slog.Debug("No upstream found, using local ref", "ref", fullRef)
+	}
+
+	// Validate our git ref exists
+	validateRef := exec.Command("git", "rev-parse", "--verify", "--quiet", fullRef)
+	validateRef.Dir = repoRoot
+
+	if out, err := validateRef.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("invalid or non-existent ref %q: %s", fullRef, strings.TrimSpace(string(out)))
+	}
+
+	return fullRef, nil
+}
+
+// RevParse returns the commit hash gitRef points at
+func RevParse(repoRoot, gitRef string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", gitRef+"^{commit}")
+	cmd.Dir = repoRoot
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve commit for %q: %w", gitRef, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ShowFile returns the content of the file at path, relative to the
+// repository root, in gitRef. A path missing in gitRef returns an error
+// wrapping fs.ErrNotExist.
+func ShowFile(repoRoot, gitRef, path string) ([]byte, error) {
+	object := gitRef + ":" + filepath.ToSlash(path)
+
+	cmd := exec.Command("git", "cat-file", "-e", object)
+	cmd.Dir = repoRoot
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s in %q: %w", path, gitRef, fs.ErrNotExist)
+	}
+
+	cmd = exec.Command("git", "show", object)
+	cmd.Dir = repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s in %q: %w", path, gitRef, err)
+	}
+	return output, nil
+}
+
+// MergeBase returns the best common ancestor commit of two refs
+func MergeBase(repoRoot, a, b string) (string, error) {
+	cmd := exec.Command("git", "merge-base", a, b)
+	cmd.Dir = repoRoot
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge-base of %q and %q: %w. Output: %s", a, b, err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// RemoteURLs returns the URLs of all remotes configured for the repository
+func RemoteURLs(repoRoot string) ([]string, error) {
+	cmd := exec.Command("git", "config", "--get-regexp", `^remote\..*\.url$`)
+	cmd.Dir = repoRoot
+
+	output, err := cmd.Output()
+	if err != nil {
+		// git config exits with 1 when there are no matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list git remotes: %w", err)
+	}
+
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, url)
+		}
+	}
+	return urls, nil
+}
+
+// Refs returns the short names of the local branches, remote-tracking
+// branches and tags of the repository
+func Refs(repoRoot string) ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads", "refs/remotes", "refs/tags")
+	cmd.Dir = repoRoot
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list git refs: %w", err)
+	}
+
+	return strings.Fields(string(output)), nil
+}
+
+// NormalizeURL reduces https, ssh and scp-like git URLs to host/path,
+// so the different ways of referencing a repository can be compared
+func NormalizeURL(repoURL string) string {
+	u := strings.TrimSpace(repoURL)
+
+	if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+		u = parsed.Hostname() + "/" + strings.TrimPrefix(parsed.Path, "/")
+	} else if host, path, ok := strings.Cut(u, ":"); ok {
+		// scp-like syntax, e.g. git@github.com:org/repo.git
+		if _, h, ok := strings.Cut(host, "@"); ok {
+			host = h
+		}
+		u = host + "/" + strings.TrimPrefix(path, "/")
+	}
+
+	u = strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	return strings.ToLower(u)
+}
+
 // GetRepoRoot finds the top-level directory of the current git repository.
 func GetRepoRoot() (string, error) {
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
@@ -1,7 +1,12 @@
 package git
 
 import (
+	"errors"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -53,3 +58,233 @@ func TestSetupWorkTree(t *testing.T) {
 		}
 	})
 }
+
+func TestNormalizeURL(t *testing.T) {
+	testCases := []string{
+		"https://github.com/Example/apps.git",
+		"https://user@github.com/example/apps",
+		"ssh://git@github.com/example/apps.git",
+		"git@github.com:example/apps.git",
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc, func(t *testing.T) {
+			if got := NormalizeURL(tc); got != "github.com/example/apps" {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tc, got, "github.com/example/apps")
+			}
+		})
+	}
+}
+
+func TestMergeBase(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	head, err := RevParse(repoRoot, "HEAD")
+	if err != nil {
+		t.Fatalf("RevParse() failed: %v", err)
+	}
+
+	// HEAD~1 is an ancestor of HEAD, so it is the merge-base
+	parent, err := RevParse(repoRoot, "HEAD~1")
+	if err != nil {
+		t.Skipf("Skipping test, HEAD has no parent: %v", err)
+	}
+
+	base, err := MergeBase(repoRoot, "HEAD~1", head)
+	if err != nil {
+		t.Fatalf("MergeBase() failed: %v", err)
+	}
+	if base != parent {
+		t.Errorf("MergeBase() = %q, want %q", base, parent)
+	}
+
+	if _, err := MergeBase(repoRoot, "this-ref-does-not-exist-12345", head); err == nil {
+		t.Error("MergeBase() succeeded for an invalid ref, expected an error")
+	}
+}
+
+func TestShowFile(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	content, err := ShowFile(repoRoot, "HEAD", "go.mod")
+	if err != nil {
+		t.Fatalf("ShowFile() failed: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "module ") {
+		t.Errorf("ShowFile() = %q, want the go.mod of HEAD", content)
+	}
+
+	if _, err := ShowFile(repoRoot, "HEAD", "this-file-does-not-exist.yaml"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ShowFile() error = %v, want fs.ErrNotExist for a missing file", err)
+	}
+}
+
+func TestShallowClone(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	// The local repository can be cloned like a remote one
+	dir, cleanup, err := ShallowClone(repoRoot, "HEAD")
+	if err != nil {
+		t.Fatalf("ShallowClone() failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		t.Errorf("ShallowClone() did not check out the repository: %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Cleanup function failed: directory still exists: %s", dir)
+	}
+
+	if _, _, err := ShallowClone(repoRoot, "this-ref-does-not-exist-12345"); err == nil {
+		t.Error("ShallowClone() succeeded for an invalid ref, expected an error")
+	}
+}
+
+func TestCachedWorkTree(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir, gc, err := CachedWorkTree(repoRoot, "HEAD", 1)
+	if err != nil {
+		t.Fatalf("CachedWorkTree() failed: %v", err)
+	}
+	gc()
+
+	// The second run reuses the cached worktree
+	again, gc, err := CachedWorkTree(repoRoot, "HEAD", 1)
+	if err != nil {
+		t.Fatalf("CachedWorkTree() failed: %v", err)
+	}
+	gc()
+	if again != dir {
+		t.Errorf("CachedWorkTree() = %s, want the cached worktree %s", again, dir)
+	}
+
+	// Only the most recently used worktree is kept
+	parent, _, err := CachedWorkTree(repoRoot, "HEAD~1", 1)
+	if err != nil {
+		t.Skipf("Skipping test, HEAD has no parent: %v", err)
+	}
+	if err := pruneWorkTrees(repoRoot, filepath.Dir(parent), 1); err != nil {
+		t.Fatalf("pruneWorkTrees() failed: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("pruneWorkTrees() did not remove the least recently used worktree %s", dir)
+	}
+
+	if err := pruneWorkTrees(repoRoot, filepath.Dir(parent), 0); err != nil {
+		t.Fatalf("pruneWorkTrees() failed: %v", err)
+	}
+}
+
+func TestSetupWorkTreeSubmodules(t *testing.T) {
+	// Local submodules need the file protocol, which git disables by default
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "protocol.file.allow")
+	t.Setenv("GIT_CONFIG_VALUE_0", "always")
+
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=rdv", "-c", "user.email=rdv@example.com"}, args...)...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	chart := t.TempDir()
+	run(chart, "init", "--quiet")
+	if err := os.WriteFile(filepath.Join(chart, "Chart.yaml"), []byte("name: vendored\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(chart, "add", ".")
+	run(chart, "commit", "--quiet", "-m", "chart")
+
+	repo := t.TempDir()
+	run(repo, "init", "--quiet")
+	run(repo, "submodule", "--quiet", "add", chart, "charts/vendored")
+	run(repo, "commit", "--quiet", "-m", "vendor chart")
+
+	dir, cleanup, err := SetupWorkTree(repo, "HEAD")
+	if err != nil {
+		t.Fatalf("SetupWorkTree() failed: %v", err)
+	}
+	defer cleanup()
+
+	if _, err := os.Stat(filepath.Join(dir, "charts", "vendored", "Chart.yaml")); err != nil {
+		t.Errorf("SetupWorkTree() did not check out the submodule: %v", err)
+	}
+
+	t.Run("Sparse worktree", func(t *testing.T) {
+		wt, cleanup, err := SetupSparseWorkTree(repo, "HEAD", []string{"apps"})
+		if err != nil {
+			t.Fatalf("SetupSparseWorkTree() failed: %v", err)
+		}
+		defer cleanup()
+
+		if _, err := os.Stat(filepath.Join(wt.Dir, "charts", "vendored", "Chart.yaml")); !os.IsNotExist(err) {
+			t.Errorf("SetupSparseWorkTree() checked out a submodule outside of the sparse patterns")
+		}
+
+		if err := wt.Add("charts"); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(wt.Dir, "charts", "vendored", "Chart.yaml")); err != nil {
+			t.Errorf("Add() did not check out the submodule: %v", err)
+		}
+	})
+}
+
+func TestSetupSparseWorkTree(t *testing.T) {
+	repoRoot, _ := GetRepoRoot()
+
+	wt, cleanup, err := SetupSparseWorkTree(repoRoot, "HEAD", []string{"internal/git"})
+	if err != nil {
+		t.Fatalf("SetupSparseWorkTree() failed: %v", err)
+	}
+	defer cleanup()
+
+	if _, err := os.Stat(filepath.Join(wt.Dir, "internal", "git", "git.go")); err != nil {
+		t.Errorf("SetupSparseWorkTree() did not check out the requested directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.Dir, "internal", "vcs")); !os.IsNotExist(err) {
+		t.Errorf("SetupSparseWorkTree() checked out a directory outside of the sparse patterns")
+	}
+
+	if err := wt.Add("internal/vcs"); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(wt.Dir, "internal", "vcs", "vcs.go")); err != nil {
+		t.Errorf("Add() did not check out the added directory: %v", err)
+	}
+}
+
+func TestSetupSparseWorkTreeConfig(t *testing.T) {
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"-c", "user.name=rdv", "-c", "user.email=rdv@example.com", "commit", "--quiet", "--allow-empty", "-m", "empty"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	_, cleanup, err := SetupSparseWorkTree(repo, "HEAD", []string{"app"})
+	if err != nil {
+		t.Fatalf("SetupSparseWorkTree() failed: %v", err)
+	}
+	if value, _ := sharedConfig(repo, worktreeConfigKey); value != "true" {
+		t.Errorf("extensions.worktreeConfig = %q during the sparse checkout, want true", value)
+	}
+
+	cleanup()
+	if value, _ := sharedConfig(repo, worktreeConfigKey); value != "" {
+		t.Errorf("extensions.worktreeConfig = %q after cleanup, want it unset again", value)
+	}
+}
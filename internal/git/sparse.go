@@ -0,0 +1,152 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SparseWorkTree is a worktree that only has some directories checked out
+type SparseWorkTree struct {
+	// Dir is the root of the worktree
+	Dir string
+}
+
+// SetupSparseWorkTree creates a worktree for gitRef in a temporary directory
+// with only the files at the root of the repository and the given directories
+// checked out, using cone mode sparse-checkout. git enables per worktree
+// config (extensions.worktreeConfig) in the shared repository config so the
+// main worktree isn't affected, cleanup turns it off again if rdv turned it on
+// and no other worktree uses it.
+func SetupSparseWorkTree(repoRoot, gitRef string, dirs []string) (*SparseWorkTree, func(), error) {
+	// Fetch from all remotes
+	if err := fetchAll(repoRoot); err != nil {
+		return nil, nil, err
+	}
+
+	worktreeConfig, err := sharedConfig(repoRoot, worktreeConfigKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tempDir, err := os.MkdirTemp("", "diff-ref-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	cleanup := func() {
+		cleanupCmd := exec.Command("git", "worktree", "remove", "--force", tempDir)
+		cleanupCmd.Dir = repoRoot
+		if output, err := cleanupCmd.CombinedOutput(); err != nil {
+			slog.Warn("Failed to run 'git worktree remove', manual cleanup may be required", "error", err, "output", string(output))
+		}
+		if err := os.RemoveAll(tempDir); err != nil {
+			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
+		}
+		if worktreeConfig != "true" {
+			restoreWorktreeConfig(repoRoot, worktreeConfig)
+		}
+	}
+
+	// Files are only checked out once the sparse patterns are set
+	addCmd := exec.Command("git", "worktree", "add", "--no-checkout", "-d", tempDir, gitRef)
+	addCmd.Dir = repoRoot
+	if output, err := addCmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return nil, nil, fmt.Errorf("failed to create worktree for '%s': %v\nOutput: %s", gitRef, err, string(output))
+	}
+
+	wt := &SparseWorkTree{Dir: tempDir}
+	if err := wt.sparseCheckout("set", dirs); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	// The index of a --no-checkout worktree is empty, reading the tree
+	// fills it and checks out the files matching the sparse patterns
+	readCmd := exec.Command("git", "read-tree", "-mu", "HEAD")
+	readCmd.Dir = tempDir
+	if output, err := readCmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to check out '%s': %v\nOutput: %s", gitRef, err, string(output))
+	}
+
+	// Submodules outside of the sparse patterns stay empty
+	if err := initSubmodules(tempDir, dirs...); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return wt, cleanup, nil
+}
+
+// Add checks out more directories in the worktree
+func (wt *SparseWorkTree) Add(dirs ...string) error {
+	if err := wt.sparseCheckout("add", dirs); err != nil {
+		return err
+	}
+	return initSubmodules(wt.Dir, dirs...)
+}
+
+func (wt *SparseWorkTree) sparseCheckout(action string, dirs []string) error {
+	args := append([]string{"sparse-checkout", action, "--cone"}, dirs...)
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = wt.Dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run 'git sparse-checkout %s': %w\nOutput: %s", action, err, string(output))
+	}
+	return nil
+}
+
+const worktreeConfigKey = "extensions.worktreeConfig"
+
+// sharedConfig returns the boolean value of key in the config shared by all
+// worktrees of the repository, empty if it isn't set
+func sharedConfig(repoRoot, key string) (string, error) {
+	cmd := exec.Command("git", "config", "--local", "--type=bool", "--get", key)
+	cmd.Dir = repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		// git config exits with 1 for keys that aren't set
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read '%s' from the git config: %w", key, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// restoreWorktreeConfig sets extensions.worktreeConfig back to its value
+// from before the sparse checkout, unless a worktree has its own config that
+// would be ignored without it
+func restoreWorktreeConfig(repoRoot, value string) {
+	cmd := exec.Command("git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	cmd.Dir = repoRoot
+	output, err := cmd.Output()
+	if err != nil {
+		slog.Warn("Failed to find the git directory, extensions.worktreeConfig is left enabled", "error", err)
+		return
+	}
+
+	commonDir := strings.TrimSpace(string(output))
+	inUse, _ := filepath.Glob(filepath.Join(commonDir, "worktrees", "*", "config.worktree"))
+	if _, err := os.Stat(filepath.Join(commonDir, "config.worktree")); err == nil || len(inUse) > 0 {
+		return
+	}
+
+	args := []string{"config", "--local", worktreeConfigKey, value}
+	if value == "" {
+		args = []string{"config", "--local", "--unset", worktreeConfigKey}
+	}
+	restoreCmd := exec.Command("git", args...)
+	restoreCmd.Dir = repoRoot
+	if output, err := restoreCmd.CombinedOutput(); err != nil {
+		slog.Warn("Failed to restore extensions.worktreeConfig", "error", err, "output", string(output))
+	}
+}
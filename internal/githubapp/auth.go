@@ -0,0 +1,112 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// App authenticates as a GitHub App to create installation tokens
+type App struct {
+	ID  int64
+	key *rsa.PrivateKey
+}
+
+// LoadApp reads the PEM encoded private key of a GitHub App
+func LoadApp(id int64, keyPath string) (*App, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", keyPath)
+	}
+
+	// GitHub hands out PKCS#1 keys, converted keys are often PKCS#8
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, pkcs8Err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if pkcs8Err != nil {
+			return nil, fmt.Errorf("failed to parse GitHub App private key %s: %w", keyPath, err)
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("GitHub App private key %s is not an RSA key", keyPath)
+		}
+	}
+
+	return &App{ID: id, key: key}, nil
+}
+
+// JWT returns a token authenticating as the app itself, valid for nine
+// minutes. The issue time is backdated to allow for clock drift.
+func (a *App) JWT(now time.Time) (string, error) {
+	encode := func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(data), nil
+	}
+
+	header, err := encode(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := encode(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(a.ID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := header + "." + claims
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// InstallationClient returns a client authenticated as an installation of
+// the app, the installation ID is sent with every webhook delivery
+func (a *App) InstallationClient(ctx context.Context, apiURL string, installationID int64) (*Client, error) {
+	if installationID == 0 {
+		return nil, errors.New("the webhook has no installation, is it configured on the GitHub App?")
+	}
+
+	jwt, err := a.JWT(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var token struct {
+		Token string `json:"token"`
+	}
+	appClient := NewClient(apiURL, jwt)
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installationID)
+	if err := appClient.do(ctx, http.MethodPost, path, nil, &token); err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	client := NewClient(apiURL, token.Token)
+	client.App = true
+	return client, nil
+}
@@ -0,0 +1,287 @@
+package githubapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultAPIURL is the API of github.com, GitHub Enterprise Server
+// instances serve it under /api/v3
+const DefaultAPIURL = "https://api.github.com"
+
+// CommentMarker identifies the comment the bot updates on every push
+// instead of adding a new one
+const CommentMarker = "<!-- rdv -->"
+
+// MaxTextLength is the longest comment body or check run summary GitHub accepts
+const MaxTextLength = 65535
+
+// MaxCommentLength is the longest body UpsertComments posts without
+// truncating it, room is left for the marker of the comment
+const MaxCommentLength = MaxTextLength - 32
+
+// Client calls the GitHub REST API with a token
+type Client struct {
+	apiURL string
+	token  string
+	http   *http.Client
+	// App is set for installation tokens, check runs can only be
+	// created by GitHub Apps
+	App bool
+}
+
+// NewClient returns a client for apiURL authenticated with token
+func NewClient(apiURL, token string) *Client {
+	return &Client{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		token:  token,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CloneURL adds the client's token to an https clone URL
+func (c *Client) CloneURL(cloneURL string) string {
+	u, err := url.Parse(cloneURL)
+	if err != nil || u.Scheme != "https" || c.token == "" {
+		return cloneURL
+	}
+	u.User = url.UserPassword("x-access-token", c.token)
+	return u.String()
+}
+
+// PullRequestFiles returns the paths changed by a pull request, renamed
+// files are listed under their old and new path
+func (c *Client) PullRequestFiles(ctx context.Context, repo string, number int) ([]string, error) {
+	var paths []string
+	// The API lists at most 3000 files, 100 per page
+	for page := 1; page <= 30; page++ {
+		var files []struct {
+			Filename         string `json:"filename"`
+			PreviousFilename string `json:"previous_filename"`
+		}
+		path := fmt.Sprintf("/repos/%s/pulls/%d/files?per_page=100&page=%d", repo, number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &files); err != nil {
+			return nil, fmt.Errorf("failed to list pull request files: %w", err)
+		}
+
+		for _, f := range files {
+			paths = append(paths, f.Filename)
+			if f.PreviousFilename != "" {
+				paths = append(paths, f.PreviousFilename)
+			}
+		}
+		if len(files) < 100 {
+			break
+		}
+	}
+	return paths, nil
+}
+
+// MergeBase returns the commit a pull request branched off from base, so
+// changes that landed on base since don't show up in its diff
+func (c *Client) MergeBase(ctx context.Context, repo, base, head string) (string, error) {
+	var compare struct {
+		MergeBaseCommit struct {
+			SHA string `json:"sha"`
+		} `json:"merge_base_commit"`
+	}
+	path := fmt.Sprintf("/repos/%s/compare/%s...%s", repo, base, head)
+	if err := c.do(ctx, http.MethodGet, path, nil, &compare); err != nil {
+		return "", fmt.Errorf("failed to find merge-base: %w", err)
+	}
+	return compare.MergeBaseCommit.SHA, nil
+}
+
+// UpsertComment updates the pull request comment containing
+// CommentMarker, or adds one. The marker is prepended to body.
+func (c *Client) UpsertComment(ctx context.Context, repo string, number int, body string) error {
+	return c.UpsertComments(ctx, repo, number, []string{body})
+}
+
+// UpsertComments posts bodies as consecutive pull request comments, for
+// reports that exceed a single comment. The first comment carries
+// CommentMarker and the following ones a numbered marker, comments with
+// the same marker are updated and the ones of parts a previous push had
+// but bodies don't are deleted.
+func (c *Client) UpsertComments(ctx context.Context, repo string, number int, bodies []string) error {
+	existing := map[string]int64{}
+	var stale []int64
+	for page := 1; ; page++ {
+		var comments []struct {
+			ID   int64  `json:"id"`
+			Body string `json:"body"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", repo, number, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return fmt.Errorf("failed to list pull request comments: %w", err)
+		}
+
+		for _, comment := range comments {
+			marker, _, _ := strings.Cut(comment.Body, "\n")
+			part, ok := commentPart(marker)
+			switch {
+			case !ok:
+			case part > len(bodies):
+				stale = append(stale, comment.ID)
+			default:
+				if _, seen := existing[marker]; !seen {
+					existing[marker] = comment.ID
+				}
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	for i, body := range bodies {
+		marker := partMarker(i + 1)
+		body = marker + "\n" + Truncate(body, MaxTextLength-len(marker)-1)
+
+		if id, ok := existing[marker]; ok {
+			path := fmt.Sprintf("/repos/%s/issues/comments/%d", repo, id)
+			if err := c.do(ctx, http.MethodPatch, path, map[string]string{"body": body}, nil); err != nil {
+				return fmt.Errorf("failed to update pull request comment: %w", err)
+			}
+			continue
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number)
+		if err := c.do(ctx, http.MethodPost, path, map[string]string{"body": body}, nil); err != nil {
+			return fmt.Errorf("failed to create pull request comment: %w", err)
+		}
+	}
+
+	for _, id := range stale {
+		path := fmt.Sprintf("/repos/%s/issues/comments/%d", repo, id)
+		if err := c.do(ctx, http.MethodDelete, path, nil, nil); err != nil {
+			return fmt.Errorf("failed to delete pull request comment: %w", err)
+		}
+	}
+	return nil
+}
+
+// partMarker returns the marker of the comment holding part n of a report
+func partMarker(n int) string {
+	if n == 1 {
+		return CommentMarker
+	}
+	return fmt.Sprintf("<!-- rdv part %d -->", n)
+}
+
+// commentPart returns the part of a report a comment marker belongs to
+func commentPart(marker string) (int, bool) {
+	if marker == CommentMarker {
+		return 1, true
+	}
+	var n int
+	if _, err := fmt.Sscanf(marker, "<!-- rdv part %d -->", &n); err != nil || n < 2 || partMarker(n) != marker {
+		return 0, false
+	}
+	return n, true
+}
+
+// CreateCheckRun starts an in progress check run on sha and returns its ID
+func (c *Client) CreateCheckRun(ctx context.Context, repo, name, sha string) (int64, error) {
+	var run struct {
+		ID int64 `json:"id"`
+	}
+	path := fmt.Sprintf("/repos/%s/check-runs", repo)
+	err := c.do(ctx, http.MethodPost, path, map[string]string{
+		"name":     name,
+		"head_sha": sha,
+		"status":   "in_progress",
+	}, &run)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create check run: %w", err)
+	}
+	return run.ID, nil
+}
+
+// CompleteCheckRun finishes a check run with a conclusion like 'success'
+// or 'failure' and a markdown summary
+func (c *Client) CompleteCheckRun(ctx context.Context, repo string, id int64, conclusion, title, summary string) error {
+	path := fmt.Sprintf("/repos/%s/check-runs/%d", repo, id)
+	err := c.do(ctx, http.MethodPatch, path, map[string]any{
+		"status":     "completed",
+		"conclusion": conclusion,
+		"output": map[string]string{
+			"title":   title,
+			"summary": Truncate(summary, MaxTextLength),
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete check run: %w", err)
+	}
+	return nil
+}
+
+// Truncate shortens text to at most limit bytes, with a note that it was cut.
+// The cut is made on a rune boundary.
+func Truncate(text string, limit int) string {
+	const note = "\n\n_Output truncated, it exceeds GitHub's size limit._\n"
+	if len(text) <= limit {
+		return text
+	}
+	// Room is left to close a code block the cut ends in
+	n := limit - len(note) - len("\n```")
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	cut := text[:n]
+	if strings.Count(cut, "```")%2 == 1 {
+		cut += "\n```"
+	}
+	return cut + note
+}
+
+// do sends a request with a JSON body and decodes the JSON response into
+// out, when both are set
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	valid := "sha256=" + sign("secret", body)
+
+	testCases := []struct {
+		name      string
+		secret    string
+		signature string
+		wantErr   bool
+	}{
+		{name: "Valid signature", secret: "secret", signature: valid},
+		{name: "Wrong secret", secret: "other", signature: valid, wantErr: true},
+		{name: "Missing header", secret: "secret", signature: "", wantErr: true},
+		{name: "Not hex", secret: "secret", signature: "sha256=zz", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifySignature(tc.secret, body, tc.signature)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("VerifySignature() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &App{ID: 42, key: key}
+
+	now := time.Unix(1700000000, 0)
+	token, err := app.JWT(now)
+	if err != nil {
+		t.Fatalf("JWT() failed: %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT() = %q, want three parts", token)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], signature); err != nil {
+		t.Errorf("JWT signature doesn't verify: %v", err)
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims struct {
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+		Iss string `json:"iss"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Iss != "42" || claims.Iat != now.Unix()-60 || claims.Exp != now.Unix()+540 {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+}
+
+func TestUpsertComment(t *testing.T) {
+	testCases := []struct {
+		name       string
+		existing   string
+		wantMethod string
+		wantPath   string
+	}{
+		{
+			name:       "Existing comment is updated",
+			existing:   `[{"id": 1, "body": "LGTM"}, {"id": 7, "body": "<!-- rdv -->\nold diff"}]`,
+			wantMethod: http.MethodPatch,
+			wantPath:   "/repos/org/repo/issues/comments/7",
+		},
+		{
+			name:       "New comment is created",
+			existing:   `[{"id": 1, "body": "LGTM"}]`,
+			wantMethod: http.MethodPost,
+			wantPath:   "/repos/org/repo/issues/3/comments",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotMethod, gotPath, gotBody string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				if r.Method == http.MethodGet {
+					_, _ = w.Write([]byte(tc.existing))
+					return
+				}
+				var body struct {
+					Body string `json:"body"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				gotMethod, gotPath, gotBody = r.Method, r.URL.Path, body.Body
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer srv.Close()
+
+			client := NewClient(srv.URL, "token")
+			if err := client.UpsertComment(context.Background(), "org/repo", 3, "new diff"); err != nil {
+				t.Fatalf("UpsertComment() failed: %v", err)
+			}
+
+			if gotMethod != tc.wantMethod || gotPath != tc.wantPath {
+				t.Errorf("Got %s %s, want %s %s", gotMethod, gotPath, tc.wantMethod, tc.wantPath)
+			}
+			if gotBody != CommentMarker+"\nnew diff" {
+				t.Errorf("Body = %q", gotBody)
+			}
+		})
+	}
+}
+
+func TestUpsertComments(t *testing.T) {
+	existing := `[{"id": 7, "body": "<!-- rdv -->\npart one"}, {"id": 8, "body": "LGTM <!-- rdv part 2 -->"}, {"id": 9, "body": "<!-- rdv part 3 -->\npart three"}]`
+
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(existing))
+			return
+		}
+		var body struct {
+			Body string `json:"body"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, r.Method+" "+r.URL.Path+" "+body.Body)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "token")
+	if err := client.UpsertComments(context.Background(), "org/repo", 3, []string{"one", "two"}); err != nil {
+		t.Fatalf("UpsertComments() failed: %v", err)
+	}
+
+	want := []string{
+		"PATCH /repos/org/repo/issues/comments/7 <!-- rdv -->\none",
+		"POST /repos/org/repo/issues/3/comments <!-- rdv part 2 -->\ntwo",
+		"DELETE /repos/org/repo/issues/comments/9 ",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Got requests\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := "summary\n```diff\n" + strings.Repeat("+ line\n", 100) + "```\n"
+
+	got := Truncate(text, 200)
+	if len(got) > 200 {
+		t.Errorf("Truncate() returned %d bytes, want at most 200", len(got))
+	}
+	if strings.Count(got, "```")%2 != 0 {
+		t.Errorf("Truncate() left a code block open: %q", got)
+	}
+	if !strings.Contains(got, "Output truncated") {
+		t.Errorf("Truncate() didn't add the truncation note: %q", got)
+	}
+
+	if got := Truncate("short", 200); got != "short" {
+		t.Errorf("Truncate() = %q, want the text unchanged", got)
+	}
+}
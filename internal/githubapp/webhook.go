@@ -0,0 +1,66 @@
+// Package githubapp implements the GitHub side of the 'rdv serve' webhook
+// bot: verifying pull_request webhooks, authenticating as a GitHub App
+// installation and posting check runs and pull request comments.
+package githubapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// PullRequestEvent is the part of a pull_request webhook payload the bot uses
+type PullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Head Branch `json:"head"`
+		Base Branch `json:"base"`
+	} `json:"pull_request"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+	// Installation is set for events delivered to a GitHub App
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+// Branch is the head or base of a pull request
+type Branch struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+// Diffable reports whether the event changes what the pull request
+// renders to, closed and labeled pull requests are left alone
+func (e PullRequestEvent) Diffable() bool {
+	switch e.Action {
+	case "opened", "reopened", "synchronize", "ready_for_review":
+		return true
+	}
+	return false
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a webhook
+// delivery against the HMAC of its body with the webhook secret
+func VerifySignature(secret string, body []byte, signature string) error {
+	hexSum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return errors.New("missing or malformed X-Hub-Signature-256 header")
+	}
+	got, err := hex.DecodeString(hexSum)
+	if err != nil {
+		return errors.New("malformed X-Hub-Signature-256 header")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("webhook signature doesn't match the secret")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package helm
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Capabilities are the Kubernetes version and API versions a chart is
+// rendered for, .Capabilities.KubeVersion and .Capabilities.APIVersions in
+// templates. Charts are rendered with Helm's defaults when they're nil.
+type Capabilities struct {
+	// KubeVersion is the Kubernetes version, e.g. v1.31.2
+	KubeVersion string
+	// APIVersions are the served API versions as group/version and
+	// group/version/Kind, e.g. apps/v1 and apps/v1/Deployment
+	APIVersions []string
+}
+
+// chartCapabilities converts c to the capabilities passed to the render
+func (c *Capabilities) chartCapabilities() (*chartutil.Capabilities, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	caps := chartutil.DefaultCapabilities.Copy()
+	if c.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(c.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kube version %q: %w", c.KubeVersion, err)
+		}
+		caps.KubeVersion = *kubeVersion
+	}
+	if len(c.APIVersions) > 0 {
+		caps.APIVersions = chartutil.VersionSet(c.APIVersions)
+	}
+	return caps, nil
+}
@@ -0,0 +1,141 @@
+package helm
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalDependencies returns the directories of 'file://' chart dependencies
+// declared by the charts under chartPath that are outside of chartPath
+func LocalDependencies(chartPath string) ([]string, error) {
+	var deps []string
+
+	err := filepath.WalkDir(chartPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != "Chart.yaml" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var chart struct {
+			Dependencies []struct {
+				Repository string `yaml:"repository"`
+			} `yaml:"dependencies"`
+		}
+		// Invalid charts are reported when they are rendered
+		if yaml.Unmarshal(content, &chart) != nil {
+			return nil
+		}
+
+		for _, dep := range chart.Dependencies {
+			rel, ok := strings.CutPrefix(dep.Repository, "file://")
+			if !ok {
+				continue
+			}
+			dir := filepath.Join(filepath.Dir(path), rel)
+			if outside(chartPath, dir) {
+				deps = append(deps, dir)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chart dependencies in %s: %w", chartPath, err)
+	}
+
+	return deps, nil
+}
+
+// outside reports if path is not inside dir
+func outside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Dependency is a chart dependency with the version it's locked to, or
+// its version constraint when the chart has no Chart.lock
+type Dependency struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+}
+
+// DependencyChange is a dependency that was added, removed, or moved to
+// another version or repository
+type DependencyChange struct {
+	Name string
+	// From and To are the versions on each side, empty when the
+	// dependency was added or removed
+	From string
+	To   string
+	// FromRepository and ToRepository are the repositories on each side
+	FromRepository string
+	ToRepository   string
+}
+
+// ParseDependencies returns the dependencies of a chart from its
+// Chart.lock, or from its Chart.yaml when lock is empty
+func ParseDependencies(chartYAML, lock []byte) ([]Dependency, error) {
+	file, content := "Chart.lock", lock
+	if len(lock) == 0 {
+		file, content = "Chart.yaml", chartYAML
+	}
+
+	var chart struct {
+		Dependencies []Dependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(content, &chart); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return chart.Dependencies, nil
+}
+
+// DependencyChanges compares the dependencies of two versions of a chart
+// by name and returns the changed ones, sorted by name
+func DependencyChanges(from, to []Dependency) []DependencyChange {
+	fromByName := make(map[string]Dependency, len(from))
+	for _, d := range from {
+		fromByName[d.Name] = d
+	}
+	toByName := make(map[string]Dependency, len(to))
+	for _, d := range to {
+		toByName[d.Name] = d
+	}
+
+	var changes []DependencyChange
+	for _, d := range to {
+		old, ok := fromByName[d.Name]
+		if ok && old.Version == d.Version && old.Repository == d.Repository {
+			continue
+		}
+		changes = append(changes, DependencyChange{
+			Name:           d.Name,
+			From:           old.Version,
+			To:             d.Version,
+			FromRepository: old.Repository,
+			ToRepository:   d.Repository,
+		})
+	}
+	for _, d := range from {
+		if _, ok := toByName[d.Name]; !ok {
+			changes = append(changes, DependencyChange{Name: d.Name, From: d.Version, FromRepository: d.Repository})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
@@ -0,0 +1,96 @@
+package helm
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Finding is a template function call that can produce different
+// output between renders
+type Finding struct {
+	File     string
+	Line     int
+	Function string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Function)
+}
+
+// nondeterministicFuncs are Sprig/Helm functions whose output changes between
+// renders. 'keys' and 'values' return map entries in random order, they are
+// only reported when the result is not sorted on the same line.
+var (
+	nondeterministicFuncs = regexp.MustCompile(`\b(randAlphaNum|randAlpha|randNumeric|randAscii|randInt|randBytes|uuidv4|now|htpasswd|shuffle|genPrivateKey|genCA|genCAWithKey|genSelfSignedCert|genSelfSignedCertWithKey|genSignedCert|genSignedCertWithKey)\b`)
+	unorderedFuncs        = regexp.MustCompile(`(^|[\s(|{])(keys|values)\s`)
+	templateAction        = regexp.MustCompile(`\{\{.*?\}\}`)
+)
+
+// FindNondeterministicCalls scans the chart's templates, including unpacked
+// subcharts, for function calls that make the rendered output nondeterministic.
+func FindNondeterministicCalls(chartPath string) ([]Finding, error) {
+	var findings []Finding
+
+	err := filepath.WalkDir(chartPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		// Only files under a templates directory are rendered
+		rel, _ := filepath.Rel(chartPath, path)
+		if !strings.Contains(filepath.ToSlash(rel), "templates/") {
+			return nil
+		}
+
+		fileFindings, err := scanTemplate(path, rel)
+		if err != nil {
+			return err
+		}
+		findings = append(findings, fileFindings...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan templates in %s: %w", chartPath, err)
+	}
+
+	return findings, nil
+}
+
+// scanTemplate checks the template actions on each line of a file
+func scanTemplate(path, name string) ([]Finding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var findings []Finding
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		for _, action := range templateAction.FindAllString(scanner.Text(), -1) {
+			for _, fn := range nondeterministicFuncs.FindAllString(action, -1) {
+				findings = append(findings, Finding{File: name, Line: lineNumber, Function: fn})
+			}
+
+			if strings.Contains(action, "sortAlpha") {
+				continue
+			}
+			for _, match := range unorderedFuncs.FindAllStringSubmatch(action, -1) {
+				findings = append(findings, Finding{File: name, Line: lineNumber, Function: match[2] + " (unsorted)"})
+			}
+		}
+	}
+
+	return findings, scanner.Err()
+}
@@ -7,12 +7,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/progress"
+	"github.com/dlactin/rdv/internal/retry"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
@@ -26,8 +30,28 @@ import (
 
 var logMutex sync.Mutex
 
+// dependencyLocks holds a mutex per chart path. Dependency builds of the
+// same chart write to the same charts/ directory, e.g. for every
+// --ci-values file rendered with --parallel, so they run one at a time.
+var dependencyLocks sync.Map
+
+// lockDependencies locks the dependencies of the chart at chartPath until
+// the returned function is first called
+func lockDependencies(chartPath string) func() {
+	if abs, err := filepath.Abs(chartPath); err == nil {
+		chartPath = abs
+	}
+	mu, _ := dependencyLocks.LoadOrStore(chartPath, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return sync.OnceFunc(mu.(*sync.Mutex).Unlock)
+}
+
 // renderChart loads, merges values, and renders a Helm chart
-func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool, update bool, lint bool) (string, error) {
+// If showOnly is not empty, only templates matching one of the
+// glob patterns are included in the output (like 'helm template -s').
+// caps overrides Helm's default capabilities if set. Dependency
+// downloads are retried following fetch.
+func RenderChart(chartPath, releaseName, namespace string, valuesFiles []string, showOnly []string, debug bool, update bool, lint bool, resolveRefs bool, caps *Capabilities, fetch retry.Policy) (string, error) {
 	chart, err := loadChart(chartPath, debug)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -37,7 +61,7 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 	}
 
 	// Load additional values files from the --values flags
-	userValues, err := loadValues(valuesFiles)
+	userValues, err := loadValues(valuesFiles, resolveRefs)
 	if err != nil {
 		return "", fmt.Errorf("failed to load/merge values: %w", err)
 	}
@@ -45,13 +69,14 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 	// Helm Dependency Build
 	// Run 'helm dependency build' if dependencies are present
 	if chart.Metadata.Dependencies != nil {
-		if debug {
-			log.Printf("Chart has dependencies, running 'helm dependency build' for: %s\n", chartPath)
-		}
+		slog.Debug("Chart has dependencies, running 'helm dependency build'", "chart", chartPath)
+
+		unlock := lockDependencies(chartPath)
+		defer unlock()
 
 		if inflatedSubCharts(chartPath) {
 			logMutex.Lock()
-			log.Printf("Warning: inflated subcharts present in %s/charts — dependency updates may be skipped or inconsistent.", chartPath)
+			slog.Warn("Inflated subcharts present in charts/, dependency updates may be skipped or inconsistent", "chart", chartPath)
 			logMutex.Unlock()
 		}
 
@@ -59,7 +84,19 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 		settings := cli.New()
 		settings.Debug = debug // Setting debug to match flag
 
-		getters := getter.All(settings)
+		// Dependencies are downloaded from the mirrors of their repositories
+		getters := mirrorGetters(getter.All(settings))
+
+		// Offline, dependencies vendored in charts/ are used as they are and
+		// anything else fails to download
+		build := true
+		if offline.Load() {
+			build, err = offlineBuild(chart)
+			if err != nil {
+				return "", err
+			}
+			getters = offlineGetters(getters)
+		}
 
 		// Create a downloader manager.
 		man := downloader.Manager{
@@ -67,13 +104,20 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 			ChartPath: chartPath,
 			Getters:   getters,
 			Debug:     debug,
+			// Indexes of repositories that aren't configured are cached
+			// here too, instead of the working directory
+			RepositoryConfig: settings.RepositoryConfig,
+			RepositoryCache:  settings.RepositoryCache,
 		}
 
 		// Run update. This updates the Chart.lock file if dependencies have changed.
 		// Only used if the -u flag is passed.
+		done := progress.StartPhase(progress.PhaseDependencies, "Building dependencies of chart %s", chart.Name())
+		defer done()
+
 		if update {
-			err = silentRun(debug, func() error {
-				return man.Update()
+			err = fetch.Do(fmt.Sprintf("dependency update of chart %s", chart.Name()), func() error {
+				return silentRun(debug, man.Update)
 			})
 			if err != nil {
 				return "", fmt.Errorf("failed to run dependency update: %w", err)
@@ -91,35 +135,57 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 
 		// Run build. This downloads charts into the 'charts/' directory.
 		// We are ignoring some log output here, which can be reverted with the --debug flag
-		err = silentRun(debug, func() error {
-			return man.Build()
-		})
-		if err != nil {
-			return "", fmt.Errorf("failed to run dependency build: %w", err)
-		}
+		if build {
+			err = fetch.Do(fmt.Sprintf("dependency build of chart %s", chart.Name()), func() error {
+				return silentRun(debug, man.Build)
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to run dependency build: %w", err)
+			}
 
-		// Reload the chart after building dependencies
-		// This ensures the newly downloaded subcharts are included in the render.
-		chart, err = loadChart(chartPath, debug)
-		if err != nil {
-			return "", fmt.Errorf("failed to reload chart after dependency build: %w", err)
+			// Reload the chart after building dependencies
+			// This ensures the newly downloaded subcharts are included in the render.
+			chart, err = loadChart(chartPath, debug)
+			if err != nil {
+				return "", fmt.Errorf("failed to reload chart after dependency build: %w", err)
+			}
 		}
+		done()
+		unlock()
 	}
 
 	// Define release options for the render
+	if namespace == "" {
+		namespace = "default"
+	}
 	options := chartutil.ReleaseOptions{
-		Name:      releaseName, // We don't need a real releaseName or namespace for the diff
-		Namespace: "default",
+		Name:      releaseName, // We don't need a real releaseName for the diff
+		Namespace: namespace,
 		Revision:  1,
 		IsInstall: true,
 	}
 
+	// Validate against values.schema.json before rendering, naming the
+	// values file of every violation
+	err = validateValues(chartPath, chart, valuesFiles, userValues)
+	if err != nil {
+		return "", err
+	}
+
+	chartCaps, err := caps.chartCapabilities()
+	if err != nil {
+		return "", err
+	}
+
 	// Get render values. This merges the chart's default values (from chart.Values/values.yaml)
-	// with the user-supplied values (from userValues).
-	renderVals, err := chartutil.ToRenderValues(chart, userValues, options, nil)
+	// with the user-supplied values (from userValues). The schema was validated above.
+	renderVals, err := chartutil.ToRenderValuesWithSchemaValidation(chart, userValues, options, chartCaps, true)
 	if err != nil {
 		return "", fmt.Errorf("failed to prepare render values: %w", err)
 	}
+	if debug {
+		traceValues(chartPath, chart, valuesFiles, renderVals)
+	}
 
 	// Render the chart
 	renderedTemplates, err := engine.Render(chart, renderVals)
@@ -143,32 +209,55 @@ func RenderChart(chartPath, releaseName string, valuesFiles []string, debug bool
 			strings.HasSuffix(key, "NOTES.txt") {
 			continue
 		}
+		if len(showOnly) > 0 && !matchTemplate(key, showOnly) {
+			continue
+		}
 		builder.WriteString("---\n")
 		builder.WriteString(fmt.Sprintf("# Source: %s\n", key))
 		builder.WriteString(content)
 		builder.WriteString("\n")
 	}
 
-	return builder.String(), nil
+	// Secrets decrypted from the values files may have been templated anywhere
+	return mask.String(builder.String()), nil
 }
 
-// loadValues merges multiple values files in order, mimicking 'helm -f file1 -f file2'
-func loadValues(valuesFiles []string) (chartutil.Values, error) {
+// matchTemplate checks if a rendered template name matches any of the
+// patterns. Like Helm, the chart name is stripped from the template name
+// first, so 'templates/deployment.yaml' matches 'mychart/templates/deployment.yaml'
+func matchTemplate(name string, patterns []string) bool {
+	_, templatePath, found := strings.Cut(name, "/")
+	if !found {
+		templatePath = name
+	}
+
+	for _, pattern := range patterns {
+		// Patterns are validated before rendering, ignore the error here
+		if ok, _ := filepath.Match(filepath.ToSlash(pattern), templatePath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadValues merges multiple values files in order, mimicking 'helm -f file1 -f file2'.
+// With resolveRefs, vals references in the files are resolved first.
+func loadValues(valuesFiles []string, resolveRefs bool) (chartutil.Values, error) {
 	mergedValues := chartutil.Values{}
 
 	for _, path := range valuesFiles {
 		// Check if file exists. It's not an error if a values file is missing
 		// in one branch but not the other; Helm just skips it.
-		if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(strings.TrimPrefix(path, SecretsScheme)); os.IsNotExist(err) {
 			logMutex.Lock()
-			log.Printf("Warning: values file '%s' not found, skipping.", path)
+			slog.Warn("Values file not found, skipping", "file", path)
 			logMutex.Unlock()
 			continue
 		}
 
-		currentValues, err := chartutil.ReadValuesFile(path)
+		currentValues, err := readValuesFile(path, resolveRefs)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read values file %s: %w", path, err)
+			return nil, err
 		}
 
 		// Coalesce merges the two maps, with 'currentValues' overwriting 'mergedValues'
@@ -255,7 +344,9 @@ func lintChart(chartPath string, userValues chartutil.Values, debug bool) error
 	actionConfig := new(action.Configuration)
 
 	settings := cli.New()
-	err := actionConfig.Init(settings.RESTClientGetter(), settings.Namespace(), "memory", log.Printf)
+	err := actionConfig.Init(settings.RESTClientGetter(), settings.Namespace(), "memory", func(format string, v ...interface{}) {
+		slog.Debug(fmt.Sprintf(format, v...))
+	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize Helm action config: %s", err)
 	}
@@ -1,8 +1,20 @@
 package helm
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/retry"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
 )
 
 func TestIsHelmChart(t *testing.T) {
@@ -49,7 +61,7 @@ func TestRenderChart(t *testing.T) {
 		update := false
 		lint := true
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update, lint)
+		output, err := RenderChart(chartPath, releaseName, "", valuesFiles, nil, debug, update, lint, false, nil, retry.Policy{})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -80,7 +92,7 @@ func TestRenderChart(t *testing.T) {
 		update := false
 		lint := true
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update, lint)
+		output, err := RenderChart(chartPath, releaseName, "", valuesFiles, nil, debug, update, lint, false, nil, retry.Policy{})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -95,6 +107,23 @@ func TestRenderChart(t *testing.T) {
 		}
 	})
 
+	t.Run("Render with show-only filter", func(t *testing.T) {
+		showOnly := []string{"templates/deploy*.yaml"}
+
+		output, err := RenderChart(chartPath, releaseName, "", []string{}, showOnly, false, false, false, false, nil, retry.Policy{})
+		if err != nil {
+			t.Fatalf("RenderChart failed: %v", err)
+		}
+
+		if !strings.Contains(output, "kind: Deployment") {
+			t.Errorf("Output missing expected content 'kind: Deployment'. Got:\n%s", output)
+		}
+
+		if strings.Contains(output, "kind: Service") {
+			t.Errorf("Output contains filtered content 'kind: Service'. Got:\n%s", output)
+		}
+	})
+
 	t.Run("Render with override values and chart dependencies", func(t *testing.T) {
 		// Using dev values file
 		valuesFile := "../../examples/helm/helloworld/values-dev.yaml"
@@ -104,7 +133,7 @@ func TestRenderChart(t *testing.T) {
 		update := true
 		lint := true
 
-		output, err := RenderChart(chartPath, releaseName, valuesFiles, debug, update, lint)
+		output, err := RenderChart(chartPath, releaseName, "", valuesFiles, nil, debug, update, lint, false, nil, retry.Policy{})
 		if err != nil {
 			t.Fatalf("RenderChart failed: %v", err)
 		}
@@ -123,4 +152,516 @@ func TestRenderChart(t *testing.T) {
 			t.Errorf("Rendered output was empty")
 		}
 	})
+
+	t.Run("Concurrent dependency builds of the same chart", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make([]error, 4)
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = RenderChart(chartPath, releaseName, "", nil, nil, false, true, false, false, nil, retry.Policy{})
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				t.Errorf("RenderChart failed: %v", err)
+			}
+		}
+	})
+}
+
+func TestFindNondeterministicCalls(t *testing.T) {
+	t.Run("Example chart is deterministic", func(t *testing.T) {
+		findings, err := FindNondeterministicCalls("../../examples/helm/helloworld")
+		if err != nil {
+			t.Fatalf("FindNondeterministicCalls failed: %v", err)
+		}
+
+		if len(findings) != 0 {
+			t.Errorf("Expected no findings, got: %v", findings)
+		}
+	})
+
+	t.Run("Detects random and unsorted functions", func(t *testing.T) {
+		chartPath := t.TempDir()
+		templates := filepath.Join(chartPath, "templates")
+		if err := os.MkdirAll(templates, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		template := `apiVersion: v1
+kind: Secret
+data:
+  password: {{ randAlphaNum 16 | b64enc }}
+  keys: {{ keys .Values.map | join "," }}
+  sorted: {{ keys .Values.map | sortAlpha | join "," }}
+  image: {{ .Values.image }}
+`
+		if err := os.WriteFile(filepath.Join(templates, "secret.yaml"), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		findings, err := FindNondeterministicCalls(chartPath)
+		if err != nil {
+			t.Fatalf("FindNondeterministicCalls failed: %v", err)
+		}
+
+		want := []string{
+			"templates/secret.yaml:4: randAlphaNum",
+			"templates/secret.yaml:5: keys (unsorted)",
+		}
+		if len(findings) != len(want) {
+			t.Fatalf("Got %d findings, want %d: %v", len(findings), len(want), findings)
+		}
+		for i := range want {
+			if findings[i].String() != want[i] {
+				t.Errorf("findings[%d] = %q, want %q", i, findings[i].String(), want[i])
+			}
+		}
+	})
+}
+
+func TestLocalDependencies(t *testing.T) {
+	root := t.TempDir()
+	chartPath := filepath.Join(root, "charts", "app")
+
+	files := map[string]string{
+		"charts/app/Chart.yaml": `apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+  - name: common
+    repository: file://../common
+  - name: nested
+    repository: file://./nested
+  - name: redis
+    repository: https://charts.example.com
+`,
+		"charts/common/Chart.yaml": "apiVersion: v2\nname: common\nversion: 0.1.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deps, err := LocalDependencies(chartPath)
+	if err != nil {
+		t.Fatalf("LocalDependencies() failed: %v", err)
+	}
+
+	want := filepath.Join(root, "charts", "common")
+	if len(deps) != 1 || deps[0] != want {
+		t.Errorf("LocalDependencies() = %v, want [%s]", deps, want)
+	}
+}
+
+func TestDependencyChanges(t *testing.T) {
+	chartYAML := []byte(`apiVersion: v2
+name: app
+version: 0.1.0
+dependencies:
+  - name: redis
+    version: ^17.0.0
+    repository: https://charts.example.com
+`)
+	oldLock := []byte(`dependencies:
+- name: redis
+  version: 17.1.0
+  repository: https://charts.example.com
+- name: postgresql
+  version: 12.0.0
+  repository: https://charts.example.com
+- name: common
+  version: 2.0.0
+  repository: https://charts.example.com
+`)
+	newLock := []byte(`dependencies:
+- name: redis
+  version: 17.3.1
+  repository: https://charts.example.com
+- name: common
+  version: 2.0.0
+  repository: oci://registry.example.com/charts
+- name: kafka
+  version: 26.0.0
+  repository: https://charts.example.com
+`)
+
+	deps, err := ParseDependencies(chartYAML, nil)
+	if err != nil {
+		t.Fatalf("ParseDependencies() failed: %v", err)
+	}
+	if len(deps) != 1 || deps[0].Version != "^17.0.0" {
+		t.Errorf("ParseDependencies() without a lock = %+v, want the Chart.yaml constraint", deps)
+	}
+
+	from, err := ParseDependencies(chartYAML, oldLock)
+	if err != nil {
+		t.Fatalf("ParseDependencies() failed: %v", err)
+	}
+	to, err := ParseDependencies(chartYAML, newLock)
+	if err != nil {
+		t.Fatalf("ParseDependencies() failed: %v", err)
+	}
+
+	want := []DependencyChange{
+		{Name: "common", From: "2.0.0", To: "2.0.0", FromRepository: "https://charts.example.com", ToRepository: "oci://registry.example.com/charts"},
+		{Name: "kafka", To: "26.0.0", ToRepository: "https://charts.example.com"},
+		{Name: "postgresql", From: "12.0.0", FromRepository: "https://charts.example.com"},
+		{Name: "redis", From: "17.1.0", To: "17.3.1", FromRepository: "https://charts.example.com", ToRepository: "https://charts.example.com"},
+	}
+	if got := DependencyChanges(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("DependencyChanges() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRenderChartSchema(t *testing.T) {
+	chartPath := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml":         "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"values.yaml":        "replicas: 1\nimage:\n  tag: latest\n",
+		"values.schema.json": `{"type": "object", "properties": {"replicas": {"type": "integer"}, "image": {"type": "object", "properties": {"tag": {"type": "string"}}}}}`,
+		"values-prod.yaml":   "replicas: three\n",
+		"values-tag.yaml":    "image:\n  tag: 1.25\n",
+		"templates/cm.yaml":  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(chartPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	valuesFiles := []string{filepath.Join(chartPath, "values-prod.yaml"), filepath.Join(chartPath, "values-tag.yaml")}
+	_, err := RenderChart(chartPath, "release", "", valuesFiles, nil, false, false, false, false, nil, retry.Policy{})
+	if err == nil {
+		t.Fatal("RenderChart() succeeded, expected a schema validation error")
+	}
+	for _, want := range []string{
+		"- at '/replicas': got string, want integer (set in values-prod.yaml)",
+		"- at '/image/tag': got number, want string (set in values-tag.yaml)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("RenderChart() error = %v, want it to contain %q", err, want)
+		}
+	}
+
+	if _, err := RenderChart(chartPath, "release", "", nil, nil, false, false, false, false, nil, retry.Policy{}); err != nil {
+		t.Errorf("RenderChart() with the default values failed: %v", err)
+	}
+}
+
+func TestRenderChartCapabilities(t *testing.T) {
+	chartPath := t.TempDir()
+	files := map[string]string{
+		"Chart.yaml": "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"templates/cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+  monitoring: {{ .Capabilities.APIVersions.Has "monitoring.coreos.com/v1/ServiceMonitor" | quote }}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(chartPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		caps *Capabilities
+		want []string
+	}{
+		{
+			name: "defaults",
+			want: []string{`monitoring: "false"`},
+		},
+		{
+			name: "cluster",
+			caps: &Capabilities{
+				KubeVersion: "v1.31.2",
+				APIVersions: []string{"v1", "monitoring.coreos.com/v1", "monitoring.coreos.com/v1/ServiceMonitor"},
+			},
+			want: []string{"kubeVersion: v1.31.2", `monitoring: "true"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := RenderChart(chartPath, "release", "", nil, nil, false, false, false, false, tt.caps, retry.Policy{})
+			if err != nil {
+				t.Fatalf("RenderChart() failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(output, want) {
+					t.Errorf("RenderChart() output does not contain %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	if err := SetMirrors(map[string]string{
+		"https://charts.bitnami.com/":        "https://artifactory.local/helm-bitnami/",
+		"https://charts.bitnami.com/bitnami": "https://artifactory.local/bitnami",
+	}); err != nil {
+		t.Fatalf("SetMirrors() failed: %v", err)
+	}
+	defer func() { _ = SetMirrors(nil) }()
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://charts.bitnami.com/index.yaml", "https://artifactory.local/helm-bitnami/index.yaml"},
+		{"https://charts.bitnami.com/bitnami/redis-17.3.1.tgz", "https://artifactory.local/bitnami/redis-17.3.1.tgz"},
+		{"https://charts.bitnami.com.evil.com/index.yaml", "https://charts.bitnami.com.evil.com/index.yaml"},
+		{"https://charts.example.com/index.yaml", "https://charts.example.com/index.yaml"},
+	}
+
+	for _, tt := range tests {
+		if got := mirrorURL(tt.url); got != tt.want {
+			t.Errorf("mirrorURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	if err := SetMirrors(map[string]string{"oci://registry.example.com": "https://mirror.local"}); err == nil {
+		t.Error("SetMirrors() accepted a mirror with another scheme")
+	}
+}
+
+func TestRenderChartMirrors(t *testing.T) {
+	// Keep the repository cache of the test out of the user's Helm home
+	helmHome := t.TempDir()
+	t.Setenv("HELM_CACHE_HOME", filepath.Join(helmHome, "cache"))
+	t.Setenv("HELM_CONFIG_HOME", filepath.Join(helmHome, "config"))
+	t.Setenv("HELM_DATA_HOME", filepath.Join(helmHome, "data"))
+
+	// The mirror serves a repository whose index points at the unreachable upstream
+	repoDir := t.TempDir()
+	dep := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "dep", Version: "0.1.0"},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: from-mirror\n")}},
+	}
+	if _, err := chartutil.Save(dep, repoDir); err != nil {
+		t.Fatal(err)
+	}
+	index, err := repo.IndexDirectory(repoDir, "http://charts.invalid/stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.StripPrefix("/stable", http.FileServer(http.Dir(repoDir))))
+	defer server.Close()
+
+	if err := SetMirrors(map[string]string{"http://charts.invalid/stable": server.URL + "/stable"}); err != nil {
+		t.Fatalf("SetMirrors() failed: %v", err)
+	}
+	defer func() { _ = SetMirrors(nil) }()
+
+	chartPath := t.TempDir()
+	chartYAML := "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: dep\n    version: 0.1.0\n    repository: http://charts.invalid/stable\n"
+	if err := os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte(chartYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := RenderChart(chartPath, "release", "", nil, nil, false, false, false, false, nil, retry.Policy{})
+	if err != nil {
+		t.Fatalf("RenderChart() failed: %v", err)
+	}
+	if !strings.Contains(output, "name: from-mirror") {
+		t.Errorf("RenderChart() output does not contain the dependency from the mirror:\n%s", output)
+	}
+}
+
+func TestRenderChartOffline(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+
+	chartPath := t.TempDir()
+	chartYAML := "apiVersion: v2\nname: app\nversion: 0.1.0\ndependencies:\n  - name: dep\n    version: ~0.1.0\n    repository: https://charts.example.com\n"
+	if err := os.WriteFile(filepath.Join(chartPath, "Chart.yaml"), []byte(chartYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := RenderChart(chartPath, "release", "", nil, nil, false, false, false, false, nil, retry.Policy{})
+	if err == nil || !strings.Contains(err.Error(), "offline, not downloading the dependencies of chart app missing from charts/: dep ~0.1.0 from https://charts.example.com") {
+		t.Errorf("RenderChart() error = %v, want an offline error", err)
+	}
+
+	// Vendored dependencies are rendered without a dependency build
+	dep := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "dep", Version: "0.1.2"},
+		Templates: []*chart.File{{Name: "templates/cm.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: vendored\n")}},
+	}
+	if err := os.MkdirAll(filepath.Join(chartPath, "charts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chartutil.Save(dep, filepath.Join(chartPath, "charts")); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := RenderChart(chartPath, "release", "", nil, nil, false, false, false, false, nil, retry.Policy{})
+	if err != nil {
+		t.Fatalf("RenderChart() failed: %v", err)
+	}
+	if !strings.Contains(output, "name: vendored") {
+		t.Errorf("RenderChart() output does not contain the vendored dependency:\n%s", output)
+	}
+}
+
+func TestValuesOrigins(t *testing.T) {
+	chartPath := "../../examples/helm/helloworld"
+	c, err := loadChart(chartPath, false)
+	if err != nil {
+		t.Fatalf("loadChart() failed: %v", err)
+	}
+
+	valuesFile := filepath.Join(chartPath, "values-dev.yaml")
+	merged := map[string]any{"replicaCount": 1, "dep": map[string]any{}, "image": map[string]any{}, "global": map[string]any{}}
+
+	origins := valuesOrigins(chartPath, c, []string{valuesFile}, merged)
+
+	want := map[string]string{
+		"replicaCount": "values-dev.yaml",
+		"dep":          "values-dev.yaml",
+		"global":       "helm",
+	}
+	for key, origin := range want {
+		if origins[key] != origin {
+			t.Errorf("origin of %s = %q, want %q", key, origins[key], origin)
+		}
+	}
+}
+
+func TestRenderChartSopsValues(t *testing.T) {
+	mask.Reset()
+	t.Cleanup(mask.Reset)
+
+	// A fake sops that prints the decrypted values
+	bin := t.TempDir()
+	fakeSops := "#!/bin/sh\nprintf 'configMap:\\n  foo: s3cr3t-value\\n'\n"
+	if err := os.WriteFile(filepath.Join(bin, "sops"), []byte(fakeSops), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	encrypted := `configMap:
+    foo: ENC[AES256_GCM,data:Zm9v,iv:YmFy,tag:YmF6,type:str]
+sops:
+    mac: ENC[AES256_GCM,data:bWFj,iv:YmFy,tag:YmF6,type:str]
+    version: 3.8.1
+`
+	valuesFile := filepath.Join(t.TempDir(), "secrets.yaml")
+	if err := os.WriteFile(valuesFile, []byte(encrypted), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := RenderChart("../../examples/helm/helloworld", "release", "", []string{valuesFile}, nil, false, false, false, false, nil, retry.Policy{})
+	if err != nil {
+		t.Fatalf("RenderChart() failed: %v", err)
+	}
+
+	if strings.Contains(output, "s3cr3t-value") || strings.Contains(output, "ENC[") {
+		t.Errorf("RenderChart() output contains the secret or the encrypted value:\n%s", output)
+	}
+	if !strings.Contains(output, mask.Placeholder("s3cr3t-value")) {
+		t.Errorf("RenderChart() output does not contain the masked secret:\n%s", output)
+	}
+}
+
+func TestResolveValuesPath(t *testing.T) {
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "values-dev.yaml", want: "/charts/app/values-dev.yaml"},
+		{path: "secrets://secrets.dev.yaml", want: "secrets:///charts/app/secrets.dev.yaml"},
+		{path: "secrets://../shared/secrets.yaml", want: "secrets:///charts/shared/secrets.yaml"},
+	}
+
+	for _, tc := range testCases {
+		if got := ResolveValuesPath("/charts/app", tc.path); got != tc.want {
+			t.Errorf("ResolveValuesPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestReadValuesFileSecrets(t *testing.T) {
+	mask.Reset()
+	t.Cleanup(mask.Reset)
+
+	// A fake vals that resolves every reference to the same secret
+	bin := t.TempDir()
+	fakeVals := "#!/bin/sh\nsed 's|ref+vault://[^ ]*|s3cr3t-value|'\n"
+	if err := os.WriteFile(filepath.Join(bin, "vals"), []byte(fakeVals), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "secrets.yaml")
+	if err := os.WriteFile(plain, []byte("password: ref+vault://secret/app#/password\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("sops backend", func(t *testing.T) {
+		t.Setenv(secretsBackendEnv, "")
+		if _, err := readValuesFile(SecretsScheme+plain, false); err == nil {
+			t.Error("readValuesFile() succeeded for a secrets:// file not encrypted with sops")
+		}
+	})
+
+	t.Run("vals backend", func(t *testing.T) {
+		t.Setenv(secretsBackendEnv, "vals")
+		values, err := readValuesFile(SecretsScheme+plain, false)
+		if err != nil {
+			t.Fatalf("readValuesFile() failed: %v", err)
+		}
+		if values["password"] != "s3cr3t-value" {
+			t.Errorf("readValuesFile() password = %v, want the resolved reference", values["password"])
+		}
+		if got := mask.String("s3cr3t-value"); got != mask.Placeholder("s3cr3t-value") {
+			t.Errorf("the resolved reference is not masked: %s", got)
+		}
+	})
+
+	t.Run("resolve refs", func(t *testing.T) {
+		values, err := readValuesFile(plain, false)
+		if err != nil {
+			t.Fatalf("readValuesFile() failed: %v", err)
+		}
+		if values["password"] != "ref+vault://secret/app#/password" {
+			t.Errorf("readValuesFile() resolved a reference without resolveRefs: %v", values["password"])
+		}
+
+		values, err = readValuesFile(plain, true)
+		if err != nil {
+			t.Fatalf("readValuesFile() failed: %v", err)
+		}
+		if values["password"] != "s3cr3t-value" {
+			t.Errorf("readValuesFile() password = %v, want the resolved reference", values["password"])
+		}
+	})
 }
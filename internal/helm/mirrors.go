@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+var (
+	mirrorsMu sync.RWMutex
+	// mirrors maps chart repository URL prefixes to the mirror serving
+	// them, without trailing slashes
+	mirrors map[string]string
+)
+
+// SetMirrors replaces the chart repository mirrors, URL prefixes of the
+// repositories in Chart.yaml mapped to the mirror to download from
+// instead, e.g. 'https://charts.bitnami.com' to
+// 'https://artifactory.local/helm-bitnami'. A mirror must use the scheme
+// of the repository it replaces.
+func SetMirrors(m map[string]string) error {
+	normalized := make(map[string]string, len(m))
+	for upstream, mirror := range m {
+		upstreamURL, err := url.Parse(upstream)
+		if err != nil || upstreamURL.Scheme == "" || upstreamURL.Host == "" {
+			return fmt.Errorf("invalid repository %q, must be a URL like https://charts.example.com", upstream)
+		}
+		mirrorURL, err := url.Parse(mirror)
+		if err != nil || mirrorURL.Host == "" {
+			return fmt.Errorf("invalid mirror %q of repository %s, must be a URL", mirror, upstream)
+		}
+		if mirrorURL.Scheme != upstreamURL.Scheme {
+			return fmt.Errorf("mirror %s of repository %s must use the %s scheme", mirror, upstream, upstreamURL.Scheme)
+		}
+		normalized[strings.TrimRight(upstream, "/")] = strings.TrimRight(mirror, "/")
+	}
+
+	mirrorsMu.Lock()
+	defer mirrorsMu.Unlock()
+	mirrors = normalized
+	return nil
+}
+
+// mirrorURL returns u with the longest repository prefix it starts with
+// replaced by its mirror, u itself if it has no mirror
+func mirrorURL(u string) string {
+	mirrorsMu.RLock()
+	defer mirrorsMu.RUnlock()
+
+	var match string
+	for upstream := range mirrors {
+		if len(upstream) > len(match) && (u == upstream || strings.HasPrefix(u, upstream+"/")) {
+			match = upstream
+		}
+	}
+	if match == "" {
+		return u
+	}
+	return mirrors[match] + strings.TrimPrefix(u, match)
+}
+
+// mirrorGetter downloads through the mirror of a URL
+type mirrorGetter struct {
+	getter.Getter
+}
+
+func (g mirrorGetter) Get(u string, options ...getter.Option) (*bytes.Buffer, error) {
+	return g.Getter.Get(mirrorURL(u), options...)
+}
+
+// mirrorGetters wraps providers so repository indexes and charts are
+// downloaded from the mirrors. The chart URLs in an index are resolved
+// against the upstream repository, so they're mirrored too.
+func mirrorGetters(providers getter.Providers) getter.Providers {
+	wrapped := make(getter.Providers, len(providers))
+	for i, p := range providers {
+		newGetter := p.New
+		wrapped[i] = getter.Provider{
+			Schemes: p.Schemes,
+			New: func(options ...getter.Option) (getter.Getter, error) {
+				g, err := newGetter(options...)
+				if err != nil {
+					return nil, err
+				}
+				return mirrorGetter{g}, nil
+			},
+		}
+	}
+	return wrapped
+}
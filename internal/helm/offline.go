@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+// offline is set by SetOffline
+var offline atomic.Bool
+
+// SetOffline turns off downloading chart dependencies. Charts are rendered
+// with the dependencies vendored in their charts/ directory, and local
+// file:// dependencies are still packaged.
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// offlineBuild decides how the dependencies of c are built offline.
+// Local file:// dependencies may have changed since they were packaged,
+// so charts only using them are built, which doesn't need the network.
+// Charts with dependencies from repositories are rendered with the
+// dependencies vendored in their charts/ directory, without a build.
+func offlineBuild(c *chart.Chart) (bool, error) {
+	var local, missing []string
+	for _, dep := range c.Metadata.Dependencies {
+		if strings.HasPrefix(dep.Repository, "file://") {
+			local = append(local, dep.Name)
+			continue
+		}
+
+		found := false
+		for _, sub := range c.Dependencies() {
+			if sub.Name() == dep.Name && versionMatches(dep.Version, sub.Metadata.Version) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%s %s from %s", dep.Name, dep.Version, dep.Repository))
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return false, fmt.Errorf("offline, not downloading the dependencies of chart %s missing from charts/: %s. Vendor them with 'helm dependency build' first", c.Name(), strings.Join(missing, ", "))
+	case len(local) == 0:
+		return false, nil
+	case len(local) < len(c.Metadata.Dependencies):
+		return false, fmt.Errorf("offline, chart %s can't be built: its local dependencies %s are packaged by 'helm dependency build', which downloads the others", c.Name(), strings.Join(local, ", "))
+	}
+	return true, nil
+}
+
+// versionMatches checks version against a Chart.yaml version constraint,
+// constraints that aren't valid semver must match exactly
+func versionMatches(constraint, version string) bool {
+	if constraint == "" {
+		return true
+	}
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return constraint == version
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+// offlineGetter refuses every download
+type offlineGetter struct{}
+
+func (offlineGetter) Get(u string, _ ...getter.Option) (*bytes.Buffer, error) {
+	return nil, fmt.Errorf("offline, not downloading %s: vendor the chart dependencies into charts/ with 'helm dependency build' first", u)
+}
+
+// offlineGetters replaces the getters of providers with ones that refuse
+// every download
+func offlineGetters(providers getter.Providers) getter.Providers {
+	refused := make(getter.Providers, len(providers))
+	for i, p := range providers {
+		refused[i] = getter.Provider{
+			Schemes: p.Schemes,
+			New: func(...getter.Option) (getter.Getter, error) {
+				return offlineGetter{}, nil
+			},
+		}
+	}
+	return refused
+}
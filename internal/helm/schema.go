@@ -0,0 +1,97 @@
+package helm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// schemaViolation matches a violation in Helm's schema validation error,
+// e.g. "- at '/image/tag': got number, want string"
+var schemaViolation = regexp.MustCompile(`^\s*- at '([^']*)':`)
+
+// validateValues validates the values a chart is rendered with against
+// the values.schema.json of the chart and its subcharts, like Helm does.
+// Every violation names the values file that set the offending value,
+// instead of leaving it to a failing template.
+func validateValues(chartPath string, c *chart.Chart, valuesFiles []string, userValues chartutil.Values) error {
+	values, err := chartutil.CoalesceValues(c, userValues)
+	if err != nil {
+		return fmt.Errorf("failed to merge values: %w", err)
+	}
+
+	err = chartutil.ValidateAgainstSchema(c, values)
+	if err == nil {
+		return nil
+	}
+
+	files := make([]chartutil.Values, len(valuesFiles))
+	for i, path := range valuesFiles {
+		// Missing and invalid files are reported by loadValues
+		files[i], _ = fileValues(path)
+	}
+
+	// Violations of a subchart's schema are listed under its name, their
+	// paths are relative to its values
+	var prefix []string
+	lines := strings.Split(strings.TrimSpace(err.Error()), "\n")
+	for i, line := range lines {
+		if name, ok := strings.CutSuffix(line, ":"); ok && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			prefix = nil
+			if name != c.Name() {
+				prefix = []string{name}
+			}
+			continue
+		}
+
+		match := schemaViolation.FindStringSubmatch(line)
+		if match == nil || match[1] == "" {
+			continue
+		}
+		path := append(append([]string{}, prefix...), pointerSegments(match[1])...)
+
+		origin := ""
+		for j := len(files) - 1; j >= 0 && origin == ""; j-- {
+			if hasPath(files[j], path) {
+				origin = valuesFileName(chartPath, valuesFiles[j])
+			}
+		}
+		if origin == "" && hasPath(c.Values, path) {
+			origin = "values.yaml"
+		}
+		if origin != "" {
+			lines[i] = fmt.Sprintf("%s (set in %s)", line, origin)
+		}
+	}
+
+	return fmt.Errorf("values don't meet the values.schema.json of the chart:\n%s", strings.Join(lines, "\n"))
+}
+
+// pointerSegments splits a JSON pointer into its unescaped segments
+func pointerSegments(pointer string) []string {
+	segments := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	return segments
+}
+
+// hasPath reports whether values sets the value at path, list entries are
+// owned by the file that sets the list
+func hasPath(values map[string]any, path []string) bool {
+	var current any = values
+	for _, key := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			_, isList := current.([]any)
+			return isList
+		}
+		if current, ok = m[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/sops"
+	"github.com/dlactin/rdv/internal/vals"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// SecretsScheme prefixes values files decrypted by the helm-secrets
+// plugin, like 'helm secrets template -f secrets://secrets.yaml'
+const SecretsScheme = "secrets://"
+
+// secretsBackendEnv selects how helm-secrets decrypts secrets:// files,
+// 'sops' by default or 'vals'
+const secretsBackendEnv = "HELM_SECRETS_BACKEND"
+
+// ResolveValuesPath joins a values file path to dir, keeping the
+// secrets:// prefix of helm-secrets files
+func ResolveValuesPath(dir, path string) string {
+	path, secrets := strings.CutPrefix(path, SecretsScheme)
+	path = filepath.Join(dir, path)
+	if secrets {
+		return SecretsScheme + path
+	}
+	return path
+}
+
+// readValuesFile reads a values file, decrypting it first if it was
+// encrypted with SOPS or is a helm-secrets secrets:// file, and resolving
+// its vals references with resolveRefs. The decrypted secrets are
+// registered with the mask package so they never show up in the output.
+func readValuesFile(path string, resolveRefs bool) (chartutil.Values, error) {
+	path, explicit := strings.CutPrefix(path, SecretsScheme)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file %s: %w", path, err)
+	}
+
+	var decrypted []byte
+	var secrets []string
+	switch {
+	case explicit && os.Getenv(secretsBackendEnv) == "vals":
+		decrypted, err = vals.Eval(content)
+		if err == nil {
+			secrets, err = vals.Secrets(content, decrypted)
+		}
+	case sops.IsEncrypted(content):
+		decrypted, err = sops.Decrypt(path)
+		if err == nil {
+			secrets, err = sops.Secrets(content, decrypted)
+		}
+	case explicit:
+		// helm-secrets fails on files sops didn't encrypt too
+		err = fmt.Errorf("values file %s%s is not encrypted with sops", SecretsScheme, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt values file %s: %w", path, err)
+	}
+	if decrypted != nil {
+		mask.Add(secrets...)
+		content = decrypted
+	}
+
+	if resolveRefs && vals.HasRefs(content) {
+		resolved, err := vals.Eval(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve references in values file %s: %w", path, err)
+		}
+		secrets, err := vals.Secrets(content, resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve references in values file %s: %w", path, err)
+		}
+		mask.Add(secrets...)
+		content = resolved
+	}
+
+	values, err := chartutil.ReadValues(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file %s: %w", path, err)
+	}
+	return values, nil
+}
@@ -0,0 +1,110 @@
+package helm
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/mask"
+	"github.com/dlactin/rdv/internal/sops"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// valuesOrigins returns where every top-level key of the merged values
+// came from: the last values file that sets it, the chart's values.yaml,
+// or the chart's subcharts and Helm itself for the rest
+func valuesOrigins(chartPath string, c *chart.Chart, valuesFiles []string, merged chartutil.Values) map[string]string {
+	origins := map[string]string{}
+	for key := range merged {
+		origins[key] = "subchart defaults"
+		if key == "global" {
+			origins[key] = "helm"
+		}
+	}
+	for key := range c.Values {
+		origins[key] = "values.yaml"
+	}
+
+	for _, path := range valuesFiles {
+		values, err := fileValues(path)
+		if err != nil {
+			// Missing and invalid files are reported by loadValues
+			continue
+		}
+
+		name := valuesFileName(chartPath, path)
+		for key := range values {
+			origins[key] = name
+		}
+	}
+
+	return origins
+}
+
+// fileValues reads the keys of a values file. Only the keys are needed,
+// SOPS encrypted files have the same keys as the decrypted values plus
+// their metadata.
+func fileValues(path string) (chartutil.Values, error) {
+	content, err := os.ReadFile(strings.TrimPrefix(path, SecretsScheme))
+	if err != nil {
+		return nil, err
+	}
+	values, err := chartutil.ReadValues(content)
+	if err != nil {
+		return nil, err
+	}
+	if sops.IsEncrypted(content) {
+		delete(values, "sops")
+	}
+	return values, nil
+}
+
+// valuesFileName names a values file relative to the chart when it's
+// inside of it
+func valuesFileName(chartPath, path string) string {
+	path = strings.TrimPrefix(path, SecretsScheme)
+	if rel, err := filepath.Rel(chartPath, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// traceValues logs the merged values a chart is rendered with and where
+// each top-level key came from, for --debug. Most unexpected render
+// differences come down to values precedence.
+func traceValues(chartPath string, c *chart.Chart, valuesFiles []string, renderVals chartutil.Values) {
+	merged, ok := renderVals["Values"].(chartutil.Values)
+	if !ok {
+		return
+	}
+	origins := valuesOrigins(chartPath, c, valuesFiles, merged)
+
+	keys := make([]string, 0, len(origins))
+	width := 0
+	for key := range origins {
+		keys = append(keys, key)
+		width = max(width, len(key))
+	}
+	sort.Strings(keys)
+
+	var trace strings.Builder
+	fmt.Fprintf(&trace, "Merged values for %s:\n", chartPath)
+	for _, key := range keys {
+		fmt.Fprintf(&trace, "  %-*s <- %s\n", width, key, origins[key])
+	}
+
+	values, err := merged.YAML()
+	if err != nil {
+		fmt.Fprintf(&trace, "  failed to encode merged values: %v\n", err)
+	} else {
+		trace.WriteString(mask.String(values))
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	slog.Debug(strings.TrimSuffix(trace.String(), "\n"))
+}
@@ -0,0 +1,210 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// functionAnnotations declare a containerized or exec KRM function on a
+// generator, transformer or validator config. kyaml still honors the
+// legacy config.k8s.io key.
+var functionAnnotations = []string{"config.kubernetes.io/function", "config.k8s.io/function"}
+
+// Function is a KRM function declared in a kustomization directory
+type Function struct {
+	// File is the config file declaring the function
+	File string
+	// Image is set for containerized functions
+	Image string
+	// Exec is set for exec functions
+	Exec string
+}
+
+// Ref returns the image or exec path the function runs
+func (f Function) Ref() string {
+	if f.Image != "" {
+		return f.Image
+	}
+	return f.Exec
+}
+
+// FindFunctions walks the kustomization directory and returns every KRM
+// function declared through a function annotation
+func FindFunctions(kustomizePath string) ([]Function, error) {
+	var functions []Function
+
+	err := filepath.WalkDir(kustomizePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (filepath.Ext(p) != ".yaml" && filepath.Ext(p) != ".yml") {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fileFunctions, err := functionsIn(p, content)
+		if err != nil {
+			return err
+		}
+		functions = append(functions, fileFunctions...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for KRM functions in %s: %w", kustomizePath, err)
+	}
+
+	return functions, nil
+}
+
+// functionsIn parses every document of a YAML file for function
+// annotations, including the inline generators, transformers and
+// validators of kustomization files. Files that aren't valid YAML are
+// skipped, kustomize will report those.
+func functionsIn(p string, content []byte) ([]Function, error) {
+	if !slices.ContainsFunc(functionAnnotations, func(key string) bool { return bytes.Contains(content, []byte(key)) }) {
+		return nil, nil
+	}
+
+	var functions []Function
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc struct {
+			Metadata struct {
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+			Generators   []string `yaml:"generators"`
+			Transformers []string `yaml:"transformers"`
+			Validators   []string `yaml:"validators"`
+		}
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return functions, nil
+		}
+
+		for _, key := range functionAnnotations {
+			spec, ok := doc.Metadata.Annotations[key]
+			if !ok {
+				continue
+			}
+
+			var fn struct {
+				Container struct {
+					Image string `yaml:"image"`
+				} `yaml:"container"`
+				Exec struct {
+					Path string `yaml:"path"`
+				} `yaml:"exec"`
+			}
+			if err := yaml.Unmarshal([]byte(spec), &fn); err != nil {
+				return nil, fmt.Errorf("failed to parse %s annotation in %s: %w", key, p, err)
+			}
+
+			functions = append(functions, Function{File: p, Image: fn.Container.Image, Exec: fn.Exec.Path})
+		}
+
+		// Generators and transformers may be inline YAML documents
+		for _, entry := range slices.Concat(doc.Generators, doc.Transformers, doc.Validators) {
+			if !strings.Contains(entry, "\n") {
+				continue
+			}
+			inline, err := functionsIn(p, []byte(entry))
+			if err != nil {
+				return nil, err
+			}
+			functions = append(functions, inline...)
+		}
+	}
+
+	return functions, nil
+}
+
+// allowlistFS checks every file kustomize reads for KRM functions that
+// aren't in the allowlist. Bases outside of the kustomization and remote
+// bases are read through it too, so the whole build is covered and not
+// only the kustomization directory.
+type allowlistFS struct {
+	filesys.FileSystem
+	allowed []string
+}
+
+func (f allowlistFS) ReadFile(p string) ([]byte, error) {
+	content, err := f.FileSystem.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFunctionAllowlist(p, content, f.allowed); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// checkFunctionAllowlist returns an error if any function declared in a
+// file kustomize reads is not matched by one of the allowed image or exec
+// globs. Kustomize fetches http files itself, not through the file
+// system, so kustomizations referencing them are rejected.
+func checkFunctionAllowlist(p string, content []byte, allowed []string) error {
+	functions, err := functionsIn(p, content)
+	if err != nil {
+		return err
+	}
+
+	var denied []string
+	for _, fn := range functions {
+		if !matchAny(fn.Ref(), allowed) {
+			denied = append(denied, fmt.Sprintf("  - %s (declared in %s)", fn.Ref(), fn.File))
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("KRM functions not in the allowlist:\n%s", strings.Join(denied, "\n"))
+	}
+
+	if slices.Contains(konfig.RecognizedKustomizationFileNames(), filepath.Base(p)) {
+		if files := httpFiles(content); len(files) > 0 {
+			return fmt.Errorf("%s references files over http, which can't be checked against the KRM function allowlist: %s", p, strings.Join(files, ", "))
+		}
+	}
+	return nil
+}
+
+// httpFiles returns the YAML and JSON files a kustomization references by
+// http URL. Remote bases are git repositories, which are cloned to disk.
+func httpFiles(content []byte) []string {
+	var files []string
+	visitEntries(content, func(entry string) {
+		u, err := url.Parse(entry)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return
+		}
+		switch path.Ext(u.Path) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, entry)
+		}
+	})
+	return files
+}
+
+func matchAny(ref string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, ref); ok {
+			return true
+		}
+	}
+	return false
+}
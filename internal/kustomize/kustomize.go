@@ -4,27 +4,147 @@ package kustomize
 
 import (
 	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
+	"github.com/dlactin/rdv/internal/progress"
+	"github.com/dlactin/rdv/internal/retry"
+	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
-// RenderKustomization runs 'kustomize build' on a given path and
-// returns the rendered manifests.
-func RenderKustomization(kustomizePath string) (string, error) {
+// Options configures the kustomize build
+type Options struct {
+	// EnableHelm turns on the helmCharts inflator
+	EnableHelm bool
+	// HelmCommand is the helm binary used by the inflator.
+	// If empty, helm is looked up in PATH.
+	HelmCommand string
+	// LoadRestrictor is 'rootOnly' (default) or 'none', which allows
+	// kustomizations to reference files outside their directory
+	LoadRestrictor string
+	// EnableAlphaPlugins allows generator and transformer plugins,
+	// including containerized KRM functions
+	EnableAlphaPlugins bool
+	// EnableExec allows exec KRM functions, requires EnableAlphaPlugins
+	EnableExec bool
+	// FnNetwork gives containerized functions network access
+	FnNetwork bool
+	// FnMounts are storage mounts passed to containerized functions
+	FnMounts []string
+	// FnEnv are environment variables passed to functions
+	FnEnv []string
+	// FnAllow restricts functions to matching images and exec paths.
+	// If empty, any function is allowed once plugins are enabled.
+	FnAllow []string
+	// Fetch retries builds failing to fetch remote bases
+	Fetch retry.Policy
+	// Offline fails builds referencing remote bases before they run
+	Offline bool
+}
+
+// loadRestrictions maps our load restrictor names, and the names used
+// by the kustomize CLI, to krusty load restrictions
+var loadRestrictions = map[string]types.LoadRestrictions{
+	"":                         types.LoadRestrictionsRootOnly,
+	"rootOnly":                 types.LoadRestrictionsRootOnly,
+	"none":                     types.LoadRestrictionsNone,
+	"LoadRestrictionsRootOnly": types.LoadRestrictionsRootOnly,
+	"LoadRestrictionsNone":     types.LoadRestrictionsNone,
+}
+
+// krustyOptions converts our Options to krusty build options
+func (o Options) krustyOptions() (*krusty.Options, error) {
 	opts := krusty.MakeDefaultOptions()
+
+	restrictions, ok := loadRestrictions[o.LoadRestrictor]
+	if !ok {
+		return nil, fmt.Errorf("unsupported load restrictor %q, must be one of: rootOnly, none", o.LoadRestrictor)
+	}
+	opts.LoadRestrictions = restrictions
+
+	if o.EnableExec && !o.EnableAlphaPlugins {
+		return nil, fmt.Errorf("exec KRM functions require alpha plugins to be enabled")
+	}
+
+	// This matches 'kustomize build --enable-alpha-plugins'
+	if o.EnableAlphaPlugins {
+		opts.PluginConfig = types.EnabledPluginConfig(types.BploUseStaticallyLinked)
+		opts.PluginConfig.FnpLoadingOptions = types.FnPluginLoadingOptions{
+			EnableExec: o.EnableExec,
+			Network:    o.FnNetwork,
+			Mounts:     o.FnMounts,
+			Env:        o.FnEnv,
+		}
+	}
 	opts.PluginConfig.HelmConfig.Enabled = false
 
+	if o.EnableHelm {
+		helmCommand := o.HelmCommand
+		if helmCommand == "" {
+			path, err := exec.LookPath("helm")
+			if err != nil {
+				return nil, fmt.Errorf("helm not found in PATH, it is required to inflate Helm charts: %w", err)
+			}
+			helmCommand = path
+		}
+
+		opts.PluginConfig.HelmConfig.Enabled = true
+		opts.PluginConfig.HelmConfig.Command = helmCommand
+	}
+
+	return opts, nil
+}
+
+// RenderKustomization runs 'kustomize build' on a given path and
+// returns the rendered manifests.
+func RenderKustomization(kustomizePath string, o Options) (string, error) {
+	opts, err := o.krustyOptions()
+	if err != nil {
+		return "", err
+	}
+
+	// Remote bases can't be fetched offline, fail before the build tries
+	if o.Offline {
+		remote, err := RemoteReferences(kustomizePath)
+		if err != nil {
+			return "", err
+		}
+		if len(remote) > 0 {
+			return "", fmt.Errorf("offline, not fetching the remote bases of %s: %s", kustomizePath, strings.Join(remote, ", "))
+		}
+	}
+
 	k := krusty.MakeKustomizer(opts)
 
 	fSys := filesys.MakeFsOnDisk()
+	// Check declared KRM functions as kustomize reads them, before it runs any
+	if o.EnableAlphaPlugins && len(o.FnAllow) > 0 {
+		fSys = allowlistFS{FileSystem: fSys, allowed: o.FnAllow}
+	}
 
 	// Run the kustomize build
 	// This is the equivalent of `kustomize build <kustomizePath>`
-	resMap, err := k.Run(fSys, kustomizePath)
+	// Remote bases and KRM functions can make this slow
+	slog.Debug("Building kustomization", "path", kustomizePath, "loadRestrictor", opts.LoadRestrictions.String(), "helm", o.EnableHelm, "plugins", o.EnableAlphaPlugins)
+	done := progress.Start("Building kustomization %s", filepath.Base(kustomizePath))
+	var resMap resmap.ResMap
+	err = o.Fetch.Do(fmt.Sprintf("build of kustomization %s", filepath.Base(kustomizePath)), func() error {
+		var err error
+		resMap, err = k.Run(fSys, kustomizePath)
+		return err
+	})
+	done()
 	if err != nil {
 		return "", fmt.Errorf("failed to run kustomize build: %w", err)
 	}
+	slog.Debug("Built kustomization", "path", kustomizePath, "resources", resMap.Size())
 
 	// Encode the resulting resources into a single YAML byte slice
 	yamlBytes, err := resMap.AsYaml()
@@ -36,13 +156,15 @@ func RenderKustomization(kustomizePath string) (string, error) {
 	return string(yamlBytes), nil
 }
 
+// IsKustomize checks if the path contains a kustomization file.
+// We only check for the file here instead of running a build, so
+// kustomizations that fail to build still report a useful error.
 func IsKustomize(path string) bool {
-	opts := krusty.MakeDefaultOptions()
-	opts.PluginConfig.HelmConfig.Enabled = false
-
-	fSys := filesys.MakeFsOnDisk()
-	k := krusty.MakeKustomizer(opts)
-
-	_, err := k.Run(fSys, path)
-	return err == nil
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		info, err := os.Stat(filepath.Join(path, name))
+		if err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
 }
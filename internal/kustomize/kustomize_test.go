@@ -1,8 +1,12 @@
 package kustomize
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"sigs.k8s.io/kustomize/api/types"
 )
 
 func TestIsKustomize(t *testing.T) {
@@ -42,7 +46,7 @@ func TestRenderKustomization(t *testing.T) {
 	t.Run("Renders a valid kustomization", func(t *testing.T) {
 		path := "../../examples/kustomize/helloworld"
 
-		output, err := RenderKustomization(path)
+		output, err := RenderKustomization(path, Options{})
 		if err != nil {
 			t.Fatalf("RenderKustomization failed: %v", err)
 		}
@@ -68,7 +72,7 @@ func TestRenderKustomization(t *testing.T) {
 		// This is a Helm chart, not kustomization
 		path := "../../examples/helm/helloworld"
 
-		_, err := RenderKustomization(path)
+		_, err := RenderKustomization(path, Options{})
 		if err == nil {
 			t.Errorf("RenderKustomization did not fail for an invalid path, expected error")
 		}
@@ -77,9 +81,241 @@ func TestRenderKustomization(t *testing.T) {
 	t.Run("Fails on a non-existent path", func(t *testing.T) {
 		path := "testdata/does-not-exist"
 
-		_, err := RenderKustomization(path)
+		_, err := RenderKustomization(path, Options{})
 		if err == nil {
 			t.Errorf("RenderKustomization did not fail for a non-existent path, expected error")
 		}
 	})
 }
+
+func TestKrustyOptions(t *testing.T) {
+	t.Run("Helm disabled by default", func(t *testing.T) {
+		opts, err := Options{}.krustyOptions()
+		if err != nil {
+			t.Fatalf("krustyOptions() failed: %v", err)
+		}
+
+		if opts.PluginConfig.HelmConfig.Enabled {
+			t.Error("Helm inflator enabled, expected it to be disabled by default")
+		}
+	})
+
+	t.Run("Helm enabled with explicit command", func(t *testing.T) {
+		opts, err := Options{EnableHelm: true, HelmCommand: "/usr/local/bin/helm"}.krustyOptions()
+		if err != nil {
+			t.Fatalf("krustyOptions() failed: %v", err)
+		}
+
+		if !opts.PluginConfig.HelmConfig.Enabled {
+			t.Error("Helm inflator disabled, expected it to be enabled")
+		}
+
+		if opts.PluginConfig.HelmConfig.Command != "/usr/local/bin/helm" {
+			t.Errorf("HelmConfig.Command = %q, want %q", opts.PluginConfig.HelmConfig.Command, "/usr/local/bin/helm")
+		}
+	})
+
+	t.Run("Load restrictor none", func(t *testing.T) {
+		opts, err := Options{LoadRestrictor: "none"}.krustyOptions()
+		if err != nil {
+			t.Fatalf("krustyOptions() failed: %v", err)
+		}
+
+		if opts.LoadRestrictions != types.LoadRestrictionsNone {
+			t.Errorf("LoadRestrictions = %v, want %v", opts.LoadRestrictions, types.LoadRestrictionsNone)
+		}
+	})
+
+	t.Run("Invalid load restrictor", func(t *testing.T) {
+		_, err := Options{LoadRestrictor: "everything"}.krustyOptions()
+		if err == nil {
+			t.Error("krustyOptions() succeeded with an invalid load restrictor, expected an error")
+		}
+	})
+
+	t.Run("Alpha plugins enabled", func(t *testing.T) {
+		opts, err := Options{EnableAlphaPlugins: true}.krustyOptions()
+		if err != nil {
+			t.Fatalf("krustyOptions() failed: %v", err)
+		}
+
+		if opts.PluginConfig.PluginRestrictions != types.PluginRestrictionsNone {
+			t.Errorf("PluginRestrictions = %v, want %v", opts.PluginConfig.PluginRestrictions, types.PluginRestrictionsNone)
+		}
+
+		if opts.PluginConfig.HelmConfig.Enabled {
+			t.Error("Helm inflator enabled by alpha plugins, expected it to follow EnableHelm")
+		}
+	})
+
+	t.Run("Exec functions require alpha plugins", func(t *testing.T) {
+		_, err := Options{EnableExec: true}.krustyOptions()
+		if err == nil {
+			t.Error("krustyOptions() succeeded with exec but no alpha plugins, expected an error")
+		}
+	})
+
+	t.Run("Function loading options", func(t *testing.T) {
+		opts, err := Options{EnableAlphaPlugins: true, EnableExec: true, FnEnv: []string{"FOO=bar"}}.krustyOptions()
+		if err != nil {
+			t.Fatalf("krustyOptions() failed: %v", err)
+		}
+
+		if !opts.PluginConfig.FnpLoadingOptions.EnableExec {
+			t.Error("FnpLoadingOptions.EnableExec = false, want true")
+		}
+
+		if len(opts.PluginConfig.FnpLoadingOptions.Env) != 1 {
+			t.Errorf("FnpLoadingOptions.Env = %v, want [FOO=bar]", opts.PluginConfig.FnpLoadingOptions.Env)
+		}
+	})
+}
+
+func TestCheckFunctionAllowlist(t *testing.T) {
+	generator := `apiVersion: example.com/v1
+kind: SecretGenerator
+metadata:
+  name: secrets
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: ghcr.io/example/sops-fn:v1.0.0
+`
+	legacy := strings.ReplaceAll(generator, "config.kubernetes.io/function", "config.k8s.io/function")
+	inline := "transformers:\n  - |\n" + indent(generator, "    ")
+
+	testCases := []struct {
+		name    string
+		file    string
+		content string
+		allowed []string
+		wantErr bool
+	}{
+		{
+			name:    "Allowed by image glob",
+			file:    "generator.yaml",
+			content: generator,
+			allowed: []string{"ghcr.io/example/*"},
+			wantErr: false,
+		},
+		{
+			name:    "Not in allowlist",
+			file:    "generator.yaml",
+			content: generator,
+			allowed: []string{"gcr.io/kpt-fn/*"},
+			wantErr: true,
+		},
+		{
+			name:    "Legacy annotation not in allowlist",
+			file:    "generator.yaml",
+			content: legacy,
+			allowed: []string{"gcr.io/kpt-fn/*"},
+			wantErr: true,
+		},
+		{
+			name:    "Inline transformer not in allowlist",
+			file:    "kustomization.yaml",
+			content: inline,
+			allowed: []string{"gcr.io/kpt-fn/*"},
+			wantErr: true,
+		},
+		{
+			name:    "File over http",
+			file:    "kustomization.yaml",
+			content: "generators:\n  - https://example.com/generator.yaml\n",
+			allowed: []string{"ghcr.io/example/*"},
+			wantErr: true,
+		},
+		{
+			name:    "Remote base",
+			file:    "kustomization.yaml",
+			content: "resources:\n  - https://github.com/example/repo//config?ref=v1\n",
+			allowed: []string{"ghcr.io/example/*"},
+			wantErr: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkFunctionAllowlist(tc.file, []byte(tc.content), tc.allowed)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("checkFunctionAllowlist() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	t.Run("Function in a base outside of the kustomization", func(t *testing.T) {
+		root := t.TempDir()
+		files := map[string]string{
+			"base/kustomization.yaml":    "generators:\n  - generator.yaml\n",
+			"base/generator.yaml":        legacy,
+			"overlay/kustomization.yaml": "resources:\n  - ../base\n",
+		}
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err := RenderKustomization(filepath.Join(root, "overlay"), Options{EnableAlphaPlugins: true, FnAllow: []string{"gcr.io/kpt-fn/*"}})
+		if err == nil || !strings.Contains(err.Error(), "not in the allowlist") {
+			t.Errorf("RenderKustomization() error = %v, want the function of the base denied", err)
+		}
+	})
+}
+
+// indent prefixes every line of s
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n"+prefix) + "\n"
+}
+
+func TestLocalReferences(t *testing.T) {
+	root := t.TempDir()
+	overlay := filepath.Join(root, "overlays", "prod")
+
+	files := map[string]string{
+		"overlays/prod/kustomization.yaml": `resources:
+  - ../../base
+  - deployment.yaml
+  - https://github.com/example/repo//config?ref=v1
+components:
+  - ../../components/monitoring
+configMapGenerator:
+  - name: settings
+    files:
+      - config.json=../../shared/config.json
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	refs, err := LocalReferences(overlay)
+	if err != nil {
+		t.Fatalf("LocalReferences() failed: %v", err)
+	}
+
+	want := []string{
+		filepath.Join(root, "base"),
+		filepath.Join(root, "components", "monitoring"),
+		filepath.Join(root, "shared", "config.json"),
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("LocalReferences() = %v, want %v", refs, want)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("LocalReferences()[%d] = %s, want %s", i, refs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package kustomize
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
+)
+
+// LocalReferences returns the local files and directories referenced by the
+// kustomizations under kustomizePath that are outside of kustomizePath.
+// Remote resources are ignored.
+func LocalReferences(kustomizePath string) ([]string, error) {
+	var refs []string
+
+	err := walkReferences(kustomizePath, func(dir, entry string) {
+		if isRemote(entry) {
+			return
+		}
+		ref := filepath.Join(dir, entry)
+		if rel, err := filepath.Rel(kustomizePath, ref); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			refs = append(refs, ref)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// RemoteReferences returns the remote resources and bases, like git
+// repositories and URLs, referenced by the kustomizations under
+// kustomizePath
+func RemoteReferences(kustomizePath string) ([]string, error) {
+	var refs []string
+
+	err := walkReferences(kustomizePath, func(_, entry string) {
+		if isRemote(entry) {
+			refs = append(refs, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// isRemote reports whether a kustomization entry is a URL or git repository
+func isRemote(entry string) bool {
+	return strings.Contains(entry, "://") || strings.HasPrefix(entry, "github.com/") || strings.HasPrefix(entry, "git@")
+}
+
+// walkReferences calls visit with every file, directory or remote entry
+// of the kustomizations under kustomizePath and the directory of the
+// kustomization referencing it
+func walkReferences(kustomizePath string, visit func(dir, entry string)) error {
+	err := filepath.WalkDir(kustomizePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !slices.Contains(konfig.RecognizedKustomizationFileNames(), d.Name()) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		dir := filepath.Dir(path)
+		visitEntries(content, func(entry string) { visit(dir, entry) })
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find references in %s: %w", kustomizePath, err)
+	}
+
+	return nil
+}
+
+// visitEntries calls visit with every file, directory or remote entry of
+// a kustomization file
+func visitEntries(content []byte, visit func(entry string)) {
+	var k struct {
+		Resources             []string `yaml:"resources"`
+		Bases                 []string `yaml:"bases"`
+		Components            []string `yaml:"components"`
+		Crds                  []string `yaml:"crds"`
+		Generators            []string `yaml:"generators"`
+		Transformers          []string `yaml:"transformers"`
+		Validators            []string `yaml:"validators"`
+		PatchesStrategicMerge []string `yaml:"patchesStrategicMerge"`
+		Patches               []struct {
+			Path string `yaml:"path"`
+		} `yaml:"patches"`
+		ConfigMapGenerator []generatorArgs `yaml:"configMapGenerator"`
+		SecretGenerator    []generatorArgs `yaml:"secretGenerator"`
+		HelmGlobals        struct {
+			ChartHome string `yaml:"chartHome"`
+		} `yaml:"helmGlobals"`
+	}
+	// Invalid kustomizations are reported when they are built
+	if yaml.Unmarshal(content, &k) != nil {
+		return
+	}
+
+	entries := slices.Concat(k.Resources, k.Bases, k.Components, k.Crds, k.Generators,
+		k.Transformers, k.Validators, k.PatchesStrategicMerge, []string{k.HelmGlobals.ChartHome})
+	for _, p := range k.Patches {
+		entries = append(entries, p.Path)
+	}
+	for _, g := range slices.Concat(k.ConfigMapGenerator, k.SecretGenerator) {
+		entries = append(entries, g.Envs...)
+		entries = append(entries, g.Env)
+		for _, f := range g.Files {
+			// Files may be given as 'key=path'
+			if _, p, ok := strings.Cut(f, "="); ok {
+				f = p
+			}
+			entries = append(entries, f)
+		}
+	}
+
+	for _, entry := range entries {
+		// Generators and transformers may be inline YAML documents
+		if entry == "" || strings.Contains(entry, "\n") {
+			continue
+		}
+		visit(entry)
+	}
+}
+
+// generatorArgs holds the file references of a ConfigMap or Secret generator
+type generatorArgs struct {
+	Files []string `yaml:"files"`
+	Envs  []string `yaml:"envs"`
+	Env   string   `yaml:"env"`
+}
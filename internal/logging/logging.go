@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Formats of the log records
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// textLogger is slog's default logger, it writes records as
+// 'LEVEL message key=value' lines through the log package
+var textLogger = slog.Default()
+
+// ParseLevel parses a level name, debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, must be one of debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// Setup makes the default slog logger write records of level and above in
+// format, text or json. Records are written to the output of the log
+// package, wherever it points at the time, so they end up next to the
+// progress messages logged with it.
+func Setup(level slog.Level, format string) error {
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		slog.SetDefault(textLogger)
+	case FormatJSON:
+		// SetDefault redirects the log package into the handler, which
+		// writes to the log package itself. The output is restored so
+		// progress messages stay plain text.
+		out, flags := log.Writer(), log.Flags()
+		slog.SetDefault(slog.New(slog.NewJSONHandler(writer{}, &slog.HandlerOptions{Level: level})))
+		log.SetOutput(out)
+		log.SetFlags(flags)
+	default:
+		return fmt.Errorf("invalid log format %q, must be %s or %s", format, FormatText, FormatJSON)
+	}
+
+	// The level of the text logger, the JSON handler has its own
+	slog.SetLogLoggerLevel(level)
+	return nil
+}
+
+// writer writes to the current output of the log package
+type writer struct{}
+
+func (writer) Write(p []byte) (int, error) {
+	return log.Writer().Write(p)
+}
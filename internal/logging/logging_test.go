@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	defer func() { _ = Setup(slog.LevelInfo, FormatText) }()
+
+	testCases := []struct {
+		name    string
+		level   string
+		format  string
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		{
+			name:    "Text records at info",
+			level:   "info",
+			format:  FormatText,
+			want:    []string{"INFO Rendering chart=app", "WARN Values file not found file=values.yaml", "Checking out main"},
+			notWant: []string{"DEBUG"},
+		},
+		{
+			name:   "Text records at debug",
+			level:  "debug",
+			format: FormatText,
+			want:   []string{"DEBUG Using cached worktree ref=main", "INFO Rendering chart=app"},
+		},
+		{
+			name:    "JSON records at warn",
+			level:   "warn",
+			format:  FormatJSON,
+			want:    []string{`"level":"WARN","msg":"Values file not found","file":"values.yaml"}`, "Checking out main"},
+			notWant: []string{"Using cached worktree", "Rendering"},
+		},
+		{
+			name:    "Invalid level",
+			level:   "verbose",
+			format:  FormatText,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid format",
+			level:   "info",
+			format:  "yaml",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.Reset()
+
+			level, err := ParseLevel(tc.level)
+			if err == nil {
+				err = Setup(level, tc.format)
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Setup() failed: %v", err)
+			}
+
+			slog.Debug("Using cached worktree", "ref", "main")
+			slog.Info("Rendering", "chart", "app")
+			slog.Warn("Values file not found", "file", "values.yaml")
+			// Progress messages of the log package stay plain text
+			log.Print("Checking out main")
+
+			for _, want := range tc.want {
+				if !strings.Contains(logs.String(), want) {
+					t.Errorf("Expected %q in the logs, got:\n%s", want, logs.String())
+				}
+			}
+			for _, notWant := range tc.notWant {
+				if strings.Contains(logs.String(), notWant) {
+					t.Errorf("Expected no %q in the logs, got:\n%s", notWant, logs.String())
+				}
+			}
+
+			if tc.format == FormatJSON {
+				for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+					if strings.HasPrefix(line, "{") && !json.Valid([]byte(line)) {
+						t.Errorf("Invalid JSON record: %s", line)
+					}
+				}
+			}
+		})
+	}
+}
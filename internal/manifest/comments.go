@@ -0,0 +1,77 @@
+package manifest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// blockScalar matches a line ending in a literal or folded block scalar
+// indicator, e.g. 'script: |' or '- >-'
+var blockScalar = regexp.MustCompile(`(^|\s)[|>][-+1-9]*$`)
+
+// StripComments removes the comments from every resource in a render.
+// Helm's '# Source:' comments are only removed when source is set, they
+// name the template of each resource. Lines inside block scalars are
+// content and kept as they are, the rest of the render keeps its
+// formatting.
+func StripComments(render string, source bool) string {
+	// blockIndent is the indent of the line starting the current block
+	// scalar, -1 outside of one
+	blockIndent := -1
+
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(render, "\n") {
+		body := strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimLeft(body, " ")
+		indent := len(body) - len(trimmed)
+
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				out.WriteString(line)
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			if !source && strings.HasPrefix(body, "# Source: ") {
+				out.WriteString(line)
+			}
+			continue
+		}
+
+		code := stripLineComment(body)
+		if blockScalar.MatchString(strings.TrimSpace(code)) {
+			blockIndent = indent
+		}
+		if code != body {
+			line = code + line[len(body):]
+		}
+		out.WriteString(line)
+	}
+
+	return out.String()
+}
+
+// stripLineComment removes a trailing comment from a line. A '#' only
+// starts a comment after whitespace and outside of quoted scalars, quotes
+// inside plain scalars like "don't" don't start one.
+func stripLineComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && i > 0 && (line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
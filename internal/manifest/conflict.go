@@ -0,0 +1,170 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Conflict is a resource changed both on the target ref and the local
+// side since their merge-base
+type Conflict struct {
+	Resource Resource
+	// Fields are the dotted paths changed on both sides, empty when both
+	// sides changed different fields
+	Fields []string
+	// Whole is set when the resource was added on both sides, or removed
+	// on one side and changed on the other
+	Whole bool
+}
+
+// Conflicts compares the upstream and local changes to the resources of
+// base and returns the resources changed on both sides, sorted by key.
+// Resources both sides changed the same way merge cleanly and are left
+// out.
+func Conflicts(base, upstream, local []Resource) ([]Conflict, error) {
+	baseByKey := resourcesByKey(base)
+	upstreamByKey := resourcesByKey(upstream)
+	localByKey := resourcesByKey(local)
+
+	var conflicts []Conflict
+	for key, l := range localByKey {
+		u, uOk := upstreamByKey[key]
+		b, bOk := baseByKey[key]
+
+		localChanged := !bOk || !sameBody(b, l)
+		upstreamChanged := uOk != bOk || (uOk && !sameBody(u, b))
+		if !localChanged || !upstreamChanged || (uOk && sameBody(u, l)) {
+			continue
+		}
+
+		// Added on both sides, or removed upstream and changed locally
+		if !bOk || !uOk {
+			conflicts = append(conflicts, Conflict{Resource: l, Whole: true})
+			continue
+		}
+
+		fields, err := conflictingFields(b, u, l)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, Conflict{Resource: l, Fields: fields})
+	}
+
+	// Resources removed locally conflict with any upstream change to them
+	for key, u := range upstreamByKey {
+		b, bOk := baseByKey[key]
+		if _, ok := localByKey[key]; !ok && bOk && !sameBody(b, u) {
+			conflicts = append(conflicts, Conflict{Resource: u, Whole: true})
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Resource.Key() < conflicts[j].Resource.Key()
+	})
+	return conflicts, nil
+}
+
+// resourcesByKey indexes resources by their Key
+func resourcesByKey(resources []Resource) map[string]Resource {
+	byKey := make(map[string]Resource, len(resources))
+	for _, r := range resources {
+		byKey[r.Key()] = r
+	}
+	return byKey
+}
+
+// sameBody reports whether a and b are the same document, the last
+// document of a render keeps the trailing newline of the stream
+func sameBody(a, b Resource) bool {
+	return strings.TrimSpace(a.Body) == strings.TrimSpace(b.Body)
+}
+
+// conflictingFields returns the sorted paths changed from base in both
+// upstream and local
+func conflictingFields(base, upstream, local Resource) ([]string, error) {
+	baseFields, err := leafFields(base)
+	if err != nil {
+		return nil, err
+	}
+	upstreamFields, err := leafFields(upstream)
+	if err != nil {
+		return nil, err
+	}
+	localFields, err := leafFields(local)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamChanged := changedFields(baseFields, upstreamFields)
+	var fields []string
+	for field := range changedFields(baseFields, localFields) {
+		if upstreamChanged[field] {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// changedFields returns the paths whose value differs between a and b,
+// including the paths only one of them has
+func changedFields(a, b map[string]any) map[string]bool {
+	changed := map[string]bool{}
+	for field, value := range a {
+		if other, ok := b[field]; !ok || !reflect.DeepEqual(value, other) {
+			changed[field] = true
+		}
+	}
+	for field := range b {
+		if _, ok := a[field]; !ok {
+			changed[field] = true
+		}
+	}
+	return changed
+}
+
+// leafFields returns the scalar values of a resource by their dotted
+// path, list entries are indexed by number
+func leafFields(r Resource) (map[string]any, error) {
+	var value any
+	if err := yaml.Unmarshal([]byte(r.Body), &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+	}
+
+	fields := map[string]any{}
+	var walk func(path string, value any)
+	walk = func(path string, value any) {
+		join := func(key string) string {
+			if path == "" {
+				return key
+			}
+			return path + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]any:
+			if len(v) == 0 {
+				fields[path] = v
+			}
+			for key, nested := range v {
+				walk(join(key), nested)
+			}
+		case []any:
+			if len(v) == 0 {
+				fields[path] = v
+			}
+			for i, nested := range v {
+				walk(join(strconv.Itoa(i)), nested)
+			}
+		default:
+			fields[path] = v
+		}
+	}
+	walk("", value)
+	return fields, nil
+}
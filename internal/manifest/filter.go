@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// labelPrefix selects a label in a selector, e.g. 'label.app=web'
+const labelPrefix = "label."
+
+// Selector matches resources by kind, name, namespace, apiVersion and
+// labels. Every field must match, values are globs.
+type Selector map[string]string
+
+// ParseSelector parses a comma separated list of key=value pairs, e.g.
+// 'kind=Deployment,name=api*'. Keys are kind, name, namespace, apiVersion
+// or label.<key>.
+func ParseSelector(s string) (Selector, error) {
+	selector := Selector{}
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid selector %q, expected key=value pairs", s)
+		}
+
+		switch {
+		case key == "kind", key == "name", key == "namespace", key == "apiVersion":
+		case strings.HasPrefix(key, labelPrefix) && len(key) > len(labelPrefix):
+		default:
+			return nil, fmt.Errorf("invalid selector %q, unknown key %q, must be one of kind, name, namespace, apiVersion or label.<key>", s, key)
+		}
+
+		// Catch malformed globs before rendering
+		if _, err := filepath.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		selector[key] = value
+	}
+
+	return selector, nil
+}
+
+// Matches reports whether every field of the selector matches the resource
+func (s Selector) Matches(r Resource) bool {
+	for key, pattern := range s {
+		var value string
+		switch key {
+		case "kind":
+			value = r.Kind
+		case "name":
+			value = r.Name
+		case "namespace":
+			value = r.Namespace
+		case "apiVersion":
+			value = r.APIVersion
+		default:
+			label, ok := r.Labels[strings.TrimPrefix(key, labelPrefix)]
+			if !ok {
+				return false
+			}
+			value = label
+		}
+
+		if ok, _ := filepath.Match(pattern, value); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Filter returns the resources of render matching any of the include
+// selectors, or all resources when there are none, that don't match any
+// of the exclude selectors
+func Filter(render string, include, exclude []Selector) (string, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return render, nil
+	}
+
+	resources, err := Parse(render)
+	if err != nil {
+		return "", err
+	}
+
+	var filtered strings.Builder
+	for _, r := range resources {
+		if len(include) > 0 && !matchesAny(include, r) {
+			continue
+		}
+		if matchesAny(exclude, r) {
+			continue
+		}
+
+		filtered.WriteString("---\n")
+		filtered.WriteString(r.Body)
+	}
+
+	return filtered.String(), nil
+}
+
+// matchesAny reports whether any of the selectors matches the resource
+func matchesAny(selectors []Selector, r Resource) bool {
+	for _, s := range selectors {
+		if s.Matches(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// podSpecPaths is the path to the pod spec for each workload kind
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// ImageChange is a container whose image differs between two renders
+type ImageChange struct {
+	// Workload is the resource running the container, from the local
+	// render unless the container was removed
+	Workload  Resource
+	Container string
+	// From and To are the image references, From is empty for an added
+	// container and To for a removed one
+	From string
+	To   string
+}
+
+// Images returns the image of every container and init container of a
+// workload by container name. Resources that aren't workloads have none.
+func Images(r Resource) (map[string]string, error) {
+	path, ok := podSpecPaths[r.Kind]
+	if !ok {
+		return nil, nil
+	}
+
+	var obj map[string]any
+	if err := yaml.Unmarshal([]byte(r.Body), &obj); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+	}
+
+	spec := obj
+	for _, key := range path {
+		spec, _ = spec[key].(map[string]any)
+	}
+
+	images := map[string]string{}
+	for _, key := range []string{"initContainers", "containers"} {
+		containers, _ := spec[key].([]any)
+		for _, c := range containers {
+			container, _ := c.(map[string]any)
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			if name != "" {
+				images[name] = image
+			}
+		}
+	}
+	return images, nil
+}
+
+// ImageChanges compares the container images of the workloads in two
+// renders, matched by resource and container name, sorted by workload
+// and container
+func ImageChanges(target, local []Resource) ([]ImageChange, error) {
+	targetByKey := make(map[string]Resource, len(target))
+	for _, r := range target {
+		targetByKey[r.Key()] = r
+	}
+	localKeys := make(map[string]bool, len(local))
+
+	var changes []ImageChange
+	compare := func(workload Resource, from, to map[string]string) {
+		for name, image := range to {
+			if from[name] != image {
+				changes = append(changes, ImageChange{Workload: workload, Container: name, From: from[name], To: image})
+			}
+		}
+		for name, image := range from {
+			if _, ok := to[name]; !ok {
+				changes = append(changes, ImageChange{Workload: workload, Container: name, From: image})
+			}
+		}
+	}
+
+	for _, r := range local {
+		localKeys[r.Key()] = true
+		to, err := Images(r)
+		if err != nil {
+			return nil, err
+		}
+		var from map[string]string
+		if old, ok := targetByKey[r.Key()]; ok {
+			if from, err = Images(old); err != nil {
+				return nil, err
+			}
+		}
+		compare(r, from, to)
+	}
+	for _, r := range target {
+		if localKeys[r.Key()] {
+			continue
+		}
+		from, err := Images(r)
+		if err != nil {
+			return nil, err
+		}
+		compare(r, from, nil)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Workload.Key() != changes[j].Workload.Key() {
+			return changes[i].Workload.Key() < changes[j].Workload.Key()
+		}
+		return changes[i].Container < changes[j].Container
+	})
+
+	return changes, nil
+}
@@ -0,0 +1,226 @@
+// Package manifest provides functions to split rendered manifests
+// into individual Kubernetes resources and compare resource sets
+// between two renders.
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resource is a single document from a rendered manifest stream
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Labels     map[string]string
+	// Source is the template path from Helm's '# Source:' comment, if present
+	Source string
+	// Body is the raw YAML of the document, without the '---' separator
+	Body string
+}
+
+// Key returns an identifier used to match a resource between two renders.
+// The apiVersion is left out so a version bump shows as a modification
+// rather than an add and a remove.
+func (r Resource) Key() string {
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// String returns a human readable identifier for the resource
+func (r Resource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// Parse splits a multi-document YAML string into resources.
+// Empty documents and documents without a kind are skipped.
+func Parse(render string) ([]Resource, error) {
+	var resources []Resource
+
+	for i, doc := range splitDocuments(render) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+
+		var meta struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string `yaml:"kind"`
+			Metadata   struct {
+				Name      string            `yaml:"name"`
+				Namespace string            `yaml:"namespace"`
+				Labels    map[string]string `yaml:"labels"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+			return nil, fmt.Errorf("failed to parse document %d: %w", i+1, err)
+		}
+
+		if meta.Kind == "" {
+			continue
+		}
+
+		resources = append(resources, Resource{
+			APIVersion: meta.APIVersion,
+			Kind:       meta.Kind,
+			Namespace:  meta.Metadata.Namespace,
+			Name:       meta.Metadata.Name,
+			Labels:     meta.Metadata.Labels,
+			Source:     SourceComment(doc),
+			Body:       doc,
+		})
+	}
+
+	return resources, nil
+}
+
+// splitDocuments splits a YAML stream on '---' separator lines
+// while keeping the original text of each document intact. The documents
+// are slices of render instead of copies, only the last one is copied to
+// end it with a newline like the others.
+func splitDocuments(render string) []string {
+	var docs []string
+
+	start := 0
+	for pos := 0; pos < len(render); {
+		end, next := len(render), len(render)
+		if i := strings.IndexByte(render[pos:], '\n'); i >= 0 {
+			end, next = pos+i, pos+i+1
+		}
+
+		if line := render[pos:end]; line == "---" || strings.HasPrefix(line, "--- ") {
+			docs = append(docs, render[start:pos])
+			// A separator on the last line without a newline leaves an
+			// empty last document
+			if end == next {
+				return append(docs, "")
+			}
+			start = next
+		}
+		pos = next
+	}
+
+	return append(docs, render[start:]+"\n")
+}
+
+// SourceComment returns the template path from a '# Source:' comment
+func SourceComment(doc string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if path, ok := strings.CutPrefix(line, "# Source: "); ok {
+			return strings.TrimSpace(path)
+		}
+	}
+	return ""
+}
+
+// KindDelta holds the number of resources of a kind on each side and
+// how many were added and removed
+type KindDelta struct {
+	Kind string
+	// Target and Local are the number of resources of the kind in each render
+	Target  int
+	Local   int
+	Added   int
+	Removed int
+}
+
+// CountChanges compares two sets of resources and returns the number of
+// resources per kind on each side, and added and removed, for every kind
+// in either set, sorted by kind
+func CountChanges(target, local []Resource) []KindDelta {
+	targetKeys := make(map[string]bool, len(target))
+	for _, r := range target {
+		targetKeys[r.Key()] = true
+	}
+	localKeys := make(map[string]bool, len(local))
+	for _, r := range local {
+		localKeys[r.Key()] = true
+	}
+
+	deltas := map[string]*KindDelta{}
+	get := func(kind string) *KindDelta {
+		if deltas[kind] == nil {
+			deltas[kind] = &KindDelta{Kind: kind}
+		}
+		return deltas[kind]
+	}
+
+	for _, r := range local {
+		d := get(r.Kind)
+		d.Local++
+		if !targetKeys[r.Key()] {
+			d.Added++
+		}
+	}
+	for _, r := range target {
+		d := get(r.Kind)
+		d.Target++
+		if !localKeys[r.Key()] {
+			d.Removed++
+		}
+	}
+
+	result := make([]KindDelta, 0, len(deltas))
+	for _, d := range deltas {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Kind < result[j].Kind
+	})
+
+	return result
+}
+
+// Changed returns the local resources that were added or modified
+// compared to the target, in the order of the local render
+func Changed(target, local []Resource) []Resource {
+	targetBodies := make(map[string]string, len(target))
+	for _, r := range target {
+		targetBodies[r.Key()] = r.Body
+	}
+
+	var changed []Resource
+	for _, r := range local {
+		if body, ok := targetBodies[r.Key()]; !ok || body != r.Body {
+			changed = append(changed, r)
+		}
+	}
+
+	return changed
+}
+
+// Duplicate is a resource that appears more than once in a render, which
+// fails to apply
+type Duplicate struct {
+	Resource Resource
+	// Count is the number of documents with the resource's identity
+	Count int
+}
+
+// Duplicates returns the resources with the same apiVersion, kind,
+// namespace and name as another resource, in the order they first appear
+func Duplicates(resources []Resource) []Duplicate {
+	counts := map[string]int{}
+	var first []Resource
+	for _, r := range resources {
+		id := r.APIVersion + "/" + r.Key()
+		if counts[id] == 0 {
+			first = append(first, r)
+		}
+		counts[id]++
+	}
+
+	var duplicates []Duplicate
+	for _, r := range first {
+		if n := counts[r.APIVersion+"/"+r.Key()]; n > 1 {
+			duplicates = append(duplicates, Duplicate{Resource: r, Count: n})
+		}
+	}
+	return duplicates
+}
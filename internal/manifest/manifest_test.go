@@ -0,0 +1,752 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+const testRender = `---
+# Source: helloworld/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hello
+  namespace: default
+data:
+  key: value
+---
+# Source: helloworld/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: hello
+---
+`
+
+func TestParse(t *testing.T) {
+	resources, err := Parse(testRender)
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	if len(resources) != 2 {
+		t.Fatalf("Parse() returned %d resources, want 2", len(resources))
+	}
+
+	testCases := []struct {
+		name       string
+		resource   Resource
+		wantKey    string
+		wantSource string
+	}{
+		{
+			name:       "Namespaced ConfigMap",
+			resource:   resources[0],
+			wantKey:    "ConfigMap/default/hello",
+			wantSource: "helloworld/templates/configmap.yaml",
+		},
+		{
+			name:       "Deployment without namespace",
+			resource:   resources[1],
+			wantKey:    "Deployment//hello",
+			wantSource: "helloworld/templates/deployment.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.resource.Key(); got != tc.wantKey {
+				t.Errorf("Key() = %q, want %q", got, tc.wantKey)
+			}
+			if tc.resource.Source != tc.wantSource {
+				t.Errorf("Source = %q, want %q", tc.resource.Source, tc.wantSource)
+			}
+		})
+	}
+
+	// Large renders are diffed resource by resource without another copy
+	// of every body
+	t.Run("Bodies are slices of the render", func(t *testing.T) {
+		render := uintptr(unsafe.Pointer(unsafe.StringData(testRender)))
+		body := uintptr(unsafe.Pointer(unsafe.StringData(resources[0].Body)))
+		if body < render || body >= render+uintptr(len(testRender)) {
+			t.Error("Body of the first resource is a copy, want a slice of the render")
+		}
+	})
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		_, err := Parse("kind: [unclosed")
+		if err == nil {
+			t.Error("Parse() succeeded on invalid YAML, expected an error")
+		}
+	})
+}
+
+func TestCountChanges(t *testing.T) {
+	target := []Resource{
+		{Kind: "ConfigMap", Name: "a"},
+		{Kind: "ConfigMap", Name: "b"},
+		{Kind: "Service", Name: "svc"},
+	}
+	local := []Resource{
+		{Kind: "ConfigMap", Name: "a"},
+		{Kind: "Deployment", Name: "api"},
+		{Kind: "Deployment", Name: "worker"},
+		{Kind: "Service", Name: "svc"},
+	}
+
+	got := CountChanges(target, local)
+	want := []KindDelta{
+		{Kind: "ConfigMap", Target: 2, Local: 1, Added: 0, Removed: 1},
+		{Kind: "Deployment", Target: 0, Local: 2, Added: 2, Removed: 0},
+		{Kind: "Service", Target: 1, Local: 1, Added: 0, Removed: 0},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("CountChanges() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("CountChanges()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	target := []Resource{
+		{Kind: "ConfigMap", Name: "same", Body: "a"},
+		{Kind: "ConfigMap", Name: "modified", Body: "a"},
+		{Kind: "ConfigMap", Name: "removed", Body: "a"},
+	}
+	local := []Resource{
+		{Kind: "ConfigMap", Name: "same", Body: "a"},
+		{Kind: "ConfigMap", Name: "modified", Body: "b"},
+		{Kind: "ConfigMap", Name: "added", Body: "a"},
+	}
+
+	got := Changed(target, local)
+	if len(got) != 2 || got[0].Name != "modified" || got[1].Name != "added" {
+		t.Errorf("Changed() = %+v, want the modified and added resources", got)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	resources := []Resource{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "a", Name: "dup"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "b", Name: "dup"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "api"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "a", Name: "dup"},
+		{APIVersion: "apps/v1beta1", Kind: "Deployment", Name: "api"},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "a", Name: "dup"},
+	}
+
+	got := Duplicates(resources)
+	if len(got) != 1 || got[0].Resource.String() != "ConfigMap/a/dup" || got[0].Count != 3 {
+		t.Errorf("Duplicates() = %+v, want ConfigMap/a/dup 3 times", got)
+	}
+}
+
+func TestStat(t *testing.T) {
+	target := []Resource{
+		{Kind: "ConfigMap", Name: "same", Body: "a: 1\n"},
+		{Kind: "ConfigMap", Name: "modified", Body: "a: 1\nb: 2\n"},
+		{Kind: "Service", Name: "removed", Body: "a: 1\nb: 2\n"},
+	}
+	local := []Resource{
+		{Kind: "ConfigMap", Name: "same", Body: "a: 1\n"},
+		{Kind: "ConfigMap", Name: "modified", Body: "a: 1\nb: 3\nc: 4\n"},
+		{Kind: "Deployment", Name: "added", Body: "a: 1\n"},
+	}
+
+	got := Stat(target, local)
+	want := []struct {
+		name    string
+		status  string
+		added   int
+		removed int
+	}{
+		{name: "modified", status: StatusModified, added: 2, removed: 1},
+		{name: "added", status: StatusAdded, added: 1, removed: 0},
+		{name: "removed", status: StatusRemoved, added: 0, removed: 2},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Stat() = %+v, want %d resources", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Resource.Name != w.name || g.Status != w.status || g.Added != w.added || g.Removed != w.removed {
+			t.Errorf("Stat()[%d] = %s %s +%d -%d, want %s %s +%d -%d",
+				i, g.Resource.Name, g.Status, g.Added, g.Removed, w.name, w.status, w.added, w.removed)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	render := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    app: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-config
+  namespace: prod
+`
+
+	parse := func(selectors ...string) []Selector {
+		var parsed []Selector
+		for _, s := range selectors {
+			selector, err := ParseSelector(s)
+			if err != nil {
+				t.Fatalf("ParseSelector(%q) failed: %v", s, err)
+			}
+			parsed = append(parsed, selector)
+		}
+		return parsed
+	}
+
+	testCases := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{name: "No selectors", want: []string{"api", "worker", "api-config"}},
+		{name: "Include by kind and name glob", include: []string{"kind=Deployment,name=api*"}, want: []string{"api"}},
+		{name: "Include is a union", include: []string{"name=worker", "namespace=prod"}, want: []string{"worker", "api-config"}},
+		{name: "Exclude by kind", exclude: []string{"kind=ConfigMap"}, want: []string{"api", "worker"}},
+		{name: "Include by label", include: []string{"label.app=web"}, want: []string{"api"}},
+		{name: "Exclude wins over include", include: []string{"name=api*"}, exclude: []string{"apiVersion=v1"}, want: []string{"api"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered, err := Filter(render, parse(tc.include...), parse(tc.exclude...))
+			if err != nil {
+				t.Fatalf("Filter() failed: %v", err)
+			}
+
+			resources, err := Parse(filtered)
+			if err != nil {
+				t.Fatalf("Parse() of the filtered render failed: %v", err)
+			}
+
+			var got []string
+			for _, r := range resources {
+				got = append(got, r.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Filter() kept %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Invalid selectors", func(t *testing.T) {
+		for _, s := range []string{"kind", "color=red", "name=[", "label.=x"} {
+			if _, err := ParseSelector(s); err == nil {
+				t.Errorf("ParseSelector(%q) succeeded, expected an error", s)
+			}
+		}
+	})
+}
+
+func TestRemoveNoise(t *testing.T) {
+	render := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    helm.sh/chart: web-1.2.3
+    "app.kubernetes.io/version": "1.2.3"
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      annotations:
+        checksum/config: 0123abcd
+        checksum/secret: >-
+          4567ef
+        prometheus.io/scrape: "true"
+      labels:
+        app: web
+        helm.sh/chart: web-1.2.3
+    spec:
+      containers:
+        - name: web
+          env:
+            - name: helm.sh/chart
+              value: kept
+`
+
+	want := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      annotations:
+        prometheus.io/scrape: "true"
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          env:
+            - name: helm.sh/chart
+              value: kept
+`
+
+	got := RemoveNoise(render, NoiseLabels, NoiseAnnotations)
+	if got != want {
+		t.Errorf("RemoveNoise() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.yaml":        "kind: Service\n",
+		"a.yml":         "kind: ConfigMap",
+		"sub/c.yaml":    "kind: Deployment\n",
+		"README.md":     "not a manifest\n",
+		"sub/notes.txt": "not a manifest\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ReadFiles(dir)
+	if err != nil {
+		t.Fatalf("ReadFiles() failed: %v", err)
+	}
+	want := "kind: ConfigMap\n---\nkind: Service\n---\nkind: Deployment\n"
+	if got != want {
+		t.Errorf("ReadFiles() =\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = ReadFiles(filepath.Join(dir, "b.yaml"))
+	if err != nil {
+		t.Fatalf("ReadFiles() failed: %v", err)
+	}
+	if got != files["b.yaml"] {
+		t.Errorf("ReadFiles() = %q, want %q", got, files["b.yaml"])
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := map[string]string{
+		"service/default/removed.yaml": "kind: Service\n",
+		"README.md":                    "kept\n",
+	}
+	for name, content := range stale {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	render := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: app
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+`
+	n, err := WriteFiles(render, dir)
+	if err != nil {
+		t.Fatalf("WriteFiles() failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("WriteFiles() wrote %d files, want 2", n)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "configmap", "app", "config.yaml"))
+	if err != nil {
+		t.Fatalf("ConfigMap wasn't written: %v", err)
+	}
+	if want := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: app\n"; string(content) != want {
+		t.Errorf("ConfigMap file = %q, want %q", content, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clusterrole", "reader.yaml")); err != nil {
+		t.Errorf("ClusterRole wasn't written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "service")); !os.IsNotExist(err) {
+		t.Errorf("Stale manifest directory wasn't removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err != nil {
+		t.Errorf("Non-YAML file was removed: %v", err)
+	}
+
+	duplicate := render + "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  namespace: app\n"
+	if _, err := WriteFiles(duplicate, dir); err == nil {
+		t.Error("WriteFiles() succeeded with a duplicate resource, expected an error")
+	}
+}
+
+func TestImageChanges(t *testing.T) {
+	deployment := func(name string, images ...string) Resource {
+		body := "kind: Deployment\nspec:\n  template:\n    spec:\n      containers:\n"
+		for i := 0; i+1 < len(images); i += 2 {
+			body += fmt.Sprintf("        - name: %s\n          image: %s\n", images[i], images[i+1])
+		}
+		return Resource{Kind: "Deployment", Name: name, Body: body}
+	}
+	cronJob := Resource{Kind: "CronJob", Name: "backup", Body: `kind: CronJob
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+            - name: init
+              image: busybox:1.36
+          containers:
+            - name: backup
+              image: restic/restic:0.16.0
+`}
+
+	target := []Resource{
+		deployment("api", "api", "ghcr.io/acme/api:1.2.0", "proxy", "envoyproxy/envoy:v1.29.0"),
+		deployment("same", "app", "nginx:1.25"),
+		deployment("removed", "app", "redis:7.2"),
+		{Kind: "ConfigMap", Name: "config", Body: "kind: ConfigMap\n"},
+	}
+	local := []Resource{
+		deployment("api", "api", "ghcr.io/acme/api:1.3.0", "sidecar", "fluent/fluent-bit:3.0"),
+		deployment("same", "app", "nginx:1.25"),
+		cronJob,
+		{Kind: "ConfigMap", Name: "config", Body: "kind: ConfigMap\ndata: {}\n"},
+	}
+
+	got, err := ImageChanges(target, local)
+	if err != nil {
+		t.Fatalf("ImageChanges() failed: %v", err)
+	}
+
+	want := []string{
+		"CronJob/backup backup:  -> restic/restic:0.16.0",
+		"CronJob/backup init:  -> busybox:1.36",
+		"Deployment/api api: ghcr.io/acme/api:1.2.0 -> ghcr.io/acme/api:1.3.0",
+		"Deployment/api proxy: envoyproxy/envoy:v1.29.0 -> ",
+		"Deployment/api sidecar:  -> fluent/fluent-bit:3.0",
+		"Deployment/removed app: redis:7.2 -> ",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ImageChanges() = %+v, want %d changes", got, len(want))
+	}
+	for i, c := range got {
+		if s := fmt.Sprintf("%s %s: %s -> %s", c.Workload, c.Container, c.From, c.To); s != want[i] {
+			t.Errorf("ImageChanges()[%d] = %q, want %q", i, s, want[i])
+		}
+	}
+}
+
+func TestInjectNamespace(t *testing.T) {
+	render := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+    name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  scope: Cluster
+  names:
+    kind: Widget
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`
+
+	want := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+    namespace: team
+    name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  scope: Cluster
+  names:
+    kind: Widget
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`
+
+	got, err := InjectNamespace(render, "team")
+	if err != nil {
+		t.Fatalf("InjectNamespace() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("InjectNamespace() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAddMetadata(t *testing.T) {
+	render := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    argocd.argoproj.io/instance: >-
+      old
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  annotations: {}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels: {app: web}
+`
+
+	want := `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    team: "true"
+  name: web
+  labels:
+    app: web
+    argocd.argoproj.io/instance: prod-web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    argocd.argoproj.io/instance: prod-web
+  name: config
+  annotations:
+    team: "true"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  annotations:
+    team: "true"
+  name: web
+  labels: {app: web}
+`
+
+	got, err := AddMetadata(render, map[string]string{"argocd.argoproj.io/instance": "prod-web"}, map[string]string{"team": "true"})
+	if err != nil {
+		t.Fatalf("AddMetadata() failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("AddMetadata() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	base := []Resource{
+		{Kind: "ConfigMap", Name: "same-field", Body: "data:\n  a: \"1\"\n  b: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "other-fields", Body: "data:\n  a: \"1\"\n  b: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "same-change", Body: "data:\n  a: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "upstream-only", Body: "data:\n  a: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "removed-upstream", Body: "data:\n  a: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "removed-locally", Body: "data:\n  a: \"1\"\n"},
+	}
+	upstream := []Resource{
+		{Kind: "ConfigMap", Name: "same-field", Body: "data:\n  a: \"2\"\n  b: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "other-fields", Body: "data:\n  a: \"2\"\n  b: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "same-change", Body: "data:\n  a: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "upstream-only", Body: "data:\n  a: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "removed-locally", Body: "data:\n  a: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "added-both", Body: "data:\n  a: \"1\"\n"},
+	}
+	local := []Resource{
+		{Kind: "ConfigMap", Name: "same-field", Body: "data:\n  a: \"3\"\n  b: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "other-fields", Body: "data:\n  a: \"1\"\n  b: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "same-change", Body: "data:\n  a: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "upstream-only", Body: "data:\n  a: \"1\"\n"},
+		{Kind: "ConfigMap", Name: "removed-upstream", Body: "data:\n  a: \"2\"\n"},
+		{Kind: "ConfigMap", Name: "added-both", Body: "data:\n  a: \"2\"\n"},
+	}
+
+	got, err := Conflicts(base, upstream, local)
+	if err != nil {
+		t.Fatalf("Conflicts() error = %v", err)
+	}
+
+	want := []Conflict{
+		{Resource: Resource{Name: "added-both"}, Whole: true},
+		{Resource: Resource{Name: "other-fields"}},
+		{Resource: Resource{Name: "removed-locally"}, Whole: true},
+		{Resource: Resource{Name: "removed-upstream"}, Whole: true},
+		{Resource: Resource{Name: "same-field"}, Fields: []string{"data.a"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Conflicts() = %+v, want %d conflicts", got, len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Resource.Name != w.Resource.Name || g.Whole != w.Whole || strings.Join(g.Fields, ",") != strings.Join(w.Fields, ",") {
+			t.Errorf("Conflicts()[%d] = %s %v %v, want %s %v %v", i, g.Resource.Name, g.Fields, g.Whole, w.Resource.Name, w.Fields, w.Whole)
+		}
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	render := `# Source: app/templates/cm.yaml
+# A full line comment
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: the-map # the name
+  annotations:
+    url: "http://example.com/#anchor" # quoted
+    note: don't # apostrophe
+    tag: a#b
+data:
+  script: |
+    # kept, it's content
+    echo "# also kept"
+  # ends the block
+  key: value
+`
+
+	testCases := []struct {
+		name   string
+		source bool
+		want   string
+	}{
+		{
+			name: "Keeps source comments",
+			want: `# Source: app/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: the-map
+  annotations:
+    url: "http://example.com/#anchor"
+    note: don't
+    tag: a#b
+data:
+  script: |
+    # kept, it's content
+    echo "# also kept"
+  key: value
+`,
+		},
+		{
+			name:   "Strips source comments",
+			source: true,
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: the-map
+  annotations:
+    url: "http://example.com/#anchor"
+    note: don't
+    tag: a#b
+data:
+  script: |
+    # kept, it's content
+    echo "# also kept"
+  key: value
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := StripComments(render, tc.source); got != tc.want {
+				t.Errorf("StripComments() =\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
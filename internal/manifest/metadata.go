@@ -0,0 +1,206 @@
+package manifest
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AddMetadata sets labels and annotations on the top-level metadata of
+// every resource in a render, replacing the values of keys it already has,
+// like the tracking label a GitOps controller adds when it applies the
+// resources. Labels and annotations in flow style are left as is. The
+// render is edited line by line so the rest of it keeps its formatting and
+// comments.
+func AddMetadata(render string, labels, annotations map[string]string) (string, error) {
+	if len(labels) == 0 && len(annotations) == 0 {
+		return render, nil
+	}
+	// Reject broken documents before editing them line by line
+	if _, err := Parse(render); err != nil {
+		return "", err
+	}
+
+	return editDocuments(render, func(doc string) string {
+		var meta struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &meta); err != nil || meta.Kind == "" {
+			return doc
+		}
+
+		lines := strings.SplitAfter(doc, "\n")
+		lines = setMetadataEntries(lines, "labels", labels)
+		lines = setMetadataEntries(lines, "annotations", annotations)
+		return strings.Join(lines, "")
+	}), nil
+}
+
+// editDocuments applies edit to every document of a render and joins them
+// again with '---' separators
+func editDocuments(render string, edit func(doc string) string) string {
+	docs := splitDocuments(render)
+	var out strings.Builder
+	for i, doc := range docs {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		// splitDocuments adds a newline to the last document
+		if i == len(docs)-1 {
+			doc = strings.TrimSuffix(doc, "\n")
+		}
+		out.WriteString(edit(doc))
+	}
+	return out.String()
+}
+
+// setMetadataEntries sets entries in the field map, labels or annotations,
+// of the top-level metadata block in lines
+func setMetadataEntries(lines []string, field string, entries map[string]string) []string {
+	if len(entries) == 0 {
+		return lines
+	}
+
+	start, end := topLevelBlock(lines, "metadata")
+	if start < 0 {
+		return lines
+	}
+	fieldIndent := blockIndent(lines[start+1:end], "  ")
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Find the field in the metadata block
+	fieldLine := -1
+	for i := start + 1; i < end; i++ {
+		match := yamlKey.FindStringSubmatch(strings.TrimPrefix(lines[i], fieldIndent))
+		if lineIndent(lines[i]) == len(fieldIndent) && match != nil && match[1] == "" && strings.Trim(match[2], `"'`) == field {
+			fieldLine = i
+			break
+		}
+	}
+
+	if fieldLine < 0 {
+		added := []string{fieldIndent + field + ":\n"}
+		for _, k := range keys {
+			added = append(added, mapEntry(fieldIndent+fieldIndent, k, entries[k]))
+		}
+		return splice(lines, start+1, start+1, added)
+	}
+
+	rest := strings.TrimSpace(strings.SplitN(lines[fieldLine], ":", 2)[1])
+	switch {
+	case rest == "{}":
+		added := []string{fieldIndent + field + ":\n"}
+		for _, k := range keys {
+			added = append(added, mapEntry(fieldIndent+fieldIndent, k, entries[k]))
+		}
+		return splice(lines, fieldLine, fieldLine+1, added)
+	case rest != "" && !strings.HasPrefix(rest, "#"):
+		// Flow style
+		return lines
+	}
+
+	// The entries of the field end at the next line indented no further
+	// than the field itself
+	fieldEnd := fieldLine + 1
+	for ; fieldEnd < end; fieldEnd++ {
+		if isContent(lines[fieldEnd]) && lineIndent(lines[fieldEnd]) <= len(fieldIndent) {
+			break
+		}
+	}
+	entryIndent := blockIndent(lines[fieldLine+1:fieldEnd], fieldIndent+fieldIndent)
+
+	var out []string
+	set := map[string]bool{}
+	skipping := false
+	for i := fieldLine + 1; i < fieldEnd; i++ {
+		line := lines[i]
+		if skipping && (!isContent(line) || lineIndent(line) > len(entryIndent)) {
+			continue
+		}
+		skipping = false
+
+		if lineIndent(line) == len(entryIndent) {
+			if match := yamlKey.FindStringSubmatch(strings.TrimPrefix(line, entryIndent)); match != nil && match[1] == "" {
+				key := strings.Trim(match[2], `"'`)
+				if value, ok := entries[key]; ok {
+					out = append(out, mapEntry(entryIndent, key, value))
+					set[key] = true
+					// Drop the continuation lines of the old value
+					skipping = true
+					continue
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	for _, k := range keys {
+		if !set[k] {
+			out = append(out, mapEntry(entryIndent, k, entries[k]))
+		}
+	}
+	return splice(lines, fieldLine+1, fieldEnd, out)
+}
+
+// topLevelBlock returns the index of the 'key:' line at the top level of
+// a document and the index of the line after its block, or -1 if the
+// document has no such block
+func topLevelBlock(lines []string, key string) (int, int) {
+	for i, line := range lines {
+		if strings.TrimRight(line, " \r\n") != key+":" {
+			continue
+		}
+		end := i + 1
+		for ; end < len(lines); end++ {
+			if isContent(lines[end]) && lineIndent(lines[end]) == 0 {
+				break
+			}
+		}
+		return i, end
+	}
+	return -1, -1
+}
+
+// blockIndent returns the indentation of the first entry in lines, or
+// fallback if there is none
+func blockIndent(lines []string, fallback string) string {
+	for _, line := range lines {
+		if isContent(line) {
+			if n := lineIndent(line); n > 0 {
+				return line[:n]
+			}
+			break
+		}
+	}
+	return fallback
+}
+
+// isContent reports whether line is neither blank nor a comment
+func isContent(line string) bool {
+	content := strings.TrimSpace(line)
+	return content != "" && !strings.HasPrefix(content, "#")
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// mapEntry formats a 'key: value' line, quoting both where YAML needs it
+func mapEntry(indent, key, value string) string {
+	k, _ := yaml.Marshal(key)
+	v, _ := yaml.Marshal(value)
+	return indent + strings.TrimSuffix(string(k), "\n") + ": " + string(v)
+}
+
+// splice replaces lines[from:to] with replacement
+func splice(lines []string, from, to int, replacement []string) []string {
+	out := make([]string, 0, len(lines)-(to-from)+len(replacement))
+	out = append(out, lines[:from]...)
+	out = append(out, replacement...)
+	return append(out, lines[to:]...)
+}
@@ -0,0 +1,95 @@
+package manifest
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clusterScopedKinds are the built-in kinds that have no namespace
+var clusterScopedKinds = map[string]bool{
+	"APIService":                       true,
+	"CertificateSigningRequest":        true,
+	"ComponentStatus":                  true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"CustomResourceDefinition":         true,
+	"FlowSchema":                       true,
+	"IngressClass":                     true,
+	"MutatingAdmissionPolicy":          true,
+	"MutatingAdmissionPolicyBinding":   true,
+	"MutatingWebhookConfiguration":     true,
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"PodSecurityPolicy":                true,
+	"PriorityClass":                    true,
+	"PriorityLevelConfiguration":       true,
+	"RuntimeClass":                     true,
+	"StorageClass":                     true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"ValidatingWebhookConfiguration":   true,
+	"VolumeAttachment":                 true,
+}
+
+// InjectNamespace sets metadata.namespace on the namespaced resources of a
+// render that don't have one, like 'helm install -n' or 'kubectl apply -n'
+// would. Built-in cluster scoped kinds, kinds defined as cluster scoped by
+// a CustomResourceDefinition in the render and kinds starting with
+// 'Cluster', like ClusterRole or cert-manager's ClusterIssuer, are left
+// alone. The namespace line is inserted into the document so the rest of
+// it keeps its formatting and comments.
+func InjectNamespace(render, namespace string) (string, error) {
+	resources, err := Parse(render)
+	if err != nil {
+		return "", err
+	}
+	clusterKinds := map[string]bool{}
+	for _, r := range resources {
+		if r.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		var crd struct {
+			Spec struct {
+				Scope string `yaml:"scope"`
+				Names struct {
+					Kind string `yaml:"kind"`
+				} `yaml:"names"`
+			} `yaml:"spec"`
+		}
+		if err := yaml.Unmarshal([]byte(r.Body), &crd); err == nil && crd.Spec.Scope == "Cluster" {
+			clusterKinds[crd.Spec.Names.Kind] = true
+		}
+	}
+
+	return editDocuments(render, func(doc string) string {
+		var meta struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Namespace string `yaml:"namespace"`
+			} `yaml:"metadata"`
+		}
+		// Documents that don't parse were rejected by Parse already
+		_ = yaml.Unmarshal([]byte(doc), &meta)
+
+		namespaced := meta.Kind != "" && !clusterScopedKinds[meta.Kind] && !clusterKinds[meta.Kind] &&
+			!strings.HasPrefix(meta.Kind, "Cluster")
+		if !namespaced || meta.Metadata.Namespace != "" {
+			return doc
+		}
+		return insertNamespace(doc, namespace)
+	}), nil
+}
+
+// insertNamespace adds a namespace line as the first entry of the
+// top-level metadata block of doc. Flow style metadata is left as is.
+func insertNamespace(doc, namespace string) string {
+	lines := strings.SplitAfter(doc, "\n")
+	start, end := topLevelBlock(lines, "metadata")
+	if start < 0 {
+		return doc
+	}
+	indent := blockIndent(lines[start+1:end], "  ")
+	return strings.Join(splice(lines, start+1, start+1, []string{mapEntry(indent, "namespace", namespace)}), "")
+}
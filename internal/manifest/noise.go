@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// NoiseLabels and NoiseAnnotations are the labels and annotations that
+// change on every chart version bump, they are globs
+var (
+	NoiseLabels      = []string{"helm.sh/chart", "app.kubernetes.io/version"}
+	NoiseAnnotations = []string{"checksum/*"}
+)
+
+// yamlKey matches the key of a mapping entry, optionally the first entry
+// of a list item
+var yamlKey = regexp.MustCompile(`^(- )?("[^"]*"|'[^']*'|[^\s#"'-][^:]*?):(\s|$)`)
+
+// RemoveNoise removes the labels and annotations matching the given globs
+// from every resource in a render. The render is edited line by line so
+// the rest of it keeps its formatting and comments.
+func RemoveNoise(render string, labels, annotations []string) string {
+	type entry struct {
+		indent int
+		key    string
+	}
+	var parents []entry
+	// skipIndent drops the continuation lines of a removed value
+	skipIndent := -1
+
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(render, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+
+		// Blank lines and comments don't change the structure
+		content := strings.TrimSpace(trimmed)
+		if content == "" || strings.HasPrefix(content, "#") {
+			if skipIndent < 0 {
+				out.WriteString(line)
+			}
+			continue
+		}
+
+		if skipIndent >= 0 {
+			if indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+		}
+
+		match := yamlKey.FindStringSubmatch(trimmed)
+		if match == nil {
+			out.WriteString(line)
+			continue
+		}
+		// The first key of a list item is indented past the dash
+		if match[1] != "" {
+			indent += len(match[1])
+		}
+		key := strings.Trim(match[2], `"'`)
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+
+		if len(parents) > 0 {
+			var patterns []string
+			switch parents[len(parents)-1].key {
+			case "labels":
+				patterns = labels
+			case "annotations":
+				patterns = annotations
+			}
+			if matchesGlob(patterns, key) {
+				skipIndent = indent
+				continue
+			}
+		}
+
+		parents = append(parents, entry{indent: indent, key: key})
+		out.WriteString(line)
+	}
+
+	return out.String()
+}
+
+// matchesGlob reports whether key matches any of the globs
+func matchesGlob(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
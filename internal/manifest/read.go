@@ -0,0 +1,50 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFiles reads pre-rendered manifests from a file, or from every YAML
+// file below a directory in lexical order, as a single multi-document
+// stream
+func ReadFiles(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read manifests from %s: %w", path, err)
+		}
+		return string(content), nil
+	}
+
+	var docs []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		if d.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, strings.TrimSuffix(string(content), "\n")+"\n")
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifests from %s: %w", path, err)
+	}
+
+	return strings.Join(docs, "---\n"), nil
+}
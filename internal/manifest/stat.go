@@ -0,0 +1,82 @@
+package manifest
+
+import (
+	"sort"
+
+	"github.com/hexops/gotextdiff"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// Statuses of a resource that differs between two renders
+const (
+	StatusAdded    = "added"
+	StatusRemoved  = "removed"
+	StatusModified = "modified"
+)
+
+// ResourceStat holds the lines changed in a resource between two renders
+type ResourceStat struct {
+	Resource Resource
+	// Status is one of the Status constants
+	Status string
+	// Added and Removed are the number of lines added and removed
+	Added   int
+	Removed int
+}
+
+// Stat compares two sets of resources and returns a ResourceStat for
+// every resource that was added, removed or modified, sorted by key
+func Stat(target, local []Resource) []ResourceStat {
+	targetByKey := make(map[string]Resource, len(target))
+	for _, r := range target {
+		targetByKey[r.Key()] = r
+	}
+	localKeys := make(map[string]bool, len(local))
+
+	var stats []ResourceStat
+	for _, r := range local {
+		localKeys[r.Key()] = true
+
+		old, ok := targetByKey[r.Key()]
+		switch {
+		case !ok:
+			added, _ := countLines("", r.Body)
+			stats = append(stats, ResourceStat{Resource: r, Status: StatusAdded, Added: added})
+		case old.Body != r.Body:
+			added, removed := countLines(old.Body, r.Body)
+			stats = append(stats, ResourceStat{Resource: r, Status: StatusModified, Added: added, Removed: removed})
+		}
+	}
+	for _, r := range target {
+		if !localKeys[r.Key()] {
+			_, removed := countLines(r.Body, "")
+			stats = append(stats, ResourceStat{Resource: r, Status: StatusRemoved, Removed: removed})
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Resource.Key() < stats[j].Resource.Key()
+	})
+
+	return stats
+}
+
+// countLines returns the number of lines added and removed between a and b
+func countLines(a, b string) (added, removed int) {
+	edits := myers.ComputeEdits(span.URIFromPath(""), a, b)
+	unified := gotextdiff.ToUnified("a", "b", a, edits)
+
+	for _, hunk := range unified.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case gotextdiff.Insert:
+				added++
+			case gotextdiff.Delete:
+				removed++
+			}
+		}
+	}
+
+	return added, removed
+}
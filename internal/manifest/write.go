@@ -0,0 +1,103 @@
+package manifest
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// FilePath returns the path of a resource in a directory written by
+// WriteFiles: kind/namespace/name.yaml with the kind lower-cased, or
+// kind/name.yaml for resources without a namespace
+func FilePath(r Resource) string {
+	kind := strings.ToLower(r.Kind)
+	if r.Namespace == "" {
+		return filepath.Join(kind, r.Name+".yaml")
+	}
+	return filepath.Join(kind, r.Namespace, r.Name+".yaml")
+}
+
+// WriteFiles writes every resource of a render to its own file below dir,
+// at FilePath, and returns the number of files written. YAML files below
+// dir that aren't part of the render are removed along with the
+// directories left empty, so dir always mirrors the latest render and can
+// be committed as is.
+func WriteFiles(render, dir string) (int, error) {
+	resources, err := Parse(render)
+	if err != nil {
+		return 0, err
+	}
+
+	written := map[string]string{}
+	for _, r := range resources {
+		rel := FilePath(r)
+		if other, ok := written[rel]; ok {
+			return 0, fmt.Errorf("%s and %s would both be written to %s", other, r, rel)
+		}
+		written[rel] = r.String()
+	}
+
+	for _, r := range resources {
+		path := filepath.Join(dir, FilePath(r))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return 0, fmt.Errorf("failed to create output directory: %w", err)
+		}
+		body := strings.TrimSpace(r.Body) + "\n"
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			return 0, fmt.Errorf("failed to write %s: %w", r, err)
+		}
+	}
+
+	if err := removeStale(dir, written); err != nil {
+		return 0, err
+	}
+	return len(resources), nil
+}
+
+// removeStale removes the YAML files below dir that aren't in keep, then
+// the directories left empty
+func removeStale(dir string, keep map[string]string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir {
+				dirs = append(dirs, p)
+			}
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(p))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if _, ok := keep[rel]; ok {
+			return nil
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove stale manifests: %w", err)
+	}
+
+	// Deepest first, so parents are empty once their children are removed
+	slices.Reverse(dirs)
+	for _, d := range dirs {
+		entries, err := os.ReadDir(d)
+		if err == nil && len(entries) == 0 {
+			if err := os.Remove(d); err != nil {
+				return fmt.Errorf("failed to remove stale manifests: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package mask hides secret values, like the ones decrypted from SOPS
+// values files, and text matching the redaction patterns of the config
+// file in everything rdv prints or writes. Each secret is replaced by a
+// placeholder derived from its keyed hash, so a changed secret still shows
+// up in the diff without revealing either value.
+package mask
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// placeholderPrefix starts every placeholder
+const placeholderPrefix = "REDACTED-"
+
+// minLength is the length below which values aren't masked, short values
+// like 'true' or '80' would mask unrelated parts of the render
+const minLength = 4
+
+var (
+	mu sync.RWMutex
+	// secrets maps every registered value and its base64 encoding to
+	// their placeholder
+	secrets = map[string]string{}
+	// replacer is rebuilt when a secret is added
+	replacer *strings.Replacer
+	// patterns are the redaction patterns
+	patterns []*regexp.Regexp
+	// key salts the placeholders, so a short or guessable secret can't
+	// be found by hashing candidates. It's random for every process.
+	key = NewKey()
+)
+
+// NewKey returns a random placeholder key
+func NewKey() []byte {
+	k := make([]byte, 32)
+	_, _ = rand.Read(k)
+	return k
+}
+
+// SetKey replaces the key placeholders are derived with, e.g. with one
+// kept next to cached renders so their placeholders match. It must be
+// called before any secret is added.
+func SetKey(k []byte) {
+	mu.Lock()
+	defer mu.Unlock()
+	key = k
+}
+
+// KeyID identifies the current key without revealing it, so renders
+// masked with different keys aren't compared
+func KeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return hex.EncodeToString(keyedHash(key, "key-id")[:4])
+}
+
+// Placeholder returns the text a secret is replaced with. It's stable for
+// the same key.
+func Placeholder(secret string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return placeholder(secret)
+}
+
+// placeholder is Placeholder for callers holding mu
+func placeholder(secret string) string {
+	return placeholderPrefix + hex.EncodeToString(keyedHash(key, secret)[:4])
+}
+
+// keyedHash returns the HMAC-SHA256 of s
+func keyedHash(k []byte, s string) []byte {
+	h := hmac.New(sha256.New, k)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// Add registers secret values to be masked. The base64 encoding of each
+// value is masked by the base64 encoding of its placeholder, so Secret
+// data stays valid base64.
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, v := range values {
+		if len(v) < minLength {
+			continue
+		}
+		masked := placeholder(v)
+		secrets[v] = masked
+		secrets[base64.StdEncoding.EncodeToString([]byte(v))] = base64.StdEncoding.EncodeToString([]byte(masked))
+	}
+	replacer = nil
+}
+
+// SetPatterns replaces the redaction patterns. Text matching a pattern is
+// masked, or only the text of its capture groups if it has any, e.g.
+// 'password: (\S+)' keeps the key.
+func SetPatterns(exprs []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern %q: %w", expr, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	patterns = compiled
+	return nil
+}
+
+// String returns s with every registered secret and every match of the
+// redaction patterns masked
+func String(s string) string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(secrets) > 0 {
+		s = replaceSecrets(s)
+	}
+	for _, re := range patterns {
+		s = redact(re, s)
+	}
+	return s
+}
+
+// replaceSecrets masks the registered secrets, the caller must hold mu
+func replaceSecrets(s string) string {
+	if replacer == nil {
+		// Longer secrets first, so a secret containing another one is
+		// masked as a whole
+		values := make([]string, 0, len(secrets))
+		for v := range secrets {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if len(values[i]) != len(values[j]) {
+				return len(values[i]) > len(values[j])
+			}
+			return values[i] < values[j]
+		})
+
+		pairs := make([]string, 0, 2*len(values))
+		for _, v := range values {
+			pairs = append(pairs, v, secrets[v])
+		}
+		replacer = strings.NewReplacer(pairs...)
+	}
+	return replacer.Replace(s)
+}
+
+// redact masks the matches of re in s, or their capture groups. Text that
+// is already a placeholder is kept, so masking is idempotent.
+func redact(re *regexp.Regexp, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		// The whole match, or every capture group that matched
+		spans := [][2]int{{m[0], m[1]}}
+		if len(m) > 2 {
+			spans = spans[:0]
+			for i := 2; i < len(m); i += 2 {
+				if m[i] >= 0 && m[i] >= last {
+					spans = append(spans, [2]int{m[i], m[i+1]})
+				}
+			}
+		}
+
+		for _, span := range spans {
+			text := s[span[0]:span[1]]
+			if span[0] < last || text == "" || strings.Contains(text, placeholderPrefix) {
+				continue
+			}
+			b.WriteString(s[last:span[0]])
+			b.WriteString(placeholder(text))
+			last = span[1]
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Reset forgets every registered secret and redaction pattern
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	secrets = map[string]string{}
+	replacer = nil
+	patterns = nil
+}
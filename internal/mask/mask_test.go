@@ -0,0 +1,92 @@
+package mask
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+
+	Add("hunter22", "hunter22-admin", "no")
+
+	input := strings.Join([]string{
+		"password: hunter22",
+		"admin: hunter22-admin",
+		"data: " + base64.StdEncoding.EncodeToString([]byte("hunter22")),
+		"enabled: no",
+	}, "\n")
+	got := String(input)
+
+	for _, secret := range []string{"hunter22", base64.StdEncoding.EncodeToString([]byte("hunter22"))} {
+		if strings.Contains(got, secret) {
+			t.Errorf("String() did not mask %q:\n%s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "admin: "+Placeholder("hunter22-admin")) {
+		t.Errorf("String() did not mask the longer secret as a whole:\n%s", got)
+	}
+	if !strings.Contains(got, "enabled: no") {
+		t.Errorf("String() masked a value shorter than the minimum length:\n%s", got)
+	}
+	if Placeholder("hunter22") == Placeholder("hunter23") {
+		t.Error("Placeholder() is the same for different secrets")
+	}
+}
+
+func TestPlaceholderKey(t *testing.T) {
+	old := key
+	t.Cleanup(func() { SetKey(old) })
+
+	SetKey([]byte("first"))
+	first, id := Placeholder("hunter22"), KeyID()
+	if Placeholder("hunter22") != first {
+		t.Error("Placeholder() changed with the same key")
+	}
+
+	// An unsalted hash of a guessed secret must not match the placeholder
+	sum := sha256.Sum256([]byte("hunter22"))
+	if first == placeholderPrefix+hex.EncodeToString(sum[:4]) {
+		t.Error("Placeholder() is the unsalted hash of the secret")
+	}
+
+	SetKey([]byte("second"))
+	if Placeholder("hunter22") == first {
+		t.Error("Placeholder() is the same for different keys")
+	}
+	if KeyID() == id {
+		t.Error("KeyID() is the same for different keys")
+	}
+}
+
+func TestSetPatterns(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+
+	if err := SetPatterns([]string{"("}); err == nil {
+		t.Error("SetPatterns() succeeded with an invalid pattern")
+	}
+
+	err := SetPatterns([]string{
+		`[a-z0-9-]+\.internal\.example\.com`,
+		`(?i)token: (\S+)`,
+	})
+	if err != nil {
+		t.Fatalf("SetPatterns() failed: %v", err)
+	}
+
+	input := "host: db.internal.example.com\nToken: abc123\nname: api\n"
+	got := String(input)
+
+	want := "host: " + Placeholder("db.internal.example.com") + "\nToken: " + Placeholder("abc123") + "\nname: api\n"
+	if got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if again := String(got); again != got {
+		t.Errorf("String() is not idempotent: %q", again)
+	}
+}
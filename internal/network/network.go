@@ -0,0 +1,417 @@
+// Package network provides a local recording proxy used to report and
+// limit the outbound connections made during a run. The proxy is installed
+// through the standard proxy environment variables so Helm, kubeconform,
+// and git subprocesses are all routed through it without extra wiring.
+package network
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// proxyEnvVars are set to point at the recorder, both casings are
+// set since git and curl only read the lowercase variables.
+var proxyEnvVars = []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"}
+
+// Call is a single outbound request or tunnelled connection
+type Call struct {
+	Method string
+	// Target is the full URL for plain HTTP and host:port for HTTPS tunnels
+	Target        string
+	Status        int
+	Duration      time.Duration
+	BytesSent     int64
+	BytesReceived int64
+	// Blocked is set when the host was not in the allow list
+	Blocked bool
+}
+
+// Recorder is a forward proxy that records every call passing through it
+type Recorder struct {
+	allowed []string
+	// offline refuses every connection
+	offline  bool
+	upstream *url.URL
+	listener net.Listener
+	server   *http.Server
+	// env holds the proxy environment from before Start, restored on Stop
+	env map[string]string
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Start launches the recording proxy on a random local port and points the
+// proxy environment variables at it. If allowedHosts is not empty, connections
+// to any other host are refused. Hosts may contain globs, e.g. '*.github.com'.
+// Must be called before any HTTP client is used, Go caches the proxy settings.
+func Start(allowedHosts []string) (*Recorder, error) {
+	r := &Recorder{allowed: allowedHosts, env: map[string]string{}}
+
+	for _, env := range append(proxyEnvVars, "NO_PROXY", "no_proxy") {
+		if v, ok := os.LookupEnv(env); ok {
+			r.env[env] = v
+		}
+	}
+
+	// Chain to an existing proxy so corporate proxies keep working
+	for _, env := range proxyEnvVars {
+		if v := r.env[env]; v != "" {
+			upstream, err := url.Parse(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", env, err)
+			}
+			r.upstream = upstream
+			break
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start network recorder: %w", err)
+	}
+	r.listener = listener
+	r.server = &http.Server{Handler: r, ReadHeaderTimeout: 30 * time.Second}
+
+	go func() {
+		if err := r.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Warning: network recorder stopped: %v", err)
+		}
+	}()
+
+	proxyURL := "http://" + listener.Addr().String()
+	for _, env := range proxyEnvVars {
+		if err := os.Setenv(env, proxyURL); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", env, err)
+		}
+	}
+	// Everything should go through the recorder
+	_ = os.Unsetenv("NO_PROXY")
+	_ = os.Unsetenv("no_proxy")
+
+	return r, nil
+}
+
+// StartOffline launches the recording proxy like Start, refusing every
+// connection so anything trying to reach the network fails right away
+func StartOffline() (*Recorder, error) {
+	r, err := Start(nil)
+	if err != nil {
+		return nil, err
+	}
+	r.offline = true
+	return r, nil
+}
+
+// Stop shuts down the proxy and restores the proxy environment
+func (r *Recorder) Stop() {
+	_ = r.server.Close()
+
+	for _, env := range append(proxyEnvVars, "NO_PROXY", "no_proxy") {
+		if v, ok := r.env[env]; ok {
+			_ = os.Setenv(env, v)
+		} else {
+			_ = os.Unsetenv(env)
+		}
+	}
+}
+
+// Calls returns the recorded calls in the order they finished
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := make([]Call, len(r.calls))
+	copy(calls, r.calls)
+	return calls
+}
+
+func (r *Recorder) record(c Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, c)
+}
+
+// isAllowed checks the host against the allow list
+func (r *Recorder) isAllowed(hostport string) bool {
+	if r.offline {
+		return false
+	}
+	if len(r.allowed) == 0 {
+		return true
+	}
+
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+
+	for _, pattern := range r.allowed {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// refusal is the error sent for a blocked request or connection
+func (r *Recorder) refusal(what, host string) string {
+	if r.offline {
+		return fmt.Sprintf("rdv: outbound %s to %s is not allowed in offline mode", what, host)
+	}
+	return fmt.Sprintf("rdv: outbound %s to %s is not allowed", what, host)
+}
+
+// ServeHTTP handles both CONNECT tunnels and plain HTTP proxy requests
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		r.handleConnect(w, req)
+		return
+	}
+	r.handleHTTP(w, req)
+}
+
+func (r *Recorder) handleHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	call := Call{Method: req.Method, Target: req.URL.String()}
+
+	if !r.isAllowed(req.URL.Host) {
+		call.Blocked = true
+		call.Status = http.StatusForbidden
+		r.record(call)
+		http.Error(w, r.refusal("request", req.URL.Host), http.StatusForbidden)
+		return
+	}
+
+	transport := &http.Transport{}
+	if r.upstream != nil {
+		transport.Proxy = http.ProxyURL(r.upstream)
+	}
+
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	sent := &countingReader{r: req.Body}
+	if req.Body != nil {
+		out.Body = sent
+	}
+
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		call.Duration = time.Since(start)
+		r.record(call)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	received, _ := io.Copy(w, resp.Body)
+
+	call.Status = resp.StatusCode
+	call.Duration = time.Since(start)
+	call.BytesSent = sent.n.Load()
+	call.BytesReceived = received
+	r.record(call)
+}
+
+func (r *Recorder) handleConnect(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	call := Call{Method: req.Method, Target: req.Host}
+
+	if !r.isAllowed(req.Host) {
+		call.Blocked = true
+		call.Status = http.StatusForbidden
+		r.record(call)
+		http.Error(w, r.refusal("connection", req.Host), http.StatusForbidden)
+		return
+	}
+
+	target, err := r.dial(req.Host)
+	if err != nil {
+		call.Duration = time.Since(start)
+		r.record(call)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		_ = target.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client, _, err := hijacker.Hijack()
+	if err != nil {
+		_ = target.Close()
+		return
+	}
+	if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		_ = target.Close()
+		_ = client.Close()
+		return
+	}
+
+	// Copy in both directions until either side closes
+	var sent, received int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		sent, _ = io.Copy(target, client)
+		_ = target.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		received, _ = io.Copy(client, target)
+		_ = client.Close()
+	}()
+	wg.Wait()
+
+	call.Status = http.StatusOK
+	call.Duration = time.Since(start)
+	call.BytesSent = sent
+	call.BytesReceived = received
+	r.record(call)
+}
+
+// dial connects to the target host, through the upstream proxy if one is set
+func (r *Recorder) dial(host string) (net.Conn, error) {
+	if r.upstream == nil {
+		return net.DialTimeout("tcp", host, 30*time.Second)
+	}
+
+	conn, err := net.DialTimeout("tcp", r.upstream.Host, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to upstream proxy %s: %w", r.upstream.Host, err)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: host},
+		Host:   host,
+		Header: http.Header{},
+	}
+	if user := r.upstream.User; user != nil {
+		pass, _ := user.Password()
+		connectReq.SetBasicAuth(user.Username(), pass)
+		connectReq.Header.Set("Proxy-Authorization", connectReq.Header.Get("Authorization"))
+		connectReq.Header.Del("Authorization")
+	}
+	if err := connectReq.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("upstream proxy refused CONNECT to %s: %s", host, resp.Status)
+	}
+
+	return conn, nil
+}
+
+// WriteReport writes a table of all recorded calls, slowest first
+func (r *Recorder) WriteReport(w io.Writer) error {
+	calls := r.Calls()
+	sort.SliceStable(calls, func(i, j int) bool {
+		return calls[i].Duration > calls[j].Duration
+	})
+
+	if _, err := fmt.Fprintf(w, "\n--- Network Report (%d calls) ---\n", len(calls)); err != nil {
+		return err
+	}
+	if len(calls) == 0 {
+		_, err := fmt.Fprintln(w, "No outbound network calls were made.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "METHOD\tTARGET\tSTATUS\tDURATION\tSENT\tRECEIVED")
+
+	var total time.Duration
+	var totalSent, totalReceived int64
+	for _, c := range calls {
+		status := fmt.Sprint(c.Status)
+		if c.Blocked {
+			status = "blocked"
+		} else if c.Status == 0 {
+			status = "failed"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Method, c.Target, status,
+			c.Duration.Round(time.Millisecond), formatBytes(c.BytesSent), formatBytes(c.BytesReceived))
+
+		total += c.Duration
+		totalSent += c.BytesSent
+		totalReceived += c.BytesReceived
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t\t\t%s\t%s\t%s\n", total.Round(time.Millisecond), formatBytes(totalSent), formatBytes(totalReceived))
+
+	return tw.Flush()
+}
+
+// Blocked returns the targets of all refused calls, sorted and without
+// duplicates
+func (r *Recorder) Blocked() []string {
+	var blocked []string
+	for _, c := range r.Calls() {
+		if c.Blocked {
+			blocked = append(blocked, c.Target)
+		}
+	}
+	sort.Strings(blocked)
+	return slices.Compact(blocked)
+}
+
+// formatBytes prints a byte count in a human readable unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	r io.ReadCloser
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	return c.r.Close()
+}
@@ -0,0 +1,104 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from the backend")
+	}))
+	defer backend.Close()
+
+	testCases := []struct {
+		name        string
+		allowed     []string
+		offline     bool
+		wantStatus  int
+		wantBlocked bool
+	}{
+		{
+			name:        "Records allowed request",
+			allowed:     nil,
+			wantStatus:  http.StatusOK,
+			wantBlocked: false,
+		},
+		{
+			name:        "Allows matching host glob",
+			allowed:     []string{"127.0.0.*"},
+			wantStatus:  http.StatusOK,
+			wantBlocked: false,
+		},
+		{
+			name:        "Blocks host not in allow list",
+			allowed:     []string{"charts.example.com"},
+			wantStatus:  http.StatusForbidden,
+			wantBlocked: true,
+		},
+		{
+			name:        "Blocks every host offline",
+			offline:     true,
+			wantStatus:  http.StatusForbidden,
+			wantBlocked: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := func() (*Recorder, error) { return Start(tc.allowed) }
+			if tc.offline {
+				start = StartOffline
+			}
+			r, err := start()
+			if err != nil {
+				t.Fatalf("Start() failed: %v", err)
+			}
+			defer r.Stop()
+
+			// http.ProxyFromEnvironment skips localhost, so point the client at the recorder directly
+			proxyURL, _ := url.Parse("http://" + r.listener.Addr().String())
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+			resp, err := client.Get(backend.URL)
+			if err != nil {
+				t.Fatalf("request through recorder failed: %v", err)
+			}
+			_, _ = io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+
+			calls := r.Calls()
+			if len(calls) != 1 {
+				t.Fatalf("recorded %d calls, want 1", len(calls))
+			}
+
+			if calls[0].Blocked != tc.wantBlocked {
+				t.Errorf("Blocked = %v, want %v", calls[0].Blocked, tc.wantBlocked)
+			}
+
+			if blocked := r.Blocked(); (len(blocked) == 1) != tc.wantBlocked {
+				t.Errorf("Blocked() = %v, want the call only when it was blocked", blocked)
+			}
+
+			if !tc.wantBlocked && calls[0].BytesReceived == 0 {
+				t.Error("BytesReceived = 0, expected the response body to be counted")
+			}
+
+			var report strings.Builder
+			if err := r.WriteReport(&report); err != nil {
+				t.Fatalf("WriteReport() failed: %v", err)
+			}
+			if !strings.Contains(report.String(), backend.URL) {
+				t.Errorf("report missing %s. Got:\n%s", backend.URL, report.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/dlactin/rdv/internal/githubapp"
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// GitHub posts the markdown report as pull request comments, split over
+// several when it exceeds GitHub's comment limit
+type GitHub struct {
+	Client *githubapp.Client
+	// Repo is the full name of the repository, e.g. 'org/repo'
+	Repo   string
+	Number int
+}
+
+// Notify adds or updates the comments of the pull request with r
+func (g *GitHub) Notify(ctx context.Context, r *report.Report) error {
+	return g.Client.UpsertComments(ctx, g.Repo, g.Number, r.MarkdownChunks(githubapp.MaxCommentLength))
+}
@@ -0,0 +1,99 @@
+// Package notify publishes the report of a run through Reporters: pull
+// request comments, chat messages and generic webhooks, so teams review
+// infrastructure changes where they already work.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// maxStatRows is the number of resources listed per section, the rest are
+// counted so messages stay readable and below the size limits of chat
+// platforms
+const maxStatRows = 30
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Reporter publishes the report of a run, e.g. as a chat message or pull
+// request comment
+type Reporter interface {
+	Notify(ctx context.Context, r *report.Report) error
+}
+
+// headline returns the one line summary of a report
+func headline(r *report.Report) string {
+	totals := r.Totals()
+	if totals.Added+totals.Removed+totals.Modified == 0 {
+		return fmt.Sprintf("Rendered manifest diff `%s` vs. `%s`: no differences", r.From, r.To)
+	}
+	return fmt.Sprintf("Rendered manifest diff `%s` vs. `%s`: %d added, %d removed, %d modified resources (+%d -%d lines)",
+		r.From, r.To, totals.Added, totals.Removed, totals.Modified, totals.LinesAdded, totals.LinesRemoved)
+}
+
+// statTable returns the changed resources of a section as an aligned
+// table like the one of --stat, without a trailing newline
+func statTable(section report.Section) string {
+	if len(section.Resources) == 0 {
+		return "No differences found between rendered manifests."
+	}
+
+	rows := section.Resources[:min(len(section.Resources), maxStatRows)]
+	width := 0
+	for _, res := range rows {
+		width = max(width, len(res.Name))
+	}
+
+	var b strings.Builder
+	for i, res := range rows {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%-*s | %-8s +%d -%d", width, res.Name, res.Status, res.Added, res.Removed)
+	}
+	if more := len(section.Resources) - len(rows); more > 0 {
+		fmt.Fprintf(&b, "\n… %d more resources", more)
+	}
+	return b.String()
+}
+
+// postJSON posts payload as JSON to endpoint. Webhook URLs carry their
+// credentials, so they are left out of errors.
+func postJSON(ctx context.Context, endpoint string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return errors.New("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
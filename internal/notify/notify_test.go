@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+const (
+	testTarget = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  key: old\n"
+	testLocal  = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  key: new\n---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: added\n"
+)
+
+func testReport(t *testing.T) *report.Report {
+	t.Helper()
+	r := &report.Report{From: "main", To: "local"}
+	if err := r.Add("<app>", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := r.Add("unchanged", testTarget, testTarget, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	return r
+}
+
+func TestSlack(t *testing.T) {
+	var got slackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := &Slack{WebhookURL: srv.URL, Channel: "#infra", Link: "https://ci.example.com/runs/1"}
+	if err := s.Notify(context.Background(), testReport(t)); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+
+	if got.Channel != "#infra" {
+		t.Errorf("Channel = %q, want #infra", got.Channel)
+	}
+	wantText := "Rendered manifest diff `main` vs. `local`: 1 added, 0 removed, 1 modified resources (+6 -2 lines) (<https://ci.example.com/runs/1|view the run>)"
+	if got.Text != wantText {
+		t.Errorf("Text = %q, want %q", got.Text, wantText)
+	}
+	if len(got.Attachments) != 2 {
+		t.Fatalf("Got %d attachments, want one per section", len(got.Attachments))
+	}
+
+	changed, unchanged := got.Attachments[0], got.Attachments[1]
+	if changed.Title != "&lt;app&gt;" || changed.Color != slackColorChanged {
+		t.Errorf("Unexpected attachment of a changed section: %+v", changed)
+	}
+	if !strings.Contains(changed.Text, "ConfigMap/config | modified +1 -2") {
+		t.Errorf("Attachment is missing the stat table:\n%s", changed.Text)
+	}
+	if unchanged.Color != slackColorUnchanged || !strings.Contains(unchanged.Text, "No differences found") {
+		t.Errorf("Unexpected attachment of an unchanged section: %+v", unchanged)
+	}
+}
+
+func TestPostErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{name: "Rejected", url: srv.URL + "/services/T000/B000/secret", wantErr: "403 Forbidden: invalid_token"},
+		{name: "Unreachable", url: "http://127.0.0.1:1/services/T000/B000/secret", wantErr: "connection refused"},
+		{name: "Invalid URL", url: "://secret", wantErr: "invalid webhook URL"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&Slack{WebhookURL: tc.url}).Notify(context.Background(), testReport(t))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("Error leaks the webhook URL: %v", err)
+			}
+		})
+	}
+}
+
+func TestStatTable(t *testing.T) {
+	var section report.Section
+	for i := range maxStatRows + 5 {
+		section.Resources = append(section.Resources, report.Resource{Name: fmt.Sprintf("ConfigMap/map-%02d", i), Status: "added", Added: 4})
+	}
+
+	table := statTable(section)
+	if got := strings.Count(table, "\n") + 1; got != maxStatRows+1 {
+		t.Errorf("Table has %d lines, want %d", got, maxStatRows+1)
+	}
+	if !strings.HasSuffix(table, "\n… 5 more resources") {
+		t.Errorf("Table doesn't count the resources left out:\n%s", table)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	testCases := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{
+			name: "Summary as JSON",
+			want: `{"headline":"Rendered manifest diff ` + "`main` vs. `local`" + `: 1 added, 0 removed, 1 modified resources (+6 -2 lines)","from":"main","to":"local","link":"https://ci.example.com/runs/1","totals":{"added":1,"removed":0,"modified":1,"linesAdded":6,"linesRemoved":2},"sections":[{"name":"\u003capp\u003e","resources":[{"name":"ConfigMap/config","kind":"ConfigMap","status":"modified","added":1,"removed":2},{"name":"Deployment/added","kind":"Deployment","status":"added","added":5,"removed":0}]},{"name":"unchanged","resources":[]}]}`,
+		},
+		{
+			name:     "Template",
+			template: writeTemplate("text.tmpl", `{"text": {{ json .Headline }}, "changed": [{{ range $i, $s := .Sections }}{{ if $i }}, {{ end }}{{ len $s.Resources }}{{ end }}]}`),
+			want:     `{"text":"Rendered manifest diff ` + "`main` vs. `local`" + `: 1 added, 0 removed, 1 modified resources (+6 -2 lines)","changed":[2,0]}`,
+		},
+		{
+			name:     "Template rendering invalid JSON",
+			template: writeTemplate("invalid.tmpl", `{"text": {{ .Headline }}}`),
+			wantErr:  "didn't render valid JSON",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = io.ReadAll(r.Body)
+			}))
+			defer srv.Close()
+
+			w := &Webhook{URL: srv.URL, Link: "https://ci.example.com/runs/1"}
+			if tc.template != "" {
+				tmpl, err := ParseTemplate(tc.template)
+				if err != nil {
+					t.Fatalf("ParseTemplate() failed: %v", err)
+				}
+				w.Template = tmpl
+			}
+
+			err := w.Notify(context.Background(), testReport(t))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Notify() failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("Posted\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTeams(t *testing.T) {
+	var got teamsMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Invalid payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	teams := &Teams{WebhookURL: srv.URL, Link: "https://ci.example.com/runs/1"}
+	if err := teams.Notify(context.Background(), testReport(t)); err != nil {
+		t.Fatalf("Notify() failed: %v", err)
+	}
+
+	if len(got.Attachments) != 1 || got.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("Expected a single Adaptive Card, got %+v", got)
+	}
+	card := got.Attachments[0].Content
+	if len(card.Actions) != 1 || card.Actions[0]["url"] != "https://ci.example.com/runs/1" {
+		t.Errorf("Expected a button opening the run, got %+v", card.Actions)
+	}
+
+	data, err := json.Marshal(card.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"text":"\u003capp\u003e"`,
+		`{"title":"ConfigMap/config","value":"modified +1 -2"}`,
+		`"text":"No differences found between rendered manifests."`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Card body is missing %s:\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// Colors of the bar next to Slack attachments
+const (
+	slackColorChanged   = "warning"
+	slackColorUnchanged = "good"
+)
+
+// Slack posts the summary of a report to a Slack incoming webhook
+type Slack struct {
+	// WebhookURL is the incoming webhook, https://hooks.slack.com/services/...
+	WebhookURL string
+	// Channel overrides the channel of the webhook when set, e.g. '#infra'
+	Channel string
+	// Link is the URL of the run or job the report is from, empty if unknown
+	Link string
+}
+
+// slackMessage is the payload of an incoming webhook
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+// slackAttachment holds the stat table of a section
+type slackAttachment struct {
+	Color    string   `json:"color"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Fallback string   `json:"fallback"`
+	MrkdwnIn []string `json:"mrkdwn_in"`
+}
+
+// Notify posts the headline of r, linked to the run, with an attachment
+// holding the stat table of each section
+func (s *Slack) Notify(ctx context.Context, r *report.Report) error {
+	if err := postJSON(ctx, s.WebhookURL, s.message(r)); err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+	return nil
+}
+
+// message returns the Slack message of a report
+func (s *Slack) message(r *report.Report) slackMessage {
+	msg := slackMessage{Channel: s.Channel, Text: slackEscape(headline(r))}
+	if s.Link != "" {
+		msg.Text += fmt.Sprintf(" (<%s|view the run>)", s.Link)
+	}
+
+	for _, section := range r.Sections {
+		color := slackColorUnchanged
+		if len(section.Resources) > 0 {
+			color = slackColorChanged
+		}
+		msg.Attachments = append(msg.Attachments, slackAttachment{
+			Color:    color,
+			Title:    slackEscape(section.Name),
+			Text:     "```\n" + slackEscape(statTable(section)) + "\n```",
+			Fallback: fmt.Sprintf("%s: %d resources changed", section.Name, len(section.Resources)),
+			MrkdwnIn: []string{"text"},
+		})
+	}
+	return msg
+}
+
+// slackEscape escapes the characters Slack reads as links and mentions
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
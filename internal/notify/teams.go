@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// Teams posts the summary of a report as an Adaptive Card to a Microsoft
+// Teams incoming webhook or workflow
+type Teams struct {
+	WebhookURL string
+	// Link is the URL of the run or job the report is from, empty if unknown
+	Link string
+}
+
+// teamsMessage is a message with a single Adaptive Card attached
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []map[string]any `json:"body"`
+	Actions []map[string]any `json:"actions,omitempty"`
+}
+
+// Notify posts a card with the headline of r, the changed resources of
+// each section as facts and a button opening the run
+func (t *Teams) Notify(ctx context.Context, r *report.Report) error {
+	if err := postJSON(ctx, t.WebhookURL, t.message(r)); err != nil {
+		return fmt.Errorf("failed to post to Teams: %w", err)
+	}
+	return nil
+}
+
+// message returns the Teams message of a report
+func (t *Teams) message(r *report.Report) teamsMessage {
+	card := teamsCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		Body: []map[string]any{
+			{"type": "TextBlock", "text": headline(r), "weight": "Bolder", "wrap": true},
+		},
+	}
+
+	for _, section := range r.Sections {
+		card.Body = append(card.Body, map[string]any{"type": "TextBlock", "text": section.Name, "weight": "Bolder", "separator": true, "wrap": true})
+		if len(section.Resources) == 0 {
+			card.Body = append(card.Body, map[string]any{"type": "TextBlock", "text": "No differences found between rendered manifests.", "wrap": true})
+			continue
+		}
+
+		var facts []map[string]string
+		for _, res := range section.Resources[:min(len(section.Resources), maxStatRows)] {
+			facts = append(facts, map[string]string{"title": res.Name, "value": fmt.Sprintf("%s +%d -%d", res.Status, res.Added, res.Removed)})
+		}
+		if more := len(section.Resources) - maxStatRows; more > 0 {
+			facts = append(facts, map[string]string{"title": "…", "value": fmt.Sprintf("%d more resources", more)})
+		}
+		card.Body = append(card.Body, map[string]any{"type": "FactSet", "facts": facts})
+	}
+
+	if t.Link != "" {
+		card.Actions = []map[string]any{{"type": "Action.OpenUrl", "title": "View the run", "url": t.Link}}
+	}
+
+	return teamsMessage{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: "application/vnd.microsoft.card.adaptive", Content: card}},
+	}
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// Summary is the JSON payload of a webhook and the data its templates are
+// executed with
+type Summary struct {
+	// Headline is the one line summary of the report, in markdown
+	Headline string           `json:"headline"`
+	From     string           `json:"from"`
+	To       string           `json:"to"`
+	Link     string           `json:"link,omitempty"`
+	Totals   report.Totals    `json:"totals"`
+	Sections []SummarySection `json:"sections"`
+}
+
+// SummarySection holds the changed resources of a target
+type SummarySection struct {
+	Name      string            `json:"name"`
+	Resources []SummaryResource `json:"resources"`
+}
+
+// SummaryResource is a changed resource, without its diff
+type SummaryResource struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+// NewSummary returns the summary of a report, link is the URL of the run
+// it's from
+func NewSummary(r *report.Report, link string) Summary {
+	s := Summary{
+		Headline: headline(r),
+		From:     r.From,
+		To:       r.To,
+		Link:     link,
+		Totals:   r.Totals(),
+		Sections: make([]SummarySection, 0, len(r.Sections)),
+	}
+	for _, section := range r.Sections {
+		resources := make([]SummaryResource, 0, len(section.Resources))
+		for _, res := range section.Resources {
+			resources = append(resources, SummaryResource{
+				Name:    res.Name,
+				Kind:    res.Kind,
+				Status:  res.Status,
+				Added:   res.Added,
+				Removed: res.Removed,
+			})
+		}
+		s.Sections = append(s.Sections, SummarySection{Name: section.Name, Resources: resources})
+	}
+	return s
+}
+
+// Webhook posts the summary of a report as JSON to a URL
+type Webhook struct {
+	URL string
+	// Link is the URL of the run or job the report is from, empty if unknown
+	Link string
+	// Template renders the body from the Summary, nil posts the Summary
+	// itself
+	Template *template.Template
+}
+
+// Notify posts the summary of r to the webhook
+func (w *Webhook) Notify(ctx context.Context, r *report.Report) error {
+	summary := NewSummary(r, w.Link)
+
+	var payload any = summary
+	if w.Template != nil {
+		var body bytes.Buffer
+		if err := w.Template.Execute(&body, summary); err != nil {
+			return fmt.Errorf("failed to render webhook template: %w", err)
+		}
+		if !json.Valid(body.Bytes()) {
+			return fmt.Errorf("webhook template %s didn't render valid JSON", w.Template.Name())
+		}
+		payload = json.RawMessage(body.Bytes())
+	}
+
+	if err := postJSON(ctx, w.URL, payload); err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	return nil
+}
+
+// ParseTemplate reads a webhook template from path. Templates are Go
+// templates executed with a Summary, the json function renders a value
+// as JSON, e.g. {"text": {{ json .Headline }}}.
+func ParseTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook template: %w", err)
+	}
+
+	tmpl, err := template.New(path).Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse webhook template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Conftest evaluates the render against the Rego policies in policyDirs
+// using the conftest CLI. Every package is evaluated, deny and violation
+// rules are reported as denials and warn rules as warnings.
+func Conftest(policyDirs []string, render string, debug bool) ([]Finding, error) {
+	if _, err := exec.LookPath("conftest"); err != nil {
+		return nil, fmt.Errorf("conftest not found in PATH, it is required for --policy-dir: %w", err)
+	}
+
+	dir, path, err := writeRender(render)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	args := []string{"test", "--all-namespaces", "--no-color", "--output", "json"}
+	for _, policyDir := range policyDirs {
+		args = append(args, "--policy", policyDir)
+	}
+	args = append(args, path)
+
+	if debug {
+		log.Printf("Running conftest %s", strings.Join(args, " "))
+	}
+
+	// conftest exits non-zero when there are failures, the JSON output
+	// tells us whether it ran at all
+	cmd := exec.Command("conftest", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, runErr := cmd.Output()
+
+	findings, err := parseConftestOutput(output)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("failed to run conftest: %w\nOutput: %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+type conftestResult struct {
+	Namespace string `json:"namespace"`
+	Warnings  []struct {
+		Msg string `json:"msg"`
+	} `json:"warnings"`
+	Failures []struct {
+		Msg string `json:"msg"`
+	} `json:"failures"`
+}
+
+// parseConftestOutput converts conftest's JSON output into findings
+func parseConftestOutput(output []byte) ([]Finding, error) {
+	var results []conftestResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse conftest output: %w\nOutput: %s", err, trimOutput(output))
+	}
+
+	var findings []Finding
+	for _, r := range results {
+		for _, f := range r.Failures {
+			findings = append(findings, Finding{Engine: "conftest", Policy: r.Namespace, Severity: SeverityDeny, Message: f.Msg})
+		}
+		for _, w := range r.Warnings {
+			findings = append(findings, Finding{Engine: "conftest", Policy: r.Namespace, Severity: SeverityWarn, Message: w.Msg})
+		}
+	}
+
+	return findings, nil
+}
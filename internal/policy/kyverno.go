@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kyverno applies the Kyverno policies at policyPaths to the render using
+// the kyverno CLI. Audit policies are reported as warnings, failures of
+// policies in Enforce mode and rule errors are reported as denials.
+func Kyverno(policyPaths []string, render string, debug bool) ([]Finding, error) {
+	if _, err := exec.LookPath("kyverno"); err != nil {
+		return nil, fmt.Errorf("kyverno not found in PATH, it is required for --kyverno-policy: %w", err)
+	}
+
+	dir, path, err := writeRender(render)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	args := append([]string{"apply"}, policyPaths...)
+	args = append(args, "--resource", path, "--policy-report", "--audit-warn")
+
+	if debug {
+		log.Printf("Running kyverno %s", strings.Join(args, " "))
+	}
+
+	// kyverno exits non-zero when a policy fails, the report tells us
+	// whether it ran at all
+	output, runErr := exec.Command("kyverno", args...).CombinedOutput()
+
+	findings, err := parseKyvernoReport(output)
+	if err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("failed to run kyverno: %w\nOutput: %s", runErr, trimOutput(output))
+		}
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+type policyReport struct {
+	Kind    string `yaml:"kind"`
+	Results []struct {
+		Policy    string `yaml:"policy"`
+		Rule      string `yaml:"rule"`
+		Result    string `yaml:"result"`
+		Message   string `yaml:"message"`
+		Resources []struct {
+			Kind      string `yaml:"kind"`
+			Namespace string `yaml:"namespace"`
+			Name      string `yaml:"name"`
+		} `yaml:"resources"`
+	} `yaml:"results"`
+}
+
+// parseKyvernoReport converts the policy report printed by 'kyverno apply
+// --policy-report' into findings. The CLI prints a banner before the
+// report, everything before the first 'apiVersion:' line is skipped.
+func parseKyvernoReport(output []byte) ([]Finding, error) {
+	text := string(output)
+	start := strings.Index(text, "apiVersion:")
+	if start == -1 {
+		return nil, fmt.Errorf("no policy report in kyverno output: %s", trimOutput(output))
+	}
+
+	var report policyReport
+	if err := yaml.Unmarshal([]byte(text[start:]), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse kyverno policy report: %w", err)
+	}
+	if !strings.HasSuffix(report.Kind, "PolicyReport") {
+		return nil, fmt.Errorf("unexpected kind %q in kyverno output", report.Kind)
+	}
+
+	var findings []Finding
+	for _, r := range report.Results {
+		var severity string
+		switch r.Result {
+		case "fail", "error":
+			severity = SeverityDeny
+		case "warn":
+			severity = SeverityWarn
+		default:
+			continue
+		}
+
+		message := r.Message
+		var id string
+		if len(r.Resources) > 0 {
+			res := r.Resources[0]
+			id = res.Kind + "/" + res.Name
+			if res.Namespace != "" {
+				id = res.Kind + "/" + res.Namespace + "/" + res.Name
+			}
+			message = id + ": " + message
+		}
+
+		findings = append(findings, Finding{
+			Engine:   "kyverno",
+			Policy:   r.Policy + "/" + r.Rule,
+			Severity: severity,
+			Message:  message,
+			Resource: id,
+		})
+	}
+
+	return findings, nil
+}
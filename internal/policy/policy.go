@@ -0,0 +1,93 @@
+// Package policy evaluates rendered manifests against organization
+// policies. Policies are run by their upstream engines, which must be
+// installed and in PATH.
+package policy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Severities of a finding. Denials fail the run, warnings are only reported.
+const (
+	SeverityDeny = "deny"
+	SeverityWarn = "warn"
+)
+
+// Finding is a single policy violation
+type Finding struct {
+	// Engine is the policy engine that reported the finding, e.g. 'conftest'
+	Engine string
+	// Policy identifies the policy, e.g. the Rego package
+	Policy   string
+	Severity string
+	Message  string
+	// Resource is the offending resource as 'Kind/namespace/name', empty
+	// when the engine doesn't report it
+	Resource string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s", f.Severity, f.Policy, f.Message)
+}
+
+// Denials returns the number of findings with deny severity
+func Denials(findings []Finding) int {
+	var n int
+	for _, f := range findings {
+		if f.Severity == SeverityDeny {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteFindings writes the findings as a report section, denials first
+func WriteFindings(w io.Writer, findings []Finding) error {
+	if _, err := fmt.Fprintln(w, "\n--- Policy Results ---"); err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No policy violations found.")
+		return err
+	}
+
+	for _, severity := range []string{SeverityDeny, SeverityWarn} {
+		for _, f := range findings {
+			if f.Severity != severity {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "  %s\n", f); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d denied, %d warnings\n", Denials(findings), len(findings)-Denials(findings))
+	return err
+}
+
+// writeRender writes the render to a temporary file for policy engines
+// that only read from files. The caller must remove the returned directory.
+func writeRender(render string) (string, string, error) {
+	dir, err := os.MkdirTemp("", "rdv-policy-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	path := filepath.Join(dir, "render.yaml")
+	if err := os.WriteFile(path, []byte(render), 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", "", fmt.Errorf("failed to write render for policy evaluation: %w", err)
+	}
+
+	return dir, path, nil
+}
+
+// trimOutput returns a short form of a command's output for error messages
+func trimOutput(output []byte) string {
+	return strings.TrimSpace(string(output))
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testConftestOutput = `[
+	{
+		"filename": "/tmp/rdv-policy-123/render.yaml",
+		"namespace": "main",
+		"successes": 4,
+		"warnings": [
+			{"msg": "Deployment api should set resource limits"}
+		],
+		"failures": [
+			{"msg": "Containers must not run as root", "metadata": {"query": "data.main.deny"}}
+		]
+	},
+	{
+		"filename": "/tmp/rdv-policy-123/render.yaml",
+		"namespace": "kubernetes.labels",
+		"successes": 5,
+		"failures": [
+			{"msg": "Service api is missing the 'team' label"}
+		]
+	}
+]`
+
+func TestParseConftestOutput(t *testing.T) {
+	findings, err := parseConftestOutput([]byte(testConftestOutput))
+	if err != nil {
+		t.Fatalf("parseConftestOutput() failed: %v", err)
+	}
+
+	want := []Finding{
+		{Engine: "conftest", Policy: "main", Severity: SeverityDeny, Message: "Containers must not run as root"},
+		{Engine: "conftest", Policy: "main", Severity: SeverityWarn, Message: "Deployment api should set resource limits"},
+		{Engine: "conftest", Policy: "kubernetes.labels", Severity: SeverityDeny, Message: "Service api is missing the 'team' label"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("parseConftestOutput() = %+v, want %+v", findings, want)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("findings[%d] = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+
+	if got := Denials(findings); got != 2 {
+		t.Errorf("Denials() = %d, want 2", got)
+	}
+
+	t.Run("Not JSON", func(t *testing.T) {
+		_, err := parseConftestOutput([]byte("Error: no policies found"))
+		if err == nil {
+			t.Error("parseConftestOutput() succeeded, but expected an error")
+		}
+	})
+}
+
+func TestWriteFindings(t *testing.T) {
+	findings := []Finding{
+		{Policy: "main", Severity: SeverityWarn, Message: "should set limits"},
+		{Policy: "main", Severity: SeverityDeny, Message: "must not run as root"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFindings(&buf, findings); err != nil {
+		t.Fatalf("WriteFindings() failed: %v", err)
+	}
+	out := buf.String()
+
+	deny := strings.Index(out, "[deny] main: must not run as root")
+	warn := strings.Index(out, "[warn] main: should set limits")
+	if deny == -1 || warn == -1 || deny > warn {
+		t.Errorf("WriteFindings() should list denials before warnings. Got:\n%s", out)
+	}
+	if !strings.Contains(out, "1 denied, 1 warnings") {
+		t.Errorf("WriteFindings() missing summary. Got:\n%s", out)
+	}
+}
+
+const testKyvernoOutput = `
+Applying 2 policy rules to 3 resources...
+----------------------------------------------------------------------
+POLICY REPORT:
+----------------------------------------------------------------------
+apiVersion: wgpolicyk8s.io/v1alpha2
+kind: ClusterPolicyReport
+metadata:
+  name: merged
+results:
+- message: validation rule 'check-team' passed.
+  policy: require-labels
+  resources:
+  - apiVersion: v1
+    kind: Service
+    name: api
+    namespace: default
+  result: pass
+  rule: check-team
+- message: 'validation error: label team is required.'
+  policy: require-labels
+  resources:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: api
+    namespace: default
+  result: fail
+  rule: check-team
+- message: 'validation error: latest tag is not allowed.'
+  policy: disallow-latest-tag
+  resources:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: api
+  result: warn
+  rule: validate-image-tag
+summary:
+  fail: 1
+  pass: 1
+  warn: 1
+`
+
+func TestParseKyvernoReport(t *testing.T) {
+	findings, err := parseKyvernoReport([]byte(testKyvernoOutput))
+	if err != nil {
+		t.Fatalf("parseKyvernoReport() failed: %v", err)
+	}
+
+	want := []Finding{
+		{Engine: "kyverno", Policy: "require-labels/check-team", Severity: SeverityDeny, Message: "Deployment/default/api: validation error: label team is required.", Resource: "Deployment/default/api"},
+		{Engine: "kyverno", Policy: "disallow-latest-tag/validate-image-tag", Severity: SeverityWarn, Message: "Deployment/api: validation error: latest tag is not allowed.", Resource: "Deployment/api"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("parseKyvernoReport() = %+v, want %+v", findings, want)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("findings[%d] = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+
+	t.Run("No report", func(t *testing.T) {
+		_, err := parseKyvernoReport([]byte("Error: failed to load policies"))
+		if err == nil {
+			t.Error("parseKyvernoReport() succeeded, but expected an error")
+		}
+	})
+}
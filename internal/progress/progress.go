@@ -0,0 +1,148 @@
+// Package progress shows a spinner on the terminal while long running
+// steps like dependency builds and checkouts run, so they don't look
+// like hangs, and logs how long each step took in debug mode.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spinnerDelay is how long a step must run before the spinner is shown,
+// fast steps would only make it flicker
+const spinnerDelay = 300 * time.Millisecond
+
+var frames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+var (
+	mu sync.Mutex
+	// out is the terminal the spinner is drawn on, nil disables it
+	out     io.Writer
+	timings bool
+	// steps are the messages of the running steps, in start order
+	steps []*step
+	// stop ends the spinner goroutine while steps are running
+	stop chan struct{}
+	// drawn is set while a spinner line is on the terminal
+	drawn bool
+)
+
+type step struct {
+	message string
+	start   time.Time
+}
+
+// Setup sets the terminal the spinner is drawn on, nil when stderr isn't
+// a terminal or is paged, and whether step durations are logged
+func Setup(w io.Writer, debug bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	out, timings = w, debug
+}
+
+// Start shows message while a step runs. The returned function ends the
+// step, calls after the first are ignored so it can also be deferred.
+// Steps may run concurrently, the spinner line lists all of them.
+func Start(format string, args ...any) func() {
+	s := &step{message: fmt.Sprintf(format, args...), start: time.Now()}
+
+	mu.Lock()
+	steps = append(steps, s)
+	if out != nil && stop == nil {
+		stop = make(chan struct{})
+		go spin(stop)
+	}
+	mu.Unlock()
+
+	var once sync.Once
+	return func() { once.Do(func() { end(s) }) }
+}
+
+// StartPhase shows message while a step runs like Start, and adds the
+// duration of the step to the total of phase like Time
+func StartPhase(phase, format string, args ...any) func() {
+	done, timed := Start(format, args...), Time(phase)
+	return func() {
+		done()
+		timed()
+	}
+}
+
+// end removes a finished step and logs its duration in debug mode
+func end(s *step) {
+	mu.Lock()
+	for i, running := range steps {
+		if running == s {
+			steps = append(steps[:i], steps[i+1:]...)
+			break
+		}
+	}
+	if len(steps) == 0 && stop != nil {
+		close(stop)
+		stop = nil
+	}
+	clearLine()
+	logTiming := timings
+	mu.Unlock()
+
+	if logTiming {
+		log.Printf("%s took %s", s.message, time.Since(s.start).Round(time.Millisecond))
+	}
+}
+
+// spin redraws the spinner line until stop is closed
+func spin(stop chan struct{}) {
+	timer := time.NewTimer(spinnerDelay)
+	defer timer.Stop()
+
+	for frame := 0; ; frame++ {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+
+		mu.Lock()
+		if out != nil && len(steps) > 0 {
+			messages := make([]string, len(steps))
+			for i, s := range steps {
+				messages[i] = s.message
+			}
+			fmt.Fprintf(out, "\r\033[K%s %s...", frames[frame%len(frames)], strings.Join(messages, ", "))
+			drawn = true
+		}
+		mu.Unlock()
+
+		timer.Reset(100 * time.Millisecond)
+	}
+}
+
+// clearLine removes the spinner line, the caller must hold mu
+func clearLine() {
+	if drawn && out != nil {
+		fmt.Fprint(out, "\r\033[K")
+		drawn = false
+	}
+}
+
+// Writer wraps the logger's output so log messages clear the spinner line
+// first instead of being appended to it. The spinner is drawn again
+// below them.
+func Writer(w io.Writer) io.Writer {
+	return &lineWriter{w: w}
+}
+
+type lineWriter struct {
+	w io.Writer
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	clearLine()
+	return l.w.Write(p)
+}
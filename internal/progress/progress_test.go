@@ -0,0 +1,49 @@
+package progress
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStart(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	defer Setup(nil, false)
+
+	t.Run("Timings in debug mode", func(t *testing.T) {
+		logs.Reset()
+		Setup(nil, true)
+
+		done := Start("Checking out %s", "main")
+		done()
+		done()
+
+		if got := strings.Count(logs.String(), "Checking out main took"); got != 1 {
+			t.Errorf("Expected one timing log line, got %d:\n%s", got, logs.String())
+		}
+	})
+
+	t.Run("Spinner on slow steps", func(t *testing.T) {
+		var terminal bytes.Buffer
+		Setup(&terminal, false)
+
+		fast := Start("Fast step")
+		fast()
+		slow := Start("Slow step")
+		time.Sleep(spinnerDelay + 50*time.Millisecond)
+		slow()
+
+		out := terminal.String()
+		if strings.Contains(out, "Fast step") {
+			t.Errorf("Expected no spinner for a fast step, got: %q", out)
+		}
+		if !strings.Contains(out, "Slow step...") || !strings.HasSuffix(out, "\r\033[K") {
+			t.Errorf("Expected a cleared spinner line for a slow step, got: %q", out)
+		}
+	})
+}
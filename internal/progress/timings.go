@@ -0,0 +1,121 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Phases of a run timed with Time. The renders include the dependency
+// builds of their charts.
+const (
+	PhaseWorktree     = "worktree setup"
+	PhaseDependencies = "dependency build"
+	PhaseLocalRender  = "local render"
+	PhaseTargetRender = "target render"
+	PhaseDiff         = "diff"
+	PhaseValidation   = "validation"
+)
+
+// Timing is the time spent in a phase of the run. Phases timed
+// concurrently, like the renders of several paths, add up their durations,
+// so the total of a phase can exceed the duration of the run.
+type Timing struct {
+	Phase    string        `json:"phase"`
+	Count    int           `json:"count"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+var (
+	timingsMu sync.Mutex
+	// phases are the totals of the timed phases, in the order they were
+	// first timed
+	phases   []*Timing
+	runStart = time.Now()
+)
+
+// Time starts timing a phase, the returned function adds the time since
+// to the phase's total. Calls after the first are ignored so it can also
+// be deferred.
+func Time(phase string) func() {
+	start := time.Now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			elapsed := time.Since(start)
+
+			timingsMu.Lock()
+			defer timingsMu.Unlock()
+			for _, t := range phases {
+				if t.Phase == phase {
+					t.Count++
+					t.Duration += elapsed
+					return
+				}
+			}
+			phases = append(phases, &Timing{Phase: phase, Count: 1, Duration: elapsed})
+		})
+	}
+}
+
+// ResetTimings clears the timed phases and restarts the run clock, for
+// every run of a watch or the daemon
+func ResetTimings() {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+	phases = nil
+	runStart = time.Now()
+}
+
+// Timings returns the totals of the timed phases, in the order they were
+// first timed, and the time since the run started
+func Timings() ([]Timing, time.Duration) {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+
+	timings := make([]Timing, len(phases))
+	for i, t := range phases {
+		timings[i] = *t
+		timings[i].Seconds = t.Duration.Seconds()
+	}
+	return timings, time.Since(runStart)
+}
+
+// WriteTimings prints a table of the timed phases and the duration of
+// the run
+func WriteTimings(w io.Writer) error {
+	timings, total := Timings()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PHASE\tCOUNT\tDURATION")
+	for _, t := range timings {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", t.Phase, t.Count, t.Duration.Round(time.Millisecond))
+	}
+	_, _ = fmt.Fprintf(tw, "total\t\t%s\n", total.Round(time.Millisecond))
+	return tw.Flush()
+}
+
+// WriteTimingsFile writes the timed phases and the duration of the run to
+// path as JSON
+func WriteTimingsFile(path string) error {
+	timings, total := Timings()
+
+	content, err := json.MarshalIndent(struct {
+		Seconds float64  `json:"seconds"`
+		Phases  []Timing `json:"phases"`
+	}{total.Seconds(), timings}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode timings: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write timing report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTimings(t *testing.T) {
+	ResetTimings()
+	defer ResetTimings()
+
+	render := Time(PhaseLocalRender)
+	render()
+	render()
+	Time(PhaseLocalRender)()
+	Time(PhaseDiff)()
+	StartPhase(PhaseWorktree, "Checking out %s", "main")()
+
+	timings, _ := Timings()
+	tests := []struct {
+		phase string
+		count int
+	}{
+		{PhaseLocalRender, 2},
+		{PhaseDiff, 1},
+		{PhaseWorktree, 1},
+	}
+	if len(timings) != len(tests) {
+		t.Fatalf("Expected %d phases, got %+v", len(tests), timings)
+	}
+	for i, tt := range tests {
+		if timings[i].Phase != tt.phase || timings[i].Count != tt.count {
+			t.Errorf("Expected phase %d to be %s timed %d times, got %+v", i, tt.phase, tt.count, timings[i])
+		}
+	}
+
+	var table bytes.Buffer
+	if err := WriteTimings(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"PHASE", "local render", "worktree setup", "total"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("Expected %q in the table:\n%s", want, table.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "timings.json")
+	if err := WriteTimingsFile(path); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Seconds float64  `json:"seconds"`
+		Phases  []Timing `json:"phases"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Invalid timing report: %v\n%s", err, content)
+	}
+	if len(report.Phases) != len(tests) || report.Phases[0].Phase != PhaseLocalRender {
+		t.Errorf("Unexpected timing report:\n%s", content)
+	}
+
+	ResetTimings()
+	if timings, _ := Timings(); len(timings) != 0 {
+		t.Errorf("Expected no phases after a reset, got %+v", timings)
+	}
+}
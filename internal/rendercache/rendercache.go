@@ -0,0 +1,136 @@
+// Package rendercache stores renders on disk in the user cache directory,
+// so repeated runs against the same target ref can skip rendering it.
+package rendercache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dlactin/rdv/internal/mask"
+)
+
+// maxAge is how long an unused render is kept
+const maxAge = 7 * 24 * time.Hour
+
+// maskKeyFile holds the key the cached renders were masked with
+const maskKeyFile = "mask.key"
+
+// Cache is a directory of renders keyed by an opaque string
+type Cache struct {
+	dir string
+}
+
+// New returns the cache in the user cache directory
+func New() (*Cache, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user cache directory: %w", err)
+	}
+	return &Cache{dir: filepath.Join(cacheDir, "rdv", "renders")}, nil
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".yaml")
+}
+
+// Get returns the render stored for key
+func (c *Cache) Get(key string) (string, bool) {
+	path := c.path(key)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+
+	// The modification time tracks when a render was last used
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return string(data), true
+}
+
+// Put stores the render for key and removes renders that haven't been
+// used for a week
+func (c *Cache) Put(key, render string) error {
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create render cache directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial render
+	tmp, err := os.CreateTemp(c.dir, "render-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write render cache: %w", err)
+	}
+	_, err = tmp.WriteString(render)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write render cache: %w", err)
+	}
+
+	c.prune(time.Now().Add(-maxAge))
+	return nil
+}
+
+// MaskKey returns the key the cached renders are masked with, creating a
+// random one on first use. Placeholders of secrets in cached renders
+// only match the local render's when both use the same key.
+func (c *Cache) MaskKey() ([]byte, error) {
+	path := filepath.Join(c.dir, maskKeyFile)
+
+	k, err := os.ReadFile(path)
+	if err == nil {
+		return k, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read render cache key: %w", err)
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create render cache directory: %w", err)
+	}
+	k = mask.NewKey()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// Another run created it first
+		return c.MaskKey()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write render cache key: %w", err)
+	}
+	_, err = f.Write(k)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("failed to write render cache key: %w", err)
+	}
+	return k, nil
+}
+
+// prune removes renders last used before cutoff
+func (c *Cache) prune(cutoff time.Time) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) || entry.Name() == maskKeyFile {
+			continue
+		}
+		_ = os.Remove(filepath.Join(c.dir, entry.Name()))
+	}
+}
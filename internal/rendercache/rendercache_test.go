@@ -0,0 +1,68 @@
+package rendercache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	c := &Cache{dir: t.TempDir()}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get() found a render that was never stored")
+	}
+
+	if err := c.Put("key", "kind: ConfigMap\n"); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	got, ok := c.Get("key")
+	if !ok || got != "kind: ConfigMap\n" {
+		t.Errorf("Get() = %q, %v, want the stored render", got, ok)
+	}
+
+	// Renders unused for longer than the cutoff are removed
+	old := time.Now().Add(-2 * maxAge)
+	if err := os.Chtimes(c.path("key"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	c.prune(time.Now().Add(-maxAge))
+
+	if _, ok := c.Get("key"); ok {
+		t.Error("prune() did not remove an old render")
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	c := &Cache{dir: filepath.Join(t.TempDir(), "renders")}
+
+	first, err := c.MaskKey()
+	if err != nil {
+		t.Fatalf("MaskKey() failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(c.dir, maskKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key to only be readable by the user, got %v", info.Mode().Perm())
+	}
+
+	// The key is kept however long it's unused
+	old := time.Now().Add(-2 * maxAge)
+	if err := os.Chtimes(filepath.Join(c.dir, maskKeyFile), old, old); err != nil {
+		t.Fatal(err)
+	}
+	c.prune(time.Now().Add(-maxAge))
+
+	second, err := c.MaskKey()
+	if err != nil {
+		t.Fatalf("MaskKey() failed: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("MaskKey() returned a new key for the same cache")
+	}
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// htmlLine is a diff line split up for highlighting
+type htmlLine struct {
+	// Class is the CSS class of the line: add, del, hunk, header or ctx
+	Class string
+	// Prefix is the diff marker and indentation
+	Prefix string
+	// Key is the YAML key of the line, highlighted separately from Rest
+	Key  string
+	Rest string
+}
+
+// htmlYAMLKey matches the key of a YAML mapping entry, after the diff marker
+var htmlYAMLKey = regexp.MustCompile(`^(\s*(?:- )?)([^\s:#][^:]*:)(\s.*|$)`)
+
+// htmlLines splits a unified diff into lines for the template
+func htmlLines(unified string) []htmlLine {
+	var lines []htmlLine
+
+	for _, line := range strings.Split(strings.TrimSuffix(unified, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			lines = append(lines, htmlLine{Class: "header", Rest: line})
+			continue
+		case strings.HasPrefix(line, "@@"):
+			lines = append(lines, htmlLine{Class: "hunk", Rest: line})
+			continue
+		case strings.HasPrefix(line, "\\"):
+			lines = append(lines, htmlLine{Class: "ctx", Rest: line})
+			continue
+		}
+
+		l := htmlLine{Class: "ctx"}
+		if line != "" {
+			l.Prefix, line = line[:1], line[1:]
+			switch l.Prefix {
+			case "+":
+				l.Class = "add"
+			case "-":
+				l.Class = "del"
+			}
+		}
+
+		if match := htmlYAMLKey.FindStringSubmatch(line); match != nil {
+			l.Prefix += match[1]
+			l.Key, l.Rest = match[2], match[3]
+		} else {
+			l.Rest = line
+		}
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lines": htmlLines,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>rdv diff: {{ .From }} vs. {{ .To }}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.15em; margin-top: 2em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; }
+table.summary { border-collapse: collapse; margin: 1em 0; }
+table.summary td, table.summary th { border: 1px solid #d0d7de; padding: .3em .8em; text-align: left; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: .5em 0; }
+summary { cursor: pointer; padding: .5em .8em; background: #f6f8fa; font-family: ui-monospace, Menlo, monospace; }
+.status { display: inline-block; min-width: 5.5em; font-weight: 600; }
+.status.added, .lines .a { color: #1a7f37; }
+.status.removed, .lines .r { color: #cf222e; }
+.status.modified { color: #9a6700; }
+pre { margin: 0; padding: .5em 0; overflow-x: auto; font-family: ui-monospace, Menlo, monospace; font-size: 12px; }
+pre span.line { display: block; padding: 0 .8em; white-space: pre; }
+.add { background: #e6ffec; }
+.del { background: #ffebe9; }
+.hunk { background: #ddf4ff; color: #57606a; }
+.header { color: #57606a; font-weight: 600; }
+.key { color: #0550ae; }
+</style>
+</head>
+<body>
+<h1>Rendered manifest diff: {{ .From }} vs. {{ .To }}</h1>
+{{- with .Summary }}
+<table class="summary">
+<tr><th>Added</th><th>Removed</th><th>Modified</th><th>Lines added</th><th>Lines removed</th></tr>
+<tr><td>{{ .Added }}</td><td>{{ .Removed }}</td><td>{{ .Modified }}</td><td>{{ .LinesAdded }}</td><td>{{ .LinesRemoved }}</td></tr>
+</table>
+{{- end }}
+{{- range .Sections }}
+<h2>{{ .Name }}</h2>
+{{- if not .Resources }}
+<p>No differences found between rendered manifests.</p>
+{{- end }}
+{{- range .Resources }}
+<details{{ if eq .Status "modified" }} open{{ end }}>
+<summary><span class="status {{ .Status }}">{{ .Status }}</span> {{ .Name }} <span class="lines"><span class="a">+{{ .Added }}</span> <span class="r">-{{ .Removed }}</span></span></summary>
+<pre>{{ range lines .Diff }}<span class="line {{ .Class }}">{{ .Prefix }}{{ if .Key }}<span class="key">{{ .Key }}</span>{{ end }}{{ .Rest }}</span>{{ end }}</pre>
+</details>
+{{- end }}
+{{- end }}
+</body>
+</html>
+`))
+
+// WriteHTML writes the report as a self-contained HTML page with a
+// collapsible diff per resource
+func (r *Report) WriteHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, struct {
+		*Report
+		Summary Totals
+	}{r, r.Totals()})
+}
+
+// WriteHTMLFile writes the report as HTML to path
+func (r *Report) WriteHTMLFile(path string) error {
+	return writeFile(path, "HTML", func(f *os.File) error {
+		return r.WriteHTML(f)
+	})
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Each target is a test suite
+// and each resource a test case: resources failing validation are
+// failures, changed resources pass with their diff as output and
+// unchanged resources are skipped.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: fmt.Sprintf("rdv %s vs. %s", r.From, r.To)}
+
+	for _, section := range r.Sections {
+		suite := junitTestSuite{Name: section.Name}
+
+		add := func(tc junitTestCase) {
+			tc.Classname = section.Name
+			if msg, ok := section.Invalid[tc.Name]; ok {
+				tc.Failure = &junitMessage{Message: "resource failed validation", Body: msg}
+				tc.Skipped = nil
+				suite.Failures++
+			} else if tc.Skipped != nil {
+				suite.Skipped++
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+
+		for _, res := range section.Resources {
+			add(junitTestCase{Name: res.Name, SystemOut: res.Diff})
+		}
+		for _, name := range section.Unchanged {
+			add(junitTestCase{Name: name, Skipped: &junitMessage{Message: "unchanged"}})
+		}
+
+		sort.SliceStable(suite.TestCases, func(i, j int) bool {
+			return suite.TestCases[i].Name < suite.TestCases[j].Name
+		})
+		suite.Tests = len(suite.TestCases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteJUnitFile writes the report as JUnit XML to path
+func (r *Report) WriteJUnitFile(path string) error {
+	return writeFile(path, "JUnit", func(f *os.File) error {
+		return r.WriteJUnit(f)
+	})
+}
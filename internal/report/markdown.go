@@ -0,0 +1,156 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// partRoom is the room left in every chunk for the ' (part i/n)' suffix
+// of its title
+const partRoom = len(" (part 999/999)")
+
+// truncatedNote ends the diff of a resource too large for a chunk of its own
+const truncatedNote = "# Diff truncated, it exceeds the comment size limit\n"
+
+// WriteMarkdown writes the report as GitHub flavored markdown, with a
+// collapsed section holding the diff of each changed resource, so large
+// reports stay navigable in pull request comments. Unchanged resources
+// are left out.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	_, err := io.WriteString(w, r.MarkdownChunks(0)[0])
+	return err
+}
+
+// MarkdownChunks returns the markdown report split into chunks of at most
+// limit bytes, so each fits in a pull request comment. Chunks are split
+// between resources, each starts with the report title numbered
+// '(part i/n)' and repeats the heading of the section it continues. The
+// diff of a resource too large for a chunk of its own is cut. A limit of
+// 0 returns the whole report as a single chunk.
+func (r *Report) MarkdownChunks(limit int) []string {
+	title := fmt.Sprintf("### Rendered manifest diff: `%s` vs. `%s`", r.From, r.To)
+	room := limit - len(title) - partRoom - 1
+
+	var chunks []string
+	var b strings.Builder
+	totals := r.Totals()
+	fmt.Fprintf(&b, "\n%d added, %d removed, %d modified resources (+%d -%d lines)\n",
+		totals.Added, totals.Removed, totals.Modified, totals.LinesAdded, totals.LinesRemoved)
+
+	// add writes block to the current chunk, or starts the next one with
+	// continued when it doesn't fit
+	add := func(block, continued string) {
+		if limit > 0 && b.Len() > 0 && b.Len()+len(block) > room {
+			chunks = append(chunks, b.String())
+			b.Reset()
+			block = continued + block
+		}
+		b.WriteString(block)
+	}
+
+	for _, section := range r.Sections {
+		heading := fmt.Sprintf("\n#### %s\n\n", section.Name)
+		if len(section.Resources) == 0 {
+			add(heading+"No differences found between rendered manifests.\n", "")
+			continue
+		}
+
+		continued := fmt.Sprintf("\n#### %s (continued)\n\n", section.Name)
+		resourceLimit := 0
+		if limit > 0 {
+			resourceLimit = max(room-len(continued), 1)
+		}
+		for i, res := range section.Resources {
+			block := resourceMarkdown(res, resourceLimit)
+			if i == 0 {
+				add(heading+block, "")
+			} else {
+				add(block, continued)
+			}
+		}
+	}
+	chunks = append(chunks, b.String())
+
+	if len(chunks) == 1 {
+		return []string{title + "\n" + chunks[0]}
+	}
+	for i := range chunks {
+		chunks[i] = fmt.Sprintf("%s (part %d/%d)\n%s", title, i+1, len(chunks), chunks[i])
+	}
+	return chunks
+}
+
+// resourceMarkdown returns the collapsed section of a resource. A diff
+// that doesn't fit in limit bytes is cut after its last line that does,
+// a limit of 0 keeps it whole.
+func resourceMarkdown(res Resource, limit int) string {
+	// The blank lines let GitHub render the code block inside the HTML
+	open := fmt.Sprintf("<details><summary><code>%s</code> %s (+%d/-%d)</summary>\n\n```diff\n", html.EscapeString(res.Name), res.Status, res.Added, res.Removed)
+	const closing = "```\n\n</details>\n"
+
+	diff := res.Diff
+	if limit > 0 && len(open)+len(diff)+len(closing) > limit {
+		n := min(max(limit-len(open)-len(closing)-len(truncatedNote), 0), len(diff))
+		diff = diff[:strings.LastIndexByte(diff[:n], '\n')+1] + truncatedNote
+	}
+	return open + diff + closing
+}
+
+// WriteMarkdownFiles writes the markdown report to path, split into
+// chunks of at most limit bytes when it's larger. The chunks are written
+// to numbered files next to path, report.1.md, report.2.md and so on,
+// and chunk files of a previous run are removed. It returns the paths
+// written.
+func (r *Report) WriteMarkdownFiles(path string, limit int) ([]string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	dir, name := filepath.Split(base)
+	if dir == "" {
+		dir = "."
+	}
+	chunkFile := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `\.[0-9]+` + regexp.QuoteMeta(ext) + `$`)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read markdown report directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !chunkFile.MatchString(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return nil, fmt.Errorf("failed to remove previous markdown chunk: %w", err)
+		}
+	}
+
+	chunks := r.MarkdownChunks(limit)
+	paths := []string{path}
+	if len(chunks) > 1 {
+		// A report from a previous run would pass for the whole report
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove previous markdown report: %w", err)
+		}
+		paths = make([]string, len(chunks))
+		for i := range chunks {
+			paths[i] = fmt.Sprintf("%s.%d%s", base, i+1, ext)
+		}
+	}
+
+	for i, chunk := range chunks {
+		err := writeFile(paths[i], "markdown", func(f *os.File) error {
+			_, err := io.WriteString(f, chunk)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
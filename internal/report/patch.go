@@ -0,0 +1,50 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PatchFileName returns the patch file name of a resource, its kind,
+// namespace and name joined by underscores, e.g.
+// Deployment_default_api.patch. Cluster scoped resources have no
+// namespace part.
+func PatchFileName(res Resource) string {
+	return strings.ReplaceAll(res.Name, "/", "_") + ".patch"
+}
+
+// WritePatchDir writes the diff of every changed resource to its own patch
+// file in dir, named by PatchFileName. With more than one section each
+// section gets its own subdirectory. Patch files left in the directories
+// by a previous run are removed, so every file is a change of this run.
+func (r *Report) WritePatchDir(dir string) error {
+	for _, section := range r.Sections {
+		sectionDir := dir
+		if len(r.Sections) > 1 {
+			sectionDir = filepath.Join(dir, section.Name)
+		}
+		if err := os.MkdirAll(sectionDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create patch directory: %w", err)
+		}
+
+		stale, err := filepath.Glob(filepath.Join(sectionDir, "*.patch"))
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove old patch file: %w", err)
+			}
+		}
+
+		for _, res := range section.Resources {
+			path := filepath.Join(sectionDir, PatchFileName(res))
+			if err := os.WriteFile(path, []byte(res.Diff), 0o644); err != nil {
+				return fmt.Errorf("failed to write patch file: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Package report collects the per resource diffs of a run so they can be
+// published outside of the terminal, e.g. as CI artifacts.
+package report
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/validate"
+)
+
+// Report holds the diffs of every target of a run
+type Report struct {
+	// From and To name the target ref and local side
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Sections []Section `json:"sections"`
+}
+
+// Section holds the changed resources of a single target
+type Section struct {
+	Name      string     `json:"name"`
+	Resources []Resource `json:"resources"`
+	// Unchanged are the names of the resources without changes
+	Unchanged []string `json:"unchanged"`
+	// Invalid maps the names of resources that failed validation to the
+	// validation error, resources that weren't validated are left out
+	Invalid map[string]string `json:"invalid,omitempty"`
+}
+
+// Resource is a resource that was added, removed or modified
+type Resource struct {
+	// Name identifies the resource, e.g. 'Deployment/default/api'
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Status is one of the manifest.Status constants
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	// Diff is the unified diff of the resource
+	Diff string `json:"diff"`
+}
+
+// Totals sums the changes of every section
+type Totals struct {
+	Added        int `json:"added"`
+	Removed      int `json:"removed"`
+	Modified     int `json:"modified"`
+	LinesAdded   int `json:"linesAdded"`
+	LinesRemoved int `json:"linesRemoved"`
+}
+
+// Add compares two renders of a target and adds a section with the
+// resources that changed between them. results are the validation
+// results of the local render, nil if it wasn't validated.
+func (r *Report) Add(name, targetRender, localRender string, results []validate.Result) error {
+	targetResources, err := manifest.Parse(targetRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse target render for %s: %w", name, err)
+	}
+
+	localResources, err := manifest.Parse(localRender)
+	if err != nil {
+		return fmt.Errorf("failed to parse local render for %s: %w", name, err)
+	}
+
+	targetBodies := make(map[string]string, len(targetResources))
+	for _, res := range targetResources {
+		targetBodies[res.Key()] = res.Body
+	}
+	localBodies := make(map[string]string, len(localResources))
+	for _, res := range localResources {
+		localBodies[res.Key()] = res.Body
+	}
+
+	section := Section{Name: name, Invalid: map[string]string{}}
+	for _, res := range localResources {
+		if body, ok := targetBodies[res.Key()]; ok && body == res.Body {
+			section.Unchanged = append(section.Unchanged, res.String())
+		}
+	}
+	for _, result := range results {
+		if result.Status == validate.StatusInvalid || result.Status == validate.StatusError {
+			section.Invalid[resultName(result)] = result.Error
+		}
+	}
+
+	for _, s := range manifest.Stat(targetResources, localResources) {
+		key := s.Resource.Key()
+		section.Resources = append(section.Resources, Resource{
+			Name:    s.Resource.String(),
+			Kind:    s.Resource.Kind,
+			Status:  s.Status,
+			Added:   s.Added,
+			Removed: s.Removed,
+			Diff: diff.CreateDiff(targetBodies[key], localBodies[key],
+				fmt.Sprintf("%s/%s", r.From, s.Resource), fmt.Sprintf("%s/%s", r.To, s.Resource)),
+		})
+	}
+
+	r.Sections = append(r.Sections, section)
+	return nil
+}
+
+// resultName returns the name of a validated resource in the format
+// of manifest.Resource.String
+func resultName(r validate.Result) string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// Totals returns the number of resources and lines changed across sections
+func (r *Report) Totals() Totals {
+	var t Totals
+	for _, section := range r.Sections {
+		for _, res := range section.Resources {
+			switch res.Status {
+			case manifest.StatusAdded:
+				t.Added++
+			case manifest.StatusRemoved:
+				t.Removed++
+			case manifest.StatusModified:
+				t.Modified++
+			}
+			t.LinesAdded += res.Added
+			t.LinesRemoved += res.Removed
+		}
+	}
+	return t
+}
+
+// writeFile creates path and writes the report to it with write
+func writeFile(path, format string, write func(f *os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s report: %w", format, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := write(f); err != nil {
+		return fmt.Errorf("failed to write %s report to %s: %w", format, path, err)
+	}
+
+	return f.Close()
+}
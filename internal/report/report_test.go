@@ -0,0 +1,329 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/validate"
+)
+
+const (
+	testTarget = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: old
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: removed
+`
+	testLocal = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: new
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: added
+`
+)
+
+func TestReport(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	got := r.Totals()
+	want := Totals{Added: 1, Removed: 1, Modified: 1, LinesAdded: 6, LinesRemoved: 6}
+	if got != want {
+		t.Errorf("Totals() = %+v, want %+v", got, want)
+	}
+
+	resources := r.Sections[0].Resources
+	if len(resources) != 3 {
+		t.Fatalf("Add() found %d changed resources, want 3", len(resources))
+	}
+	if !strings.Contains(resources[0].Diff, "--- main/ConfigMap/config\n+++ local/ConfigMap/config\n") {
+		t.Errorf("Diff of %s is missing the resource headers:\n%s", resources[0].Name, resources[0].Diff)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("<app>", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteHTML(&buf); err != nil {
+		t.Fatalf("WriteHTML() failed: %v", err)
+	}
+	html := buf.String()
+
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "Section names are escaped", want: "<h2>&lt;app&gt;</h2>"},
+		{name: "Modified resources are expanded", want: `<details open>`},
+		{name: "Added lines are highlighted", want: `<span class="line add">&#43;  <span class="key">key:</span> new</span>`},
+		{name: "Summary header", want: "<td>1</td><td>1</td><td>1</td>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(html, tc.want) {
+				t.Errorf("WriteHTML() output is missing %q:\n%s", tc.want, html)
+			}
+		})
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	target := testTarget + "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: same\n"
+	local := testLocal + "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: same\n"
+	results := []validate.Result{
+		{Kind: "Deployment", Name: "added", Status: validate.StatusInvalid, Error: "missing spec"},
+		{Kind: "ConfigMap", Name: "config", Status: validate.StatusValid},
+	}
+
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", target, local, results); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatalf("WriteJUnit() failed: %v", err)
+	}
+	junit := buf.String()
+
+	testCases := []struct {
+		name string
+		want string
+	}{
+		{name: "Suite totals", want: `<testsuite name="app" tests="4" failures="1" skipped="1">`},
+		{name: "Invalid resource fails", want: `<testcase name="Deployment/added" classname="app">
+      <failure message="resource failed validation">missing spec</failure>`},
+		{name: "Unchanged resource is skipped", want: `<testcase name="Secret/same" classname="app">
+      <skipped message="unchanged"></skipped>`},
+		{name: "Changed resource has its diff as output", want: `<system-out>--- main/ConfigMap/config`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !strings.Contains(junit, tc.want) {
+				t.Errorf("WriteJUnit() output is missing %q:\n%s", tc.want, junit)
+			}
+		})
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	unchanged := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: same\n---\n"
+	if err := r.Add("app", unchanged+testTarget, unchanged+testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := r.Add("unchanged", testTarget, testTarget, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteMarkdown(&buf); err != nil {
+		t.Fatalf("WriteMarkdown() failed: %v", err)
+	}
+	markdown := buf.String()
+
+	for _, want := range []string{
+		"### Rendered manifest diff: `main` vs. `local`\n\n1 added, 1 removed, 1 modified resources (+6 -6 lines)\n",
+		"<details><summary><code>ConfigMap/config</code> modified (+1/-1)</summary>\n\n```diff\n--- main/ConfigMap/config\n",
+		"```\n\n</details>\n",
+		"#### unchanged\n\nNo differences found between rendered manifests.\n",
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("WriteMarkdown() output is missing %q:\n%s", want, markdown)
+		}
+	}
+	if got := strings.Count(markdown, "<details>"); got != 3 {
+		t.Errorf("WriteMarkdown() output has %d collapsed resources, want 3:\n%s", got, markdown)
+	}
+	if strings.Contains(markdown, "Secret/same") {
+		t.Errorf("WriteMarkdown() output includes an unchanged resource:\n%s", markdown)
+	}
+}
+
+func TestMarkdownChunks(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	large := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: large\ndata:\n" + strings.Repeat("  key: value\n", 100)
+	if err := r.Add("large", "", large, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	t.Run("No limit", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := r.WriteMarkdown(&buf); err != nil {
+			t.Fatal(err)
+		}
+		chunks := r.MarkdownChunks(0)
+		if len(chunks) != 1 || chunks[0] != buf.String() {
+			t.Errorf("MarkdownChunks(0) = %q, want the whole report", chunks)
+		}
+	})
+
+	t.Run("Split on resources", func(t *testing.T) {
+		const limit = 700
+		chunks := r.MarkdownChunks(limit)
+		if len(chunks) < 3 {
+			t.Fatalf("MarkdownChunks() returned %d chunks, want at least 3", len(chunks))
+		}
+
+		details := 0
+		for i, chunk := range chunks {
+			if len(chunk) > limit {
+				t.Errorf("Chunk %d has %d bytes, want at most %d", i+1, len(chunk), limit)
+			}
+			title := fmt.Sprintf("### Rendered manifest diff: `main` vs. `local` (part %d/%d)\n", i+1, len(chunks))
+			if !strings.HasPrefix(chunk, title) {
+				t.Errorf("Chunk %d doesn't start with %q:\n%s", i+1, title, chunk)
+			}
+			if strings.Count(chunk, "<details>") != strings.Count(chunk, "</details>") || strings.Count(chunk, "```")%2 != 0 {
+				t.Errorf("Chunk %d cuts a resource:\n%s", i+1, chunk)
+			}
+			details += strings.Count(chunk, "<details>")
+		}
+		if details != 4 {
+			t.Errorf("Chunks hold %d resources, want 4", details)
+		}
+		if !strings.Contains(chunks[1], "#### app (continued)\n") {
+			t.Errorf("Second chunk doesn't repeat the section heading:\n%s", chunks[1])
+		}
+		if last := chunks[len(chunks)-1]; !strings.Contains(last, "<code>ConfigMap/large</code>") || !strings.Contains(last, truncatedNote) {
+			t.Errorf("Resource larger than a chunk isn't cut:\n%s", last)
+		}
+	})
+}
+
+func TestWriteMarkdownFiles(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.md")
+	// Left over from a previous run
+	for _, name := range []string{"report.7.md", "report.notes.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := r.WriteMarkdownFiles(path, 700)
+	if err != nil {
+		t.Fatalf("WriteMarkdownFiles() failed: %v", err)
+	}
+	if len(paths) < 2 || paths[0] != filepath.Join(dir, "report.1.md") {
+		t.Errorf("WriteMarkdownFiles() wrote %v, want numbered chunks", paths)
+	}
+
+	paths, err = r.WriteMarkdownFiles(path, 0)
+	if err != nil {
+		t.Fatalf("WriteMarkdownFiles() failed: %v", err)
+	}
+	if !slices.Equal(paths, []string{path}) {
+		t.Errorf("WriteMarkdownFiles() wrote %v, want %s", paths, path)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"report.md", "report.notes.md"}; !slices.Equal(names, want) {
+		t.Errorf("Directory holds %v, want %v", names, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", testTarget, testLocal, nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+
+	var got struct {
+		Report
+		Totals Totals `json:"totals"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	if got.From != "main" || got.Totals != r.Totals() || len(got.Sections) != 1 || len(got.Sections[0].Resources) != 3 {
+		t.Errorf("WriteJSON() = %+v, want the report with its totals", got)
+	}
+	if got.Sections[0].Resources[0].Diff != r.Sections[0].Resources[0].Diff {
+		t.Errorf("WriteJSON() left out the diff of %s", got.Sections[0].Resources[0].Name)
+	}
+}
+
+func TestWritePatchDir(t *testing.T) {
+	r := &Report{From: "main", To: "local"}
+	if err := r.Add("app", testTarget, testLocal+"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: creds\n  namespace: team\n", nil); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	// Left over from a previous run
+	if err := os.WriteFile(filepath.Join(dir, "Service_old.patch"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.WritePatchDir(dir); err != nil {
+		t.Fatalf("WritePatchDir() failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	want := []string{"ConfigMap_config.patch", "Deployment_added.patch", "Secret_team_creds.patch", "Service_removed.patch"}
+	if !slices.Equal(names, want) {
+		t.Errorf("WritePatchDir() wrote %v, want %v", names, want)
+	}
+
+	patch, err := os.ReadFile(filepath.Join(dir, "ConfigMap_config.patch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(patch), "--- main/ConfigMap/config\n+++ local/ConfigMap/config\n") || !strings.Contains(string(patch), "+  key: new") {
+		t.Errorf("Unexpected patch:\n%s", patch)
+	}
+}
@@ -0,0 +1,112 @@
+// Package retry retries network fetches like chart dependency downloads
+// and remote kustomize bases, so a flaky proxy or registry doesn't fail
+// the whole run.
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultBackoff is the wait before the first retry, it doubles on every
+// retry after that
+const DefaultBackoff = time.Second
+
+// transientErrors are parts of the messages of errors worth retrying.
+// Fetches shell out to git or go through Helm's getters, which often
+// only return the message of the underlying network error.
+var transientErrors = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"unexpected eof",
+	"tls handshake",
+	"temporary failure in name resolution",
+	"proxyconnect",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+	"429 too many requests",
+	"the remote end hung up",
+	"could not read from remote repository",
+}
+
+// Policy configures how a fetch is retried. The zero value runs it once
+// without a time limit.
+type Policy struct {
+	// Retries is how many times a fetch failing with a transient error is
+	// retried
+	Retries int
+	// Backoff is the wait before the first retry, defaults to
+	// DefaultBackoff
+	Backoff time.Duration
+	// Timeout limits the fetch, retries and backoff included, 0 disables
+	// the limit
+	Timeout time.Duration
+}
+
+// Do runs fetch, retrying it with exponential backoff while it fails with
+// a transient error. what names the fetch in the log and errors, e.g.
+// 'dependency build of chart app'. A fetch still running when the timeout
+// passes is abandoned, its error is returned instead.
+func (p Policy) Do(what string, fetch func() error) error {
+	var deadline <-chan time.Time
+	if p.Timeout > 0 {
+		timer := time.NewTimer(p.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	timedOut := fmt.Errorf("%s timed out after %s", what, p.Timeout)
+
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		// Buffered so an abandoned fetch doesn't block forever
+		result := make(chan error, 1)
+		go func() { result <- fetch() }()
+
+		var err error
+		select {
+		case err = <-result:
+		case <-deadline:
+			return timedOut
+		}
+		if err == nil || attempt >= p.Retries || !Transient(err) {
+			return err
+		}
+
+		slog.Warn(fmt.Sprintf("%s failed, retrying", what), "backoff", backoff, "attempt", attempt+1, "retries", p.Retries, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-deadline:
+			return fmt.Errorf("%w: %w", timedOut, err)
+		}
+		backoff *= 2
+	}
+}
+
+// Transient reports whether err looks like a network failure that may
+// succeed when retried, as opposed to e.g. a missing chart version
+func Transient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, transient := range transientErrors {
+		if strings.Contains(message, transient) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDo(t *testing.T) {
+	transient := errors.New("dial tcp 10.0.0.1:443: connect: connection refused")
+	permanent := errors.New("chart \"app\" version \"9.9.9\" not found")
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		errs      []error
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "success",
+			policy:    Policy{Retries: 2},
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "transient error retried",
+			policy:    Policy{Retries: 2, Backoff: time.Millisecond},
+			errs:      []error{transient, transient, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "retries exhausted",
+			policy:    Policy{Retries: 1, Backoff: time.Millisecond},
+			errs:      []error{transient, transient, nil},
+			wantCalls: 2,
+			wantErr:   "connection refused",
+		},
+		{
+			name:      "permanent error not retried",
+			policy:    Policy{Retries: 2, Backoff: time.Millisecond},
+			errs:      []error{permanent, nil},
+			wantCalls: 1,
+			wantErr:   "not found",
+		},
+		{
+			name:      "timeout during backoff",
+			policy:    Policy{Retries: 2, Backoff: time.Hour, Timeout: 10 * time.Millisecond},
+			errs:      []error{transient, nil},
+			wantCalls: 1,
+			wantErr:   "fetch timed out after 10ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.Do("fetch", func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+
+			if calls != tt.wantCalls {
+				t.Errorf("Do() called fetch %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Do() failed: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Do() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	err := Policy{Timeout: 10 * time.Millisecond}.Do("fetch", func() error {
+		<-release
+		return nil
+	})
+	if err == nil || err.Error() != "fetch timed out after 10ms" {
+		t.Errorf("Do() error = %v, want a timeout", err)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("failed to fetch: %w", &net.DNSError{Err: "i/o timeout", IsTimeout: true}), true},
+		{errors.New("Get \"https://charts.example.com/index.yaml\": proxyconnect tcp: EOF"), true},
+		{errors.New("failed to fetch https://charts.example.com/index.yaml : 503 Service Unavailable"), true},
+		{errors.New("fatal: the remote end hung up unexpectedly"), true},
+		{errors.New("failed to fetch https://charts.example.com/index.yaml : 404 Not Found"), false},
+		{errors.New("no matching version found for chart redis"), false},
+	}
+
+	for _, tt := range tests {
+		if got := Transient(tt.err); got != tt.want {
+			t.Errorf("Transient(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package sarif writes validation and policy findings as a SARIF 2.1.0
+// log, so they show up in code scanning UIs like GitHub's annotated
+// against the files that produced them.
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Levels of a result
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+const schemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// Result is a single finding
+type Result struct {
+	// RuleID identifies the check, e.g. 'kubeconform' or 'conftest/main'
+	RuleID string
+	// Level is one of the Level constants
+	Level   string
+	Message string
+	// Path is the file the finding is reported against, relative to the
+	// repository root with forward slashes
+	Path string
+}
+
+// Log collects results, it is safe for concurrent use
+type Log struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+// Add records results
+func (l *Log) Add(results ...Result) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.results = append(l.results, results...)
+}
+
+// Results returns a copy of the recorded results
+func (l *Log) Results() []Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Result{}, l.results...)
+}
+
+type sarifLog struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []run  `json:"runs"`
+}
+
+type run struct {
+	Tool    tool          `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type tool struct {
+	Driver driver `json:"driver"`
+}
+
+type driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri"`
+	Rules          []rule `json:"rules"`
+}
+
+type rule struct {
+	ID               string  `json:"id"`
+	ShortDescription message `json:"shortDescription"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   message    `json:"message"`
+	Locations []location `json:"locations"`
+}
+
+type location struct {
+	PhysicalLocation physicalLocation `json:"physicalLocation"`
+}
+
+type physicalLocation struct {
+	ArtifactLocation artifactLocation `json:"artifactLocation"`
+}
+
+type artifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+// Write writes the results as a SARIF log with a single run of rdv
+func (l *Log) Write(w io.Writer, version string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := run{
+		Tool: tool{Driver: driver{
+			Name:           "rdv",
+			Version:        version,
+			InformationURI: "https://github.com/dlactin/rdv",
+			Rules:          []rule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seen := map[string]bool{}
+	for _, res := range l.results {
+		if !seen[res.RuleID] {
+			seen[res.RuleID] = true
+			r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule{ID: res.RuleID, ShortDescription: message{Text: res.RuleID}})
+		}
+
+		r.Results = append(r.Results, sarifResult{
+			RuleID:  res.RuleID,
+			Level:   res.Level,
+			Message: message{Text: res.Message},
+			Locations: []location{{PhysicalLocation: physicalLocation{
+				ArtifactLocation: artifactLocation{URI: res.Path, URIBaseID: "%SRCROOT%"},
+			}}},
+		})
+	}
+	sort.Slice(r.Tool.Driver.Rules, func(i, j int) bool {
+		return r.Tool.Driver.Rules[i].ID < r.Tool.Driver.Rules[j].ID
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: schemaURI, Version: "2.1.0", Runs: []run{r}})
+}
+
+// WriteFile writes the SARIF log to path
+func (l *Log) WriteFile(path, version string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create SARIF report: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := l.Write(f, version); err != nil {
+		return fmt.Errorf("failed to write SARIF report to %s: %w", path, err)
+	}
+
+	return f.Close()
+}
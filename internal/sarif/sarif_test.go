@@ -0,0 +1,41 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	l := &Log{}
+	l.Add(
+		Result{RuleID: "kubeconform", Level: LevelError, Message: "missing spec", Path: "charts/app/templates/deployment.yaml"},
+		Result{RuleID: "conftest/main", Level: LevelWarning, Message: "no limits", Path: "charts/app/Chart.yaml"},
+		Result{RuleID: "kubeconform", Level: LevelError, Message: "bad port", Path: "charts/app/templates/service.yaml"},
+	)
+
+	var buf bytes.Buffer
+	if err := l.Write(&buf, "v1.0.0"); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Write() produced invalid JSON: %v", err)
+	}
+
+	if got.Version != "2.1.0" || len(got.Runs) != 1 {
+		t.Fatalf("Write() = version %q with %d runs, want 2.1.0 with 1 run", got.Version, len(got.Runs))
+	}
+
+	run := got.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "conftest/main" {
+		t.Errorf("Rules = %+v, want conftest/main and kubeconform", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Write() wrote %d results, want 3", len(run.Results))
+	}
+	if uri := run.Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "charts/app/templates/deployment.yaml" {
+		t.Errorf("Location = %q, want the template path", uri)
+	}
+}
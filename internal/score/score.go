@@ -0,0 +1,271 @@
+// Package score runs kube-score style best-practice checks on rendered
+// workloads, e.g. missing probes, resource requests or disruption budgets.
+package score
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// Severities of a failed check
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+// Issue is a failed check on a resource
+type Issue struct {
+	Check    string
+	Severity string
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Severity, i.Check, i.Message)
+}
+
+// Result holds the checks run on a single resource
+type Result struct {
+	Resource manifest.Resource
+	// Checks is the number of checks that were run
+	Checks int
+	Issues []Issue
+}
+
+// Passed returns the number of checks without issues
+func (r Result) Passed() int {
+	failed := map[string]bool{}
+	for _, i := range r.Issues {
+		failed[i.Check] = true
+	}
+	return r.Checks - len(failed)
+}
+
+// podSpecPaths is the path to the pod spec for each workload kind
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// Score runs the checks on every workload in resources. all is the full
+// render, used to find the PodDisruptionBudgets covering a workload.
+// Resources that aren't workloads are skipped.
+func Score(resources, all []manifest.Resource) ([]Result, error) {
+	pdbs, err := disruptionBudgets(all)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, r := range resources {
+		path, ok := podSpecPaths[r.Kind]
+		if !ok {
+			continue
+		}
+
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(r.Body), &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+
+		w := workload{kind: r.Kind, obj: obj, podSpec: mapAt(obj, path...)}
+		result := Result{Resource: r}
+		for _, c := range checks {
+			if !c.applies(w) {
+				continue
+			}
+			result.Checks++
+			for _, msg := range c.run(w, pdbs, r.Namespace) {
+				result.Issues = append(result.Issues, Issue{Check: c.name, Severity: c.severity, Message: msg})
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// workload is a parsed resource with a pod template
+type workload struct {
+	kind    string
+	obj     map[string]any
+	podSpec map[string]any
+}
+
+// longRunning reports whether the workload's pods are expected to keep running
+func (w workload) longRunning() bool {
+	return w.kind != "Job" && w.kind != "CronJob"
+}
+
+func (w workload) containers() []map[string]any {
+	var containers []map[string]any
+	for _, c := range sliceAt(w.podSpec, "containers") {
+		if m, ok := c.(map[string]any); ok {
+			containers = append(containers, m)
+		}
+	}
+	return containers
+}
+
+type check struct {
+	name     string
+	severity string
+	applies  func(w workload) bool
+	run      func(w workload, pdbs []pdb, namespace string) []string
+}
+
+func always(workload) bool { return true }
+
+var checks = []check{
+	{
+		name:     "container-resources",
+		severity: SeverityWarning,
+		applies:  always,
+		run: func(w workload, _ []pdb, _ string) []string {
+			var msgs []string
+			for _, c := range w.containers() {
+				for _, field := range []string{"requests.cpu", "requests.memory", "limits.memory"} {
+					kind, resource, _ := strings.Cut(field, ".")
+					if mapAt(c, "resources", kind)[resource] == nil {
+						msgs = append(msgs, fmt.Sprintf("container '%s' has no %s %s", c["name"], resource, strings.TrimSuffix(kind, "s")))
+					}
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		name:     "container-probes",
+		severity: SeverityWarning,
+		applies:  workload.longRunning,
+		run: func(w workload, _ []pdb, _ string) []string {
+			var msgs []string
+			for _, c := range w.containers() {
+				for _, probe := range []string{"readinessProbe", "livenessProbe"} {
+					if c[probe] == nil {
+						msgs = append(msgs, fmt.Sprintf("container '%s' has no %s", c["name"], probe))
+					}
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		name:     "container-image-tag",
+		severity: SeverityCritical,
+		applies:  always,
+		run: func(w workload, _ []pdb, _ string) []string {
+			var msgs []string
+			for _, c := range w.containers() {
+				image, _ := c["image"].(string)
+				if strings.Contains(image, "@") {
+					continue
+				}
+				// The tag follows the last ':' after the registry host and port
+				name := image[strings.LastIndex(image, "/")+1:]
+				_, tag, ok := strings.Cut(name, ":")
+				if !ok || tag == "latest" {
+					msgs = append(msgs, fmt.Sprintf("container '%s' image %q is not pinned to a tag", c["name"], image))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		name:     "container-security-context",
+		severity: SeverityCritical,
+		applies:  always,
+		run: func(w workload, _ []pdb, _ string) []string {
+			var msgs []string
+			podNonRoot := mapAt(w.podSpec, "securityContext")["runAsNonRoot"] == true
+			for _, c := range w.containers() {
+				sc := mapAt(c, "securityContext")
+				if sc["privileged"] == true {
+					msgs = append(msgs, fmt.Sprintf("container '%s' is privileged", c["name"]))
+				}
+				if !podNonRoot && sc["runAsNonRoot"] != true {
+					msgs = append(msgs, fmt.Sprintf("container '%s' may run as root, set runAsNonRoot", c["name"]))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		name:     "pod-disruption-budget",
+		severity: SeverityWarning,
+		applies: func(w workload) bool {
+			// Single replica workloads can't keep a minimum available anyway
+			replicas, _ := mapAt(w.obj, "spec")["replicas"].(int)
+			return (w.kind == "Deployment" || w.kind == "StatefulSet") && replicas > 1
+		},
+		run: func(w workload, pdbs []pdb, namespace string) []string {
+			labels := mapAt(w.obj, "spec", "template", "metadata", "labels")
+			for _, p := range pdbs {
+				if p.namespace == namespace && p.matches(labels) {
+					return nil
+				}
+			}
+			return []string{"no PodDisruptionBudget matches the pod labels"}
+		},
+	},
+}
+
+// pdb is the selector of a PodDisruptionBudget in the render
+type pdb struct {
+	namespace   string
+	matchLabels map[string]any
+}
+
+// matches reports whether the budget selects pods with these labels
+func (p pdb) matches(labels map[string]any) bool {
+	if len(p.matchLabels) == 0 {
+		return false
+	}
+	for k, v := range p.matchLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func disruptionBudgets(resources []manifest.Resource) ([]pdb, error) {
+	var pdbs []pdb
+	for _, r := range resources {
+		if r.Kind != "PodDisruptionBudget" {
+			continue
+		}
+		var obj map[string]any
+		if err := yaml.Unmarshal([]byte(r.Body), &obj); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", r, err)
+		}
+		pdbs = append(pdbs, pdb{namespace: r.Namespace, matchLabels: mapAt(obj, "spec", "selector", "matchLabels")})
+	}
+	return pdbs, nil
+}
+
+// mapAt returns the nested map at path, or nil if any element is missing
+func mapAt(m map[string]any, path ...string) map[string]any {
+	for _, key := range path {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	return m
+}
+
+// sliceAt returns the list at key in m, or nil if it is missing
+func sliceAt(m map[string]any, key string) []any {
+	s, _ := m[key].([]any)
+	return s
+}
@@ -0,0 +1,162 @@
+package score
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/manifest"
+)
+
+const goodDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: good
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: good
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      containers:
+        - name: app
+          image: registry.example.com:5000/app:1.2.3
+          resources:
+            requests:
+              cpu: 100m
+              memory: 128Mi
+            limits:
+              memory: 128Mi
+          readinessProbe:
+            httpGet:
+              path: /ready
+              port: 8080
+          livenessProbe:
+            httpGet:
+              path: /live
+              port: 8080
+`
+
+const goodPDB = `apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: good
+spec:
+  minAvailable: 1
+  selector:
+    matchLabels:
+      app: good
+`
+
+const badDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: bad
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: bad
+    spec:
+      containers:
+        - name: app
+          image: nginx
+          securityContext:
+            privileged: true
+`
+
+const job = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      containers:
+        - name: migrate
+          image: app@sha256:0123
+          securityContext:
+            runAsNonRoot: true
+          resources:
+            requests:
+              cpu: 100m
+              memory: 128Mi
+            limits:
+              memory: 128Mi
+`
+
+func TestScore(t *testing.T) {
+	all, err := manifest.Parse(strings.Join([]string{goodDeployment, goodPDB, badDeployment, job}, "---\n"))
+	if err != nil {
+		t.Fatalf("Parse() failed: %v", err)
+	}
+
+	results, err := Score(all, all)
+	if err != nil {
+		t.Fatalf("Score() failed: %v", err)
+	}
+
+	// The PodDisruptionBudget is not a workload
+	if len(results) != 3 {
+		t.Fatalf("Score() returned %d results, want 3", len(results))
+	}
+
+	testCases := []struct {
+		name       string
+		result     Result
+		wantChecks int
+		wantPassed int
+		wantIssues []string
+	}{
+		{
+			name:       "Deployment following best practices",
+			result:     results[0],
+			wantChecks: 5,
+			wantPassed: 5,
+		},
+		{
+			name:       "Deployment with issues",
+			result:     results[1],
+			wantChecks: 5,
+			wantPassed: 0,
+			wantIssues: []string{
+				"container 'app' has no cpu request",
+				"container 'app' has no readinessProbe",
+				`container 'app' image "nginx" is not pinned to a tag`,
+				"container 'app' is privileged",
+				"container 'app' may run as root, set runAsNonRoot",
+				"no PodDisruptionBudget matches the pod labels",
+			},
+		},
+		{
+			name:       "Job skips probes and disruption budgets",
+			result:     results[2],
+			wantChecks: 3,
+			wantPassed: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.result.Checks != tc.wantChecks {
+				t.Errorf("Checks = %d, want %d", tc.result.Checks, tc.wantChecks)
+			}
+			if got := tc.result.Passed(); got != tc.wantPassed {
+				t.Errorf("Passed() = %d, want %d. Issues: %v", got, tc.wantPassed, tc.result.Issues)
+			}
+
+			var messages []string
+			for _, i := range tc.result.Issues {
+				messages = append(messages, i.Message)
+			}
+			for _, want := range tc.wantIssues {
+				if !strings.Contains(strings.Join(messages, "\n"), want) {
+					t.Errorf("Issues missing %q. Got: %v", want, messages)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package server implements the HTTP API of 'rdv serve', which renders
+// and diffs two refs of a repository on request so services and ChatOps
+// bots can get a diff without waiting on CI.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dlactin/rdv/internal/git"
+	"github.com/dlactin/rdv/internal/report"
+)
+
+// Request is the body of POST /diff
+type Request struct {
+	// Repo is the URL of the git repository to clone
+	Repo string `json:"repo"`
+	// Base is the ref diffed against, defaults to 'main'
+	Base string `json:"base"`
+	// Ref is the ref with the changes
+	Ref string `json:"ref"`
+	// Path is the chart or kustomization directory relative to the
+	// repository root, defaults to the root
+	Path string `json:"path"`
+	// Values are Helm values files relative to Path
+	Values []string `json:"values"`
+}
+
+// Response is the JSON body returned by POST /diff
+type Response struct {
+	Base      string        `json:"base"`
+	Ref       string        `json:"ref"`
+	Totals    report.Totals `json:"totals"`
+	Resources []Resource    `json:"resources"`
+}
+
+// Resource is a changed resource in a Response
+type Resource struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Diff    string `json:"diff"`
+}
+
+// DiffFunc clones, renders and diffs the refs of a request
+type DiffFunc func(ctx context.Context, req Request) (*report.Report, error)
+
+// Options configures the server
+type Options struct {
+	// AllowRepos are globs matched against the normalized repository URL,
+	// e.g. 'github.com/org/*'. Every repository is allowed when empty.
+	AllowRepos []string
+	// Timeout bounds each request, zero disables it
+	Timeout time.Duration
+	// Concurrency is the number of diffs run at the same time, further
+	// requests wait for a slot
+	Concurrency int
+}
+
+// New returns the HTTP handler serving POST /diff and GET /healthz
+func New(diff DiffFunc, opts Options) http.Handler {
+	slots := make(chan struct{}, max(opts.Concurrency, 1))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("POST /diff", func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if err := req.normalize(opts.AllowRepos); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		ctx := r.Context()
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+		case <-ctx.Done():
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("no free slot before the request ended: %w", ctx.Err()))
+			return
+		}
+
+		start := time.Now()
+		rep, err := diff(ctx, req)
+		if err != nil {
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			log.Printf("Diff of %s %s..%s failed: %v", req.Repo, req.Base, req.Ref, err)
+			writeError(w, status, err)
+			return
+		}
+		log.Printf("Diffed %s %s..%s in %s", req.Repo, req.Base, req.Ref, time.Since(start).Round(time.Millisecond))
+
+		if r.URL.Query().Get("format") == "markdown" {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			_ = rep.WriteMarkdown(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, newResponse(req, rep))
+	})
+	return mux
+}
+
+// normalize fills in the defaults of a request and rejects requests for
+// repositories that aren't allowed or paths outside of the repository
+func (req *Request) normalize(allowRepos []string) error {
+	if req.Repo == "" {
+		return errors.New("repo is required")
+	}
+	if req.Ref == "" {
+		return errors.New("ref is required")
+	}
+	if req.Base == "" {
+		req.Base = "main"
+	}
+	if req.Path == "" {
+		req.Path = "."
+	}
+
+	// Values starting with a dash would be passed to git as options
+	for _, v := range []string{req.Repo, req.Base, req.Ref} {
+		if strings.HasPrefix(v, "-") {
+			return fmt.Errorf("invalid repo or ref %q", v)
+		}
+	}
+
+	if !filepath.IsLocal(req.Path) {
+		return fmt.Errorf("path %q must be relative to the repository root", req.Path)
+	}
+	for _, v := range req.Values {
+		if !filepath.IsLocal(filepath.Join(req.Path, v)) {
+			return fmt.Errorf("values file %q must be inside the repository", v)
+		}
+	}
+
+	if len(allowRepos) == 0 {
+		return nil
+	}
+	repo := git.NormalizeURL(req.Repo)
+	for _, pattern := range allowRepos {
+		if ok, _ := path.Match(pattern, repo); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("repository %q is not allowed", req.Repo)
+}
+
+// newResponse flattens the sections of a report, a request diffs a
+// single path
+func newResponse(req Request, rep *report.Report) Response {
+	resp := Response{Base: req.Base, Ref: req.Ref, Totals: rep.Totals(), Resources: []Resource{}}
+	for _, section := range rep.Sections {
+		for _, res := range section.Resources {
+			resp.Resources = append(resp.Resources, Resource(res))
+		}
+	}
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
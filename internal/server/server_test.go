@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dlactin/rdv/internal/report"
+)
+
+const (
+	baseRender = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: old\n"
+	refRender  = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: the-map\ndata:\n  key: new\n"
+)
+
+func fakeDiff(ctx context.Context, req Request) (*report.Report, error) {
+	if req.Ref == "broken" {
+		return nil, errors.New("failed to render")
+	}
+	rep := &report.Report{From: req.Base, To: req.Ref}
+	if err := rep.Add(req.Path, baseRender, refRender, nil); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(New(fakeDiff, Options{AllowRepos: []string{"github.com/org/*"}}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name       string
+		query      string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{
+			name:       "JSON diff",
+			body:       `{"repo": "https://github.com/org/repo.git", "ref": "feature", "path": "charts/app"}`,
+			wantStatus: http.StatusOK,
+			want:       `"status":"modified"`,
+		},
+		{
+			name:       "Markdown diff",
+			query:      "?format=markdown",
+			body:       `{"repo": "git@github.com:org/repo.git", "base": "v1", "ref": "feature"}`,
+			wantStatus: http.StatusOK,
+			want:       "`v1` vs. `feature`",
+		},
+		{
+			name:       "Repository not allowed",
+			body:       `{"repo": "https://github.com/other/repo", "ref": "feature"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       "is not allowed",
+		},
+		{
+			name:       "Missing ref",
+			body:       `{"repo": "https://github.com/org/repo"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       "ref is required",
+		},
+		{
+			name:       "Path outside the repository",
+			body:       `{"repo": "https://github.com/org/repo", "ref": "feature", "path": "../etc"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       "must be relative to the repository root",
+		},
+		{
+			name:       "Ref passed as a git option",
+			body:       `{"repo": "https://github.com/org/repo", "ref": "--upload-pack=touch /tmp/x"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       "invalid repo or ref",
+		},
+		{
+			name:       "Unknown field",
+			body:       `{"repo": "https://github.com/org/repo", "ref": "feature", "branch": "main"}`,
+			wantStatus: http.StatusBadRequest,
+			want:       "invalid request body",
+		},
+		{
+			name:       "Render failure",
+			body:       `{"repo": "https://github.com/org/repo", "ref": "broken"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			want:       "failed to render",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/diff"+tc.query, "application/json", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatalf("POST /diff failed: %v", err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tc.wantStatus, body)
+			}
+			if !strings.Contains(string(body), tc.want) {
+				t.Errorf("Expected %q in the response, got: %s", tc.want, body)
+			}
+		})
+	}
+}
+
+func TestResponse(t *testing.T) {
+	rep, err := fakeDiff(context.Background(), Request{Base: "main", Ref: "feature", Path: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := json.Marshal(newResponse(Request{Base: "main", Ref: "feature"}, rep))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(encoded, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Totals.Modified != 1 || len(resp.Resources) != 1 {
+		t.Fatalf("Expected one modified resource, got %+v", resp)
+	}
+	if res := resp.Resources[0]; res.Name != "ConfigMap/the-map" || !strings.Contains(res.Diff, "+  key: new") {
+		t.Errorf("Unexpected resource %+v", res)
+	}
+}
@@ -0,0 +1,96 @@
+// Package sops decrypts SOPS encrypted values files with the sops CLI,
+// which picks the age, KMS or PGP keys from the file's metadata and the
+// environment, like when the file is decrypted by hand
+package sops
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// encryptedPrefix starts every value encrypted by sops
+const encryptedPrefix = "ENC["
+
+// IsEncrypted reports whether a YAML or JSON document was encrypted by
+// sops, which adds a top-level 'sops' key with a 'mac'
+func IsEncrypted(content []byte) bool {
+	var doc struct {
+		Sops *struct {
+			MAC string `json:"mac"`
+		} `json:"sops"`
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return false
+	}
+	return doc.Sops != nil && doc.Sops.MAC != ""
+}
+
+// Decrypt returns the decrypted contents of the file at path. The file is
+// never written decrypted, sops prints it to stdout.
+func Decrypt(path string) ([]byte, error) {
+	if _, err := exec.LookPath("sops"); err != nil {
+		return nil, fmt.Errorf("sops not found in PATH, it is required to decrypt %s: %w", path, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", path)
+	// sops looks for .sops.yaml from the working directory up
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with sops: %w\nOutput: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Secrets returns the decrypted values of the leaves that are encrypted
+// in the encrypted document, values left in plain text by sops'
+// unencrypted_suffix or encrypted_regex settings aren't secrets
+func Secrets(encrypted, decrypted []byte) ([]string, error) {
+	var enc, dec any
+	if err := yaml.Unmarshal(encrypted, &enc); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted document: %w", err)
+	}
+	if err := yaml.Unmarshal(decrypted, &dec); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted document: %w", err)
+	}
+
+	var secrets []string
+	collectSecrets(enc, dec, &secrets)
+	return secrets, nil
+}
+
+func collectSecrets(enc, dec any, secrets *[]string) {
+	switch e := enc.(type) {
+	case map[string]any:
+		d, ok := dec.(map[string]any)
+		if !ok {
+			return
+		}
+		for key, value := range e {
+			if key == "sops" {
+				continue
+			}
+			collectSecrets(value, d[key], secrets)
+		}
+	case []any:
+		d, ok := dec.([]any)
+		if !ok {
+			return
+		}
+		for i := range min(len(e), len(d)) {
+			collectSecrets(e[i], d[i], secrets)
+		}
+	case string:
+		if strings.HasPrefix(e, encryptedPrefix) && dec != nil {
+			*secrets = append(*secrets, fmt.Sprint(dec))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package sops
+
+import (
+	"slices"
+	"testing"
+)
+
+const encrypted = `image:
+    tag: 1.2.3
+database:
+    password: ENC[AES256_GCM,data:Zm9v,iv:YmFy,tag:YmF6,type:str]
+    hosts:
+        - ENC[AES256_GCM,data:Zm9v,iv:YmFy,tag:YmF6,type:str]
+    port_unencrypted: 5432
+sops:
+    age:
+        - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+    lastmodified: "2024-01-01T00:00:00Z"
+    mac: ENC[AES256_GCM,data:bWFj,iv:YmFy,tag:YmF6,type:str]
+    version: 3.8.1
+`
+
+const decrypted = `image:
+    tag: 1.2.3
+database:
+    password: s3cr3t-pass
+    hosts:
+        - db.internal.example.com
+    port_unencrypted: 5432
+`
+
+func TestIsEncrypted(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "Encrypted", content: encrypted, want: true},
+		{name: "Plain values", content: decrypted, want: false},
+		{name: "Key named sops", content: "sops:\n  enabled: true\n", want: false},
+		{name: "Invalid YAML", content: ":\n- [", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsEncrypted([]byte(tc.content)); got != tc.want {
+				t.Errorf("IsEncrypted() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	secrets, err := Secrets([]byte(encrypted), []byte(decrypted))
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+
+	slices.Sort(secrets)
+	want := []string{"db.internal.example.com", "s3cr3t-pass"}
+	if !slices.Equal(secrets, want) {
+		t.Errorf("Secrets() = %v, want %v", secrets, want)
+	}
+}
@@ -0,0 +1,77 @@
+// Package timoni renders Timoni modules with the timoni CLI
+package timoni
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleFile marks the root of a Timoni module
+const ModuleFile = "timoni.cue"
+
+// Options configures how a module instance is built
+type Options struct {
+	// Instance is the instance name, defaults to 'release' like the Helm
+	// release name
+	Instance string
+	// Namespace is the instance namespace, defaults to 'default'
+	Namespace string
+	// Values are values files (CUE, YAML or JSON) merged in order over the
+	// module defaults, like the values of a bundle instance
+	Values []string
+	Debug  bool
+}
+
+// IsModule reports whether path is the root of a Timoni module
+func IsModule(path string) bool {
+	info, err := os.Stat(filepath.Join(path, ModuleFile))
+	return err == nil && !info.IsDir()
+}
+
+// buildArgs returns the arguments of 'timoni build' for the module at path
+func buildArgs(path string, opts Options) []string {
+	instance := opts.Instance
+	if instance == "" {
+		instance = "release"
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	args := []string{"build", instance, path, "--namespace", namespace}
+	for _, v := range opts.Values {
+		args = append(args, "--values", v)
+	}
+	return args
+}
+
+// Render builds an instance of the module at path and returns the
+// manifests. Timoni fetches nothing for a local module, the CUE
+// dependencies must be vendored in its cue.mod directory.
+func Render(ctx context.Context, path string, opts Options) (string, error) {
+	if _, err := exec.LookPath("timoni"); err != nil {
+		return "", fmt.Errorf("timoni not found in PATH, it is required to render Timoni modules: %w", err)
+	}
+
+	args := buildArgs(path, opts)
+	if opts.Debug {
+		log.Printf("Running timoni %s", strings.Join(args, " "))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "timoni", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to build Timoni module %s: %w\nOutput: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
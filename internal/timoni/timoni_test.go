@@ -0,0 +1,51 @@
+package timoni
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsModule(t *testing.T) {
+	module := t.TempDir()
+	if err := os.WriteFile(filepath.Join(module, ModuleFile), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsModule(module) {
+		t.Errorf("IsModule() = false for a directory with %s", ModuleFile)
+	}
+	if IsModule(t.TempDir()) {
+		t.Error("IsModule() = true for an empty directory")
+	}
+	if IsModule("../../examples/helm/helloworld") {
+		t.Error("IsModule() = true for a Helm chart")
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{
+			name: "Defaults",
+			want: []string{"build", "release", "/module", "--namespace", "default"},
+		},
+		{
+			name: "Instance and values",
+			opts: Options{Instance: "podinfo", Namespace: "apps", Values: []string{"/module/values-dev.cue", "/module/values-ci.yaml"}},
+			want: []string{"build", "podinfo", "/module", "--namespace", "apps", "--values", "/module/values-dev.cue", "--values", "/module/values-ci.yaml"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := buildArgs("/module", tc.opts); !slices.Equal(got, tc.want) {
+				t.Errorf("buildArgs() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
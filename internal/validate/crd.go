@@ -0,0 +1,143 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yannh/kubeconform/pkg/resource"
+	"github.com/yannh/kubeconform/pkg/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// crdSchemaLocation is the kubeconform schema path template for the
+// schemas extracted from CustomResourceDefinitions
+const crdSchemaLocation = "{{ .Group }}/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json"
+
+// crdValidator validates custom resources against the schemas of
+// the CustomResourceDefinitions found in a manifest
+type crdValidator struct {
+	kv  validator.Validator
+	dir string
+	// gvks holds the 'group/version/Kind' of every resource with a schema
+	gvks map[string]bool
+}
+
+// newCRDValidator extracts the openAPIV3Schema of every served version of
+// each apiextensions.k8s.io/v1 CRD in the manifest. It returns nil if the
+// manifest contains no CRDs with a schema.
+func newCRDValidator(manifest string) (*crdValidator, error) {
+	crds, err := findCRDs(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if len(crds) == 0 {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "rdv-crd-schemas-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CRD schema directory: %w", err)
+	}
+
+	c := &crdValidator{dir: dir, gvks: map[string]bool{}}
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			if version.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+
+			if err := writeSchema(dir, crd.Spec.Group, crd.Spec.Names.Kind, version.Name, version.Schema.OpenAPIV3Schema); err != nil {
+				c.Close()
+				return nil, err
+			}
+			c.gvks[fmt.Sprintf("%s/%s/%s", crd.Spec.Group, version.Name, crd.Spec.Names.Kind)] = true
+		}
+	}
+
+	c.kv, err = validator.New([]string{filepath.Join(dir, crdSchemaLocation)}, validator.Opts{Strict: true})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("error creating CRD validator: %w", err)
+	}
+
+	return c, nil
+}
+
+// Defines reports whether the resource has a schema from a CRD
+func (c *crdValidator) Defines(sig *resource.Signature) bool {
+	if c == nil {
+		return false
+	}
+	return c.gvks[sig.GroupVersionKind()]
+}
+
+// Close removes the extracted schemas
+func (c *crdValidator) Close() {
+	_ = os.RemoveAll(c.dir)
+}
+
+type crd struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Spec       struct {
+		Group string `yaml:"group"`
+		Names struct {
+			Kind string `yaml:"kind"`
+		} `yaml:"names"`
+		Versions []struct {
+			Name   string `yaml:"name"`
+			Schema struct {
+				OpenAPIV3Schema map[string]any `yaml:"openAPIV3Schema"`
+			} `yaml:"schema"`
+		} `yaml:"versions"`
+	} `yaml:"spec"`
+}
+
+// findCRDs returns the apiextensions.k8s.io/v1 CRDs in the manifest.
+// v1beta1 CRDs were removed in Kubernetes 1.22 and are not supported.
+func findCRDs(manifest string) ([]crd, error) {
+	if !strings.Contains(manifest, "CustomResourceDefinition") {
+		return nil, nil
+	}
+
+	var crds []crd
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var doc crd
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse manifest for CRDs: %w", err)
+		}
+
+		if doc.Kind == "CustomResourceDefinition" && doc.APIVersion == "apiextensions.k8s.io/v1" {
+			crds = append(crds, doc)
+		}
+	}
+
+	return crds, nil
+}
+
+// writeSchema writes a CRD version schema to the path kubeconform
+// looks it up at
+func writeSchema(dir, group, kind, version string, schema map[string]any) error {
+	content, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to convert schema for %s/%s %s: %w", group, version, kind, err)
+	}
+
+	path := filepath.Join(dir, group, fmt.Sprintf("%s_%s.json", strings.ToLower(kind), version))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create CRD schema directory: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write schema for %s/%s %s: %w", group, version, kind, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Report collects the validation results of one or more renders so they
+// can be written out for CI systems
+type Report struct {
+	mu     sync.Mutex
+	Suites []Suite `json:"suites"`
+}
+
+// Suite holds the results of a single render, e.g. 'local/charts/app'
+type Suite struct {
+	Name    string   `json:"name"`
+	Results []Result `json:"results"`
+}
+
+// Add records the results of a render. It is safe for concurrent use.
+func (r *Report) Add(name string, results []Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Suites = append(r.Suites, Suite{Name: name, Results: results})
+}
+
+// WriteFile writes the report to path, as JUnit XML if the file
+// has an .xml extension and JSON otherwise
+func (r *Report) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create validation report: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if strings.EqualFold(filepath.Ext(path), ".xml") {
+		err = r.WriteJUnit(f)
+	} else {
+		err = r.WriteJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write validation report to %s: %w", path, err)
+	}
+
+	return f.Close()
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Each render is a test suite
+// and each resource a test case named after its kind and name.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	suites := junitTestSuites{Name: "rdv"}
+
+	for _, s := range r.Suites {
+		suite := junitTestSuite{Name: s.Name, Tests: len(s.Results)}
+
+		for _, res := range s.Results {
+			tc := junitTestCase{Name: res.testName(), Classname: s.Name, File: res.Source}
+
+			switch res.Status {
+			case StatusInvalid:
+				tc.Failure = &junitMessage{Message: "resource is invalid", Body: res.Error}
+				suite.Failures++
+			case StatusError:
+				tc.Error = &junitMessage{Message: "error validating resource", Body: res.Error}
+				suite.Errors++
+			case StatusSkipped:
+				tc.Skipped = &junitMessage{Message: "no schema validation for this kind"}
+				suite.Skipped++
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// testName returns 'Kind/namespace/name', falling back to the document
+// position for resources without a kind or name
+func (r Result) testName() string {
+	if r.Kind == "" || r.Name == "" {
+		return r.ID()
+	}
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
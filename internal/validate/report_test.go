@@ -0,0 +1,56 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testReport() *Report {
+	r := &Report{}
+	r.Add("local/chart", []Result{
+		{Document: 1, Kind: "ConfigMap", Name: "config", Namespace: "default", Source: "chart/templates/configmap.yaml", Status: StatusValid},
+		{Document: 2, Kind: "Deployment", Name: "api", Source: "chart/templates/deployment.yaml", Status: StatusInvalid, Error: "spec.replicas: expected integer"},
+		{Document: 3, Kind: "Widget", Name: "small", Status: StatusSkipped},
+	})
+	return r
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJUnit(&buf); err != nil {
+		t.Fatalf("WriteJUnit() failed: %v", err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		`<testsuites name="rdv" tests="3" failures="1" errors="0" skipped="1">`,
+		`<testcase name="ConfigMap/default/config" classname="local/chart" file="chart/templates/configmap.yaml"></testcase>`,
+		`<failure message="resource is invalid">spec.replicas: expected integer</failure>`,
+		`<testcase name="Widget/small" classname="local/chart">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("WriteJUnit() output missing %q. Got:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testReport().WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON() failed: %v", err)
+	}
+
+	var got Report
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+
+	if len(got.Suites) != 1 || len(got.Suites[0].Results) != 3 {
+		t.Fatalf("WriteJSON() = %+v, want 1 suite with 3 results", got.Suites)
+	}
+	if res := got.Suites[0].Results[1]; res.Status != StatusInvalid || res.Source != "chart/templates/deployment.yaml" {
+		t.Errorf("Results[1] = %+v, want an invalid result with its source", res)
+	}
+}
@@ -0,0 +1,71 @@
+package validate
+
+import (
+	"fmt"
+
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/yannh/kubeconform/pkg/validator"
+)
+
+// Validation statuses of a single resource
+const (
+	StatusValid   = "valid"
+	StatusInvalid = "invalid"
+	StatusError   = "error"
+	StatusSkipped = "skipped"
+)
+
+// Result is the validation outcome of a single resource
+type Result struct {
+	// Document is the 1-based position of the resource in the manifest
+	Document  int    `json:"document"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Source is the template path from Helm's '# Source:' comment, if present
+	Source string `json:"source,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newResult(document int, kr validator.Result) Result {
+	r := Result{
+		Document: document,
+		Source:   manifest.SourceComment(string(kr.Resource.Bytes)),
+	}
+
+	if sig, _ := kr.Resource.Signature(); sig != nil {
+		r.Kind = sig.Kind
+		r.Name = sig.Name
+		r.Namespace = sig.Namespace
+	}
+
+	switch kr.Status {
+	case validator.Valid:
+		r.Status = StatusValid
+	case validator.Invalid:
+		r.Status = StatusInvalid
+	case validator.Skipped:
+		r.Status = StatusSkipped
+	default:
+		r.Status = StatusError
+	}
+	if kr.Err != nil {
+		r.Error = kr.Err.Error()
+	}
+
+	return r
+}
+
+// ID returns a useful string for the resource.
+// We want to return the Document, Kind and Name if available
+func (r Result) ID() string {
+	switch {
+	case r.Kind != "" && r.Name != "":
+		return fmt.Sprintf("Document %d (Kind: %s, Name: %s)", r.Document, r.Kind, r.Name)
+	case r.Kind != "":
+		return fmt.Sprintf("Document %d (Kind: %s)", r.Document, r.Kind)
+	default:
+		return fmt.Sprintf("Document %d", r.Document)
+	}
+}
@@ -1,59 +1,142 @@
 // Package validate provides functions to validate rendered manifests
 // We're using the kubeconform library here for manifest validation against
-// the default schemas supported by kubeconform. Will need a way to pass
-// in additional schema locations.
+// the default schemas supported by kubeconform. Custom resources are
+// validated against the CustomResourceDefinitions in the same render.
 package validate
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"log/slog"
 	"strings"
 
+	"github.com/dlactin/rdv/internal/progress"
 	"github.com/yannh/kubeconform/pkg/resource"
 	"github.com/yannh/kubeconform/pkg/validator"
 )
 
-func ValidateManifests(manifest string, debug bool) error {
-	// We're not passing in any schemas here, we should grab this from an envvar
-	v, err := validator.New(nil, validator.Opts{
-		Strict:    true,
-		Debug:     debug,
-		SkipKinds: map[string]struct{}{"CustomResourceDefinition": {}},
+// Validator wraps a kubeconform validator. kubeconform keeps an in-memory
+// cache of the schemas it has downloaded, so a single Validator should be
+// created per run and shared between renders. It is safe for concurrent use.
+type Validator struct {
+	kv validator.Validator
+}
+
+// Options configures a Validator
+type Options struct {
+	Debug bool
+	// SchemaLocations are kubeconform templates like
+	// 'https://example.com/{{ .ResourceKind }}.json', 'default' adds the
+	// upstream Kubernetes schemas. The upstream schemas are used if empty.
+	SchemaLocations []string
+	// KubernetesVersion selects the version of the upstream schemas, like
+	// '1.29.0'. Defaults to the latest.
+	KubernetesVersion string
+}
+
+// NewValidator creates a Validator using the default kubeconform schemas,
+// or the given schema locations. Locations are kubeconform templates like
+// 'https://example.com/{{ .ResourceKind }}.json', 'default' adds the
+// upstream Kubernetes schemas.
+func NewValidator(debug bool, schemaLocations ...string) (*Validator, error) {
+	return New(Options{Debug: debug, SchemaLocations: schemaLocations})
+}
+
+// New creates a Validator configured by opts
+func New(opts Options) (*Validator, error) {
+	kv, err := validator.New(opts.SchemaLocations, validator.Opts{
+		Strict:            true,
+		Debug:             opts.Debug,
+		KubernetesVersion: opts.KubernetesVersion,
+		SkipKinds:         map[string]struct{}{"CustomResourceDefinition": {}},
 	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating validator: %w", err)
+	}
+
+	return &Validator{kv: kv}, nil
+}
+
+// ValidateManifests creates a one-off Validator and validates the manifest
+func ValidateManifests(manifest string, debug bool) error {
+	v, err := NewValidator(debug)
 	if err != nil {
 		return fmt.Errorf("error validating supplied manifest: %w", err)
 	}
 
-	// The kubeconform validator expects a file stream and not a string
-	reader := strings.NewReader(manifest)
-	stream := io.NopCloser(reader)
+	return v.Validate(manifest)
+}
+
+// Validate runs all documents in the manifest through kubeconform and
+// returns an error listing every invalid resource
+func (v *Validator) Validate(manifest string) error {
+	results, err := v.Check(manifest)
+	if err != nil {
+		return err
+	}
+
+	return ResultsError(results)
+}
 
-	results := v.Validate("", stream)
+// Check runs all documents in the manifest through kubeconform and returns
+// the result for each of them. Custom resources defined by a
+// CustomResourceDefinition in the manifest are validated against the
+// CRD's schema instead of the default schemas.
+func (v *Validator) Check(manifest string) ([]Result, error) {
+	defer progress.Time(progress.PhaseValidation)()
 
+	crds, err := newCRDValidator(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if crds != nil {
+		defer crds.Close()
+	}
+
+	// Each resource is routed to the validator that has its schema
+	var results []Result
+	resources, _ := resource.FromStream(context.Background(), "", strings.NewReader(manifest))
+	for res := range resources {
+		kv := v.kv
+		if sig, err := res.Signature(); err == nil && crds.Defines(sig) {
+			kv = crds.kv
+		}
+
+		kr := kv.ValidateResource(res)
+		if kr.Status == validator.Empty {
+			continue
+		}
+		result := newResult(len(results)+1, kr)
+		slog.Debug("Validated resource", "resource", result.ID(), "status", result.Status)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ResultsError returns an error listing every invalid resource in results,
+// or nil if all of them are valid
+func ResultsError(results []Result) error {
 	// We want to ensure all the errors are captured
 	// So we don't return early while there are still invalid manifests
 	var errs strings.Builder
 	var validationFailed bool
 
-	for i, res := range results {
-		// Build a more helpful identifier for the resource
-		// We want to know which resource failed validation
-		resourceID := buildResourceID(i+1, &res.Resource)
-
+	for _, res := range results {
 		switch res.Status {
-		case validator.Invalid:
+		case StatusInvalid:
 			validationFailed = true
 			errs.WriteString(fmt.Sprintf(
 				"  - %s is invalid:\n      %s\n",
-				resourceID,
-				res.Err,
+				res.ID(),
+				res.Error,
 			))
-		case validator.Error:
+		case StatusError:
 			validationFailed = true
 			errs.WriteString(fmt.Sprintf(
 				"  - Error processing %s:\n      %s\n",
-				resourceID,
-				res.Err,
+				res.ID(),
+				res.Error,
 			))
 		}
 	}
@@ -64,25 +147,3 @@ func ValidateManifests(manifest string, debug bool) error {
 
 	return nil
 }
-
-// Create a useful string for the target resource
-// We want to return the Document, Kind and Name if available
-func buildResourceID(index int, res *resource.Resource) string {
-	sig, _ := res.Signature()
-
-	var kind, name string
-	if sig != nil {
-		kind = sig.Kind
-		name = sig.Name
-	}
-
-	switch {
-	case kind != "" && name != "":
-		return fmt.Sprintf("Document %d (Kind: %s, Name: %s)", index, kind, name)
-	case kind != "":
-		return fmt.Sprintf("Document %d (Kind: %s)", index, kind)
-	default:
-		// This is the fallback for when Kind is empty,
-		return fmt.Sprintf("Document %d (at %s)", index, res.Path)
-	}
-}
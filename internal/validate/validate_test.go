@@ -0,0 +1,97 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [size]
+              properties:
+                size:
+                  type: integer
+`
+
+func TestValidateCustomResources(t *testing.T) {
+	v, err := NewValidator(false)
+	if err != nil {
+		t.Fatalf("NewValidator() failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		resource string
+		wantErr  string
+	}{
+		{
+			name: "Valid custom resource",
+			resource: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: small
+spec:
+  size: 1
+`,
+		},
+		{
+			name: "Invalid custom resource",
+			resource: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: broken
+spec:
+  size: large
+`,
+			wantErr: "Kind: Widget, Name: broken",
+		},
+		{
+			name: "Missing required field",
+			resource: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: empty
+spec: {}
+`,
+			wantErr: "Kind: Widget, Name: empty",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Validate(testCRD + "---\n" + tc.resource)
+
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() failed: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Validate() succeeded, but expected an error containing %q", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package vals resolves 'ref+BACKEND://' references in values, like
+// 'ref+vault://secret/app#/password', with the vals CLI
+package vals
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// refPrefix starts every vals reference
+const refPrefix = "ref+"
+
+// Eval returns the YAML document content with every reference resolved
+func Eval(content []byte) ([]byte, error) {
+	if _, err := exec.LookPath("vals"); err != nil {
+		return nil, fmt.Errorf("vals not found in PATH, it is required to resolve references: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("vals", "eval", "-f", "-")
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to resolve references with vals: %w\nOutput: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// HasRefs reports whether a YAML document contains a reference
+func HasRefs(content []byte) bool {
+	return bytes.Contains(content, []byte(refPrefix))
+}
+
+// Secrets returns the resolved values of the leaves that are references
+// in the original document
+func Secrets(original, resolved []byte) ([]string, error) {
+	var orig, res any
+	if err := yaml.Unmarshal(original, &orig); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+	if err := yaml.Unmarshal(resolved, &res); err != nil {
+		return nil, fmt.Errorf("failed to parse resolved document: %w", err)
+	}
+
+	var secrets []string
+	collectSecrets(orig, res, &secrets)
+	return secrets, nil
+}
+
+func collectSecrets(orig, res any, secrets *[]string) {
+	switch o := orig.(type) {
+	case map[string]any:
+		r, ok := res.(map[string]any)
+		if !ok {
+			return
+		}
+		for key, value := range o {
+			collectSecrets(value, r[key], secrets)
+		}
+	case []any:
+		r, ok := res.([]any)
+		if !ok {
+			return
+		}
+		for i := range min(len(o), len(r)) {
+			collectSecrets(o[i], r[i], secrets)
+		}
+	case string:
+		if strings.HasPrefix(o, refPrefix) && res != nil {
+			*secrets = append(*secrets, fmt.Sprint(res))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package vals
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	original := `database:
+  password: ref+vault://secret/app#/password
+  host: db.example.com
+  replicas:
+    - ref+awsssm://app/replica-host
+`
+	resolved := `database:
+  password: s3cr3t-pass
+  host: db.example.com
+  replicas:
+    - replica.internal.example.com
+`
+
+	secrets, err := Secrets([]byte(original), []byte(resolved))
+	if err != nil {
+		t.Fatalf("Secrets() failed: %v", err)
+	}
+
+	slices.Sort(secrets)
+	want := []string{"replica.internal.example.com", "s3cr3t-pass"}
+	if !slices.Equal(secrets, want) {
+		t.Errorf("Secrets() = %v, want %v", secrets, want)
+	}
+}
+
+func TestHasRefs(t *testing.T) {
+	if !HasRefs([]byte("password: ref+vault://secret/app#/password\n")) {
+		t.Error("HasRefs() = false for a document with a reference")
+	}
+	if HasRefs([]byte("password: hunter2\n")) {
+		t.Error("HasRefs() = true for a document without references")
+	}
+}
@@ -0,0 +1,78 @@
+package vcs
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/dlactin/rdv/internal/git"
+)
+
+// gitVCS uses git worktrees to check out the target ref
+type gitVCS struct {
+	root string
+}
+
+func newGit() (*gitVCS, error) {
+	// A local git installation is required
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git not found in PATH: %w", err)
+	}
+
+	root, err := git.GetRepoRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitVCS{root: root}, nil
+}
+
+func (g *gitVCS) Name() string {
+	return "git"
+}
+
+func (g *gitVCS) Root() string {
+	return g.root
+}
+
+func (g *gitVCS) ResolveRef(ref string) (string, error) {
+	return git.ResolveRef(g.root, ref)
+}
+
+func (g *gitVCS) Commit(ref string) (string, error) {
+	return git.RevParse(g.root, ref)
+}
+
+func (g *gitVCS) MergeBase(ref, other string) (string, error) {
+	if other == "" {
+		other = "HEAD"
+	}
+	return git.MergeBase(g.root, ref, other)
+}
+
+func (g *gitVCS) ReadFile(ref, path string) ([]byte, error) {
+	return git.ShowFile(g.root, ref, path)
+}
+
+func (g *gitVCS) Remotes() ([]string, error) {
+	return git.RemoteURLs(g.root)
+}
+
+func (g *gitVCS) Refs() ([]string, error) {
+	return git.Refs(g.root)
+}
+
+func (g *gitVCS) Checkout(ref string) (string, func(), error) {
+	return git.SetupWorkTree(g.root, ref)
+}
+
+func (g *gitVCS) CachedCheckout(ref string, keep int) (string, func(), error) {
+	return git.CachedWorkTree(g.root, ref, keep)
+}
+
+func (g *gitVCS) SparseCheckout(ref string, dirs []string) (string, func(dirs ...string) error, func(), error) {
+	wt, cleanup, err := git.SetupSparseWorkTree(g.root, ref, dirs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return wt.Dir, wt.Add, cleanup, nil
+}
@@ -0,0 +1,196 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// jjVCS uses jj workspaces to check out the target revision
+type jjVCS struct {
+	root string
+}
+
+func newJJ() (*jjVCS, error) {
+	if _, err := exec.LookPath("jj"); err != nil {
+		return nil, fmt.Errorf("jj not found in PATH: %w", err)
+	}
+
+	output, err := exec.Command("jj", "root").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find jj repo root: %w. Make sure you are running this inside a jj repository. Output: %s", err, string(output))
+	}
+
+	return &jjVCS{root: strings.TrimSpace(string(output))}, nil
+}
+
+func (j *jjVCS) Name() string {
+	return "jj"
+}
+
+func (j *jjVCS) Root() string {
+	return j.root
+}
+
+// ResolveRef verifies the revision resolves to exactly one commit.
+// jj has no concept of upstream tracking for a revset, so unlike git
+// the ref is returned unchanged.
+func (j *jjVCS) ResolveRef(ref string) (string, error) {
+	cmd := exec.Command("jj", "log", "--no-graph", "--ignore-working-copy", "-r", ref, "-T", `commit_id ++ "\n"`)
+	cmd.Dir = j.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("invalid or non-existent ref %q: %s", ref, strings.TrimSpace(stderr.String()))
+	}
+
+	// A revset like 'main::' or 'trunk() | @' is valid but has no single
+	// tree to render
+	commits := strings.Fields(string(output))
+	if len(commits) != 1 {
+		return "", fmt.Errorf("ref %q resolves to %d commits, it must resolve to exactly one", ref, len(commits))
+	}
+
+	slog.Debug("Resolved jj revision", "ref", ref, "commit", commits[0])
+
+	return ref, nil
+}
+
+func (j *jjVCS) Commit(ref string) (string, error) {
+	cmd := exec.Command("jj", "log", "--no-graph", "--ignore-working-copy", "-r", ref, "-T", "commit_id")
+	cmd.Dir = j.root
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve commit for %q: %s", ref, strings.TrimSpace(string(output)))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// MergeBase uses the newest common ancestor, jj revsets have no
+// merge-base function that works on all supported versions
+func (j *jjVCS) MergeBase(ref, other string) (string, error) {
+	if other == "" {
+		other = "@-"
+	}
+
+	revset := fmt.Sprintf("heads(::(%s) & ::(%s))", ref, other)
+	cmd := exec.Command("jj", "log", "--no-graph", "--ignore-working-copy", "--limit", "1", "-r", revset, "-T", "commit_id")
+	cmd.Dir = j.root
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge-base of %q and %q: %s", ref, other, strings.TrimSpace(string(output)))
+	}
+
+	commit := strings.TrimSpace(string(output))
+	if commit == "" {
+		return "", fmt.Errorf("%q and %q have no common ancestor", ref, other)
+	}
+	return commit, nil
+}
+
+// ReadFile prints the file at ref with 'jj file show'
+func (j *jjVCS) ReadFile(ref, path string) ([]byte, error) {
+	cmd := exec.Command("jj", "file", "show", "--ignore-working-copy", "-r", ref, "root:"+filepath.ToSlash(path))
+	cmd.Dir = j.root
+
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "No such path") {
+			return nil, fmt.Errorf("%s in %q: %w", path, ref, fs.ErrNotExist)
+		}
+		return nil, fmt.Errorf("failed to read %s in %q: %s", path, ref, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// Remotes lists the git remotes of the backing git repository
+func (j *jjVCS) Remotes() ([]string, error) {
+	cmd := exec.Command("jj", "git", "remote", "list", "--ignore-working-copy")
+	cmd.Dir = j.root
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jj git remotes: %s", strings.TrimSpace(string(output)))
+	}
+
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, strings.TrimSpace(url))
+		}
+	}
+	return urls, nil
+}
+
+// Refs returns the local bookmarks and tags, one per line from the
+// list templates
+func (j *jjVCS) Refs() ([]string, error) {
+	var refs []string
+	for _, kind := range []string{"bookmark", "tag"} {
+		cmd := exec.Command("jj", kind, "list", "--ignore-working-copy", "-T", `name ++ "\n"`)
+		cmd.Dir = j.root
+
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jj %ss: %s", kind, strings.TrimSpace(string(output)))
+		}
+		refs = append(refs, strings.Fields(string(output))...)
+	}
+	return refs, nil
+}
+
+// Checkout creates a new jj workspace at ref in a temporary directory.
+// The workspace is forgotten and the directory removed on cleanup.
+func (j *jjVCS) Checkout(ref string) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "diff-ref-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+
+	// jj expects the workspace directory to not exist yet
+	workspaceDir := filepath.Join(tempDir, "workspace")
+	workspaceName := "rdv-" + filepath.Base(tempDir)
+
+	cleanup := func() {
+		forgetCmd := exec.Command("jj", "workspace", "forget", workspaceName)
+		forgetCmd.Dir = j.root
+		if output, err := forgetCmd.CombinedOutput(); err != nil {
+			slog.Warn("Failed to run 'jj workspace forget', manual cleanup may be required", "error", err, "output", string(output))
+		}
+		if err := os.RemoveAll(tempDir); err != nil {
+			fmt.Printf("error removing temporary directory %s: %v\n", tempDir, err)
+		}
+	}
+
+	addCmd := exec.Command("jj", "workspace", "add", "--name", workspaceName, "-r", ref, workspaceDir)
+	addCmd.Dir = j.root
+	if output, err := addCmd.CombinedOutput(); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return "", nil, fmt.Errorf("failed to create workspace for '%s': %v\nOutput: %s", ref, err, string(output))
+	}
+
+	return workspaceDir, cleanup, nil
+}
+
+// CachedCheckout is not supported for jj, workspaces are cheap to create
+// compared to git worktrees of large repositories
+func (j *jjVCS) CachedCheckout(ref string, keep int) (string, func(), error) {
+	slog.Debug("Worktree caching is not supported for jj, creating a new workspace")
+	return j.Checkout(ref)
+}
+
+func (j *jjVCS) SparseCheckout(ref string, dirs []string) (string, func(dirs ...string) error, func(), error) {
+	return "", nil, nil, fmt.Errorf("sparse checkouts are not supported for jj")
+}
@@ -0,0 +1,86 @@
+// Package vcs provides a common interface over the version control
+// systems rdv can compare against. Git is the default backend, jj
+// (Jujutsu) repositories are supported through the jj CLI.
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// VCS is the set of operations rdv needs from a version control system
+type VCS interface {
+	// Name returns the backend name, e.g. 'git'
+	Name() string
+	// Root returns the top-level directory of the repository
+	Root() string
+	// ResolveRef resolves and verifies a user supplied ref
+	ResolveRef(ref string) (string, error)
+	// Commit returns the commit id ref currently points at
+	Commit(ref string) (string, error)
+	// MergeBase returns the commit id of the common ancestor of ref and
+	// other, the working copy's parent is used if other is empty
+	MergeBase(ref, other string) (string, error)
+	// ReadFile returns the content of the file at path, relative to the
+	// repository root, in ref. Missing files return an error wrapping
+	// fs.ErrNotExist.
+	ReadFile(ref, path string) ([]byte, error)
+	// Remotes returns the URLs of the repository's remotes
+	Remotes() ([]string, error)
+	// Refs returns the branch (or bookmark) and tag names, for completion
+	Refs() ([]string, error)
+	// Checkout materializes ref in a temporary directory and returns
+	// the directory and a cleanup function
+	Checkout(ref string) (string, func(), error)
+	// SparseCheckout is like Checkout, but only checks out dirs. The
+	// returned add function checks out more directories.
+	SparseCheckout(ref string, dirs []string) (string, func(dirs ...string) error, func(), error)
+	// CachedCheckout is like Checkout, but reuses checkouts of the same
+	// commit across runs and keeps up to keep of them
+	CachedCheckout(ref string, keep int) (string, func(), error)
+}
+
+// New returns the VCS backend with the given name for the current
+// working directory. 'auto' picks jj when a .jj directory is found
+// at or above the working directory, and git otherwise.
+func New(name string) (VCS, error) {
+	if name == "auto" {
+		name = detect()
+	}
+
+	switch name {
+	case "git":
+		return newGit()
+	case "jj":
+		return newJJ()
+	default:
+		return nil, fmt.Errorf("unsupported vcs %q, must be one of: auto, git, jj", name)
+	}
+}
+
+// detect walks up from the working directory looking for a .jj directory.
+// Colocated jj repositories also contain .git, jj takes precedence since
+// the git HEAD is usually detached in those repositories.
+func detect() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "git"
+	}
+
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".jj")); err == nil && info.IsDir() {
+			if _, err := exec.LookPath("jj"); err == nil {
+				return "jj"
+			}
+			return "git"
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "git"
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,73 @@
+package vcs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name     string
+		backend  string
+		wantName string
+		wantErr  bool
+	}{
+		{
+			name:     "Git backend",
+			backend:  "git",
+			wantName: "git",
+			wantErr:  false,
+		},
+		{
+			name:     "Auto detects git",
+			backend:  "auto",
+			wantName: "git",
+			wantErr:  false,
+		},
+		{
+			name:    "Unsupported backend",
+			backend: "svn",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, err := New(tc.backend)
+
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("New(%q) error = %v, wantErr %v", tc.backend, err, tc.wantErr)
+			}
+
+			if !tc.wantErr && repo.Name() != tc.wantName {
+				t.Errorf("New(%q).Name() = %q, want %q", tc.backend, repo.Name(), tc.wantName)
+			}
+		})
+	}
+}
+
+func TestGitCheckout(t *testing.T) {
+	repo, err := New("git")
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	ref, err := repo.ResolveRef("HEAD")
+	if err != nil {
+		t.Fatalf("ResolveRef() failed: %v", err)
+	}
+
+	dir, cleanup, err := repo.Checkout(ref)
+	if err != nil {
+		t.Fatalf("Checkout() failed: %v", err)
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Checkout() directory does not exist: %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Cleanup function failed: directory still exists: %s", dir)
+	}
+}
@@ -0,0 +1,384 @@
+// Package renderdiff exposes the core operations of rdv as a Go library,
+// so tools and bots can render, normalize, diff and validate Kubernetes
+// manifests without shelling out to the CLI and parsing its output.
+//
+// A typical caller renders both sides of a change, normalizes them and
+// diffs the result:
+//
+//	from, err := renderdiff.Render(ctx, "/checkout/main/charts/app", renderdiff.RenderOptions{})
+//	to, err := renderdiff.Render(ctx, "charts/app", renderdiff.RenderOptions{})
+//	from, err = renderdiff.Normalize(from, renderdiff.NormalizeOptions{})
+//	to, err = renderdiff.Normalize(to, renderdiff.NormalizeOptions{})
+//	unified, err := renderdiff.Diff(ctx, from, to, renderdiff.DiffOptions{})
+//
+// Renders are multi-document YAML strings, like the output of
+// 'helm template' or 'kustomize build'.
+package renderdiff
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dlactin/rdv/internal/diff"
+	"github.com/dlactin/rdv/internal/helm"
+	"github.com/dlactin/rdv/internal/kustomize"
+	"github.com/dlactin/rdv/internal/manifest"
+	"github.com/dlactin/rdv/internal/retry"
+	"github.com/dlactin/rdv/internal/validate"
+)
+
+// Renderers accepted by RenderOptions.Renderer
+const (
+	RendererAuto          = diff.RendererAuto
+	RendererHelm          = diff.RendererHelm
+	RendererKustomize     = diff.RendererKustomize
+	RendererKustomizeHelm = diff.RendererKustomizeHelm
+	RendererTimoni        = diff.RendererTimoni
+)
+
+// KustomizeOptions configures kustomize builds
+type KustomizeOptions struct {
+	// EnableHelm turns on the helmCharts inflator
+	EnableHelm bool
+	// HelmCommand is the helm binary used by the inflator, looked up in
+	// PATH if empty
+	HelmCommand string
+	// LoadRestrictor is 'rootOnly' (default) or 'none', which allows
+	// kustomizations to reference files outside their directory
+	LoadRestrictor string
+	// EnableAlphaPlugins allows generator and transformer plugins,
+	// including containerized KRM functions
+	EnableAlphaPlugins bool
+	// EnableExec allows exec KRM functions, requires EnableAlphaPlugins
+	EnableExec bool
+	// FnNetwork gives containerized functions network access
+	FnNetwork bool
+	// FnMounts are storage mounts passed to containerized functions
+	FnMounts []string
+	// FnEnv are environment variables passed to functions
+	FnEnv []string
+	// FnAllow restricts functions to matching images and exec paths.
+	// If empty, any function is allowed once plugins are enabled.
+	FnAllow []string
+	// FetchRetries is how many times a build failing to fetch a remote
+	// base is retried
+	FetchRetries int
+	// FetchTimeout limits fetching remote bases, retries included.
+	// Zero disables the limit.
+	FetchTimeout time.Duration
+}
+
+// options converts KustomizeOptions to the options of the kustomize build
+func (o KustomizeOptions) options() kustomize.Options {
+	return kustomize.Options{
+		EnableHelm:         o.EnableHelm,
+		HelmCommand:        o.HelmCommand,
+		LoadRestrictor:     o.LoadRestrictor,
+		EnableAlphaPlugins: o.EnableAlphaPlugins,
+		EnableExec:         o.EnableExec,
+		FnNetwork:          o.FnNetwork,
+		FnMounts:           o.FnMounts,
+		FnEnv:              o.FnEnv,
+		FnAllow:            o.FnAllow,
+		Fetch:              retry.Policy{Retries: o.FetchRetries, Timeout: o.FetchTimeout},
+	}
+}
+
+// ValidationResult is the validation outcome of a single resource
+type ValidationResult struct {
+	// Document is the 1-based position of the resource in the render
+	Document  int    `json:"document"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Source is the template path from Helm's '# Source:' comment, if present
+	Source string `json:"source,omitempty"`
+	// Status is one of the Status constants
+	Status string `json:"status"`
+	// Error explains an invalid or failed resource
+	Error string `json:"error,omitempty"`
+}
+
+// Validation statuses of a ValidationResult
+const (
+	StatusValid   = validate.StatusValid
+	StatusInvalid = validate.StatusInvalid
+	StatusError   = validate.StatusError
+	StatusSkipped = validate.StatusSkipped
+)
+
+// Change is a resource that was added, removed or modified between two
+// renders, with the number of lines changed in it
+type Change struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	// Status is one of the Change constants
+	Status string
+	// Added and Removed are the number of lines added and removed
+	Added   int
+	Removed int
+}
+
+// Statuses of a Change
+const (
+	ChangeAdded    = manifest.StatusAdded
+	ChangeRemoved  = manifest.StatusRemoved
+	ChangeModified = manifest.StatusModified
+)
+
+// RenderOptions configures Render
+type RenderOptions struct {
+	// Renderer is one of the Renderer constants, defaults to RendererAuto
+	Renderer string
+	// ReleaseName is the Helm release name, defaults to 'release'
+	ReleaseName string
+	// Values are additional Helm values files, merged in order. Relative
+	// paths are resolved against the rendered path.
+	Values []string
+	// ShowOnly limits a Helm render to templates matching these globs
+	ShowOnly []string
+	// Kustomize configures kustomize builds
+	Kustomize KustomizeOptions
+	// UpdateDependencies runs 'helm dependency update' before building
+	// chart dependencies
+	UpdateDependencies bool
+	// Lint runs 'helm lint' on the chart before rendering
+	Lint bool
+	// ResolveRefs resolves vals references like 'ref+vault://' in the
+	// values files with the vals CLI. Resolved values are masked in the
+	// render.
+	ResolveRefs bool
+}
+
+// Render renders the Helm chart, kustomization or Timoni module at path. Renderer
+// plugins are stopped when ctx is cancelled, Helm and kustomize renders
+// can't be interrupted and finish in the background while Render returns
+// the context's error.
+func Render(ctx context.Context, path string, opts RenderOptions) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve absolute path for %s: %w", path, err)
+	}
+
+	values := make([]string, len(opts.Values))
+	for i, v := range opts.Values {
+		if !filepath.IsAbs(strings.TrimPrefix(v, helm.SecretsScheme)) {
+			v = helm.ResolveValuesPath(path, v)
+		}
+		values[i] = v
+	}
+
+	return withContext(ctx, func() (string, error) {
+		return diff.RenderManifestsContext(ctx, path, diff.RenderOptions{
+			Renderer:    opts.Renderer,
+			ReleaseName: opts.ReleaseName,
+			Values:      values,
+			ShowOnly:    opts.ShowOnly,
+			Kustomize:   opts.Kustomize.options(),
+			Update:      opts.UpdateDependencies,
+			Lint:        opts.Lint,
+			ResolveRefs: opts.ResolveRefs,
+		})
+	})
+}
+
+// NormalizeOptions configures Normalize
+type NormalizeOptions struct {
+	// KeepNoise keeps the helm.sh/chart and app.kubernetes.io/version
+	// labels and checksum/* annotations, which change on every chart bump
+	KeepNoise bool
+	// IgnoreComments removes the YAML comments, IgnoreSourceComments
+	// removes Helm's '# Source:' comments as well
+	IgnoreComments       bool
+	IgnoreSourceComments bool
+	// Include keeps only the resources matching one of these selectors,
+	// e.g. 'kind=Deployment,name=api*'
+	Include []string
+	// Exclude drops the resources matching one of these selectors
+	Exclude []string
+}
+
+// Normalize removes the noise and the resources not selected by opts from
+// a render, the same way rdv does before diffing
+func Normalize(render string, opts NormalizeOptions) (string, error) {
+	include, err := parseSelectors(opts.Include)
+	if err != nil {
+		return "", err
+	}
+	exclude, err := parseSelectors(opts.Exclude)
+	if err != nil {
+		return "", err
+	}
+
+	if !opts.KeepNoise {
+		render = manifest.RemoveNoise(render, manifest.NoiseLabels, manifest.NoiseAnnotations)
+	}
+	if opts.IgnoreComments || opts.IgnoreSourceComments {
+		render = manifest.StripComments(render, opts.IgnoreSourceComments)
+	}
+	return manifest.Filter(render, include, exclude)
+}
+
+func parseSelectors(selectors []string) ([]manifest.Selector, error) {
+	parsed := make([]manifest.Selector, 0, len(selectors))
+	for _, s := range selectors {
+		selector, err := manifest.ParseSelector(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, selector)
+	}
+	return parsed, nil
+}
+
+// DiffOptions configures Diff
+type DiffOptions struct {
+	// FromName and ToName label the sides in the diff headers,
+	// default to 'from' and 'to'
+	FromName string
+	ToName   string
+	// Context is the number of unchanged lines around each change.
+	// Zero uses the default of 3, a negative value shows the whole render.
+	Context int
+	// IgnoreWhitespace ignores changes that only add or remove whitespace
+	IgnoreWhitespace bool
+}
+
+// Diff returns the unified diff between two renders, or an empty string
+// when they are the same
+func Diff(ctx context.Context, from, to string, opts DiffOptions) (string, error) {
+	if opts.FromName == "" {
+		opts.FromName = "from"
+	}
+	if opts.ToName == "" {
+		opts.ToName = "to"
+	}
+	if opts.Context == 0 {
+		opts.Context = diff.DefaultContext
+	}
+
+	if opts.IgnoreWhitespace {
+		to = diff.IgnoreWhitespace(from, to)
+	}
+
+	return withContext(ctx, func() (string, error) {
+		return diff.CreateDiffWithContext(from, to, opts.FromName, opts.ToName, opts.Context), nil
+	})
+}
+
+// Changes returns the resources added, removed and modified between two
+// renders, matched by kind, namespace and name
+func Changes(from, to string) ([]Change, error) {
+	fromResources, err := manifest.Parse(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse from render: %w", err)
+	}
+	toResources, err := manifest.Parse(to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse to render: %w", err)
+	}
+
+	stats := manifest.Stat(fromResources, toResources)
+	changes := make([]Change, len(stats))
+	for i, stat := range stats {
+		changes[i] = Change{
+			APIVersion: stat.Resource.APIVersion,
+			Kind:       stat.Resource.Kind,
+			Namespace:  stat.Resource.Namespace,
+			Name:       stat.Resource.Name,
+			Status:     stat.Status,
+			Added:      stat.Added,
+			Removed:    stat.Removed,
+		}
+	}
+	return changes, nil
+}
+
+// Validator validates renders against the Kubernetes JSON schemas with
+// kubeconform. Downloaded schemas are cached, so a Validator should be
+// reused. It is safe for concurrent use.
+type Validator struct {
+	v *validate.Validator
+}
+
+// ValidatorOptions configures NewValidator
+type ValidatorOptions struct {
+	// SchemaLocations are kubeconform schema location templates like
+	// 'https://example.com/{{ .ResourceKind }}.json', 'default' adds the
+	// upstream Kubernetes schemas. The upstream schemas are used if empty.
+	SchemaLocations []string
+	// KubernetesVersion selects the version of the upstream schemas, like
+	// '1.29.0'. Defaults to the latest.
+	KubernetesVersion string
+}
+
+// NewValidator returns a Validator
+func NewValidator(opts ValidatorOptions) (*Validator, error) {
+	v, err := validate.New(validate.Options{
+		SchemaLocations:   opts.SchemaLocations,
+		KubernetesVersion: opts.KubernetesVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{v: v}, nil
+}
+
+// Validate returns the validation result of every resource in render.
+// Custom resources are validated against the CustomResourceDefinitions
+// in the same render.
+func (v *Validator) Validate(ctx context.Context, render string) ([]ValidationResult, error) {
+	return withContext(ctx, func() ([]ValidationResult, error) {
+		results, err := v.v.Check(render)
+		if err != nil {
+			return nil, err
+		}
+
+		converted := make([]ValidationResult, len(results))
+		for i, r := range results {
+			converted[i] = ValidationResult(r)
+		}
+		return converted, nil
+	})
+}
+
+// ValidationError returns an error listing every invalid resource in
+// results, or nil if all of them are valid
+func ValidationError(results []ValidationResult) error {
+	converted := make([]validate.Result, len(results))
+	for i, r := range results {
+		converted[i] = validate.Result(r)
+	}
+	return validate.ResultsError(converted)
+}
+
+// withContext runs fn and returns early with the context's error when
+// ctx is cancelled first
+func withContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		out T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := fn()
+		done <- result{out, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case r := <-done:
+		return r.out, r.err
+	}
+}
@@ -0,0 +1,75 @@
+package renderdiff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderNormalizeDiff(t *testing.T) {
+	ctx := context.Background()
+
+	render, err := Render(ctx, "../../examples/kustomize/helloworld", RenderOptions{})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if !strings.Contains(render, "name: the-map") {
+		t.Fatalf("Render() output is missing the ConfigMap:\n%s", render)
+	}
+
+	from, err := Normalize(render, NormalizeOptions{Include: []string{"kind=ConfigMap"}})
+	if err != nil {
+		t.Fatalf("Normalize() failed: %v", err)
+	}
+	if strings.Contains(from, "kind: Deployment") {
+		t.Errorf("Normalize() kept a resource not selected by Include:\n%s", from)
+	}
+
+	to := strings.Replace(from, "name: the-map", "name: the-map2", 1)
+	unified, err := Diff(ctx, from, to, DiffOptions{FromName: "main", ToName: "local"})
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if !strings.Contains(unified, "--- main") || !strings.Contains(unified, "+  name: the-map2") {
+		t.Errorf("Diff() output is missing the change:\n%s", unified)
+	}
+
+	changes, err := Changes(from, to)
+	if err != nil {
+		t.Fatalf("Changes() failed: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("Changes() returned %d changes, want an added and a removed ConfigMap: %+v", len(changes), changes)
+	}
+	for _, c := range changes {
+		if c.Kind != "ConfigMap" || (c.Status != ChangeAdded && c.Status != ChangeRemoved) {
+			t.Errorf("Changes() returned an unexpected change: %+v", c)
+		}
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	results := []ValidationResult{
+		{Document: 1, Kind: "ConfigMap", Name: "valid", Status: StatusValid},
+		{Document: 2, Kind: "Deployment", Name: "api", Status: StatusInvalid, Error: "missing field 'selector'"},
+	}
+
+	err := ValidationError(results)
+	if err == nil || !strings.Contains(err.Error(), "missing field 'selector'") {
+		t.Errorf("ValidationError() = %v, want the error of the invalid Deployment", err)
+	}
+	if err := ValidationError(results[:1]); err != nil {
+		t.Errorf("ValidationError() = %v for valid results, want nil", err)
+	}
+}
+
+func TestRenderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Render(ctx, "../../examples/kustomize/helloworld", RenderOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Render() with a cancelled context returned %v, want context.Canceled", err)
+	}
+}